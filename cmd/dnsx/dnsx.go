@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 
 	"github.com/projectdiscovery/dnsx/internal/runner"
 	"github.com/projectdiscovery/gologger"
@@ -13,29 +16,80 @@ func main() {
 	options := runner.ParseOptions()
 
 	dnsxRunner, err := runner.New(options)
+	var noResolvers *runner.NoResolversError
+	if errors.As(err, &noResolvers) {
+		// written regardless of the log level for scripts running in silent mode
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(runner.ExitNoResolvers)
+	}
 	if err != nil {
 		gologger.Fatal().Msgf("Could not create runner: %s\n", err)
 	}
 
-	// Setup graceful exits
+	// Setup graceful exits, CTRL+C closes the runner to stop the run and main writes the
+	// resume file and exits once the run returned
+	var (
+		exitMutex   sync.Mutex
+		finished    bool
+		interrupted bool
+	)
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		for range c {
 			gologger.Info().Msgf("CTRL+C pressed: Exiting\n")
-			dnsxRunner.Close()
-			if options.ShouldSaveResume() {
-				gologger.Info().Msgf("Creating resume file: %s\n", runner.DefaultResumeFile)
-				err := dnsxRunner.SaveResumeConfig()
-				if err != nil {
-					gologger.Error().Msgf("Couldn't create resume file: %s\n", err)
-				}
+			exitMutex.Lock()
+			// a run already completed isn't reported as interrupted
+			if !finished {
+				interrupted = true
 			}
-			os.Exit(1)
+			exitMutex.Unlock()
+			dnsxRunner.Close()
 		}
 	}()
 
-	// nolint:errcheck
-	dnsxRunner.Run()
+	err = dnsxRunner.Run()
+	exitMutex.Lock()
+	finished = true
+	wasInterrupted := interrupted
+	exitMutex.Unlock()
+	if wasInterrupted {
+		interrupt(dnsxRunner, options, 1)
+	}
+	if err != nil {
+		if errors.Is(err, runner.ErrPrecheckAborted) {
+			gologger.Info().Msgf("Scan aborted after precheck\n")
+			dnsxRunner.Close()
+			return
+		}
+		if errors.Is(err, runner.ErrPanicsRecovered) {
+			dnsxRunner.Close()
+			os.Exit(runner.ExitPanicsRecovered)
+		}
+		if errors.Is(err, runner.ErrMaxRuntime) {
+			gologger.Info().Msgf("Max runtime reached: Exiting\n")
+			// the panics recovered before the deadline keep their exit code
+			code := 1
+			if dnsxRunner.PanicsRecovered() > 0 {
+				code = runner.ExitPanicsRecovered
+			}
+			interrupt(dnsxRunner, options, code)
+		}
+		dnsxRunner.Close()
+		gologger.Fatal().Msgf("Could not run dnsx: %s\n", err)
+	}
+	dnsxRunner.Close()
+}
+
+// interrupt closes the runner and writes the resume file before exiting with the code
+func interrupt(dnsxRunner *runner.Runner, options *runner.Options, code int) {
 	dnsxRunner.Close()
+	if options.ShouldSaveResume() {
+		gologger.Info().Msgf("Creating resume file: %s\n", runner.DefaultResumeFile)
+		err := dnsxRunner.SaveResumeConfig()
+		if err != nil {
+			gologger.Error().Msgf("Couldn't create resume file: %s\n", err)
+		}
+	}
+	os.Exit(code)
 }
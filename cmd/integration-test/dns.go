@@ -1,16 +1,35 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/miekg/dns"
 	"github.com/projectdiscovery/dnsx/internal/testutils"
 )
 
 var dnsTestcases = map[string]testutils.TestCase{
-	"DNS A Request":    &dnsARequest{question: "projectdiscovery.io", expectedOutput: "projectdiscovery.io"},
-	"DNS AAAA Request": &dnsAAAARequest{question: "projectdiscovery.io", expectedOutput: "projectdiscovery.io"},
+	"DNS A Request":       &dnsARequest{question: "projectdiscovery.io", expectedOutput: "projectdiscovery.io"},
+	"DNS AAAA Request":    &dnsAAAARequest{question: "projectdiscovery.io", expectedOutput: "projectdiscovery.io"},
+	"DNS Large TXT":       &dnsLargeTXTRequest{question: "projectdiscovery.io", records: 230, length: 250},
+	"DNS Wildcard Stats":  &dnsWildcardStatsRequest{domain: "projectdiscovery.io", wildcardHosts: 10, expectedOutput: "www.projectdiscovery.io"},
+	"DNS Name Violations": &dnsNameViolationsRequest{question: "projectdiscovery.io", expectedViolations: []string{"embedded-dot", "embedded-null", "illegal-character"}},
+	"DNS Long Label":      &dnsRawNameRequest{question: "projectdiscovery.io", name: rawName(strings.Repeat("a", 64))},
+	"DNS Long Name":       &dnsRawNameRequest{question: "projectdiscovery.io", name: rawName(strings.Repeat("a", 60), strings.Repeat("b", 60), strings.Repeat("c", 60), strings.Repeat("d", 60), strings.Repeat("e", 60))},
+	"DNS First Only":      &dnsFirstOnlyRequest{question: "projectdiscovery.io", expectedOutput: []string{"1.2.3.4", "2001:db8::1"}},
+	"DNS Prefer IPv4":     &dnsFirstOnlyRequest{question: "projectdiscovery.io", prefer: "-prefer-ipv4", expectedOutput: []string{"1.2.3.4"}},
+	"DNS Prefer IPv6":     &dnsFirstOnlyRequest{question: "projectdiscovery.io", prefer: "-prefer-ipv6", expectedOutput: []string{"2001:db8::1"}},
+	"DNS PTR CSV":         &dnsPTRCSVRequest{cidr: "192.0.2.0/28", expectedOutput: []string{"192.0.2.2,two.projectdiscovery.io", "192.0.2.9,nine.projectdiscovery.io", "192.0.2.10,ten.projectdiscovery.io"}},
+	"DNS Serve":           &dnsServeRequest{},
+	"DNS Summarize CIDRs": &dnsSummarizeCIDRsRequest{addresses: map[string]string{"a": "192.0.2.0", "b": "192.0.2.1", "c": "192.0.2.2", "d": "192.0.2.3", "e": "198.51.100.7"}, expectedOutput: []string{"192.0.2.0/30", "198.51.100.7/32"}},
+	"DNS Trace Start":     &dnsTraceStartRequest{question: "projectdiscovery.io", server: "127.0.0.1:15000", expectedOutput: "1.2.3.4"},
+	"DNS PTR CSV Labels":  &dnsPTRCSVRequest{cidr: "192.0.2.0/28", labels: []string{"program=acme", "note=a,b"}, expectedOutput: []string{`192.0.2.2,two.projectdiscovery.io,acme,"a,b"`}},
 }
 
 type dnsARequest struct {
@@ -89,10 +108,511 @@ func (h *dnsAAAARequest) Execute() error {
 	return nil
 }
 
+// dnsLargeTXTRequest serves a TXT rrset of about 60KB, truncated over udp, which must be
+// received in full through the tcp fallback
+type dnsLargeTXTRequest struct {
+	question string
+	records  int
+	length   int
+}
+
+func (h *dnsLargeTXTRequest) Execute() error {
+	var values []string
+	for i := 0; i < h.records; i++ {
+		value := fmt.Sprintf("%03d", i)
+		values = append(values, value+strings.Repeat("x", h.length-len(value)))
+	}
+	handler := &dnshandler{
+		answers: []answer{
+			{question: h.question, questionType: dns.TypeTXT, values: values},
+		},
+	}
+	for _, network := range []string{"udp", "tcp"} {
+		srv := &dns.Server{
+			Handler: handler,
+			Addr:    "127.0.0.1:15000",
+			Net:     network,
+		}
+		go srv.ListenAndServe() //nolint
+		defer srv.Shutdown()    //nolint
+	}
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-txt", "-json")
+
+	results, err := testutils.RunDnsxAndGetResults(h.question, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 {
+		return errIncorrectResultsCount(results)
+	}
+	var result struct {
+		TXT            []string `json:"txt"`
+		TruncatedFinal bool     `json:"truncated_final"`
+	}
+	if err := json.Unmarshal([]byte(results[0]), &result); err != nil {
+		return err
+	}
+	if result.TruncatedFinal || len(result.TXT) != len(values) {
+		return errIncorrectResult(fmt.Sprintf("%d txt records", len(values)), fmt.Sprintf("%d txt records (truncated_final=%v)", len(result.TXT), result.TruncatedFinal))
+	}
+	return nil
+}
+
+// dnsWildcardStatsRequest resolves hosts of a wildcard domain with the statistics enabled,
+// the wildcard hosts must be removed and the host having its own record kept
+type dnsWildcardStatsRequest struct {
+	domain         string
+	wildcardHosts  int
+	expectedOutput string
+}
+
+func (h *dnsWildcardStatsRequest) Execute() error {
+	handler := &dnshandler{
+		answers: []answer{
+			{question: h.expectedOutput, questionType: dns.TypeA, values: []string{"5.6.7.8"}},
+			{question: "*." + h.domain, questionType: dns.TypeA, values: []string{"1.2.3.4"}},
+		},
+	}
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    "127.0.0.1:15000",
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	list, err := ioutil.TempFile("", "dnsx-wildcard-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+	hosts := []string{h.expectedOutput}
+	for i := 0; i < h.wildcardHosts; i++ {
+		hosts = append(hosts, fmt.Sprintf("host%d.%s", i, h.domain))
+	}
+	if _, err := list.WriteString(strings.Join(hosts, "\n")); err != nil {
+		return err
+	}
+	list.Close()
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-l", list.Name(), "-wd", h.domain, "-stats")
+
+	results, err := testutils.RunDnsxAndGetResults("", debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 {
+		return errIncorrectResultsCount(results)
+	}
+	if !strings.EqualFold(results[0], h.expectedOutput) {
+		return errIncorrectResult(results[0], h.expectedOutput)
+	}
+	return nil
+}
+
+// dnsNameViolationsRequest serves a CNAME chain whose names embed dots, nulls and spaces in
+// their labels, each class must be reported in the name violations of the result
+type dnsNameViolationsRequest struct {
+	question           string
+	expectedViolations []string
+}
+
+func (h *dnsNameViolationsRequest) Execute() error {
+	handler := &dnshandler{
+		answers: []answer{
+			{question: h.question, questionType: dns.TypeA, records: []string{
+				h.question + `. 60 IN CNAME dot\.label.` + h.question + `.`,
+				`dot\.label.` + h.question + `. 60 IN CNAME null\000label.` + h.question + `.`,
+				`null\000label.` + h.question + `. 60 IN CNAME space\032label.` + h.question + `.`,
+				`space\032label.` + h.question + `. 60 IN A 1.2.3.4`,
+			}},
+		},
+	}
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    "127.0.0.1:15000",
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-validate-names", "-json")
+
+	results, err := testutils.RunDnsxAndGetResults(h.question, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 {
+		return errIncorrectResultsCount(results)
+	}
+	var result struct {
+		NameViolations []struct {
+			Violation string `json:"violation"`
+		} `json:"name_violations"`
+	}
+	if err := json.Unmarshal([]byte(results[0]), &result); err != nil {
+		return err
+	}
+	found := make(map[string]struct{})
+	for _, violation := range result.NameViolations {
+		found[violation.Violation] = struct{}{}
+	}
+	for _, expected := range h.expectedViolations {
+		if _, ok := found[expected]; !ok {
+			return errIncorrectResult(expected, results[0])
+		}
+	}
+	return nil
+}
+
+// dnsRawNameRequest serves a CNAME whose target can't be encoded by regular packers (labels
+// over 63 octets, names over 255 octets), the response must be rejected instead of being
+// written to the output
+type dnsRawNameRequest struct {
+	question string
+	name     []byte
+}
+
+func (h *dnsRawNameRequest) Execute() error {
+	srv := &dns.Server{
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			msg := new(dns.Msg)
+			msg.SetReply(r)
+			raw, err := msg.Pack()
+			if err != nil {
+				return
+			}
+			// single answer: owner pointing to the question, CNAME IN, ttl 60, raw target
+			raw[7] = 1
+			raw = append(raw, 0xC0, 12, 0, byte(dns.TypeCNAME), 0, 1, 0, 0, 0, 60, byte(len(h.name)>>8), byte(len(h.name)))
+			raw = append(raw, h.name...)
+			w.Write(raw) //nolint
+		}),
+		Addr: "127.0.0.1:15000",
+		Net:  "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-cname", "-validate-names", "-retry", "1")
+
+	results, err := testutils.RunDnsxAndGetResults(h.question, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != 0 {
+		return errIncorrectResultsCount(results)
+	}
+	return nil
+}
+
+// rawName returns the wire format of the labels without any length validation
+func rawName(labels ...string) []byte {
+	var name []byte
+	for _, label := range labels {
+		name = append(name, byte(len(label)))
+		name = append(name, label...)
+	}
+	return append(name, 0)
+}
+
+// dnsFirstOnlyRequest serves several A and AAAA records, only the first address of each
+// family, or of the preferred one, must be displayed
+type dnsFirstOnlyRequest struct {
+	question       string
+	prefer         string
+	expectedOutput []string
+}
+
+func (h *dnsFirstOnlyRequest) Execute() error {
+	handler := &dnshandler{
+		answers: []answer{
+			{question: h.question, questionType: dns.TypeA, values: []string{"1.2.3.4", "1.2.3.5", "1.2.3.6"}},
+			{question: h.question, questionType: dns.TypeAAAA, values: []string{"2001:db8::1", "2001:db8::2"}},
+		},
+	}
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    "127.0.0.1:15000",
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-a", "-aaaa", "-resp-only", "-first-only")
+	if h.prefer != "" {
+		extra = append(extra, h.prefer)
+	}
+
+	results, err := testutils.RunDnsxAndGetResults(h.question, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != len(h.expectedOutput) {
+		return errIncorrectResultsCount(results)
+	}
+	for i, expected := range h.expectedOutput {
+		if !strings.EqualFold(results[i], expected) {
+			return errIncorrectResult(results[i], expected)
+		}
+	}
+	return nil
+}
+
+// dnsPTRCSVRequest serves the PTR records of some addresses of the cidr, the ip,ptr lines
+// must be sorted numerically by ip
+type dnsPTRCSVRequest struct {
+	cidr string
+	// labels are appended as csv columns, the expected lines hold them
+	labels         []string
+	expectedOutput []string
+}
+
+func (h *dnsPTRCSVRequest) Execute() error {
+	handler := &dnshandler{}
+	for _, expected := range h.expectedOutput {
+		parts := strings.SplitN(expected, ",", 3)
+		reverse, err := dns.ReverseAddr(parts[0])
+		if err != nil {
+			return err
+		}
+		handler.answers = append(handler.answers, answer{
+			question:     strings.TrimSuffix(reverse, "."),
+			questionType: dns.TypePTR,
+			records:      []string{reverse + " 60 IN PTR " + parts[1] + "."},
+		})
+	}
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    "127.0.0.1:15000",
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-ptr-csv", "-retry", "1")
+	for _, label := range h.labels {
+		extra = append(extra, "-label", label)
+	}
+
+	results, err := testutils.RunDnsxAndGetResults(h.cidr, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != len(h.expectedOutput) {
+		return errIncorrectResultsCount(results)
+	}
+	for i, expected := range h.expectedOutput {
+		if results[i] != expected {
+			return errIncorrectResult(results[i], expected)
+		}
+	}
+	return nil
+}
+
+// dnsSummarizeCIDRsRequest resolves subdomains to the addresses, the minimal covering cidrs
+// must be displayed
+type dnsSummarizeCIDRsRequest struct {
+	addresses      map[string]string
+	expectedOutput []string
+}
+
+func (h *dnsSummarizeCIDRsRequest) Execute() error {
+	handler := &dnshandler{}
+	var hosts []string
+	for subdomain, address := range h.addresses {
+		host := subdomain + ".projectdiscovery.io"
+		hosts = append(hosts, host)
+		handler.answers = append(handler.answers, answer{question: host, questionType: dns.TypeA, values: []string{address}})
+	}
+	list, err := ioutil.TempFile("", "dnsx-summarize-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+	if _, err := list.WriteString(strings.Join(hosts, "\n") + "\n"); err != nil {
+		return err
+	}
+	list.Close()
+
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    "127.0.0.1:15000",
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", "127.0.0.1:15000")
+	extra = append(extra, "-l", list.Name(), "-summarize-cidrs")
+
+	results, err := testutils.RunDnsxAndGetResults("", debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != len(h.expectedOutput) {
+		return errIncorrectResultsCount(results)
+	}
+	for i, expected := range h.expectedOutput {
+		if results[i] != expected {
+			return errIncorrectResult(results[i], expected)
+		}
+	}
+	return nil
+}
+
+// dnsTraceStartRequest traces the question from a local server instead of the root servers,
+// its answer must be the single step of the trace
+type dnsTraceStartRequest struct {
+	question       string
+	server         string
+	expectedOutput string
+}
+
+func (h *dnsTraceStartRequest) Execute() error {
+	handler := &dnshandler{
+		answers: []answer{
+			{question: h.question, questionType: dns.TypeA, values: []string{h.expectedOutput}},
+		},
+	}
+	srv := &dns.Server{
+		Handler: handler,
+		Addr:    h.server,
+		Net:     "udp",
+	}
+	go srv.ListenAndServe() //nolint
+	defer srv.Shutdown()    //nolint
+
+	var extra []string
+	extra = append(extra, "-r", h.server)
+	extra = append(extra, "-trace", "-trace-start-server", h.server, "-json")
+
+	results, err := testutils.RunDnsxAndGetResults(h.question, debug, extra...)
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 {
+		return errIncorrectResultsCount(results)
+	}
+	var result struct {
+		Trace struct {
+			Chain []struct {
+				Resolver []string `json:"resolver"`
+				A        []string `json:"a"`
+			} `json:"chain"`
+		} `json:"trace"`
+	}
+	if err := json.Unmarshal([]byte(results[0]), &result); err != nil {
+		return err
+	}
+	chain := result.Trace.Chain
+	if len(chain) != 1 || len(chain[0].Resolver) != 1 || chain[0].Resolver[0] != h.server || len(chain[0].A) != 1 || chain[0].A[0] != h.expectedOutput {
+		return errIncorrectResult(results[0], fmt.Sprintf("a trace answered by %s with %s", h.server, h.expectedOutput))
+	}
+	return nil
+}
+
+// dnsServeRequest serves the json results of a run and queries them with a dns client, the
+// cname chain, the synthesized ttl, the reverse names and the unknown names are checked
+type dnsServeRequest struct{}
+
+func (h *dnsServeRequest) Execute() error {
+	results, err := ioutil.TempFile("", "dnsx-serve-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(results.Name())
+	lines := []string{
+		`{"host":"www.projectdiscovery.io","ttl":120,"cname":["cdn.projectdiscovery.io"],"a":["1.2.3.4"]}`,
+		`{"host":"www.projectdiscovery.io","aaaa":["2001:db8::1"]}`,
+		`{"host":"192.0.2.2","ptr":["two.projectdiscovery.io"]}`,
+	}
+	if _, err := results.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return err
+	}
+	results.Close()
+
+	cmd := exec.Command("./dnsx", "-serve", "127.0.0.1:15001", "-serve-from", results.Name(), "-silent")
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer cmd.Wait()         //nolint
+	defer cmd.Process.Kill() //nolint
+
+	queries := []struct {
+		name     string
+		qtype    uint16
+		rcode    int
+		expected []string
+	}{
+		{"www.projectdiscovery.io.", dns.TypeA, dns.RcodeSuccess, []string{
+			"www.projectdiscovery.io. 120 IN CNAME cdn.projectdiscovery.io.",
+			"cdn.projectdiscovery.io. 120 IN A 1.2.3.4",
+		}},
+		{"www.projectdiscovery.io.", dns.TypeAAAA, dns.RcodeSuccess, []string{
+			"www.projectdiscovery.io. 120 IN CNAME cdn.projectdiscovery.io.",
+			"cdn.projectdiscovery.io. 120 IN AAAA 2001:db8::1",
+		}},
+		{"2.2.0.192.in-addr.arpa.", dns.TypePTR, dns.RcodeSuccess, []string{
+			"2.2.0.192.in-addr.arpa. 300 IN PTR two.projectdiscovery.io.",
+		}},
+		{"www.projectdiscovery.io.", dns.TypeTXT, dns.RcodeSuccess, nil},
+		{"unknown.projectdiscovery.io.", dns.TypeA, dns.RcodeNameError, nil},
+	}
+	client := &dns.Client{Timeout: time.Second}
+	for _, query := range queries {
+		msg := new(dns.Msg)
+		msg.SetQuestion(query.name, query.qtype)
+		var resp *dns.Msg
+		// the server may still be starting
+		for attempt := 0; attempt < 20; attempt++ {
+			if resp, _, err = client.Exchange(msg, "127.0.0.1:15001"); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Rcode != query.rcode {
+			return errIncorrectResult(dns.RcodeToString[resp.Rcode], dns.RcodeToString[query.rcode])
+		}
+		var answers []string
+		for _, rr := range resp.Answer {
+			answers = append(answers, strings.Join(strings.Fields(rr.String()), " "))
+		}
+		if len(answers) != len(query.expected) {
+			return errIncorrectResultsCount(answers)
+		}
+		for i, expected := range query.expected {
+			if answers[i] != expected {
+				return errIncorrectResult(answers[i], expected)
+			}
+		}
+	}
+	return nil
+}
+
 type answer struct {
 	question     string
 	questionType uint16
 	values       []string
+	// records are answer records in zone file format, served as is
+	records []string
 }
 
 type dnshandler struct {
@@ -104,17 +624,41 @@ func (t *dnshandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	question = strings.TrimSuffix(question, ".")
 	questionType := r.Question[0].Qtype
 	for _, answer := range t.answers {
-		if strings.EqualFold(question, answer.question) && answer.questionType == questionType {
+		if matchQuestion(answer.question, question) && answer.questionType == questionType {
+			// wildcard answers are owned by the queried name
+			answer.question = question
 			resp := buildAnswer(r, answer)
+			if w.LocalAddr().Network() == "udp" {
+				size := dns.MinMsgSize
+				if opt := r.IsEdns0(); opt != nil {
+					size = int(opt.UDPSize())
+				}
+				resp.Truncate(size)
+			}
 			w.WriteMsg(resp) //nolint
+			return
 		}
 	}
 }
 
+// matchQuestion reports whether the question matches the name of the answer, a leading
+// *. label matching any name below it
+func matchQuestion(name, question string) bool {
+	if strings.HasPrefix(name, "*.") {
+		return strings.HasSuffix(strings.ToLower(question), strings.ToLower(name[1:]))
+	}
+	return strings.EqualFold(question, name)
+}
+
 func buildAnswer(r *dns.Msg, ans answer) *dns.Msg {
 	msg := dns.Msg{}
 	msg.SetReply(r)
 	msg.Authoritative = true
+	for _, record := range ans.records {
+		if rr, err := dns.NewRR(record); err == nil {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
 	switch ans.questionType {
 	case dns.TypeA:
 		for _, value := range ans.values {
@@ -130,6 +674,13 @@ func buildAnswer(r *dns.Msg, ans answer) *dns.Msg {
 				AAAA: net.ParseIP(value),
 			})
 		}
+	case dns.TypeTXT:
+		for _, value := range ans.values {
+			msg.Answer = append(msg.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: dns.Fqdn(ans.question), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+				Txt: []string{value},
+			})
+		}
 	}
 	return &msg
 }
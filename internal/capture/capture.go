@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Capture contains the unique names queried in a capture along with the answers observed for them
+type Capture struct {
+	// Names are the unique query names in order of appearance
+	Names []string
+	// Observed are the A, AAAA and CNAME values of the responses for each name
+	Observed map[string][]string
+	// Malformed is the number of frames or packets skipped because they couldn't be parsed
+	Malformed int
+
+	seen         map[string]struct{}
+	seenObserved map[string]map[string]struct{}
+}
+
+func newCapture() *Capture {
+	return &Capture{
+		Observed:     make(map[string][]string),
+		seen:         make(map[string]struct{}),
+		seenObserved: make(map[string]map[string]struct{}),
+	}
+}
+
+// addMessage records the question and answers of a packed dns message
+func (c *Capture) addMessage(data []byte) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(data); err != nil || len(msg.Question) == 0 {
+		c.Malformed++
+		return
+	}
+	name := normalizeName(msg.Question[0].Name)
+	if name == "" {
+		return
+	}
+	if _, ok := c.seen[name]; !ok {
+		c.seen[name] = struct{}{}
+		c.Names = append(c.Names, name)
+	}
+	if !msg.Response {
+		return
+	}
+	for _, rr := range msg.Answer {
+		var value string
+		switch record := rr.(type) {
+		case *dns.A:
+			value = record.A.String()
+		case *dns.AAAA:
+			value = record.AAAA.String()
+		case *dns.CNAME:
+			value = normalizeName(record.Target)
+		default:
+			continue
+		}
+		if c.seenObserved[name] == nil {
+			c.seenObserved[name] = make(map[string]struct{})
+		}
+		if _, ok := c.seenObserved[name][value]; ok {
+			continue
+		}
+		c.seenObserved[name][value] = struct{}{}
+		c.Observed[name] = append(c.Observed[name], value)
+	}
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
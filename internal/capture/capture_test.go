@@ -0,0 +1,377 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	framestream "github.com/farsightsec/golang-framestream"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/miekg/dns"
+	"google.golang.org/protobuf/proto"
+)
+
+// packMessage returns a packed query, or a response answering it with the values
+func packMessage(tb testing.TB, name string, values ...string) []byte {
+	tb.Helper()
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	if len(values) > 0 {
+		msg.Response = true
+		for _, value := range values {
+			rr, err := dns.NewRR(fmt.Sprintf("%s 60 IN %s", dns.Fqdn(name), value))
+			if err != nil {
+				tb.Fatal(err)
+			}
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// ipv4UDP returns the layers of an ipv4 udp packet sent to the port
+func ipv4UDP(port layers.UDPPort, fragmentOffset uint16, payload []byte) []gopacket.SerializableLayer {
+	return []gopacket.SerializableLayer{
+		&layers.IPv4{
+			Version:    4,
+			TTL:        64,
+			Protocol:   layers.IPProtocolUDP,
+			FragOffset: fragmentOffset,
+			SrcIP:      net.IPv4(192, 0, 2, 1).To4(),
+			DstIP:      net.IPv4(192, 0, 2, 53).To4(),
+		},
+		&layers.UDP{SrcPort: 40000, DstPort: port},
+		gopacket.Payload(payload),
+	}
+}
+
+// ipv6TCP returns the layers of an ipv6 tcp segment carrying the length prefixed message
+func ipv6TCP(message []byte) []gopacket.SerializableLayer {
+	return []gopacket.SerializableLayer{
+		&layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			NextHeader: layers.IPProtocolTCP,
+			SrcIP:      net.ParseIP("2001:db8::53"),
+			DstIP:      net.ParseIP("2001:db8::1"),
+		},
+		&layers.TCP{SrcPort: dnsPort, DstPort: 40000, ACK: true, PSH: true, Window: 65535},
+		gopacket.Payload(append([]byte{byte(len(message) >> 8), byte(len(message))}, message...)),
+	}
+}
+
+// ethernet returns the layers of an ethernet header with the vlan tags
+func ethernet(etherType layers.EthernetType, vlans ...uint16) []gopacket.SerializableLayer {
+	frame := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		EthernetType: etherType,
+	}
+	result := []gopacket.SerializableLayer{frame}
+	for i, vlan := range vlans {
+		frame.EthernetType = layers.EthernetTypeDot1Q
+		tag := &layers.Dot1Q{VLANIdentifier: vlan, Type: etherType}
+		if i < len(vlans)-1 {
+			tag.Type = layers.EthernetTypeDot1Q
+		}
+		result = append(result, tag)
+	}
+	return result
+}
+
+// serialize encodes the layers of a packet, the layers are given outermost first
+func serialize(tb testing.TB, packetLayers ...[]gopacket.SerializableLayer) []byte {
+	tb.Helper()
+	var all []gopacket.SerializableLayer
+	for _, layers := range packetLayers {
+		all = append(all, layers...)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, all...); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// header returns a link layer header that gopacket can't serialize
+func header(data []byte) []gopacket.SerializableLayer {
+	return []gopacket.SerializableLayer{gopacket.Payload(data)}
+}
+
+// pcapFile writes a pcap file holding the packets
+func pcapFile(tb testing.TB, nanoseconds bool, linkType layers.LinkType, packets ...[]byte) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	if nanoseconds {
+		w = pcapgo.NewWriterNanos(&buf)
+	}
+	if err := w.WriteFileHeader(65535, linkType); err != nil {
+		tb.Fatal(err)
+	}
+	for i, packet := range packets {
+		info := gopacket.CaptureInfo{
+			Timestamp:     time.Unix(int64(1700000000+i), 0),
+			CaptureLength: len(packet),
+			Length:        len(packet),
+		}
+		if err := w.WritePacket(info, packet); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func checkCapture(t *testing.T, capture *Capture, names []string, observed map[string][]string, malformed int) {
+	t.Helper()
+	if fmt.Sprint(capture.Names) != fmt.Sprint(names) {
+		t.Errorf("got names %v, want %v", capture.Names, names)
+	}
+	if fmt.Sprint(capture.Observed) != fmt.Sprint(observed) {
+		t.Errorf("got observed %v, want %v", capture.Observed, observed)
+	}
+	if capture.Malformed != malformed {
+		t.Errorf("got %d malformed, want %d", capture.Malformed, malformed)
+	}
+}
+
+func TestReadPcap(t *testing.T) {
+	query := packMessage(t, "WWW.Example.com")
+	response := packMessage(t, "www.example.com", "CNAME web.example.com.", "A 192.0.2.10", "A 192.0.2.10")
+	responseV6 := packMessage(t, "v6.example.com", "AAAA 2001:db8::10")
+	// the protocol type of the linux cooked capture header is ipv4
+	linuxSLL := make([]byte, 16)
+	linuxSLL[14] = 0x08
+	null := []gopacket.SerializableLayer{&layers.Loopback{Family: layers.ProtocolFamilyIPv4}}
+
+	tests := []struct {
+		name        string
+		nanoseconds bool
+		linkType    layers.LinkType
+		packets     [][]byte
+		names       []string
+		observed    map[string][]string
+		malformed   int
+	}{
+		{
+			name:     "ethernet udp",
+			linkType: layers.LinkTypeEthernet,
+			packets: [][]byte{
+				serialize(t, ethernet(layers.EthernetTypeIPv4), ipv4UDP(dnsPort, 0, query)),
+				serialize(t, ethernet(layers.EthernetTypeIPv4), ipv4UDP(dnsPort, 0, response)),
+			},
+			names:    []string{"www.example.com"},
+			observed: map[string][]string{"www.example.com": {"web.example.com", "192.0.2.10"}},
+		},
+		{
+			name:        "nanoseconds with vlan tags",
+			nanoseconds: true,
+			linkType:    layers.LinkTypeEthernet,
+			packets: [][]byte{
+				serialize(t, ethernet(layers.EthernetTypeIPv4, 10, 20), ipv4UDP(dnsPort, 0, response)),
+			},
+			names:    []string{"www.example.com"},
+			observed: map[string][]string{"www.example.com": {"web.example.com", "192.0.2.10"}},
+		},
+		{
+			name:     "ipv6 tcp",
+			linkType: layers.LinkTypeEthernet,
+			packets: [][]byte{
+				serialize(t, ethernet(layers.EthernetTypeIPv6), ipv6TCP(responseV6)),
+			},
+			names:    []string{"v6.example.com"},
+			observed: map[string][]string{"v6.example.com": {"2001:db8::10"}},
+		},
+		{
+			name:     "raw link",
+			linkType: layers.LinkTypeRaw,
+			packets: [][]byte{
+				serialize(t, ipv4UDP(dnsPort, 0, query)),
+			},
+			names:    []string{"www.example.com"},
+			observed: map[string][]string{},
+		},
+		{
+			name:     "ipv6 link",
+			linkType: layers.LinkTypeIPv6,
+			packets: [][]byte{
+				serialize(t, ipv6TCP(responseV6)),
+			},
+			names:    []string{"v6.example.com"},
+			observed: map[string][]string{"v6.example.com": {"2001:db8::10"}},
+		},
+		{
+			name:     "null link",
+			linkType: layers.LinkTypeNull,
+			packets: [][]byte{
+				serialize(t, null, ipv4UDP(dnsPort, 0, query)),
+			},
+			names:    []string{"www.example.com"},
+			observed: map[string][]string{},
+		},
+		{
+			name:     "linux sll",
+			linkType: layers.LinkTypeLinuxSLL,
+			packets: [][]byte{
+				serialize(t, header(linuxSLL), ipv4UDP(dnsPort, 0, response)),
+			},
+			names:    []string{"www.example.com"},
+			observed: map[string][]string{"www.example.com": {"web.example.com", "192.0.2.10"}},
+		},
+		{
+			name:     "skipped fragments, other ports and malformed messages",
+			linkType: layers.LinkTypeRaw,
+			packets: [][]byte{
+				serialize(t, ipv4UDP(dnsPort, 100, query)),
+				serialize(t, ipv4UDP(8080, 0, query)),
+				serialize(t, ipv4UDP(dnsPort, 0, []byte{1, 2, 3})),
+				serialize(t, ipv4UDP(dnsPort, 0, responseV6)),
+			},
+			names:     []string{"v6.example.com"},
+			observed:  map[string][]string{"v6.example.com": {"2001:db8::10"}},
+			malformed: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capture, err := ReadPcap(bytes.NewReader(pcapFile(t, test.nanoseconds, test.linkType, test.packets...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkCapture(t, capture, test.names, test.observed, test.malformed)
+		})
+	}
+}
+
+func TestReadPcapTruncated(t *testing.T) {
+	packet := serialize(t, ipv4UDP(dnsPort, 0, packMessage(t, "www.example.com")))
+	data := pcapFile(t, false, layers.LinkTypeRaw, packet, packet)
+	capture, err := ReadPcap(bytes.NewReader(data[:len(data)-5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCapture(t, capture, []string{"www.example.com"}, map[string][]string{}, 1)
+}
+
+func TestReadPcapInvalid(t *testing.T) {
+	pcapng := make([]byte, 24)
+	copy(pcapng, pcapngMagic)
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", err: errInvalidPcap},
+		{name: "short header", data: []byte{0xd4, 0xc3, 0xb2, 0xa1}, err: errInvalidPcap},
+		{name: "unknown magic", data: make([]byte, 24), err: errInvalidPcap},
+		{name: "pcapng", data: pcapng, err: errPcapng},
+		{name: "unsupported link", data: pcapFile(t, false, 147), err: errUnsupportedLink},
+	}
+	for _, test := range tests {
+		if _, err := ReadPcap(bytes.NewReader(test.data)); err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+// dnstapFrame encodes a Dnstap message carrying the query and the response
+func dnstapFrame(tb testing.TB, query, response []byte) []byte {
+	tb.Helper()
+	frame, err := proto.Marshal(&dnstap.Dnstap{
+		Type: dnstap.Dnstap_MESSAGE.Enum(),
+		Message: &dnstap.Message{
+			Type:            dnstap.Message_CLIENT_RESPONSE.Enum(),
+			QueryMessage:    query,
+			ResponseMessage: response,
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return frame
+}
+
+// dnstapFile writes the frames to a frame streams file, the stop frame is written when closed
+// is set
+func dnstapFile(tb testing.TB, closed bool, frames ...[]byte) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w, err := framestream.NewWriter(&buf, &framestream.WriterOptions{ContentTypes: [][]byte{dnstap.FSContentType}})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, frame := range frames {
+		if _, err := w.WriteFrame(frame); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if closed {
+		err = w.Close()
+	} else {
+		err = w.Flush()
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadDnstap(t *testing.T) {
+	query := packMessage(t, "www.example.com")
+	response := packMessage(t, "www.example.com", "A 192.0.2.10")
+	other := packMessage(t, "mail.example.com", "A 192.0.2.25")
+
+	file := dnstapFile(t, true,
+		dnstapFrame(t, query, nil),
+		dnstapFrame(t, query, response),
+		[]byte{0xff},
+		dnstapFrame(t, nil, other),
+	)
+	// frames after the stop frame are ignored
+	file = append(file, dnstapFile(t, false, dnstapFrame(t, packMessage(t, "ignored.example.com"), nil))...)
+
+	capture, err := ReadDnstap(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCapture(t, capture, []string{"www.example.com", "mail.example.com"}, map[string][]string{
+		"www.example.com":  {"192.0.2.10"},
+		"mail.example.com": {"192.0.2.25"},
+	}, 1)
+}
+
+func TestReadDnstapInvalid(t *testing.T) {
+	frame := dnstapFrame(t, packMessage(t, "www.example.com"), nil)
+	start := dnstapFile(t, false)
+	file := dnstapFile(t, false, frame, frame)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "data frame before the start frame", data: file[len(start):]},
+		{name: "short control frame", data: []byte{0, 0, 0, 0, 0, 0, 0, 2, 0, 0}},
+		{name: "other content type", data: bytes.Replace(start, []byte("dnstap"), []byte("tapdns"), 1)},
+	}
+	for _, test := range tests {
+		if _, err := ReadDnstap(bytes.NewReader(test.data)); err != errInvalidDnstap {
+			t.Errorf("%s: got error %v, want %v", test.name, err, errInvalidDnstap)
+		}
+	}
+
+	// a truncated file keeps the frames read before it
+	capture, err := ReadDnstap(bytes.NewReader(file[:len(file)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCapture(t, capture, []string{"www.example.com"}, map[string][]string{}, 1)
+}
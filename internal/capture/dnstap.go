@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"errors"
+	"io"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	framestream "github.com/farsightsec/golang-framestream"
+	"google.golang.org/protobuf/proto"
+)
+
+// frameStreamsMaxFrameSize is the size of the largest dnstap frame read
+const frameStreamsMaxFrameSize = 1 << 20
+
+var errInvalidDnstap = errors.New("invalid dnstap file")
+
+// ReadDnstap extracts the dns messages of a dnstap file in the frame streams format
+func ReadDnstap(r io.Reader) (*Capture, error) {
+	reader, err := dnstap.NewReader(r, nil)
+	if err != nil {
+		return nil, errInvalidDnstap
+	}
+
+	capture := newCapture()
+	frame := make([]byte, frameStreamsMaxFrameSize)
+	for {
+		n, err := reader.ReadFrame(frame)
+		if err == framestream.ErrDataFrameTooLarge {
+			capture.Malformed++
+			continue
+		}
+		if err == io.EOF {
+			// end of the file or stop frame
+			break
+		}
+		if err != nil {
+			// truncated file, keep the frames read so far
+			capture.Malformed++
+			break
+		}
+		message := new(dnstap.Dnstap)
+		if err := proto.Unmarshal(frame[:n], message); err != nil {
+			capture.Malformed++
+			continue
+		}
+		capture.addDnstapMessage(message)
+	}
+	return capture, nil
+}
+
+// addDnstapMessage records the dns messages of a Dnstap message
+func (c *Capture) addDnstapMessage(message *dnstap.Dnstap) {
+	if data := message.GetMessage().GetQueryMessage(); data != nil {
+		c.addMessage(data)
+	}
+	if data := message.GetMessage().GetResponseMessage(); data != nil {
+		c.addMessage(data)
+	}
+}
@@ -0,0 +1,2 @@
+// Package capture extracts the dns names and answers observed in pcap and dnstap files.
+package capture
@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const dnsPort = 53
+
+var (
+	errPcapng          = errors.New("pcapng files are not supported, convert them to pcap first")
+	errInvalidPcap     = errors.New("invalid pcap file")
+	errUnsupportedLink = errors.New("unsupported pcap link type")
+)
+
+// pcapngMagic is the block type of the section header starting a pcapng file
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// ReadPcap extracts the dns messages exchanged on port 53 from a pcap file
+func ReadPcap(r io.Reader) (*Capture, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(pcapngMagic)); bytes.Equal(magic, pcapngMagic) {
+		return nil, errPcapng
+	}
+	reader, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, errInvalidPcap
+	}
+	decoder, ok := linkDecoder(reader.LinkType())
+	if !ok {
+		return nil, errUnsupportedLink
+	}
+
+	capture := newCapture()
+	for {
+		data, _, err := reader.ZeroCopyReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// truncated capture, keep what was read so far
+			capture.Malformed++
+			break
+		}
+		packet := gopacket.NewPacket(data, decoder, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		if payload, ok := dnsPayload(packet); ok {
+			capture.addMessage(payload)
+		}
+	}
+	return capture, nil
+}
+
+// linkDecoder returns the decoder of the packets of the link type
+func linkDecoder(linkType layers.LinkType) (gopacket.Decoder, bool) {
+	switch linkType {
+	case layers.LinkTypeNull, layers.LinkTypeEthernet, layers.LinkTypeRaw, layers.LinkTypeLinuxSLL:
+		return linkType, true
+	case layers.LinkTypeIPv4:
+		return layers.LayerTypeIPv4, true
+	case layers.LinkTypeIPv6:
+		return layers.LayerTypeIPv6, true
+	}
+	return nil, false
+}
+
+// dnsPayload returns the dns message carried by the packet, if any
+func dnsPayload(packet gopacket.Packet) ([]byte, bool) {
+	// fragments other than the first one carry no transport layer
+	switch transport := packet.TransportLayer().(type) {
+	case *layers.UDP:
+		if transport.SrcPort != dnsPort && transport.DstPort != dnsPort {
+			return nil, false
+		}
+		return transport.Payload, true
+	case *layers.TCP:
+		if transport.SrcPort != dnsPort && transport.DstPort != dnsPort {
+			return nil, false
+		}
+		// only segments carrying a whole length prefixed message are used
+		data := transport.Payload
+		if len(data) < 2 {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(data))
+		if len(data) < length+2 || length == 0 {
+			return nil, false
+		}
+		return data[2 : length+2], true
+	}
+	return nil, false
+}
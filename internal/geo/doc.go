@@ -0,0 +1,3 @@
+// Package geo looks up the location and network of addresses in MaxMind DB (mmdb) files
+// such as GeoLite2-City, GeoLite2-Country and GeoLite2-ASN.
+package geo
@@ -0,0 +1,118 @@
+package geo
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+)
+
+// supportedTypes are the database types whose records hold the location or the network
+var supportedTypes = []string{"city", "country", "asn"}
+
+// Location is the geolocation and network of an address, the fields missing from the
+// database are empty
+type Location struct {
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     uint64 `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"`
+}
+
+// record holds the fields of the city, country and asn records used by the locations,
+// the other fields of the records aren't decoded
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	City struct {
+		Names struct {
+			English string `maxminddb:"en"`
+		} `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN uint64 `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// Reader looks up addresses in a database loaded in memory, it's safe for concurrent use
+type Reader struct {
+	// DatabaseType is the type declared in the metadata (eg. GeoLite2-City)
+	DatabaseType string
+
+	db *maxminddb.Reader
+}
+
+// Open loads the database, the files not being MaxMind DB databases of a supported type
+// are rejected
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(buf)
+}
+
+func newReader(buf []byte) (*Reader, error) {
+	db, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	// the reader checks the record size and the tree bounds, the other fields it relies on
+	// are checked here rather than on the first lookup
+	metadata := db.Metadata
+	if metadata.BinaryFormatMajorVersion != 2 {
+		return nil, errors.Errorf("unsupported maxmind db format version %d", metadata.BinaryFormatMajorVersion)
+	}
+	if metadata.IPVersion != 4 && metadata.IPVersion != 6 {
+		return nil, errors.Errorf("unsupported ip version %d", metadata.IPVersion)
+	}
+	if metadata.NodeCount == 0 {
+		return nil, errors.New("invalid search tree size")
+	}
+	if !supportedType(db.Metadata.DatabaseType) {
+		return nil, errors.Errorf("unsupported database type %s (city, country or asn expected)", db.Metadata.DatabaseType)
+	}
+	return &Reader{DatabaseType: db.Metadata.DatabaseType, db: db}, nil
+}
+
+// Lookup returns the location of the address, nil when the database has no record for it
+func (r *Reader) Lookup(ip net.IP) (*Location, error) {
+	if ip == nil {
+		return nil, errors.New("invalid address")
+	}
+	// ipv4 databases have no ipv6 address
+	if ip.To4() == nil && r.db.Metadata.IPVersion == 4 {
+		return nil, nil
+	}
+	var result record
+	_, ok, err := r.db.LookupNetwork(ip, &result)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	location := &Location{
+		Country: result.Country.ISOCode,
+		City:    result.City.Names.English,
+		ASN:     result.ASN,
+		Org:     result.Org,
+	}
+	if location.Country == "" {
+		location.Country = result.RegisteredCountry.ISOCode
+	}
+	return location, nil
+}
+
+func supportedType(databaseType string) bool {
+	databaseType = strings.ToLower(databaseType)
+	for _, supported := range supportedTypes {
+		if strings.Contains(databaseType, supported) {
+			return true
+		}
+	}
+	return false
+}
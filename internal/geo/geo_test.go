@@ -0,0 +1,247 @@
+package geo
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// data section types of the values encoded in the test databases
+const (
+	typeString = 2
+	typeDouble = 3
+	typeMap    = 7
+	typeUint64 = 9
+)
+
+// dataSectionSeparator is the size of the zeroes between the search tree and the data
+const dataSectionSeparator = 16
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// encodeControl returns the control byte of the type followed by its extended type and size
+func encodeControl(kind, size int) []byte {
+	var first byte
+	var extended []byte
+	if kind > 7 {
+		extended = []byte{byte(kind - 7)}
+	} else {
+		first = byte(kind << 5)
+	}
+	var sizes []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		sizes = []byte{byte(size - 29)}
+	case size < 65821:
+		first |= 30
+		sizes = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		first |= 31
+		sizes = []byte{byte((size - 65821) >> 16), byte((size - 65821) >> 8), byte(size - 65821)}
+	}
+	return append(append([]byte{first}, extended...), sizes...)
+}
+
+// encode returns the data section encoding of the value
+func encode(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(encodeControl(typeString, len(v)), v...)
+	case uint64:
+		var data []byte
+		for ; v > 0; v >>= 8 {
+			data = append([]byte{byte(v)}, data...)
+		}
+		return append(encodeControl(typeUint64, len(data)), data...)
+	case float64:
+		bits := math.Float64bits(v)
+		data := []byte{byte(bits >> 56), byte(bits >> 48), byte(bits >> 40), byte(bits >> 32), byte(bits >> 24), byte(bits >> 16), byte(bits >> 8), byte(bits)}
+		return append(encodeControl(typeDouble, len(data)), data...)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		data := encodeControl(typeMap, len(v))
+		for _, key := range keys {
+			data = append(data, encode(key)...)
+			data = append(data, encode(v[key])...)
+		}
+		return data
+	}
+	panic(fmt.Sprintf("unsupported value %T", value))
+}
+
+// buildDatabase returns a database whose addresses starting with a 0 bit have the first
+// record and the others the second one, a nil record leaves the addresses without data. The
+// ipv6 databases have a chain of 96 nodes leading to the ipv4 addresses.
+func buildDatabase(ipVersion, recordSize int, databaseType string, records [2]interface{}) []byte {
+	var data []byte
+	var values [2]int
+	nodeCount := 1
+	if ipVersion == 6 {
+		nodeCount = 97
+	}
+	for i, record := range records {
+		values[i] = nodeCount
+		if record != nil {
+			values[i] = nodeCount + dataSectionSeparator + len(data)
+			data = append(data, encode(record)...)
+		}
+	}
+
+	var tree []byte
+	writeNode := func(left, right int) {
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(left>>24)<<4|byte(right>>24)&0xf, byte(right>>16), byte(right>>8), byte(right))
+		default:
+			tree = append(tree, byte(left>>24), byte(left>>16), byte(left>>8), byte(left), byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+	for node := 1; node < nodeCount; node++ {
+		writeNode(node, nodeCount)
+	}
+	writeNode(values[0], values[1])
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encode(map[string]interface{}{
+		"binary_format_major_version": uint64(2),
+		"database_type":               databaseType,
+		"ip_version":                  uint64(ipVersion),
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 uint64(recordSize),
+	}))
+	return buf.Bytes()
+}
+
+var (
+	cityRecord = map[string]interface{}{
+		"city":    map[string]interface{}{"names": map[string]interface{}{"de": "Berlin", "en": "Berlin"}},
+		"country": map[string]interface{}{"iso_code": "DE", "names": map[string]interface{}{"en": "Germany"}},
+		"location": map[string]interface{}{
+			"latitude":  52.5,
+			"longitude": 13.4,
+		},
+	}
+	asnRecord = map[string]interface{}{
+		"autonomous_system_number":       uint64(64496),
+		"autonomous_system_organization": "Example Networks",
+		"registered_country":             map[string]interface{}{"iso_code": "US"},
+	}
+)
+
+func TestLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			t.Run(fmt.Sprintf("ipv%d/%d", ipVersion, recordSize), func(t *testing.T) {
+				r, err := newReader(buildDatabase(ipVersion, recordSize, "GeoLite2-City", [2]interface{}{cityRecord, asnRecord}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tests := []struct {
+					ip   string
+					want *Location
+				}{
+					{ip: "5.6.7.8", want: &Location{Country: "DE", City: "Berlin"}},
+					// the registered country is used when the country is missing
+					{ip: "192.0.2.1", want: &Location{Country: "US", ASN: 64496, Org: "Example Networks"}},
+					// ipv4 databases have no ipv6 address, 2001:db8:: leaves the ipv4 subtree
+					// of the ipv6 ones on its third bit
+					{ip: "2001:db8::1"},
+				}
+				for _, test := range tests {
+					location, err := r.Lookup(net.ParseIP(test.ip))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !reflect.DeepEqual(location, test.want) {
+						t.Errorf("%s: got %+v, want %+v", test.ip, location, test.want)
+					}
+				}
+			})
+		}
+	}
+
+	// the addresses without record have no location
+	r, err := newReader(buildDatabase(4, 24, "GeoLite2-ASN", [2]interface{}{asnRecord, nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location, err := r.Lookup(net.ParseIP("192.0.2.1")); location != nil || err != nil {
+		t.Errorf("got %+v and %v, want no location", location, err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	valid := buildDatabase(4, 24, "GeoLite2-City", [2]interface{}{cityRecord, nil})
+	metadata := func(fields map[string]interface{}) []byte {
+		base := map[string]interface{}{
+			"binary_format_major_version": uint64(2),
+			"database_type":               "GeoLite2-City",
+			"ip_version":                  uint64(4),
+			"node_count":                  uint64(1),
+			"record_size":                 uint64(24),
+		}
+		for key, value := range fields {
+			base[key] = value
+		}
+		end := bytes.LastIndex(valid, metadataMarker)
+		return append(append(append([]byte(nil), valid[:end]...), metadataMarker...), encode(base)...)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "no metadata", data: []byte("not a database")},
+		{name: "invalid metadata", data: append(append([]byte(nil), metadataMarker...), 0x5d)},
+		{name: "metadata not a map", data: append(append([]byte(nil), metadataMarker...), encode("x")...)},
+		{name: "format version", data: metadata(map[string]interface{}{"binary_format_major_version": uint64(3)})},
+		{name: "record size", data: metadata(map[string]interface{}{"record_size": uint64(20)})},
+		{name: "ip version", data: metadata(map[string]interface{}{"ip_version": uint64(5)})},
+		{name: "database type", data: metadata(map[string]interface{}{"database_type": "GeoIP2-Anonymous-IP"})},
+		{name: "tree larger than the file", data: metadata(map[string]interface{}{"node_count": uint64(1000)})},
+		{name: "overflowing node count", data: metadata(map[string]interface{}{"node_count": uint64(math.MaxUint64 / 2)})},
+		{name: "no node", data: metadata(map[string]interface{}{"node_count": uint64(0)})},
+	}
+	for _, test := range tests {
+		if r, err := newReader(test.data); err == nil {
+			t.Errorf("%s: got reader %+v, want an error", test.name, r)
+		}
+	}
+	if _, err := newReader(valid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// FuzzReader checks that invalid databases are rejected or looked up without panicking
+func FuzzReader(f *testing.F) {
+	f.Add(buildDatabase(4, 24, "GeoLite2-City", [2]interface{}{cityRecord, asnRecord}))
+	f.Add(buildDatabase(6, 28, "GeoLite2-ASN", [2]interface{}{asnRecord, nil}))
+	f.Add(buildDatabase(6, 32, "GeoLite2-Country", [2]interface{}{nil, cityRecord}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := newReader(data)
+		if err != nil {
+			return
+		}
+		for _, ip := range []string{"5.6.7.8", "192.0.2.1", "2001:db8::1", "ffff::1"} {
+			// nolint:errcheck
+			r.Lookup(net.ParseIP(ip))
+		}
+	})
+}
@@ -0,0 +1,2 @@
+// Package mutate generates candidate names from the label patterns of known names.
+package mutate
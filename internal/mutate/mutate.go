@@ -0,0 +1,177 @@
+package mutate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Rules used to generate the candidates
+const (
+	RuleNumber      = "number"
+	RuleEnvironment = "environment"
+	RuleRegion      = "region"
+	RuleApex        = "apex"
+)
+
+// Environments contains the deployment environment tokens swapped with each other
+var Environments = []string{"dev", "test", "qa", "uat", "stage", "staging", "preprod", "prod"}
+
+// Regions contains the region code tokens swapped with each other
+var Regions = []string{"us", "eu", "ap", "uk", "de", "fr", "jp", "sg", "au", "ca", "br"}
+
+// maxNumberDistance is the range of the numbers generated around a numeric suffix
+const maxNumberDistance = 3
+
+// Candidate is a generated name along with the rule that produced it
+type Candidate struct {
+	Domain string
+	Rule   string
+}
+
+// Generate returns up to max unique candidates mutating the numeric suffixes, environment
+// and region tokens of the subdomain labels of the names. Each subdomain is also tried under
+// the apex domains of the other names. The names themselves are never returned, a max <= 0
+// disables the limit.
+func Generate(names []string, max int) []Candidate {
+	type split struct {
+		sub, apex string
+	}
+	seen := make(map[string]struct{})
+	var (
+		splits []split
+		apexes []string
+	)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if _, ok := seen[name]; ok || name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		apex, err := publicsuffix.EffectiveTLDPlusOne(name)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[apex]; !ok {
+			seen[apex] = struct{}{}
+			apexes = append(apexes, apex)
+		}
+		if name != apex {
+			splits = append(splits, split{sub: strings.TrimSuffix(name, "."+apex), apex: apex})
+		}
+	}
+	sort.Slice(splits, func(i, j int) bool {
+		return splits[i].sub+"."+splits[i].apex < splits[j].sub+"."+splits[j].apex
+	})
+	sort.Strings(apexes)
+
+	var candidates []Candidate
+	add := func(candidate, rule string) bool {
+		if max > 0 && len(candidates) >= max {
+			return false
+		}
+		if _, ok := seen[candidate]; ok {
+			return true
+		}
+		seen[candidate] = struct{}{}
+		candidates = append(candidates, Candidate{Domain: candidate, Rule: rule})
+		return true
+	}
+
+	for _, s := range splits {
+		for _, mutation := range mutations(s.sub) {
+			if !add(mutation.Domain+"."+s.apex, mutation.Rule) {
+				return candidates
+			}
+		}
+	}
+	for _, s := range splits {
+		for _, apex := range apexes {
+			if apex == s.apex {
+				continue
+			}
+			if !add(s.sub+"."+apex, RuleApex) {
+				return candidates
+			}
+		}
+	}
+	return candidates
+}
+
+// mutations returns the subdomains obtained replacing one token of the subdomain, the
+// tokens are the dash separated parts of its labels
+func mutations(sub string) []Candidate {
+	labels := strings.Split(sub, ".")
+	var results []Candidate
+	for i, label := range labels {
+		tokens := strings.Split(label, "-")
+		for j, token := range tokens {
+			replace := func(replacement, rule string) {
+				mutated := append([]string{}, tokens...)
+				mutated[j] = replacement
+				mutatedLabels := append([]string{}, labels...)
+				mutatedLabels[i] = strings.Join(mutated, "-")
+				results = append(results, Candidate{Domain: strings.Join(mutatedLabels, "."), Rule: rule})
+			}
+			switch {
+			case contains(Environments, token):
+				for _, environment := range Environments {
+					if environment != token {
+						replace(environment, RuleEnvironment)
+					}
+				}
+			case contains(Regions, token):
+				for _, region := range Regions {
+					if region != token {
+						replace(region, RuleRegion)
+					}
+				}
+			default:
+				for _, number := range numbers(token) {
+					replace(number, RuleNumber)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// numbers returns the token with the numbers close to its numeric suffix, the padding of
+// the suffix is preserved
+func numbers(token string) []string {
+	digits := len(token)
+	for digits > 0 && token[digits-1] >= '0' && token[digits-1] <= '9' {
+		digits--
+	}
+	prefix, suffix := token[:digits], token[digits:]
+	if suffix == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(suffix)
+	if err != nil {
+		return nil
+	}
+	var results []string
+	for n := value - maxNumberDistance; n <= value+maxNumberDistance; n++ {
+		if n < 0 || n == value {
+			continue
+		}
+		number := strconv.Itoa(n)
+		for len(number) < len(suffix) {
+			number = "0" + number
+		}
+		results = append(results, prefix+number)
+	}
+	return results
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,2 @@
+// Package reputation looks up the reputation of ip addresses on threat intelligence services.
+package reputation
@@ -0,0 +1,273 @@
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Providers of the reputation lookups
+const (
+	ProviderAbuseIPDB  = "abuseipdb"
+	ProviderVirusTotal = "virustotal"
+)
+
+// Providers contains the supported reputation services
+var Providers = []string{ProviderAbuseIPDB, ProviderVirusTotal}
+
+const (
+	abuseIPDBEndpoint  = "https://api.abuseipdb.com/api/v2/check"
+	virusTotalEndpoint = "https://www.virustotal.com/api/v3/ip_addresses/"
+	// maxResponseSize bounds the size of the api responses read
+	maxResponseSize = 4 << 20
+	// maxAttempts is the number of requests sent for a lookup answered with 429
+	maxAttempts = 4
+	// retryDelay is the first backoff delay of the requests answered with 429 without a
+	// Retry-After header, it doubles with each attempt
+	retryDelay = time.Second
+	// maxRetryAfter bounds the delay asked by the Retry-After header
+	maxRetryAfter = 5 * time.Minute
+)
+
+// abuseIPDBCategories are the names of the report categories of abuseipdb
+var abuseIPDBCategories = map[int]string{
+	1: "dns-compromise", 2: "dns-poisoning", 3: "fraud-orders", 4: "ddos-attack",
+	5: "ftp-brute-force", 6: "ping-of-death", 7: "phishing", 8: "fraud-voip",
+	9: "open-proxy", 10: "web-spam", 11: "email-spam", 12: "blog-spam",
+	13: "vpn-ip", 14: "port-scan", 15: "hacking", 16: "sql-injection",
+	17: "spoofing", 18: "brute-force", 19: "bad-web-bot", 20: "exploited-host",
+	21: "web-app-attack", 22: "ssh", 23: "iot-targeted",
+}
+
+// Report is the reputation of an address. The score goes from 0 to 100, higher is better:
+// the opposite of the abuse confidence on abuseipdb and the share of engines not flagging
+// the address on virustotal.
+type Report struct {
+	IP         string   `json:"ip"`
+	Provider   string   `json:"provider"`
+	Score      int      `json:"score"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Client looks up addresses on a reputation service. The requests are spread to stay under
+// the rate limit and are paused by the 429 responses for the delay asked by the service.
+type Client struct {
+	provider   string
+	key        string
+	endpoint   string
+	interval   time.Duration
+	retryDelay time.Duration
+	http       *http.Client
+
+	mutex sync.Mutex
+	// next is the time of the next request allowed by the rate limit
+	next time.Time
+	// paused is the time until which the service asked not to send requests
+	paused time.Time
+}
+
+// New creates a client of the provider authenticated with the api key, sending at most
+// rate requests per minute
+func New(provider, key string, timeout time.Duration, rate int) (*Client, error) {
+	var endpoint string
+	switch provider {
+	case ProviderAbuseIPDB:
+		endpoint = abuseIPDBEndpoint
+	case ProviderVirusTotal:
+		endpoint = virusTotalEndpoint
+	default:
+		return nil, errors.Errorf("unsupported reputation provider %s", provider)
+	}
+	if key == "" {
+		return nil, errors.New("missing reputation api key")
+	}
+	if rate <= 0 {
+		return nil, errors.New("the reputation rate must be positive")
+	}
+	return &Client{
+		provider:   provider,
+		key:        key,
+		endpoint:   endpoint,
+		interval:   time.Minute / time.Duration(rate),
+		retryDelay: retryDelay,
+		http:       &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Lookup returns the reputation report of the address, it returns early with the error of
+// the context once it's done
+func (c *Client) Lookup(ctx context.Context, ip string) (*Report, error) {
+	if c.provider == ProviderVirusTotal {
+		return c.virusTotal(ctx, ip)
+	}
+	return c.abuseIPDB(ctx, ip)
+}
+
+func (c *Client) abuseIPDB(ctx context.Context, ip string) (*Report, error) {
+	query := url.Values{"ipAddress": {ip}, "maxAgeInDays": {"90"}, "verbose": {""}}
+	var response struct {
+		Data struct {
+			AbuseConfidenceScore int `json:"abuseConfidenceScore"`
+			Reports              []struct {
+				Categories []int `json:"categories"`
+			} `json:"reports"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, c.endpoint+"?"+query.Encode(), map[string]string{"Key": c.key}, &response); err != nil {
+		return nil, err
+	}
+	categories := make(map[string]struct{})
+	for _, report := range response.Data.Reports {
+		for _, category := range report.Categories {
+			if name, ok := abuseIPDBCategories[category]; ok {
+				categories[name] = struct{}{}
+			}
+		}
+	}
+	return &Report{IP: ip, Provider: c.provider, Score: 100 - response.Data.AbuseConfidenceScore, Categories: sortedKeys(categories)}, nil
+}
+
+func (c *Client) virusTotal(ctx context.Context, ip string) (*Report, error) {
+	var response struct {
+		Data struct {
+			Attributes struct {
+				LastAnalysisResults map[string]struct {
+					Category string `json:"category"`
+					Result   string `json:"result"`
+				} `json:"last_analysis_results"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, c.endpoint+url.PathEscape(ip), map[string]string{"x-apikey": c.key}, &response); err != nil {
+		return nil, err
+	}
+	results := response.Data.Attributes.LastAnalysisResults
+	categories := make(map[string]struct{})
+	flagged := 0
+	for _, result := range results {
+		if result.Category != "malicious" && result.Category != "suspicious" {
+			continue
+		}
+		flagged++
+		if result.Result != "" {
+			categories[result.Result] = struct{}{}
+		}
+	}
+	score := 100
+	if len(results) > 0 {
+		score = 100 - flagged*100/len(results)
+	}
+	return &Report{IP: ip, Provider: c.provider, Score: score, Categories: sortedKeys(categories)}, nil
+}
+
+// get performs the authenticated request and decodes the json response, the requests
+// answered with 429 are sent again after the delay asked by the service
+func (c *Client) get(ctx context.Context, endpoint string, headers map[string]string, v interface{}) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			// the url contains the address, the errors are reported without it
+			if urlErr, ok := err.(*url.Error); ok {
+				err = urlErr.Err
+			}
+			return errors.Wrapf(err, "%s request failed", c.provider)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxAttempts {
+			resp.Body.Close()
+			c.pause(retryAfter(resp.Header.Get("Retry-After"), c.retryDelay<<(attempt-1)))
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.Errorf("%s returned status %d", c.provider, resp.StatusCode)
+		}
+		return errors.Wrap(json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v), "could not decode response")
+	}
+}
+
+// wait blocks until the rate limit and the pause asked by the service allow a request
+func (c *Client) wait(ctx context.Context) error {
+	for {
+		c.mutex.Lock()
+		now := time.Now()
+		if c.next.Before(now) {
+			c.next = now
+		}
+		if c.next.Before(c.paused) {
+			c.next = c.paused
+		}
+		slot := c.next
+		c.next = c.next.Add(c.interval)
+		c.mutex.Unlock()
+
+		timer := time.NewTimer(time.Until(slot))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		// a 429 received while waiting pauses the requests not sent yet
+		c.mutex.Lock()
+		paused := time.Now().Before(c.paused)
+		c.mutex.Unlock()
+		if !paused {
+			return nil
+		}
+	}
+}
+
+// pause delays the requests not sent yet by the duration
+func (c *Client) pause(delay time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if until := time.Now().Add(delay); until.After(c.paused) {
+		c.paused = until
+	}
+}
+
+// retryAfter returns the delay of the Retry-After header, given in seconds or as a date,
+// or the backoff delay when it's missing
+func retryAfter(header string, backoff time.Duration) time.Duration {
+	delay := backoff
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		delay = time.Until(date)
+	}
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+func sortedKeys(items map[string]struct{}) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package reputation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of the provider sending its requests to the handler
+func newTestClient(t *testing.T, provider string, rate int, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := New(provider, "secret", time.Second, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.endpoint = server.URL + "/"
+	client.retryDelay = 10 * time.Millisecond
+	return client
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		provider   string
+		header     string
+		body       string
+		score      int
+		categories string
+	}{
+		{
+			provider:   ProviderAbuseIPDB,
+			header:     "Key",
+			body:       `{"data":{"abuseConfidenceScore":75,"reports":[{"categories":[18,22]},{"categories":[18,99]}]}}`,
+			score:      25,
+			categories: "brute-force,ssh",
+		},
+		{provider: ProviderAbuseIPDB, header: "Key", body: `{"data":{"abuseConfidenceScore":0}}`, score: 100},
+		{
+			provider:   ProviderVirusTotal,
+			header:     "x-apikey",
+			body:       `{"data":{"attributes":{"last_analysis_results":{"a":{"category":"malicious","result":"malware"},"b":{"category":"suspicious","result":"phishing"},"c":{"category":"harmless"},"d":{"category":"undetected"}}}}}`,
+			score:      50,
+			categories: "malware,phishing",
+		},
+		{provider: ProviderVirusTotal, header: "x-apikey", body: `{"data":{"attributes":{}}}`, score: 100},
+	}
+	for _, test := range tests {
+		t.Run(test.provider, func(t *testing.T) {
+			client := newTestClient(t, test.provider, 6000, func(w http.ResponseWriter, req *http.Request) {
+				if req.Header.Get(test.header) != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if !strings.Contains(req.URL.String(), "192.0.2.1") {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, test.body)
+			})
+			report, err := client.Lookup(context.Background(), "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.IP != "192.0.2.1" || report.Provider != test.provider || report.Score != test.score || strings.Join(report.Categories, ",") != test.categories {
+				t.Errorf("got report %+v, want score %d and categories %s", report, test.score, test.categories)
+			}
+		})
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		requests int32
+		err      string
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, requests: 1, err: "abuseipdb returned status 401"},
+		// the backoff gives up after the last attempt
+		{name: "rate limited", status: http.StatusTooManyRequests, requests: maxAttempts, err: "abuseipdb returned status 429"},
+		{name: "invalid json", status: http.StatusOK, body: "{", requests: 1, err: "could not decode response"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			client := newTestClient(t, ProviderAbuseIPDB, 6000, func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			})
+			_, err := client.Lookup(context.Background(), "192.0.2.1")
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+			if got := atomic.LoadInt32(&requests); got != test.requests {
+				t.Errorf("got %d requests, want %d", got, test.requests)
+			}
+		})
+	}
+}
+
+// TestLookupRetryAfter checks that the requests answered with 429 wait for the delay of the
+// Retry-After header, the other lookups included
+func TestLookupRetryAfter(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, ProviderAbuseIPDB, 6000, func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"abuseConfidenceScore":0}}`)
+	})
+	start := time.Now()
+	if _, err := client.Lookup(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Lookup(context.Background(), "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("the lookups took %s, want at least the second of the Retry-After header", elapsed)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestLookupRateLimit(t *testing.T) {
+	// 600 requests per minute are one every 100ms
+	client := newTestClient(t, ProviderAbuseIPDB, 600, func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"data":{"abuseConfidenceScore":0}}`)
+	})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Lookup(context.Background(), "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 lookups took %s, want at least 200ms", elapsed)
+	}
+
+	// the lookups waiting for the rate limit return once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	client.pause(time.Minute)
+	if _, err := client.Lookup(ctx, "192.0.2.1"); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %s", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: time.Second},
+		{header: "3", want: 3 * time.Second},
+		{header: "-3", want: time.Second},
+		{header: "invalid", want: time.Second},
+		{header: "86400", want: maxRetryAfter},
+		{header: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0},
+	}
+	for _, test := range tests {
+		if got := retryAfter(test.header, time.Second); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.header, got, test.want)
+		}
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date, time.Second); got < 58*time.Second || got > time.Minute {
+		t.Errorf("%q: got %s, want a minute", date, got)
+	}
+}
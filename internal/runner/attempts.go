@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// attemptsBuffer is the number of query timings queued for a consumer
+const attemptsBuffer = 1 << 16
+
+// attemptQueue hands the query timings to a consumer running in a dedicated goroutine, the
+// timings arriving while the queue is full are dropped so that a slow consumer never delays
+// the queries
+type attemptQueue struct {
+	sync.RWMutex
+	closed  bool
+	timings chan dnsx.QueryTiming
+	done    chan struct{}
+	dropped uint64
+}
+
+// newAttemptQueue starts the consumer, flush is called every interval seconds when both are set
+func newAttemptQueue(consume func(dnsx.QueryTiming), flush func(), interval int) *attemptQueue {
+	q := &attemptQueue{
+		timings: make(chan dnsx.QueryTiming, attemptsBuffer),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+
+		var tick <-chan time.Time
+		if flush != nil && interval > 0 {
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case timing, more := <-q.timings:
+				if !more {
+					return
+				}
+				consume(timing)
+			case <-tick:
+				flush()
+			}
+		}
+	}()
+	return q
+}
+
+// record queues the timing of a query, it's dropped when the consumer can't keep up
+func (q *attemptQueue) record(timing dnsx.QueryTiming) {
+	q.RLock()
+	defer q.RUnlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.timings <- timing:
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+	}
+}
+
+// close waits for the queued timings to be consumed and returns the number of dropped ones
+func (q *attemptQueue) close() uint64 {
+	q.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.timings)
+	}
+	q.Unlock()
+	<-q.done
+	return atomic.LoadUint64(&q.dropped)
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// suspiciousAuthorities returns the authority records claiming a zone which is not on the
+// delegation path of the host or of the CNAME targets of its answers
+func suspiciousAuthorities(host string, metadata *dnsx.Metadata) []string {
+	names := []string{dns.Fqdn(strings.ToLower(host))}
+	for _, answer := range metadata.Answers {
+		if cname, ok := answer.(*dns.CNAME); ok {
+			names = append(names, dns.Fqdn(strings.ToLower(cname.Target)))
+		}
+	}
+
+	var suspicious []string
+	for _, authority := range metadata.Authorities {
+		switch authority.(type) {
+		case *dns.NS, *dns.SOA:
+		default:
+			continue
+		}
+		zone := dns.Fqdn(strings.ToLower(authority.Header().Name))
+		onPath := false
+		for _, name := range names {
+			if dns.IsSubDomain(zone, name) {
+				onPath = true
+				break
+			}
+		}
+		if !onPath {
+			suspicious = append(suspicious, authority.String())
+		}
+	}
+	return suspicious
+}
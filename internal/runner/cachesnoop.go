@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"time"
+
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+const (
+	cacheStatusCached    = "CACHED"
+	cacheStatusNotCached = "NOT_CACHED"
+)
+
+// cacheSnoop checks whether the resolvers have the host in their cache and emits the outcome,
+// the host is never resolved with recursion so that the check doesn't fill the caches
+func (r *Runner) cacheSnoop(domain, input string) {
+	cachedBy, err := r.dnsx.CacheSnoop(domain, r.dnsx.Options.QuestionTypes[0])
+	if err != nil {
+		if r.outputsUnmatched {
+			r.emitFailure(domain, input, err)
+		}
+		return
+	}
+	result := r.newResult(&retryabledns.DNSData{Host: domain, Timestamp: time.Now()})
+	result.Input = input
+	result.CacheStatus = cacheStatusNotCached
+	if len(cachedBy) > 0 {
+		result.CacheStatus = cacheStatusCached
+		result.CachedBy = cachedBy
+	}
+	key := r.outputKey(domain, input)
+	r.emit(&outputEvent{result: result, status: statusMatched, lines: []string{key + r.field(result.CacheStatus)}})
+}
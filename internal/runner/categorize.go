@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"gopkg.in/yaml.v2"
+)
+
+// Answer types of the categorization providers
+const (
+	categoryTypeA   = "a"
+	categoryTypeTXT = "txt"
+)
+
+// categorizeConfig is the configuration of the dns categorization providers
+type categorizeConfig struct {
+	Providers []struct {
+		// Name identifies the provider in the results
+		Name string `yaml:"name"`
+		// Zone is the zone the hosts are queried under (host.zone)
+		Zone string `yaml:"zone"`
+		// Resolver is a filtering resolver the hosts are queried on, instead of a zone
+		Resolver string `yaml:"resolver"`
+		// Type is the answer type holding the categories (a, txt), a by default
+		Type string `yaml:"type"`
+		// Categories maps the A answers to category names, the other answers are ignored
+		Categories map[string]string `yaml:"categories"`
+	} `yaml:"providers"`
+}
+
+// categoryProvider is a dns categorization service
+type categoryProvider struct {
+	name       string
+	zone       string
+	kind       string
+	categories map[string]string
+	// client queries the filtering resolver of the provider, nil for zone providers
+	client *dnsx.DNSX
+}
+
+// categorizer looks up the categories of the hosts on the configured providers
+type categorizer struct {
+	providers []*categoryProvider
+}
+
+// newCategorizer loads the providers of the config, the ones querying a filtering resolver
+// get their own client built from the options of the run
+func newCategorizer(path string, dnsxOptions dnsx.Options) (*categorizer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config categorizeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "could not parse categorize config")
+	}
+	if len(config.Providers) == 0 {
+		return nil, errors.New("no categorization providers configured")
+	}
+
+	c := &categorizer{}
+	for _, item := range config.Providers {
+		provider := &categoryProvider{
+			name:       item.Name,
+			zone:       strings.ToLower(strings.Trim(item.Zone, ".")),
+			kind:       strings.ToLower(item.Type),
+			categories: item.Categories,
+		}
+		if provider.name == "" {
+			return nil, errors.New("categorization provider without name")
+		}
+		if provider.kind == "" {
+			provider.kind = categoryTypeA
+		}
+		if provider.kind != categoryTypeA && provider.kind != categoryTypeTXT {
+			return nil, errors.Errorf("invalid type %s of provider %s (allowed: %s, %s)", item.Type, item.Name, categoryTypeA, categoryTypeTXT)
+		}
+		if provider.kind == categoryTypeA && len(provider.categories) == 0 {
+			return nil, errors.Errorf("provider %s of type a has no categories", item.Name)
+		}
+		switch {
+		case provider.zone != "" && item.Resolver != "":
+			return nil, errors.Errorf("provider %s can't have both a zone and a resolver", item.Name)
+		case provider.zone != "":
+		case item.Resolver != "":
+			if provider.kind != categoryTypeA {
+				return nil, errors.Errorf("provider %s querying a resolver must be of type a", item.Name)
+			}
+			resolvers, _ := parseResolvers([]string{item.Resolver})
+			if len(resolvers) == 0 {
+				return nil, errors.Errorf("invalid resolver %s of provider %s", item.Resolver, item.Name)
+			}
+			options := dnsxOptions
+			options.BaseResolvers = resolvers
+			options.ZoneOverrides = nil
+			options.OnAttempt = nil
+			options.QuestionTypes = []uint16{dns.TypeA}
+			provider.client, err = dnsx.New(options)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.Errorf("provider %s has neither a zone nor a resolver", item.Name)
+		}
+		c.providers = append(c.providers, provider)
+	}
+	return c, nil
+}
+
+// categorize returns the categories of the host by provider, the providers not categorizing
+// the host are omitted
+func (r *Runner) categorize(host string) map[string][]string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	categories := make(map[string][]string)
+	for _, provider := range r.categorizer.providers {
+		client, name := r.dnsx, host
+		if provider.client != nil {
+			client = provider.client
+		} else {
+			name = host + "." + provider.zone
+		}
+		questionType := dns.TypeA
+		if provider.kind == categoryTypeTXT {
+			questionType = dns.TypeTXT
+		}
+		msg, err := client.QueryMsg(name, questionType)
+		if err != nil || msg == nil {
+			continue
+		}
+		if values := provider.categoriesOf(msg.Answer); len(values) > 0 {
+			categories[provider.name] = values
+		}
+	}
+	if len(categories) == 0 {
+		return nil
+	}
+	return categories
+}
+
+// categoriesOf returns the categories of the answers, the TXT values can list several
+// comma separated categories
+func (p *categoryProvider) categoriesOf(answers []dns.RR) []string {
+	var values []string
+	seen := make(map[string]struct{})
+	add := func(value string) {
+		value = strings.TrimSpace(value)
+		if _, ok := seen[value]; ok || value == "" {
+			return
+		}
+		seen[value] = struct{}{}
+		values = append(values, value)
+	}
+	for _, answer := range answers {
+		switch record := answer.(type) {
+		case *dns.A:
+			if p.kind == categoryTypeA {
+				add(p.categories[record.A.String()])
+			}
+		case *dns.TXT:
+			if p.kind == categoryTypeTXT {
+				for _, value := range strings.Split(strings.Join(record.Txt, ""), Comma) {
+					add(value)
+				}
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// largeCIDRSize is the number of addresses above which a cidr (larger than a /24) or a
+// range is only expanded with force-large-cidr or sampled with cidr-sample-density
+const largeCIDRSize = 256
+
+// expandCIDR emits the addresses of the cidr, the large ones are skipped with a warning
+// unless forced or sampled
+func (r *Runner) expandCIDR(target string, emit func(host string)) {
+	_, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	// the addresses are computed for both families, mapcidr only expands the ipv4 cidrs
+	if size.Cmp(big.NewInt(largeCIDRSize)) <= 0 {
+		first := new(big.Int).SetBytes(network.IP)
+		for offset := int64(0); offset < size.Int64(); offset++ {
+			emit(rangeAddress(first, offset, len(network.IP)))
+		}
+		return
+	}
+
+	r.expandLargeRange(target, network.IP, size, emit)
+}
+
+// expandRange emits the addresses of a start-end range (192.168.1.1-192.168.1.254 or
+// 192.168.1.1-254), the large ones are handled like the large cidrs
+func (r *Runner) expandRange(target string, emit func(host string)) {
+	start, end, ok := parseIPRange(target)
+	if !ok {
+		return
+	}
+	first, last := new(big.Int).SetBytes(start), new(big.Int).SetBytes(end)
+	size := new(big.Int).Sub(last, first)
+	size.Add(size, big.NewInt(1))
+	if size.Cmp(big.NewInt(largeCIDRSize)) <= 0 {
+		for offset := int64(0); offset < size.Int64(); offset++ {
+			emit(rangeAddress(first, offset, len(start)))
+		}
+		return
+	}
+	r.expandLargeRange(target, start, size, emit)
+}
+
+// expandLargeRange emits the addresses of a range larger than a /24 from its first address,
+// sampled with cidr-sample-density, all of them with force-large-cidr or none
+func (r *Runner) expandLargeRange(target string, start net.IP, size *big.Int, emit func(host string)) {
+	first := new(big.Int).SetBytes(start)
+	switch {
+	case r.options.CIDRSampleDensity > 1:
+		gologger.Warning().Msgf("Sampling 1 in %d addresses of %s (%s addresses)\n", r.options.CIDRSampleDensity, target, size)
+		density := int64(r.options.CIDRSampleDensity)
+		step := big.NewInt(density)
+		// one random address of every block of density consecutive addresses, the other
+		// addresses are never iterated
+		for offset := new(big.Int); offset.Cmp(size) < 0; offset.Add(offset, step) {
+			block := density
+			if remaining := new(big.Int).Sub(size, offset); remaining.Cmp(step) < 0 {
+				block = remaining.Int64()
+			}
+			address := new(big.Int).Add(first, offset)
+			emit(rangeAddress(address, rand.Int63n(block), len(start)))
+		}
+	case r.options.ForceLargeCIDR:
+		gologger.Warning().Msgf("Expanding %s larger than /24 (%s addresses)\n", target, size)
+		for offset := new(big.Int); offset.Cmp(size) < 0; offset.Add(offset, big.NewInt(1)) {
+			emit(rangeAddress(new(big.Int).Add(first, offset), 0, len(start)))
+		}
+	default:
+		gologger.Warning().Msgf("Skipping %s: range larger than /24 (%s addresses), use -force-large-cidr or -cidr-sample-density\n", target, size)
+	}
+}
+
+// rangeAddress returns the address at the offset of the base address, of length octets
+func rangeAddress(base *big.Int, offset int64, length int) string {
+	address := new(big.Int).Add(base, big.NewInt(offset))
+	ip := make(net.IP, length)
+	address.FillBytes(ip)
+	return ip.String()
+}
+
+// parseIPRange parses a start-end range of addresses of the same family, the end can be
+// reduced to the last octet of an ipv4 range (192.168.1.1-254)
+func parseIPRange(target string) (net.IP, net.IP, bool) {
+	parts := strings.SplitN(target, "-", 2)
+	if len(parts) != 2 {
+		return nil, nil, false
+	}
+	start := net.ParseIP(strings.TrimSpace(parts[0]))
+	if start == nil {
+		return nil, nil, false
+	}
+	last := strings.TrimSpace(parts[1])
+	end := net.ParseIP(last)
+	if start4 := start.To4(); start4 != nil {
+		start = start4
+		if end == nil {
+			octet, err := strconv.Atoi(last)
+			if err != nil || octet < 0 || octet > 255 {
+				return nil, nil, false
+			}
+			end = net.IPv4(start[0], start[1], start[2], byte(octet))
+		}
+		if end = end.To4(); end == nil {
+			return nil, nil, false
+		}
+	} else if end == nil || end.To4() != nil {
+		return nil, nil, false
+	}
+	if bytes.Compare(start, end) > 0 {
+		return nil, nil, false
+	}
+	return start, end, true
+}
+
+// isIPRange reports whether the target is a start-end range of addresses
+func isIPRange(target string) bool {
+	_, _, ok := parseIPRange(target)
+	return ok
+}
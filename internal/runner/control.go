@@ -0,0 +1,280 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"go.uber.org/ratelimit"
+)
+
+// controlServer exposes runtime controls of the runner over a unix socket.
+// Access is restricted by the socket file permissions only, the commands other
+// than status require a POST request.
+type controlServer struct {
+	path     string
+	listener net.Listener
+	server   *http.Server
+}
+
+// controlStatus is the response of the status command
+type controlStatus struct {
+	Paused        bool     `json:"paused"`
+	Threads       int      `json:"threads"`
+	RateLimit     int      `json:"rate_limit"`
+	QuestionTypes []string `json:"question_types"`
+	Hosts         uint64   `json:"hosts,omitempty"`
+	Requests      uint64   `json:"requests,omitempty"`
+	Total         uint64   `json:"total,omitempty"`
+}
+
+func (r *Runner) startControlServer() error {
+	path := r.options.ControlSocket
+	if info, err := os.Stat(path); err == nil {
+		// only stale sockets are removed, regular files are never overwritten
+		if info.Mode()&os.ModeSocket == 0 {
+			return errors.Errorf("control socket path %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	listener, err := listenControlSocket(path)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", r.handleControlStatus)
+	mux.HandleFunc("/pause", postOnly(r.handleControlPause))
+	mux.HandleFunc("/resume", postOnly(r.handleControlResume))
+	mux.HandleFunc("/rate-limit", postOnly(r.handleControlRateLimit))
+	mux.HandleFunc("/threads", postOnly(r.handleControlThreads))
+	mux.HandleFunc("/resolvers", postOnly(r.handleControlResolvers))
+
+	control := &controlServer{path: path, listener: listener, server: &http.Server{Handler: mux}}
+	r.controlmutex.Lock()
+	r.control = control
+	r.controlmutex.Unlock()
+	go func() {
+		if err := control.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			gologger.Warning().Msgf("Control socket stopped: %s\n", err)
+		}
+	}()
+	gologger.Info().Msgf("Control socket listening on %s\n", path)
+	return nil
+}
+
+// listenControlSocket creates the socket in a private directory next to the path and moves
+// it to the path once restricted to the owner, the socket is never reachable with the
+// permissions left by the umask
+func listenControlSocket(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".dnsx-control-")
+	if err != nil {
+		return nil, err
+	}
+	// nolint:errcheck
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "control.sock")
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(private, 0600); err == nil {
+		err = os.Rename(private, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// postOnly answers the requests of the command made with another method than POST with a 405
+func postOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+func (r *Runner) stopControlServer() {
+	r.controlmutex.Lock()
+	defer r.controlmutex.Unlock()
+	if r.control == nil {
+		return
+	}
+	// nolint:errcheck
+	r.control.server.Close()
+	// nolint:errcheck
+	os.Remove(r.control.path)
+	r.control = nil
+}
+
+func (r *Runner) handleControlStatus(w http.ResponseWriter, _ *http.Request) {
+	status := controlStatus{
+		Paused:    r.isPaused(),
+		Threads:   int(atomic.LoadInt32(&r.threads)),
+		RateLimit: int(atomic.LoadInt32(&r.ratelimit)),
+	}
+	for _, questionType := range r.dnsx.Options.QuestionTypes {
+		status.QuestionTypes = append(status.QuestionTypes, dns.TypeToString[questionType])
+	}
+	if r.stats != nil {
+		status.Hosts, _ = r.stats.GetCounter("hosts")
+		status.Requests, _ = r.stats.GetCounter("requests")
+		status.Total, _ = r.stats.GetCounter("total")
+	}
+	writeControlResponse(w, status)
+}
+
+func (r *Runner) handleControlPause(w http.ResponseWriter, _ *http.Request) {
+	r.pause()
+	writeControlResponse(w, map[string]bool{"paused": true})
+}
+
+func (r *Runner) handleControlResume(w http.ResponseWriter, _ *http.Request) {
+	r.resume()
+	writeControlResponse(w, map[string]bool{"paused": false})
+}
+
+func (r *Runner) handleControlRateLimit(w http.ResponseWriter, req *http.Request) {
+	value, err := strconv.Atoi(req.URL.Query().Get("value"))
+	if err != nil {
+		http.Error(w, "invalid rate limit value", http.StatusBadRequest)
+		return
+	}
+	r.setRateLimit(value)
+	writeControlResponse(w, map[string]int{"rate_limit": value})
+}
+
+func (r *Runner) handleControlThreads(w http.ResponseWriter, req *http.Request) {
+	value, err := strconv.Atoi(req.URL.Query().Get("value"))
+	if err != nil || value <= 0 {
+		http.Error(w, "invalid threads value", http.StatusBadRequest)
+		return
+	}
+	r.setThreads(value)
+	writeControlResponse(w, map[string]int{"threads": value})
+}
+
+func (r *Runner) handleControlResolvers(w http.ResponseWriter, _ *http.Request) {
+	writeControlResponse(w, r.dnsx.ResolverHealth())
+}
+
+// reportServerCapabilities logs the capabilities observed for each resolver which answered
+func (r *Runner) reportServerCapabilities() {
+	for _, health := range r.dnsx.ResolverHealth() {
+		if health.Queries == 0 || health.ServerCapabilities == nil {
+			continue
+		}
+		data, err := json.Marshal(health.ServerCapabilities)
+		if err != nil {
+			continue
+		}
+		gologger.Info().Msgf("%s server_capabilities: %s\n", health.Resolver, data)
+	}
+}
+
+func writeControlResponse(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	// nolint:errcheck
+	json.NewEncoder(w).Encode(v)
+}
+
+// pause blocks the resolve workers before their next query
+func (r *Runner) pause() {
+	r.pausestatemutex.Lock()
+	defer r.pausestatemutex.Unlock()
+	if !r.paused {
+		r.paused = true
+		r.pausemutex.Lock()
+	}
+}
+
+// resume releases the resolve workers
+func (r *Runner) resume() {
+	r.pausestatemutex.Lock()
+	defer r.pausestatemutex.Unlock()
+	if r.paused {
+		r.paused = false
+		r.pausemutex.Unlock()
+	}
+}
+
+func (r *Runner) isPaused() bool {
+	r.pausestatemutex.Lock()
+	defer r.pausestatemutex.Unlock()
+	return r.paused
+}
+
+// waitIfPaused blocks the caller while the runner is paused
+func (r *Runner) waitIfPaused() {
+	r.pausemutex.RLock()
+	// nolint:staticcheck
+	r.pausemutex.RUnlock()
+}
+
+// setRateLimit replaces the current limiter, values <= 0 disable rate limiting
+func (r *Runner) setRateLimit(value int) {
+	limiter := ratelimit.NewUnlimited()
+	if value > 0 {
+		limiter = ratelimit.New(value)
+	}
+	r.limitermutex.Lock()
+	r.limiter = limiter
+	r.limitermutex.Unlock()
+	atomic.StoreInt32(&r.ratelimit, int32(value))
+}
+
+// takeLimiter blocks until the current limiter allows a new request
+func (r *Runner) takeLimiter() {
+	r.limitermutex.RLock()
+	limiter := r.limiter
+	r.limitermutex.RUnlock()
+	limiter.Take()
+}
+
+// setThreads spawns or stops resolve workers to match the requested count
+func (r *Runner) setThreads(value int) {
+	r.threadsmutex.Lock()
+	defer r.threadsmutex.Unlock()
+	current := int(atomic.LoadInt32(&r.threads))
+	if value > current {
+		for i := current; i < value; i++ {
+			r.wgresolveworkers.Add(1)
+			go r.worker()
+		}
+	} else {
+		// workers exit before picking their next item
+		atomic.AddInt32(&r.pendingstops, int32(current-value))
+	}
+	atomic.StoreInt32(&r.threads, int32(value))
+}
+
+// shouldStopWorker consumes a pending worker stop request if any
+func (r *Runner) shouldStopWorker() bool {
+	for {
+		pending := atomic.LoadInt32(&r.pendingstops)
+		if pending <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&r.pendingstops, pending, pending-1) {
+			return true
+		}
+	}
+}
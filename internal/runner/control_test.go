@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newControlClient returns a client sending the requests to the control socket
+func newControlClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestControlServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the control socket permissions require a posix system")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "control.sock")
+	r := newConfiguredRunner(t, newTestDNSServer(t, answerA), func(options *Options) {
+		options.ControlSocket = path
+	}, func(*Result) {})
+	defer r.Close()
+	if err := r.startControlServer(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.stopControlServer()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("got mode %s, want a socket with 0600", info.Mode())
+	}
+	// the private directory the socket was created in is removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d entries in the socket directory, want 1", len(entries))
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: http.MethodGet, path: "/status", status: http.StatusOK},
+		{method: http.MethodGet, path: "/pause", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/resume", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/rate-limit?value=10", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPut, path: "/threads?value=2", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/resolvers", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/pause", status: http.StatusOK},
+		{method: http.MethodPost, path: "/resume", status: http.StatusOK},
+		{method: http.MethodPost, path: "/rate-limit?value=10", status: http.StatusOK},
+		{method: http.MethodPost, path: "/threads?value=x", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/resolvers", status: http.StatusOK},
+	}
+	client := newControlClient(path)
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, "http://dnsx"+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.path, resp.StatusCode, test.status)
+		}
+		if resp.StatusCode == http.StatusMethodNotAllowed && resp.Header.Get("Allow") != http.MethodPost {
+			t.Errorf("%s %s: got allow header %q", test.method, test.path, resp.Header.Get("Allow"))
+		}
+	}
+	if r.isPaused() {
+		t.Error("the runner is paused")
+	}
+	client.CloseIdleConnections()
+}
+
+func TestControlServerExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := newConfiguredRunner(t, newTestDNSServer(t, answerA), func(options *Options) {
+		options.ControlSocket = path
+	}, func(*Result) {})
+	defer r.Close()
+	if err := r.startControlServer(); err == nil || !strings.Contains(err.Error(), "is not a socket") {
+		t.Fatalf("got error %v, want the path rejected", err)
+	}
+}
@@ -0,0 +1,357 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+// coordinatorPoll is the delay of the workers between the requests of a job when all the
+// remaining jobs are leased to other workers
+const coordinatorPoll = time.Second
+
+// jobHost is a host of a job along with the input line it comes from
+type jobHost struct {
+	Host  string `json:"host"`
+	Input string `json:"input,omitempty"`
+}
+
+// job is a chunk of the input resolved by a worker
+type job struct {
+	ID    int       `json:"id"`
+	Hosts []jobHost `json:"hosts"`
+
+	leasedUntil time.Time
+	done        bool
+}
+
+// jobEvent is an output event of a worker sent back to the coordinator
+type jobEvent struct {
+	Status resultStatus `json:"status"`
+	Lines  []string     `json:"lines,omitempty"`
+	Zone   []string     `json:"zone,omitempty"`
+	Result *dnsResult   `json:"result,omitempty"`
+}
+
+// jobQueue holds the jobs of the coordinator, the jobs whose lease expires before their
+// results are received are handed to the next worker asking for a job
+type jobQueue struct {
+	sync.Mutex
+	jobs      []*job
+	remaining int
+	lease     time.Duration
+	finished  chan struct{}
+	saving    sync.Mutex
+}
+
+// next returns the next job to resolve, nil with done set when all the jobs are completed
+func (q *jobQueue) next() (next *job, done bool) {
+	q.Lock()
+	defer q.Unlock()
+	if q.remaining == 0 {
+		return nil, true
+	}
+	now := time.Now()
+	for _, job := range q.jobs {
+		if !job.done && now.After(job.leasedUntil) {
+			job.leasedUntil = now.Add(q.lease)
+			return job, false
+		}
+	}
+	return nil, false
+}
+
+// completed marks the jobs completed by a previous coordinator as done, before the jobs are
+// served
+func (q *jobQueue) completed(ids []int) {
+	for _, id := range ids {
+		if id >= 0 && id < len(q.jobs) && !q.jobs[id].done {
+			q.jobs[id].done = true
+			q.remaining--
+		}
+	}
+}
+
+// complete marks the job as done, the results of jobs already completed by another worker
+// are rejected
+func (q *jobQueue) complete(id int) bool {
+	q.Lock()
+	defer q.Unlock()
+	if id < 0 || id >= len(q.jobs) || q.jobs[id].done {
+		return false
+	}
+	q.jobs[id].done = true
+	q.remaining--
+	if q.remaining == 0 {
+		close(q.finished)
+	}
+	return true
+}
+
+// runCoordinator splits the input into jobs served over http to the workers and writes the
+// results they send back to the outputs. It returns once every job is completed.
+func (r *Runner) runCoordinator() error {
+	queue, err := r.coordinatorJobs()
+	if err != nil {
+		return err
+	}
+	if queue.remaining == 0 {
+		return r.removeCoordinatorResume()
+	}
+
+	listener, err := net.Listen("tcp", r.options.Coordinator)
+	if err != nil {
+		return err
+	}
+	if err := r.startOutputWorker(); err != nil {
+		listener.Close()
+		return err
+	}
+	defer r.closeOutputWorker()
+
+	server := &http.Server{Handler: r.coordinatorHandler(queue)}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			gologger.Warning().Msgf("Coordinator stopped: %s\n", err)
+		}
+	}()
+	defer server.Close()
+	gologger.Info().Msgf("Coordinator listening on %s with %d jobs\n", listener.Addr(), queue.remaining)
+
+	<-queue.finished
+	// the workers asking for the next job or polling are told that every job is completed
+	// before the server is closed
+	time.Sleep(2 * coordinatorPoll)
+	return r.removeCoordinatorResume()
+}
+
+// coordinatorJobs splits the input into jobs, the jobs completed by the previous coordinator
+// are skipped when resuming with the same job size
+func (r *Runner) coordinatorJobs() (*jobQueue, error) {
+	queue := &jobQueue{lease: r.options.jobLease, finished: make(chan struct{})}
+	var current *job
+	err := r.prepareInput(func(host, input string) {
+		if current == nil || len(current.Hosts) >= r.options.JobSize {
+			current = &job{ID: len(queue.jobs)}
+			queue.jobs = append(queue.jobs, current)
+		}
+		current.Hosts = append(current.Hosts, jobHost{Host: host, Input: input})
+	})
+	if err != nil {
+		return nil, err
+	}
+	queue.remaining = len(queue.jobs)
+
+	r.resumemutex.Lock()
+	defer r.resumemutex.Unlock()
+	resumeCfg := r.options.resumeCfg
+	if resumeCfg.CompletedJobs != "" {
+		if resumeCfg.JobSize == r.options.JobSize {
+			var ids []int
+			for _, value := range strings.Split(resumeCfg.CompletedJobs, Comma) {
+				if id, err := strconv.Atoi(value); err == nil {
+					ids = append(ids, id)
+				}
+			}
+			queue.completed(ids)
+			gologger.Info().Msgf("Resuming coordinator, %d of %d jobs completed\n", len(queue.jobs)-queue.remaining, len(queue.jobs))
+		} else {
+			gologger.Warning().Msgf("Resume file %s was written with another job size, serving every job\n", DefaultResumeFile)
+			resumeCfg.CompletedJobs = ""
+		}
+	}
+	resumeCfg.JobSize = r.options.JobSize
+	return queue, nil
+}
+
+// coordinatorHandler serves the jobs of the queue to the workers sending the token
+func (r *Runner) coordinatorHandler(queue *jobQueue) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/job", func(w http.ResponseWriter, req *http.Request) {
+		r.handleJobRequest(queue, w, req)
+	})
+	mux.HandleFunc("/job/", func(w http.ResponseWriter, req *http.Request) {
+		r.handleJobResults(queue, w, req)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(r.options.CoordinatorToken)) != 1 {
+			http.Error(w, "invalid coordinator token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// saveCompletedJob adds the job to the resume file, so that a coordinator restarted with
+// -resume doesn't serve it again
+func (r *Runner) saveCompletedJob(queue *jobQueue, id int) {
+	// the writes of the resume file are serialized, the last one has every completed job
+	queue.saving.Lock()
+	defer queue.saving.Unlock()
+	r.resumemutex.Lock()
+	if r.options.resumeCfg.CompletedJobs != "" {
+		r.options.resumeCfg.CompletedJobs += Comma
+	}
+	r.options.resumeCfg.CompletedJobs += strconv.Itoa(id)
+	r.resumemutex.Unlock()
+	if err := r.SaveResumeConfig(); err != nil {
+		gologger.Warning().Msgf("Could not save completed job %d to %s: %s\n", id, DefaultResumeFile, err)
+	}
+}
+
+// removeCoordinatorResume removes the resume file once every job is completed
+func (r *Runner) removeCoordinatorResume() error {
+	if err := os.Remove(DefaultResumeFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// handleJobRequest leases the next job: 200 with the job, 202 when the remaining jobs are
+// leased to other workers and 204 once every job is completed
+func (r *Runner) handleJobRequest(queue *jobQueue, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, done := queue.next()
+	switch {
+	case done:
+		w.WriteHeader(http.StatusNoContent)
+	case job == nil:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(job)
+	}
+}
+
+// handleJobResults receives the output events of a job and sends them to the outputs
+func (r *Runner) handleJobResults(queue *jobQueue, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/job/"))
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	var events []jobEvent
+	if err := json.NewDecoder(req.Body).Decode(&events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !queue.complete(id) {
+		http.Error(w, "job already completed", http.StatusConflict)
+		return
+	}
+	r.saveCompletedJob(queue, id)
+	for _, event := range events {
+		r.emit(&outputEvent{result: event.Result, status: event.Status, lines: event.Lines, zone: parseZoneRecords(event.Zone)})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jobCollector gathers the output events of the job being resolved by a worker
+type jobCollector struct {
+	sync.Mutex
+	events []jobEvent
+}
+
+func (c *jobCollector) add(event *outputEvent) {
+	c.Lock()
+	defer c.Unlock()
+	c.events = append(c.events, jobEvent{Status: event.status, Lines: event.lines, Zone: formatZoneRecords(event.zone), Result: event.result})
+}
+
+// runWorker resolves the jobs of the coordinator until all of them are completed
+func (r *Runner) runWorker() error {
+	base := strings.TrimSuffix(r.options.Worker, "/")
+	client := &http.Client{Timeout: time.Minute}
+	post := func(url string, body []byte) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+r.options.CoordinatorToken)
+		return client.Do(req)
+	}
+	for {
+		resp, err := post(base+"/job", nil)
+		if err != nil {
+			return errors.Wrap(err, "could not request job")
+		}
+		var next job
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&next)
+		case http.StatusAccepted:
+		case http.StatusNoContent:
+			resp.Body.Close()
+			return nil
+		default:
+			err = errors.Errorf("unexpected coordinator status %s", resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusAccepted {
+			time.Sleep(coordinatorPoll)
+			continue
+		}
+
+		events := r.resolveJob(&next)
+		body, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		resp, err = post(base+"/job/"+strconv.Itoa(next.ID), body)
+		if err != nil {
+			return errors.Wrap(err, "could not send job results")
+		}
+		_, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		// the job was completed by another worker after the lease expired
+		if resp.StatusCode == http.StatusConflict {
+			gologger.Warning().Msgf("Results of job %d discarded, it was completed by another worker\n", next.ID)
+		} else if resp.StatusCode != http.StatusNoContent {
+			return errors.Errorf("unexpected coordinator status %s", resp.Status)
+		}
+	}
+}
+
+// resolveJob resolves the hosts of the job and returns their output events
+func (r *Runner) resolveJob(next *job) []jobEvent {
+	collector := &jobCollector{}
+	r.collector = collector
+	defer func() {
+		r.collector = nil
+	}()
+
+	r.workerchan = make(chan inputItem)
+	for i := 0; i < r.options.Threads; i++ {
+		r.wgresolveworkers.Add(1)
+		go r.worker()
+	}
+	for _, host := range next.Hosts {
+		r.sendItem(inputItem{input: host.Input, host: host.Host})
+	}
+	close(r.workerchan)
+	r.wgresolveworkers.Wait()
+	return collector.events
+}
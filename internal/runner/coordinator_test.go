@@ -0,0 +1,191 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/goconfig"
+)
+
+var coordinatorTargets = []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com", "f.example.com"}
+
+// newTestCoordinator returns a coordinator of the targets split in jobs of two hosts and the
+// server of its jobs
+func newTestCoordinator(t *testing.T, server string, configure func(*Options)) (*Runner, *jobQueue, *httptest.Server) {
+	t.Helper()
+	r := newConfiguredRunner(t, server, func(options *Options) {
+		options.Targets = coordinatorTargets
+		options.Coordinator = "127.0.0.1:0"
+		options.CoordinatorToken = "secret"
+		options.JobSize = 2
+		configure(options)
+	}, func(*Result) {})
+	t.Cleanup(r.Close)
+	r.prepareRun()
+	queue, err := r.coordinatorJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coordinator := httptest.NewServer(r.coordinatorHandler(queue))
+	t.Cleanup(coordinator.Close)
+	return r, queue, coordinator
+}
+
+// postJob sends the request to the coordinator with the authorization header and returns
+// the status of the response
+func postJob(t *testing.T, url, authorization, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestCoordinatorToken(t *testing.T) {
+	inTempDir(t)
+	_, _, coordinator := newTestCoordinator(t, newTestDNSServer(t, answerA), func(*Options) {})
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		body          string
+		status        int
+	}{
+		{name: "job without token", path: "/job", status: http.StatusUnauthorized},
+		{name: "job with another token", path: "/job", authorization: "Bearer other", status: http.StatusUnauthorized},
+		{name: "results without token", path: "/job/0", body: `[{"status":0,"lines":["forged.example.com"]}]`, status: http.StatusUnauthorized},
+		{name: "job", path: "/job", authorization: "Bearer secret", status: http.StatusOK},
+		{name: "results", path: "/job/0", authorization: "Bearer secret", body: "[]", status: http.StatusNoContent},
+	}
+	for _, test := range tests {
+		if status := postJob(t, coordinator.URL+test.path, test.authorization, test.body); status != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, status, test.status)
+		}
+	}
+}
+
+// TestCoordinatorResume checks that the completed jobs are written to the resume file and
+// skipped by a coordinator resuming with the same job size
+func TestCoordinatorResume(t *testing.T) {
+	inTempDir(t)
+	server := newTestDNSServer(t, answerA)
+	_, _, coordinator := newTestCoordinator(t, server, func(*Options) {})
+	for _, id := range []int{2, 0} {
+		if status := postJob(t, fmt.Sprintf("%s/job/%d", coordinator.URL, id), "Bearer secret", "[]"); status != http.StatusNoContent {
+			t.Fatalf("job %d: got status %d", id, status)
+		}
+	}
+	saved := &ResumeCfg{}
+	if err := goconfig.Load(saved, DefaultResumeFile); err != nil {
+		t.Fatal(err)
+	}
+	if saved.CompletedJobs != "2,0" || saved.JobSize != 2 {
+		t.Fatalf("got completed jobs %q with job size %d", saved.CompletedJobs, saved.JobSize)
+	}
+
+	tests := []struct {
+		name    string
+		jobSize int
+		jobs    []int
+	}{
+		{name: "same job size", jobSize: 2, jobs: []int{1}},
+		// the ids of the jobs depend on their size
+		{name: "other job size", jobSize: 3, jobs: []int{0, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, queue, _ := newTestCoordinator(t, server, func(options *Options) {
+				options.Resume = true
+				options.JobSize = test.jobSize
+			})
+			var jobs []int
+			for next, done := queue.next(); next != nil && !done; next, done = queue.next() {
+				jobs = append(jobs, next.ID)
+			}
+			if fmt.Sprint(jobs) != fmt.Sprint(test.jobs) {
+				t.Errorf("got jobs %v, want %v", jobs, test.jobs)
+			}
+		})
+	}
+}
+
+func TestCoordinatorWorkers(t *testing.T) {
+	inTempDir(t)
+	server := newTestDNSServer(t, answerA)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var hosts []string
+	coordinator := newConfiguredRunner(t, server, func(options *Options) {
+		options.Targets = coordinatorTargets
+		options.Coordinator = address
+		options.CoordinatorToken = "secret"
+		options.JobSize = 2
+	}, func(result *Result) {
+		hosts = append(hosts, result.Host)
+	})
+	defer coordinator.Close()
+	done := make(chan error)
+	go func() {
+		done <- coordinator.Run()
+	}()
+	// the workers start once the coordinator listens
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("coordinator not listening")
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		worker := newConfiguredRunner(t, server, func(options *Options) {
+			options.Worker = "http://" + address
+			options.CoordinatorToken = "secret"
+			options.JSON = true
+		}, func(*Result) {})
+		defer worker.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := worker.Run(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(hosts)
+	if fmt.Sprint(hosts) != fmt.Sprint(coordinatorTargets) {
+		t.Errorf("got results %v, want %v", hosts, coordinatorTargets)
+	}
+	if _, err := os.Stat(DefaultResumeFile); !os.IsNotExist(err) {
+		t.Errorf("resume file not removed: %v", err)
+	}
+}
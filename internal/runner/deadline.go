@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+// ErrMaxRuntime is returned by Run when the scan was stopped by the max-runtime deadline
+var ErrMaxRuntime = errors.New("maximum runtime reached")
+
+// maxRuntimeDrain is the time left to the in-flight queries once no new host is scheduled
+const maxRuntimeDrain = 30 * time.Second
+
+// startDeadline arms the max-runtime deadlines of the run: hosts stop being scheduled when
+// the drain time is left and the workers still running at the end are stopped
+func (r *Runner) startDeadline() {
+	atomic.StoreInt32(&r.deadlinereached, 0)
+	r.deadline = nil
+	if r.options.maxRuntime <= 0 {
+		return
+	}
+	drain := maxRuntimeDrain
+	if drain > r.options.maxRuntime/2 {
+		drain = r.options.maxRuntime / 2
+	}
+	r.scheduleUntil = time.Now().Add(r.options.maxRuntime - drain)
+	r.deadline = time.NewTimer(r.options.maxRuntime)
+}
+
+// stopDeadline releases the timer of the run deadline
+func (r *Runner) stopDeadline() {
+	if r.deadline != nil {
+		r.deadline.Stop()
+	}
+}
+
+// pastDeadline reports whether new hosts must not be scheduled anymore
+func (r *Runner) pastDeadline() bool {
+	if r.deadline == nil {
+		return false
+	}
+	if atomic.LoadInt32(&r.deadlinereached) == 1 {
+		return true
+	}
+	if time.Now().Before(r.scheduleUntil) {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&r.deadlinereached, 0, 1) {
+		gologger.Info().Msgf("Max runtime approaching, draining in-flight queries\n")
+	}
+	return true
+}
+
+// ingesting reports whether the input is still read, it stops once the runner is closed or
+// no new host can be scheduled before the deadline
+func (r *Runner) ingesting() bool {
+	return r.workerctx.Err() == nil && !r.pastDeadline()
+}
+
+// waitWorkers waits for the resolve workers to complete, the queries still in flight when
+// the run deadline expires are aborted and their hosts are left for the resume
+func (r *Runner) waitWorkers() {
+	if r.deadline == nil {
+		r.wgresolveworkers.Wait()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		r.wgresolveworkers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-r.deadline.C:
+		atomic.StoreInt32(&r.deadlinereached, 1)
+		gologger.Warning().Msgf("Max runtime reached, stopping the in-flight queries\n")
+		r.workercancel()
+		<-done
+	}
+}
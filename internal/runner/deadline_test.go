@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TestMaxRuntimeResume stops a scan with a short deadline and resumes it, every host is
+// resolved once by one of the two runs
+func TestMaxRuntimeResume(t *testing.T) {
+	inTempDir(t)
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		time.Sleep(20 * time.Millisecond)
+		answerA(w, req)
+	})
+	var targets []string
+	for i := 0; i < 100; i++ {
+		targets = append(targets, fmt.Sprintf("host%d.example.com", i))
+	}
+
+	var (
+		mutex    sync.Mutex
+		resolved = make(map[string]int)
+	)
+	run := func(resume bool) (*Runner, error) {
+		r := newConfiguredRunner(t, server, func(options *Options) {
+			options.Targets = targets
+			options.Threads = 2
+			options.Resume = resume
+			if !resume {
+				options.MaxRuntime = "400ms"
+			}
+		}, func(result *Result) {
+			mutex.Lock()
+			resolved[result.Host]++
+			mutex.Unlock()
+		})
+		return r, r.Run()
+	}
+
+	r, err := run(false)
+	if !errors.Is(err, ErrMaxRuntime) {
+		t.Fatalf("got error %v, want %v", err, ErrMaxRuntime)
+	}
+	first := len(resolved)
+	if first == 0 || first == len(targets) {
+		t.Fatalf("got %d hosts resolved before the deadline", first)
+	}
+	// the input stops being read once no host can be scheduled
+	var ingested int
+	r.hm.Scan(func(_, _ []byte) error { // nolint:errcheck
+		ingested++
+		return nil
+	})
+	if ingested == len(targets) {
+		t.Errorf("the whole input was read after the deadline")
+	}
+	if err := r.SaveResumeConfig(); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	r, err = run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	for _, target := range targets {
+		if resolved[target] != 1 {
+			t.Errorf("%s resolved %d times", target, resolved[target])
+		}
+	}
+}
+
+// TestMaxRuntimeAbort checks that the queries in flight at the deadline are aborted, the run
+// returns right after the deadline instead of waiting for the timeouts of a resolver never
+// answering, and the aborted host isn't output
+func TestMaxRuntimeAbort(t *testing.T) {
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name == "silent.example.com." {
+			return
+		}
+		answerA(w, req)
+	})
+	defer verifyNoLeaks(t)()
+
+	var hosts []string
+	r := newConfiguredRunner(t, server, func(options *Options) {
+		options.Targets = []string{"silent.example.com", "fast.example.com"}
+		options.MaxRuntime = "300ms"
+	}, func(result *Result) {
+		hosts = append(hosts, result.Host)
+	})
+	start := time.Now()
+	if err := r.Run(); !errors.Is(err, ErrMaxRuntime) {
+		t.Fatalf("got error %v, want %v", err, ErrMaxRuntime)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond+500*time.Millisecond {
+		t.Errorf("got run returning after %s, want the deadline of 300ms", elapsed)
+	}
+	if len(hosts) != 1 || hosts[0] != "fast.example.com" {
+		t.Errorf("got results %v, want fast.example.com", hosts)
+	}
+	r.Close()
+}
+
+// TestPanicsRecovered checks that the panics are reported whether the run completed or was
+// stopped by the deadline
+func TestPanicsRecovered(t *testing.T) {
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name == "slow.example.com." {
+			time.Sleep(time.Second)
+		}
+		answerA(w, req)
+	})
+	tests := []struct {
+		name       string
+		targets    []string
+		maxRuntime string
+		err        error
+	}{
+		{name: "completed", targets: []string{"panic.example.com", "fast.example.com"}, err: ErrPanicsRecovered},
+		{name: "deadline", targets: []string{"panic.example.com", "slow.example.com"}, maxRuntime: "300ms", err: ErrMaxRuntime},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.Targets = test.targets
+				options.MaxRuntime = test.maxRuntime
+			}, func(result *Result) {
+				if result.Host == "panic.example.com" {
+					panic("result callback")
+				}
+			})
+			defer r.Close()
+			if err := r.Run(); !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if panics := r.PanicsRecovered(); panics != 1 {
+				t.Errorf("got %d panics recovered, want 1", panics)
+			}
+		})
+	}
+}
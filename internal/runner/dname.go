@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// maxDNAMEDepth bounds the DNAME rewrites followed for a host
+const maxDNAMEDepth = 8
+
+// dnameTargets returns the targets of the DNAME records of the answers
+func dnameTargets(metadata *dnsx.Metadata) []string {
+	var targets []string
+	for _, answer := range metadata.Answers {
+		if record, ok := answer.(*dns.DNAME); ok {
+			targets = append(targets, strings.TrimSuffix(record.Target, "."))
+		}
+	}
+	return uniqueWords(targets)
+}
+
+// dnameRewrite substitutes the owner of the DNAME record covering the name with its target
+// (rfc 6672), the name is returned empty when no answer covers it
+func dnameRewrite(name string, answers []dns.RR) string {
+	fqdn := dns.Fqdn(strings.ToLower(name))
+	for _, answer := range answers {
+		record, ok := answer.(*dns.DNAME)
+		if !ok {
+			continue
+		}
+		owner := dns.Fqdn(strings.ToLower(record.Hdr.Name))
+		// the owner itself isn't redirected, only the names below it
+		if owner == fqdn || !dns.IsSubDomain(owner, fqdn) {
+			continue
+		}
+		rewritten := strings.TrimSuffix(fqdn, owner) + dns.Fqdn(strings.ToLower(record.Target))
+		if _, ok := dns.IsDomainName(rewritten); !ok || len(rewritten) > 255 {
+			continue
+		}
+		return strings.TrimSuffix(rewritten, ".")
+	}
+	return ""
+}
+
+// followDNAME resolves the names substituted by the DNAME records covering the host and merges
+// their records into the dns data, the rewritten names are returned in order
+func (r *Runner) followDNAME(host string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata) []string {
+	var rewrites []string
+	seen := map[string]struct{}{strings.ToLower(host): {}}
+	name := host
+	for depth := 0; depth < maxDNAMEDepth && metadata != nil; depth++ {
+		rewritten := dnameRewrite(name, metadata.Answers)
+		if rewritten == "" {
+			break
+		}
+		if _, loop := seen[rewritten]; loop {
+			gologger.Warning().Msgf("DNAME loop for %s at %s\n", host, rewritten)
+			break
+		}
+		seen[rewritten] = struct{}{}
+		rewrites = append(rewrites, rewritten)
+
+		var data *retryabledns.DNSData
+		r.takeLimiter()
+		data, metadata, _ = r.query(rewritten)
+		if data == nil || data.Host == "" {
+			break
+		}
+		if r.options.ShowStatistics && metadata != nil {
+			r.stats.IncrementCounter("queries", metadata.Queries)
+			r.stats.IncrementCounter("retries", metadata.Retries)
+		}
+		mergeRecords(dnsData, data)
+		name = rewritten
+	}
+	return rewrites
+}
+
+// mergeRecords appends the records of the source missing from the destination
+func mergeRecords(dst, src *retryabledns.DNSData) {
+	dst.A = uniqueWords(append(dst.A, src.A...))
+	dst.AAAA = uniqueWords(append(dst.AAAA, src.AAAA...))
+	dst.CNAME = uniqueWords(append(dst.CNAME, src.CNAME...))
+	dst.PTR = uniqueWords(append(dst.PTR, src.PTR...))
+	dst.MX = uniqueWords(append(dst.MX, src.MX...))
+	dst.NS = uniqueWords(append(dst.NS, src.NS...))
+	dst.SOA = uniqueWords(append(dst.SOA, src.SOA...))
+	dst.TXT = uniqueWords(append(dst.TXT, src.TXT...))
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+func TestDNAMERewrite(t *testing.T) {
+	answers := parseRRs(t, "old.example.com. 60 IN DNAME new.example.net.")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "www.old.example.com", want: "www.new.example.net"},
+		{name: "A.B.Old.Example.com.", want: "a.b.new.example.net"},
+		// the owner itself and the names out of it aren't rewritten
+		{name: "old.example.com"},
+		{name: "www.example.com"},
+	}
+	for _, test := range tests {
+		if got := dnameRewrite(test.name, answers); got != test.want {
+			t.Errorf("dnameRewrite(%s) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFollowDNAME(t *testing.T) {
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		switch req.Question[0].Name {
+		case "www.loop.example.com.":
+			rr, _ := dns.NewRR("loop.example.com. 60 IN DNAME loop.example.com.")
+			resp.Answer = append(resp.Answer, rr)
+		case "www.new.example.net.":
+			answerA(w, req)
+			return
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	})
+	tests := []struct {
+		host     string
+		answers  []string
+		rewrites []string
+		a        []string
+	}{
+		{host: "www.old.example.com", answers: []string{"old.example.com. 60 IN DNAME new.example.net."}, rewrites: []string{"www.new.example.net"}, a: []string{"192.0.2.1"}},
+		{host: "www.example.com", answers: []string{"www.example.com. 60 IN A 192.0.2.2"}, a: []string{"192.0.2.2"}},
+		// the rewrites stop at the first name seen twice
+		{host: "www.other.example.com", answers: []string{"other.example.com. 60 IN DNAME loop.example.com."}, rewrites: []string{"www.loop.example.com"}},
+	}
+	for _, test := range tests {
+		limiter := &countingLimiter{}
+		r := &Runner{options: &Options{}, dnsx: newTestDNSX(t, server, dns.TypeA), limiter: limiter, workerctx: context.Background()}
+		dnsData := &retryabledns.DNSData{Host: test.host}
+		for _, rr := range parseRRs(t, test.answers...) {
+			if a, ok := rr.(*dns.A); ok {
+				dnsData.A = append(dnsData.A, a.A.String())
+			}
+		}
+		rewrites := r.followDNAME(test.host, dnsData, &dnsx.Metadata{Answers: parseRRs(t, test.answers...)})
+		if fmt.Sprint(rewrites) != fmt.Sprint(test.rewrites) || fmt.Sprint(dnsData.A) != fmt.Sprint(test.a) {
+			t.Errorf("%s: got rewrites %v and A %v, want %v and %v", test.host, rewrites, dnsData.A, test.rewrites, test.a)
+		}
+		// every rewritten name is resolved through the rate limiter
+		if takes := int(limiter.takes); takes != len(test.rewrites) {
+			t.Errorf("%s: got %d limiter takes, want %d", test.host, takes, len(test.rewrites))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// dnsblListing is an address listed by a dns blacklist
+type dnsblListing struct {
+	IP     string `json:"ip"`
+	Zone   string `json:"zone"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// dnsblLookups checks the A and AAAA records of the response against the dns blacklists,
+// the listings of each address and zone are looked up once per run
+func (r *Runner) dnsblLookups(dnsData *retryabledns.DNSData) []dnsblListing {
+	var listings []dnsblListing
+	for _, records := range [][]string{dnsData.A, dnsData.AAAA} {
+		for _, ip := range records {
+			for _, zone := range r.options.dnsblZones {
+				if listing, ok := r.dnsblLookup(ip, zone); ok {
+					listings = append(listings, listing)
+				}
+			}
+		}
+	}
+	return listings
+}
+
+// dnsblLookup queries the reversed address under the blacklist zone (rfc 5782), the A answer
+// is the listing code and the TXT answer its reason. The error codes aren't cached so that
+// the address is checked again.
+func (r *Runner) dnsblLookup(ip, zone string) (dnsblListing, bool) {
+	key := ip + "@" + zone
+	if cached, ok := r.dnsblCache.Load(key); ok {
+		listing := cached.(*dnsblListing)
+		return *listing, listing.Code != ""
+	}
+	listing := &dnsblListing{IP: ip, Zone: zone}
+	if name := dnsblName(ip, zone); name != "" {
+		if msg, err := r.queryMsg(name, dns.TypeA); err == nil && msg != nil {
+			for _, answer := range msg.Answer {
+				a, ok := answer.(*dns.A)
+				if !ok {
+					continue
+				}
+				listed, failed := dnsblCode(a.A)
+				if failed {
+					if _, logged := r.dnsblErrors.LoadOrStore(zone+"@"+a.A.String(), struct{}{}); !logged {
+						gologger.Error().Msgf("DNSBL %s returned the error code %s, its answers are ignored\n", zone, a.A)
+					}
+					return *listing, false
+				}
+				if listed {
+					listing.Code = a.A.String()
+					break
+				}
+			}
+		}
+		if listing.Code != "" {
+			if msg, err := r.queryMsg(name, dns.TypeTXT); err == nil && msg != nil {
+				for _, answer := range msg.Answer {
+					if txt, ok := answer.(*dns.TXT); ok {
+						listing.Reason = strings.Join(txt.Txt, "")
+						break
+					}
+				}
+			}
+		}
+	}
+	r.dnsblCache.Store(key, listing)
+	return *listing, listing.Code != ""
+}
+
+// dnsblCode classifies an address answered by a blacklist: the listing codes are in
+// 127.0.0.0/8 (rfc 5782) except the ones of 127.255.255.0/24, which report an error such as
+// the refusal of the queries sent through public resolvers
+func dnsblCode(ip net.IP) (listed, failed bool) {
+	ip4 := ip.To4()
+	if ip4 == nil || ip4[0] != 127 {
+		return false, false
+	}
+	if ip4[1] == 255 && ip4[2] == 255 {
+		return false, true
+	}
+	return true, false
+}
+
+// dnsblName returns the name queried for the address in the blacklist zone, the reverse
+// order of its octets or nibbles
+func dnsblName(ip, zone string) string {
+	reverse, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	reverse = strings.TrimSuffix(reverse, "in-addr.arpa.")
+	reverse = strings.TrimSuffix(reverse, "ip6.arpa.")
+	return reverse + zone
+}
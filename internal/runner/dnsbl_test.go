@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSBLName(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "192.0.2.1", want: "1.2.0.192.zen.example.org"},
+		{ip: "2001:db8::1", want: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.zen.example.org"},
+		{ip: "invalid"},
+	}
+	for _, test := range tests {
+		if got := dnsblName(test.ip, "zen.example.org"); got != test.want {
+			t.Errorf("dnsblName(%s) = %q, want %q", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestDNSBLCode(t *testing.T) {
+	tests := []struct {
+		code   string
+		listed bool
+		failed bool
+	}{
+		{code: "127.0.0.2", listed: true},
+		{code: "127.0.1.4", listed: true},
+		{code: "127.255.255.254", failed: true},
+		{code: "127.255.255.252", failed: true},
+		{code: "192.0.2.1"},
+		{code: "::1"},
+	}
+	for _, test := range tests {
+		listed, failed := dnsblCode(net.ParseIP(test.code))
+		if listed != test.listed || failed != test.failed {
+			t.Errorf("dnsblCode(%s) = %v, %v, want %v, %v", test.code, listed, failed, test.listed, test.failed)
+		}
+	}
+}
+
+func TestDNSBLLookup(t *testing.T) {
+	var queries int32
+	codes := map[string]string{
+		"2.2.0.192.bl.example.org.": "127.0.0.2",
+		"3.2.0.192.bl.example.org.": "127.255.255.254",
+		// a resolver redirecting the missing names isn't a listing
+		"4.2.0.192.bl.example.org.": "198.51.100.1",
+	}
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(&queries, 1)
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		code, ok := codes[question.Name]
+		switch {
+		case !ok:
+			resp.Rcode = dns.RcodeNameError
+		case question.Qtype == dns.TypeA:
+			rr, _ := dns.NewRR(question.Name + " 60 IN A " + code)
+			resp.Answer = append(resp.Answer, rr)
+		case question.Qtype == dns.TypeTXT:
+			rr, _ := dns.NewRR(question.Name + ` 60 IN TXT "listed for spam"`)
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	})
+	tests := []struct {
+		ip     string
+		listed bool
+		code   string
+		reason string
+		// the queries sent by the second lookup, the error codes aren't cached
+		requeried int32
+	}{
+		{ip: "192.0.2.2", listed: true, code: "127.0.0.2", reason: "listed for spam"},
+		{ip: "192.0.2.3", requeried: 1},
+		{ip: "192.0.2.4"},
+		{ip: "192.0.2.5"},
+	}
+	for _, test := range tests {
+		limiter := &countingLimiter{}
+		r := &Runner{options: &Options{}, dnsx: newTestDNSX(t, server), limiter: limiter, workerctx: context.Background()}
+		atomic.StoreInt32(&queries, 0)
+		listing, listed := r.dnsblLookup(test.ip, "bl.example.org")
+		if listed != test.listed || listing.Code != test.code || listing.Reason != test.reason {
+			t.Errorf("%s: got %+v (listed %v)", test.ip, listing, listed)
+		}
+		sent := atomic.LoadInt32(&queries)
+		r.dnsblLookup(test.ip, "bl.example.org")
+		if requeried := atomic.LoadInt32(&queries) - sent; requeried != test.requeried {
+			t.Errorf("%s: got %d queries for the second lookup, want %d", test.ip, requeried, test.requeried)
+		}
+		// every query takes the rate limiter
+		if takes := atomic.LoadInt32(&limiter.takes); takes != atomic.LoadInt32(&queries) {
+			t.Errorf("%s: got %d limiter takes for %d queries", test.ip, takes, queries)
+		}
+	}
+}
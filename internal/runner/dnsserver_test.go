@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// newTestDNSServer starts a udp dns server answering with the handler and returns its address
+func newTestDNSServer(tb testing.TB, handler dns.HandlerFunc) string {
+	tb.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe() // nolint:errcheck
+	<-started
+	tb.Cleanup(func() { server.Shutdown() }) // nolint:errcheck
+	return conn.LocalAddr().String()
+}
+
+// newTestDNSX returns a dnsx client using the server as its only resolver
+func newTestDNSX(tb testing.TB, server string, questionTypes ...uint16) *dnsx.DNSX {
+	tb.Helper()
+	options := dnsx.DefaultOptions
+	options.BaseResolvers = []string{"udp:" + server}
+	options.MaxRetries = 1
+	options.Transport = dnsx.TransportUDP
+	if len(questionTypes) > 0 {
+		options.QuestionTypes = questionTypes
+	}
+	client, err := dnsx.New(options)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client
+}
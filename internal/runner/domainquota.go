@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/publicsuffix"
+)
+
+// apexDomain returns the registered domain of the host, the host itself when it has none
+func apexDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if apex, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return apex
+	}
+	return host
+}
+
+// domainQuotaExceeded accounts the queries of the host to its apex domain and reports whether
+// the apex already reached the max queries per domain, dropped hosts are counted
+func (r *Runner) domainQuotaExceeded(host string) bool {
+	apex := apexDomain(host)
+	value, _ := r.domainQueries.LoadOrStore(apex, new(int64))
+	counter := value.(*int64)
+	limit := int64(r.options.MaxDomainQueries)
+	for {
+		current := atomic.LoadInt64(counter)
+		if current >= limit {
+			atomic.AddUint64(&r.quotaDropped, 1)
+			return true
+		}
+		next := current + int64(r.requestsPerHost())
+		if atomic.CompareAndSwapInt64(counter, current, next) {
+			if next >= limit {
+				gologger.Warning().Msgf("Max queries reached for %s, its remaining hosts are dropped\n", apex)
+			}
+			return false
+		}
+	}
+}
+
+// reportQuotaDropped logs the number of hosts dropped by the max queries per domain
+func (r *Runner) reportQuotaDropped() {
+	if r.options.MaxDomainQueries > 0 {
+		gologger.Info().Msgf("%d hosts dropped [max-queries-per-domain]\n", atomic.LoadUint64(&r.quotaDropped))
+	}
+}
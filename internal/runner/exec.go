@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// execPlaceholder stands for the result in the exec command, the result is passed to the
+// shell as its first positional parameter and never pasted into the script
+const execPlaceholder = "{}"
+
+// execArgument is the quoted positional parameter replacing the placeholder
+const execArgument = `"$1"`
+
+// execHook runs the exec command for each emitted result with bounded concurrency
+type execHook struct {
+	command string
+	stdin   bool
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	sync.Mutex
+	exitCodes map[int]int
+}
+
+func newExecHook(command string, threads int, stdin bool) *execHook {
+	if threads <= 0 {
+		threads = 1
+	}
+	return &execHook{
+		command:   execScript(command),
+		stdin:     stdin,
+		sem:       make(chan struct{}, threads),
+		exitCodes: make(map[int]int),
+	}
+}
+
+// run executes the command for the event, the placeholder is replaced with the output line
+// or with the host when the json result is written to the command stdin
+func (e *execHook) run(event *outputEvent) {
+	if event.status != statusMatched {
+		return
+	}
+	if e.stdin && event.result != nil {
+		data, err := event.result.JSON()
+		if err != nil {
+			return
+		}
+		e.start(event.result.Host, event.result.Host, data)
+		return
+	}
+	for _, line := range event.lines {
+		host := line
+		if event.result != nil {
+			host = event.result.Host
+		}
+		e.start(host, line, "")
+	}
+}
+
+// start waits for a free slot and runs the command in background
+func (e *execHook) start(host, value, input string) {
+	e.sem <- struct{}{}
+	e.wg.Add(1)
+	go func() {
+		defer func() {
+			<-e.sem
+			e.wg.Done()
+		}()
+
+		// dns data is attacker influenced, the value is an argument of the script and is
+		// never interpreted by the shell
+		cmd := exec.Command("sh", "-c", e.command, "sh", value)
+		if input != "" {
+			cmd.Stdin = strings.NewReader(input + "\n")
+		}
+		// results own the standard output, the command output goes to stderr
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		exitCode := 0
+		if err := cmd.Run(); err != nil {
+			exitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			}
+			gologger.Warning().Msgf("Exec command failed for %s: %s\n", host, err)
+		}
+		e.Lock()
+		e.exitCodes[exitCode]++
+		e.Unlock()
+	}()
+}
+
+// wait waits for the running commands and returns the aggregated exit codes
+func (e *execHook) wait() string {
+	e.wg.Wait()
+	e.Lock()
+	defer e.Unlock()
+	var (
+		codes []int
+		total int
+	)
+	for code, count := range e.exitCodes {
+		codes = append(codes, code)
+		total += count
+	}
+	sort.Ints(codes)
+	var summary []string
+	for _, code := range codes {
+		summary = append(summary, fmt.Sprintf("%d=%d", code, e.exitCodes[code]))
+	}
+	return fmt.Sprintf("%d commands executed (exit codes: %s)", total, strings.Join(summary, Comma))
+}
+
+// reset clears the exit codes of the previous runs
+func (e *execHook) reset() {
+	e.Lock()
+	defer e.Unlock()
+	e.exitCodes = make(map[int]int)
+}
+
+// finishExec waits for the exec commands still running and reports their exit codes
+func (r *Runner) finishExec() {
+	if r.execHook == nil {
+		return
+	}
+	gologger.Info().Msgf("%s\n", r.execHook.wait())
+}
+
+// execScript replaces the placeholders of the command with the positional parameter of the
+// result, the placeholders the user already quoted lose their quotes so that the parameter
+// stays a single word
+func execScript(command string) string {
+	replacer := strings.NewReplacer(`"`+execPlaceholder+`"`, execArgument, "'"+execPlaceholder+"'", execArgument, execPlaceholder, execArgument)
+	return replacer.Replace(command)
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExecScript(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "notify {}", want: `notify "$1"`},
+		{command: `echo "{}"`, want: `echo "$1"`},
+		{command: "echo '{}'", want: `echo "$1"`},
+		{command: "echo {} {}", want: `echo "$1" "$1"`},
+		{command: "echo found", want: "echo found"},
+	}
+	for _, test := range tests {
+		if got := execScript(test.command); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.command, got, test.want)
+		}
+	}
+}
+
+// TestExecInjection runs the commands with the results of hostile dns data, the value must
+// reach the command as is without running any shell code
+func TestExecInjection(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec requires a posix shell")
+	}
+	payloads := []string{
+		"x$(touch {marker})",
+		"x`touch {marker}`",
+		"x'; touch {marker}; '",
+		`x"; touch {marker}; "`,
+		`x"$(touch {marker})"`,
+		"x'$(touch {marker})'",
+		"x $HOME * ; touch {marker}",
+	}
+	templates := []string{
+		"printf '%s\\n' {} >> {output}",
+		`printf '%s\n' "{}" >> {output}`,
+		`printf '%s\n' '{}' >> {output}`,
+	}
+	for _, template := range templates {
+		for _, payload := range payloads {
+			dir := t.TempDir()
+			marker, output := filepath.Join(dir, "injected"), filepath.Join(dir, "output")
+			payload = strings.ReplaceAll(payload, "{marker}", marker)
+
+			e := newExecHook(strings.ReplaceAll(template, "{output}", output), 1, false)
+			e.start("www.example.com", payload, "")
+			if got := e.wait(); got != "1 commands executed (exit codes: 0=1)" {
+				t.Errorf("%s with %s: got %s", template, payload, got)
+			}
+			if _, err := os.Stat(marker); !os.IsNotExist(err) {
+				t.Errorf("%s with %s: the payload ran", template, payload)
+			}
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(string(data), "\n"); got != payload {
+				t.Errorf("%s: got value %q, want %q", template, got, payload)
+			}
+		}
+	}
+}
+
+func TestExecStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec requires a posix shell")
+	}
+	output := filepath.Join(t.TempDir(), "output")
+	e := newExecHook("{ printf '%s\\n' {}; cat; } > "+output, 1, true)
+	e.start("www.example.com", "www.example.com", `{"host":"www.example.com"}`)
+	e.wait()
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "www.example.com\n{\"host\":\"www.example.com\"}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
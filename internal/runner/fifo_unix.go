@@ -0,0 +1,47 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package runner
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+)
+
+// openPipe opens the named pipe for reading, the open waits for a writer in a goroutine so
+// that the run can be closed meanwhile. Opening the pipe with O_NONBLOCK isn't an option,
+// its reads return EOF as long as no writer connected.
+func openPipe(ctx context.Context, fileName string) (*os.File, error) {
+	type opened struct {
+		file *os.File
+		err  error
+	}
+	done := make(chan opened, 1)
+	go func() {
+		file, err := os.Open(fileName)
+		done <- opened{file: file, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.file, result.err
+	case <-ctx.Done():
+	}
+	// a writer opened without blocking releases the waiting open, it fails until the
+	// goroutine opened the pipe
+	for {
+		if writer, err := os.OpenFile(fileName, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			writer.Close()
+		}
+		select {
+		case result := <-done:
+			if result.file != nil {
+				result.file.Close()
+			}
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
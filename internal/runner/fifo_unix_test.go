@@ -0,0 +1,88 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newFIFO(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("could not create named pipe: %s", err)
+	}
+	return path
+}
+
+func TestOpenPipe(t *testing.T) {
+	defer verifyNoLeaks(t)()
+	path := newFIFO(t)
+	go func() {
+		writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		writer.WriteString("www.example.com\n") // nolint:errcheck
+		writer.Close()
+	}()
+
+	f, err := openFileContext(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "www.example.com\n" {
+		t.Fatalf("got %q", data)
+	}
+}
+
+// TestOpenPipeCanceled checks that the wait for a writer ends with the context, the waiting
+// goroutine included
+func TestOpenPipeCanceled(t *testing.T) {
+	defer verifyNoLeaks(t)()
+	path := newFIFO(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := openFileContext(ctx, path); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("open returned after %s", elapsed)
+	}
+}
+
+// TestRunClosedWaitingForPipe closes a runner whose list is a named pipe without writer
+func TestRunClosedWaitingForPipe(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	path := newFIFO(t)
+	r := newConfiguredRunner(t, server, func(options *Options) {
+		options.Hosts = path
+	}, func(*Result) {})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	r.Close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the run kept waiting for a writer")
+	}
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/projectdiscovery/dnsx/internal/geo"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// geoLookups returns the locations of the A and AAAA records of the response found in the database
+func (r *Runner) geoLookups(dnsData *retryabledns.DNSData) map[string]*geo.Location {
+	locations := make(map[string]*geo.Location)
+	for _, records := range [][]string{dnsData.A, dnsData.AAAA} {
+		for _, ip := range records {
+			location, err := r.geo.Lookup(net.ParseIP(ip))
+			if err != nil {
+				gologger.Debug().Msgf("Could not geolocate %s: %s\n", ip, err)
+				continue
+			}
+			if location != nil {
+				locations[ip] = location
+			}
+		}
+	}
+	return locations
+}
+
+// geoAllowed reports whether the country of the address passes the geo filter, addresses
+// without country only pass exclusion filters
+func (r *Runner) geoAllowed(location *geo.Location) bool {
+	var country string
+	if location != nil {
+		country = location.Country
+	}
+	if _, excluded := r.options.geoExclude[country]; excluded && country != "" {
+		return false
+	}
+	if len(r.options.geoInclude) == 0 {
+		return true
+	}
+	_, included := r.options.geoInclude[country]
+	return included
+}
+
+// filterGeo removes the addresses not passing the geo filter from the response and reports
+// whether the host still has addresses
+func (r *Runner) filterGeo(dnsData *retryabledns.DNSData, locations map[string]*geo.Location) bool {
+	filter := func(records []string) []string {
+		var kept []string
+		for _, ip := range records {
+			if r.geoAllowed(locations[ip]) {
+				kept = append(kept, ip)
+			}
+		}
+		return kept
+	}
+	dnsData.A = filter(dnsData.A)
+	dnsData.AAAA = filter(dnsData.AAAA)
+	return len(dnsData.A) > 0 || len(dnsData.AAAA) > 0
+}
+
+// geoTag returns the plain output value of the location, the country or the network when
+// the database has no country
+func geoTag(location *geo.Location) string {
+	if location.Country != "" {
+		return location.Country
+	}
+	if location.ASN > 0 {
+		return fmt.Sprintf("AS%d", location.ASN)
+	}
+	return ""
+}
+
+// outputAddressType returns the lines of the addresses as outputRecordType, the response
+// values are followed by their geolocation
+func (r *Runner) outputAddressType(domain string, items []string, locations map[string]*geo.Location, suffix string) []string {
+	if len(locations) == 0 || (!r.options.Response && !r.options.ResponseOnly) {
+		return r.outputRecordType(domain, items, suffix)
+	}
+	var lines []string
+	for _, item := range items {
+		itemSuffix := suffix
+		if location, ok := locations[item]; ok {
+			if tag := geoTag(location); tag != "" {
+				itemSuffix = r.field(tag) + suffix
+			}
+		}
+		lines = append(lines, r.outputRecordType(domain, []string{item}, itemSuffix)...)
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// glueRecords returns the glue records found in the additional section of the NS responses
+// of the host, keyed by name server
+func glueRecords(metadata *dnsx.Metadata) map[string][]string {
+	nameservers := make(map[string]struct{})
+	for _, rr := range append(append([]dns.RR{}, metadata.Answers...), metadata.Authorities...) {
+		if ns, ok := rr.(*dns.NS); ok {
+			nameservers[strings.ToLower(dns.Fqdn(ns.Ns))] = struct{}{}
+		}
+	}
+
+	glue := make(map[string][]string)
+	for _, rr := range metadata.Additionals {
+		name := strings.ToLower(dns.Fqdn(rr.Header().Name))
+		if _, ok := nameservers[name]; !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".")
+		switch record := rr.(type) {
+		case *dns.A:
+			glue[name] = append(glue[name], record.A.String())
+		case *dns.AAAA:
+			glue[name] = append(glue[name], record.AAAA.String())
+		}
+	}
+	if len(glue) == 0 {
+		return nil
+	}
+	return glue
+}
+
+// staleGlue returns the name servers whose glue differs from a fresh lookup of their addresses
+func (r *Runner) staleGlue(glue map[string][]string) []string {
+	var stale []string
+	for nameserver, addresses := range glue {
+		current := r.lookupNameserver(nameserver)
+		if current == nil {
+			continue
+		}
+		if strings.Join(normalizeAnswers(addresses), Comma) != strings.Join(current, Comma) {
+			stale = append(stale, nameserver)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// lookupNameserver returns the sorted A and AAAA records of the name server, results are cached
+func (r *Runner) lookupNameserver(nameserver string) []string {
+	if cached, ok := r.glueCache.Load(nameserver); ok {
+		return cached.([]string)
+	}
+	var addresses []string
+	for _, questionType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, err := r.queryMsg(nameserver, questionType)
+		if err != nil || msg == nil {
+			continue
+		}
+		for _, rr := range msg.Answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				addresses = append(addresses, record.A.String())
+			case *dns.AAAA:
+				addresses = append(addresses, record.AAAA.String())
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	addresses = normalizeAnswers(addresses)
+	r.glueCache.Store(nameserver, addresses)
+	return addresses
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+func parseRRs(tb testing.TB, records ...string) []dns.RR {
+	tb.Helper()
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+func TestGlueRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *dnsx.Metadata
+		want     string
+	}{
+		{
+			name: "answer",
+			metadata: &dnsx.Metadata{
+				Answers:     parseRRs(t, "example.com. 300 IN NS NS1.example.com.", "example.com. 300 IN NS ns2.example.net."),
+				Additionals: parseRRs(t, "ns1.example.com. 300 IN A 192.0.2.1", "ns1.example.com. 300 IN AAAA 2001:db8::1", "ns2.example.net. 300 IN A 192.0.2.2"),
+			},
+			want: "map[ns1.example.com:[192.0.2.1 2001:db8::1] ns2.example.net:[192.0.2.2]]",
+		},
+		{
+			name: "referral",
+			metadata: &dnsx.Metadata{
+				Authorities: parseRRs(t, "example.com. 300 IN NS ns1.example.com."),
+				Additionals: parseRRs(t, "ns1.example.com. 300 IN A 192.0.2.1"),
+			},
+			want: "map[ns1.example.com:[192.0.2.1]]",
+		},
+		{
+			name: "unrelated additionals",
+			metadata: &dnsx.Metadata{
+				Answers:     parseRRs(t, "example.com. 300 IN NS ns1.example.com."),
+				Additionals: parseRRs(t, "mail.example.com. 300 IN A 192.0.2.3"),
+			},
+			want: "map[]",
+		},
+		{name: "no records", metadata: &dnsx.Metadata{}, want: "map[]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(glueRecords(test.metadata)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"bufio"
+	"os"
+	"sync"
+
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// hostsWriter writes the resolved addresses in the /etc/hosts format (IP hostname)
+type hostsWriter struct {
+	sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+func newHostsWriter(path string) (*hostsWriter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &hostsWriter{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// write adds one line for each A and AAAA record of the host
+func (h *hostsWriter) write(host string, dnsdata *retryabledns.DNSData) {
+	h.Lock()
+	defer h.Unlock()
+	for _, records := range [][]string{dnsdata.A, dnsdata.AAAA} {
+		for _, ip := range records {
+			// nolint:errcheck
+			h.w.WriteString(ip + "\t" + host + "\n")
+		}
+	}
+}
+
+func (h *hostsWriter) Close() error {
+	h.Lock()
+	defer h.Unlock()
+	if err := h.w.Flush(); err != nil {
+		h.file.Close()
+		return err
+	}
+	return h.file.Close()
+}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPrepareInputPorts(t *testing.T) {
+	targets := []string{"example.com:80", "example.com:443", "example.com:80", "example.com", "www.example.com:8080"}
+	tests := []struct {
+		preservePort bool
+		want         string
+	}{
+		{preservePort: false, want: "[example.com:example.com:80 www.example.com:www.example.com:8080]"},
+		{preservePort: true, want: "[example.com:example.com:80 example.com:example.com:443 example.com:example.com www.example.com:www.example.com:8080]"},
+	}
+	for _, test := range tests {
+		r := &Runner{options: &Options{Targets: targets, PreservePort: test.preservePort}, hm: newTestHMap(t)}
+		r.prepareRun()
+		var got []string
+		err := r.prepareInput(func(host, input string) {
+			got = append(got, host+":"+input)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != test.want {
+			t.Errorf("preserve-port %v: got %v, want %s", test.preservePort, got, test.want)
+		}
+	}
+}
+
+// answerPTR answers the PTR questions of the reverse names with ptr.example.com
+func answerPTR(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if question := req.Question[0]; question.Qtype == dns.TypePTR && (strings.HasSuffix(question.Name, ".ip6.arpa.") || strings.HasSuffix(question.Name, ".in-addr.arpa.")) {
+		resp.Answer = append(resp.Answer, &dns.PTR{
+			Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 60},
+			Ptr: "ptr.example.com.",
+		})
+	} else {
+		resp.Rcode = dns.RcodeNameError
+	}
+	w.WriteMsg(resp) // nolint:errcheck
+}
+
+// TestPTRInputs resolves the PTR records of the address forms of the input, the results are
+// reported for the address
+func TestPTRInputs(t *testing.T) {
+	server := newTestDNSServer(t, answerPTR)
+	tests := []struct {
+		input string
+		hosts []string
+	}{
+		{input: "192.0.2.1", hosts: []string{"192.0.2.1"}},
+		{input: "2001:db8::1", hosts: []string{"2001:db8::1"}},
+		{input: "2001:0db8:0000:0000:0000:0000:0000:0002", hosts: []string{"2001:0db8:0000:0000:0000:0000:0000:0002"}},
+		{input: "::ffff:192.0.2.3", hosts: []string{"::ffff:192.0.2.3"}},
+		{input: "[2001:db8::4]", hosts: []string{"2001:db8::4"}},
+		{input: "http://[2001:db8::5]:8080/path", hosts: []string{"2001:db8::5"}},
+		{input: "2001:db8::8/126", hosts: []string{"2001:db8::8", "2001:db8::9", "2001:db8::a", "2001:db8::b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var results []*Result
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.Targets = []string{test.input}
+				options.PTR = true
+			}, func(result *Result) {
+				results = append(results, result)
+			})
+			defer r.Close()
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+			var hosts []string
+			for _, result := range results {
+				hosts = append(hosts, result.Host)
+				if fmt.Sprint(result.PTR) != "[ptr.example.com]" {
+					t.Errorf("got PTR %v for %s, want ptr.example.com", result.PTR, result.Host)
+				}
+			}
+			sort.Strings(hosts)
+			if fmt.Sprint(hosts) != fmt.Sprint(test.hosts) {
+				t.Errorf("got hosts %v, want %v", hosts, test.hosts)
+			}
+		})
+	}
+}
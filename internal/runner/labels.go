@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"regexp"
+	"strings"
+)
+
+// labelKeyRegex matches the identifier-like keys of the labels
+var labelKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// resultLabel is a key=value label of the run, attached verbatim to the results
+type resultLabel struct {
+	key   string
+	value string
+}
+
+// labelsMap returns the labels of the run attached to the json results, nil without labels
+func (options *Options) labelsMap() map[string]string {
+	if len(options.labels) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(options.labels))
+	for _, label := range options.labels {
+		labels[label.key] = label.value
+	}
+	return labels
+}
+
+// csvLine returns the fields followed by the label values in the order of the flags, quoted
+// when they hold a comma, a quote or a line break
+func (options *Options) csvLine(fields ...string) string {
+	for _, label := range options.labels {
+		fields = append(fields, label.value)
+	}
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+	// nolint:errcheck
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(buffer.String(), NewLine)
+}
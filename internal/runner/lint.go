@@ -0,0 +1,179 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	lintCNAMEAndOther = "cname-and-other"
+	lintCNAMEAtApex   = "cname-at-apex"
+	lintMXToCNAME     = "mx-to-cname"
+	lintNSToCNAME     = "ns-to-cname"
+	lintMXNoAddress   = "mx-no-address"
+	lintSPFLookups    = "spf-lookups"
+	lintSOARnameAt    = "soa-rname-at"
+
+	// maxSPFLookups is the number of dns lookups allowed during an spf evaluation (rfc 7208)
+	maxSPFLookups = 10
+)
+
+// lintFinding is a protocol violation found in the responses of a host
+type lintFinding struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// lintTarget is the outcome of the lookup of a name referenced by MX or NS records
+type lintTarget struct {
+	cname     bool
+	addresses bool
+}
+
+// lint checks the answers of the host for protocol violations, the names referenced by
+// MX and NS records are looked up once per run
+func (r *Runner) lint(host string, metadata *dnsx.Metadata) []lintFinding {
+	var findings []lintFinding
+	add := func(check, format string, args ...interface{}) {
+		findings = append(findings, lintFinding{Check: check, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	owners := make(map[string]map[uint16]struct{})
+	for _, answer := range metadata.Answers {
+		name := strings.ToLower(dns.Fqdn(answer.Header().Name))
+		if owners[name] == nil {
+			owners[name] = make(map[uint16]struct{})
+		}
+		owners[name][answer.Header().Rrtype] = struct{}{}
+	}
+	var names []string
+	for name := range owners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		types := owners[name]
+		if _, ok := types[dns.TypeCNAME]; !ok {
+			continue
+		}
+		var others []string
+		for questionType := range types {
+			switch questionType {
+			case dns.TypeCNAME, dns.TypeRRSIG, dns.TypeNSEC:
+			default:
+				others = append(others, dns.TypeToString[questionType])
+			}
+		}
+		if len(others) > 0 {
+			sort.Strings(others)
+			add(lintCNAMEAndOther, "%s has a CNAME along with %s records", strings.TrimSuffix(name, "."), strings.Join(others, Comma))
+		}
+	}
+	if apex, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil && strings.EqualFold(apex, host) {
+		if _, ok := owners[dns.Fqdn(strings.ToLower(host))][dns.TypeCNAME]; ok {
+			add(lintCNAMEAtApex, "%s is a zone apex having a CNAME", host)
+		}
+	}
+
+	for _, answer := range metadata.Answers {
+		switch record := answer.(type) {
+		case *dns.MX:
+			// null MX (rfc 7505)
+			if record.Mx == "." {
+				continue
+			}
+			target := r.lintTarget(record.Mx)
+			if target.cname {
+				add(lintMXToCNAME, "MX %s is a CNAME", strings.TrimSuffix(record.Mx, "."))
+			}
+			if !target.addresses {
+				add(lintMXNoAddress, "MX %s has no A or AAAA records", strings.TrimSuffix(record.Mx, "."))
+			}
+		case *dns.NS:
+			if r.lintTarget(record.Ns).cname {
+				add(lintNSToCNAME, "NS %s is a CNAME", strings.TrimSuffix(record.Ns, "."))
+			}
+		case *dns.TXT:
+			value := strings.Join(record.Txt, "")
+			if lookups := spfLookups(value); lookups > maxSPFLookups {
+				add(lintSPFLookups, "spf record needs %d dns lookups, the limit is %d", lookups, maxSPFLookups)
+			}
+		case *dns.SOA:
+			if strings.Contains(record.Mbox, "@") {
+				add(lintSOARnameAt, "SOA rname %s contains '@'", record.Mbox)
+			}
+		}
+	}
+	return dedupFindings(findings)
+}
+
+// lintTarget looks up the A and AAAA records of the name, the outcome is cached
+func (r *Runner) lintTarget(name string) lintTarget {
+	name = strings.ToLower(dns.Fqdn(name))
+	if cached, ok := r.lintTargets.Load(name); ok {
+		return cached.(lintTarget)
+	}
+	var target lintTarget
+	for _, questionType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, err := r.queryMsg(name, questionType)
+		if err != nil || msg == nil {
+			continue
+		}
+		for _, answer := range msg.Answer {
+			switch answer.(type) {
+			case *dns.CNAME:
+				if strings.EqualFold(dns.Fqdn(answer.Header().Name), name) {
+					target.cname = true
+				}
+			case *dns.A, *dns.AAAA:
+				target.addresses = true
+			}
+		}
+		if target.addresses {
+			break
+		}
+	}
+	r.lintTargets.Store(name, target)
+	return target
+}
+
+// spfLookups returns the number of terms of the spf record causing a dns lookup, zero
+// for values not being spf records
+func spfLookups(value string) int {
+	terms := strings.Fields(strings.ToLower(value))
+	if len(terms) == 0 || terms[0] != "v=spf1" {
+		return 0
+	}
+	var lookups int
+	for _, term := range terms[1:] {
+		term = strings.TrimLeft(term, "+-~?")
+		switch {
+		case term == "a", term == "mx", term == "ptr",
+			strings.HasPrefix(term, "a:"), strings.HasPrefix(term, "a/"),
+			strings.HasPrefix(term, "mx:"), strings.HasPrefix(term, "mx/"),
+			strings.HasPrefix(term, "ptr:"), strings.HasPrefix(term, "include:"),
+			strings.HasPrefix(term, "exists:"), strings.HasPrefix(term, "redirect="):
+			lookups++
+		}
+	}
+	return lookups
+}
+
+// dedupFindings removes the findings repeated by the answers of several questions
+func dedupFindings(findings []lintFinding) []lintFinding {
+	seen := make(map[lintFinding]struct{})
+	var unique []lintFinding
+	for _, finding := range findings {
+		if _, ok := seen[finding]; ok {
+			continue
+		}
+		seen[finding] = struct{}{}
+		unique = append(unique, finding)
+	}
+	return unique
+}
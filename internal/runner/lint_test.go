@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// answerLint answers the A questions of mail.example.com and of alias.example.com, a CNAME
+// to it, the other names have no address
+func answerLint(queries *int32) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(queries, 1)
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		switch {
+		case question.Name == "alias.example.com.":
+			resp.Answer = append(resp.Answer, &dns.CNAME{
+				Hdr:    dns.RR_Header{Name: question.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60},
+				Target: "mail.example.com.",
+			})
+			fallthrough
+		case question.Name == "mail.example.com.":
+			if question.Qtype != dns.TypeA {
+				break
+			}
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: "mail.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.IPv4(192, 0, 2, 25),
+			})
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	}
+}
+
+func TestLint(t *testing.T) {
+	var queries int32
+	server := newTestDNSServer(t, answerLint(&queries))
+	tests := []struct {
+		records []string
+		want    []string
+	}{
+		{records: []string{"example.com. 60 IN MX 10 mail.example.com."}},
+		{records: []string{"example.com. 60 IN MX 10 alias.example.com."}, want: []string{lintMXToCNAME}},
+		{records: []string{"example.com. 60 IN MX 10 missing.example.com."}, want: []string{lintMXNoAddress}},
+		{records: []string{"example.com. 60 IN NS alias.example.com."}, want: []string{lintNSToCNAME}},
+		{records: []string{"www.example.com. 60 IN CNAME web.example.com.", "www.example.com. 60 IN A 192.0.2.1"}, want: []string{lintCNAMEAndOther}},
+		{records: []string{"example.com. 60 IN SOA ns.example.com. admin@example.com. 1 3600 600 86400 60"}, want: []string{lintSOARnameAt}},
+		{records: []string{`example.com. 60 IN TXT "v=spf1 include:a include:b include:c include:d include:e include:f a mx ptr exists:x redirect=y -all"`}, want: []string{lintSPFLookups}},
+	}
+	for i, test := range tests {
+		limiter := &countingLimiter{}
+		atomic.StoreInt32(&queries, 0)
+		r := &Runner{options: &Options{}, dnsx: newTestDNSX(t, server), limiter: limiter, workerctx: context.Background()}
+		var checks []string
+		for _, finding := range r.lint("example.com", &dnsx.Metadata{Answers: parseRRs(t, test.records...)}) {
+			checks = append(checks, finding.Check)
+		}
+		if fmt.Sprint(checks) != fmt.Sprint(test.want) {
+			t.Errorf("test %d: got findings %v, want %v", i, checks, test.want)
+		}
+		// the lookups of the MX and NS targets are rate limited
+		if takes, sent := atomic.LoadInt32(&limiter.takes), atomic.LoadInt32(&queries); takes != sent {
+			t.Errorf("test %d: got %d limiter takes for %d queries", i, takes, sent)
+		}
+	}
+}
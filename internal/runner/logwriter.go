@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/gologger/writer"
+)
+
+var (
+	// stderrMutex serializes the writes of the logger and of the statistics printer,
+	// a statistics line never interleaves with a log line
+	stderrMutex    sync.Mutex
+	syncLoggerOnce sync.Once
+)
+
+// syncedWriter is a log writer holding the standard error lock while writing
+type syncedWriter struct {
+	writer writer.Writer
+}
+
+func (w *syncedWriter) Write(data []byte, level levels.Level) {
+	stderrMutex.Lock()
+	defer stderrMutex.Unlock()
+	w.writer.Write(data, level)
+}
+
+// syncLogger makes the logger share the standard error lock with the statistics printer
+func syncLogger() {
+	syncLoggerOnce.Do(func() {
+		gologger.DefaultLogger.SetWriter(&syncedWriter{writer: writer.NewCLI()})
+	})
+}
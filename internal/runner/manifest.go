@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/goflags"
+	"github.com/projectdiscovery/gologger"
+)
+
+// unreplayedFlags are the flags left out of the manifests, a replay gets its input and
+// output locations and its verbosity from its own command line
+var unreplayedFlags = map[string]struct{}{
+	"list": {}, "l": {}, "output": {}, "o": {}, "manifest": {}, "replay": {}, "resume": {},
+	"silent": {}, "verbose": {}, "v": {}, "stats": {}, "version": {}, "yes": {},
+}
+
+// credentialFlags are the flags whose values are redacted from the manifests, the manifests
+// are shared to re-verify the findings. A replay takes them from its own command line.
+var credentialFlags = map[string]struct{}{
+	"reputation-key": {}, "splunk-token": {}, "tsig-key": {}, "coordinator-token": {},
+}
+
+// redactedValue replaces the values of the credential flags in the manifests
+const redactedValue = "REDACTED"
+
+// runManifest records the configuration of a run so that it can be replayed with the same
+// resolvers in the same order and the same random choices
+type runManifest struct {
+	Version       string              `json:"version"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Options       map[string][]string `json:"options"`
+	Resolvers     []string            `json:"resolvers"`
+	ResolversHash string              `json:"resolvers_hash"`
+	InputHash     string              `json:"input_hash,omitempty"`
+	Seed          int64               `json:"seed"`
+}
+
+// inputDigest is an order insensitive hash of the hosts of the input, the hosts read
+// ahead by precheck are fed in another order
+type inputDigest struct {
+	sync.Mutex
+	sum   [sha256.Size]byte
+	count uint64
+}
+
+func (d *inputDigest) add(host string) {
+	hash := sha256.Sum256([]byte(strings.ToLower(host)))
+	d.Lock()
+	defer d.Unlock()
+	for i := range d.sum {
+		d.sum[i] ^= hash[i]
+	}
+	d.count++
+}
+
+func (d *inputDigest) String() string {
+	d.Lock()
+	defer d.Unlock()
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], d.count)
+	hash := sha256.Sum256(append(d.sum[:], count[:]...))
+	return hex.EncodeToString(hash[:])
+}
+
+// commandLineOptions returns the values of the flags set on the command line or by the
+// config file, one value per occurrence for the repeatable flags
+func commandLineOptions(flags *flag.FlagSet) map[string][]string {
+	values := make(map[string][]string)
+	flags.Visit(func(f *flag.Flag) {
+		if _, ok := unreplayedFlags[f.Name]; ok {
+			return
+		}
+		if _, ok := credentialFlags[f.Name]; ok {
+			values[f.Name] = []string{redactedValue}
+			return
+		}
+		if slice, ok := f.Value.(*goflags.StringSlice); ok {
+			values[f.Name] = append([]string{}, *slice...)
+			return
+		}
+		values[f.Name] = []string{f.Value.String()}
+	})
+	return values
+}
+
+// configureReplay applies the options of the replayed manifest, the flags given on the
+// command line take precedence. The resolvers of the manifest replace the discovered and
+// checked ones, the credentials of the manifest must be given on the command line.
+func (options *Options) configureReplay(flags *flag.FlagSet) error {
+	if options.Replay == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(options.Replay)
+	if err != nil {
+		return fmt.Errorf("could not read manifest: %s", err)
+	}
+	manifest := &runManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return fmt.Errorf("invalid manifest %s: %s", options.Replay, err)
+	}
+	if len(manifest.Resolvers) == 0 {
+		return fmt.Errorf("invalid manifest %s: no resolvers", options.Replay)
+	}
+
+	explicit := make(map[string]struct{})
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = struct{}{}
+	})
+	var missing []string
+	for name, values := range manifest.Options {
+		if _, ok := unreplayedFlags[name]; ok {
+			continue
+		}
+		if _, ok := explicit[name]; ok {
+			continue
+		}
+		if _, ok := credentialFlags[name]; ok {
+			missing = append(missing, "-"+name)
+			continue
+		}
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("could not replay option %s=%s: %s", name, value, err)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the replayed run used credentials that aren't in the manifest, give them on the command line: %s", strings.Join(missing, ", "))
+	}
+	options.Resolvers = strings.Join(manifest.Resolvers, Comma)
+	options.DiscoverResolvers = ""
+	options.OpenResolverCheck = false
+	options.SkipOpenResolver = false
+	options.replay = manifest
+	return nil
+}
+
+// seedRandom seeds the random choices of the run (source ports, cidr and precheck
+// samples) with the seed of the replayed manifest or a new one recorded by the manifest
+func (options *Options) seedRandom() {
+	if options.Manifest == "" && options.replay == nil {
+		return
+	}
+	if options.replay != nil {
+		options.seed = options.replay.Seed
+	} else if options.seed == 0 {
+		options.seed = time.Now().UnixNano()
+	}
+	rand.Seed(options.seed)
+}
+
+// writeManifest writes the manifest of the run, the input hash is added once the input is read
+func (r *Runner) writeManifest() error {
+	if r.options.Manifest == "" {
+		return nil
+	}
+	if r.manifest == nil {
+		values := r.options.flagValues
+		if values == nil {
+			values = make(map[string][]string)
+		}
+		r.manifest = &runManifest{
+			Version:       Version,
+			CreatedAt:     time.Now().UTC(),
+			Options:       values,
+			Resolvers:     r.dnsx.Options.BaseResolvers,
+			ResolversHash: resolversFingerprint(r.dnsx.Options.BaseResolvers),
+			Seed:          r.options.seed,
+		}
+	}
+	data, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(r.options.Manifest, append(data, NewLine...), 0644), "could not write manifest")
+}
+
+// finishManifest records the hash of the input read by the run in its manifest and warns
+// when a replayed run read another input than the original one
+func (r *Runner) finishManifest() error {
+	inputHash := r.inputDigest.String()
+	if replay := r.options.replay; replay != nil && replay.InputHash != "" && replay.InputHash != inputHash {
+		gologger.Warning().Msgf("Input differs from the one of the replayed run, results may not be comparable\n")
+	}
+	if r.manifest == nil {
+		return nil
+	}
+	r.manifest.InputHash = inputHash
+	return r.writeManifest()
+}
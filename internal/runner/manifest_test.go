@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseFlags parses the arguments on a private flag set defining the options
+func parseFlags(t *testing.T, args ...string) (*Options, *defaultsFlagSet) {
+	t.Helper()
+	options := &Options{}
+	flagSet := newDefaultsFlagSet()
+	defineFlags(flagSet, options)
+	if err := flagSet.flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return options, flagSet
+}
+
+func TestCommandLineOptions(t *testing.T) {
+	_, flagSet := parseFlags(t, "-l", "hosts.txt", "-a", "-label", "a=1", "-label", "b=2", "-reputation-key", "secret", "-splunk-token", "token", "-tsig-key", "name:c2VjcmV0", "-coordinator-token", "token")
+	values := commandLineOptions(flagSet.flags)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "a", want: []string{"true"}},
+		{name: "label", want: []string{"a=1", "b=2"}},
+		{name: "reputation-key", want: []string{redactedValue}},
+		{name: "splunk-token", want: []string{redactedValue}},
+		{name: "tsig-key", want: []string{redactedValue}},
+		{name: "coordinator-token", want: []string{redactedValue}},
+		// the input isn't replayed
+		{name: "l"},
+	}
+	for _, test := range tests {
+		if got := values[test.name]; strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConfigureReplay(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "run.manifest")
+	data, err := json.Marshal(&runManifest{
+		Options:   map[string][]string{"a": {"true"}, "retry": {"5"}, "reputation-key": {redactedValue}, "splunk-token": {redactedValue}},
+		Resolvers: []string{"udp:192.0.2.53:53"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifest, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		// the error message, empty when the replay is configured
+		err     string
+		retries int
+	}{
+		{name: "missing credentials", args: []string{"-replay", manifest}, err: "-reputation-key, -splunk-token"},
+		{name: "missing credential", args: []string{"-replay", manifest, "-reputation-key", "key"}, err: "-splunk-token"},
+		{name: "credentials", args: []string{"-replay", manifest, "-reputation-key", "key", "-splunk-token", "token"}, retries: 5},
+		// the command line takes precedence
+		{name: "explicit", args: []string{"-replay", manifest, "-reputation-key", "key", "-splunk-token", "token", "-retry", "1"}, retries: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, flagSet := parseFlags(t, test.args...)
+			err := options.configureReplay(flagSet.flags)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !options.A || options.Retries != test.retries || options.ReputationKey != "key" || options.SplunkToken != "token" {
+				t.Errorf("got a %v, retries %d, key %q and token %q", options.A, options.Retries, options.ReputationKey, options.SplunkToken)
+			}
+			if options.Resolvers != "udp:192.0.2.53:53" {
+				t.Errorf("got resolvers %s", options.Resolvers)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	severityLow = iota
+	severityMedium
+	severityHigh
+
+	// notifyBatchSize is the number of findings sent in a single alert
+	notifyBatchSize = 20
+	// notifyAttempts is the number of attempts of an alert before it's dropped
+	notifyAttempts = 3
+	// discordMaxContent is the length limit of a discord message
+	discordMaxContent = 2000
+	notifyTimeout     = 30 * time.Second
+)
+
+// severityNames are the names of the severities by level
+var severityNames = []string{"low", "medium", "high"}
+
+// finding is a high signal annotation of a result sent as an alert
+type finding struct {
+	Host      string            `json:"host"`
+	Type      string            `json:"type"`
+	Severity  string            `json:"severity"`
+	Evidence  string            `json:"evidence"`
+	Timestamp time.Time         `json:"timestamp"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// findingSeverities are the severities of the finding types
+var findingSeverities = map[string]int{
+	"stale-glue":           severityHigh,
+	"spoof-suspect":        severityHigh,
+	"dnsbl":                severityHigh,
+	"low-reputation":       severityHigh,
+	"observed-changed":     severityMedium,
+	"suspicious-authority": severityMedium,
+	"transport-diff":       severityMedium,
+	"subzone":              severityMedium,
+	"lint":                 severityLow,
+}
+
+// notifyConfig is the notify style configuration of the alert providers
+type notifyConfig struct {
+	Slack []struct {
+		WebhookURL string `yaml:"slack_webhook_url"`
+	} `yaml:"slack"`
+	Discord []struct {
+		WebhookURL string `yaml:"discord_webhook_url"`
+	} `yaml:"discord"`
+	Webhook []struct {
+		URL     string            `yaml:"webhook_url"`
+		Headers map[string]string `yaml:"headers"`
+	} `yaml:"webhook"`
+	// Severity is the minimum severity of the findings sent (low, medium, high)
+	Severity string `yaml:"severity"`
+}
+
+// notifyProvider is a destination of the alerts
+type notifyProvider struct {
+	kind    string
+	url     string
+	headers map[string]string
+}
+
+// notifier sends the findings of the results to the alert providers in batches, it's only
+// used from the output worker
+type notifier struct {
+	providers       []notifyProvider
+	minSeverity     int
+	reputationLimit int
+	max             int
+	sent            int
+	capped          bool
+	pending         []finding
+	http            *http.Client
+}
+
+func newNotifier(path string, max, reputationLimit int) (*notifier, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config notifyConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "could not parse notify config")
+	}
+	n := &notifier{minSeverity: severityMedium, reputationLimit: reputationLimit, max: max, http: &http.Client{Timeout: notifyTimeout}}
+	if config.Severity != "" {
+		severity := strings.ToLower(config.Severity)
+		if !contains(severityNames, severity) {
+			return nil, errors.Errorf("invalid notify severity %s (allowed: %s)", config.Severity, strings.Join(severityNames, ", "))
+		}
+		for level, name := range severityNames {
+			if name == severity {
+				n.minSeverity = level
+			}
+		}
+	}
+	for _, slack := range config.Slack {
+		n.providers = append(n.providers, notifyProvider{kind: "slack", url: os.ExpandEnv(slack.WebhookURL)})
+	}
+	for _, discord := range config.Discord {
+		n.providers = append(n.providers, notifyProvider{kind: "discord", url: os.ExpandEnv(discord.WebhookURL)})
+	}
+	for _, webhook := range config.Webhook {
+		n.providers = append(n.providers, notifyProvider{kind: "webhook", url: os.ExpandEnv(webhook.URL), headers: webhook.Headers})
+	}
+	for _, provider := range n.providers {
+		if provider.url == "" {
+			return nil, errors.Errorf("missing %s webhook url in notify config", provider.kind)
+		}
+	}
+	if len(n.providers) == 0 {
+		return nil, errors.New("no provider in notify config")
+	}
+	return n, nil
+}
+
+// add queues the findings of the result reaching the minimum severity, the batch is sent once full
+func (n *notifier) add(event *outputEvent) {
+	if event.status != statusMatched || event.result == nil {
+		return
+	}
+	for _, finding := range resultFindings(event.result, n.reputationLimit) {
+		if findingSeverities[finding.Type] < n.minSeverity {
+			continue
+		}
+		if n.max > 0 && n.sent+len(n.pending) >= n.max {
+			if !n.capped {
+				n.capped = true
+				gologger.Warning().Msgf("Max notifications reached (%d), the next findings are not sent\n", n.max)
+			}
+			return
+		}
+		n.pending = append(n.pending, finding)
+		if len(n.pending) >= notifyBatchSize {
+			n.flush()
+		}
+	}
+}
+
+// flush sends the pending findings to every provider
+func (n *notifier) flush() {
+	if len(n.pending) == 0 {
+		return
+	}
+	for _, provider := range n.providers {
+		if err := n.send(provider, n.pending); err != nil {
+			gologger.Warning().Msgf("Could not send %d findings to %s: %s\n", len(n.pending), provider.kind, err)
+		}
+	}
+	n.sent += len(n.pending)
+	n.pending = nil
+}
+
+// send posts the findings in the format of the provider, discord messages are split to fit its limit
+func (n *notifier) send(provider notifyProvider, findings []finding) error {
+	var bodies []interface{}
+	switch provider.kind {
+	case "slack":
+		bodies = append(bodies, map[string]string{"text": findingsText(findings)})
+	case "discord":
+		var message string
+		for _, finding := range findings {
+			line := findingLine(finding)
+			if message != "" && len(message)+len(line)+1 > discordMaxContent {
+				bodies = append(bodies, map[string]string{"content": message})
+				message = ""
+			}
+			message += line + NewLine
+		}
+		bodies = append(bodies, map[string]string{"content": message})
+	default:
+		bodies = append(bodies, findings)
+	}
+	for _, body := range bodies {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		if err := n.post(provider, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends the body to the provider, retrying transient failures with a backoff
+func (n *notifier) post(provider notifyProvider, body []byte) error {
+	var err error
+	for attempt := 0; attempt < notifyAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		var retry bool
+		retry, err = n.postOnce(provider, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// postOnce sends the body once and reports whether a failure is worth retrying
+func (n *notifier) postOnce(provider notifyProvider, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, provider.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range provider.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	// nolint:errcheck
+	io.Copy(ioutil.Discard, resp.Body)
+	return false, nil
+}
+
+// resultFindings returns the findings of the result, whatever their severity
+func resultFindings(result *dnsResult, reputationLimit int) []finding {
+	var findings []finding
+	add := func(kind, evidence string) {
+		severity := severityNames[findingSeverities[kind]]
+		findings = append(findings, finding{Host: result.Host, Type: kind, Severity: severity, Evidence: evidence, Timestamp: result.Timestamp, Labels: result.Labels})
+	}
+	for _, nameserver := range result.StaleGlue {
+		add("stale-glue", "glue of nameserver "+nameserver+" doesn't match its addresses")
+	}
+	if result.SpoofSuspect {
+		add("spoof-suspect", fmt.Sprintf("%d conflicting responses", len(result.SpoofResponses)))
+	}
+	for _, listing := range result.DNSBL {
+		add("dnsbl", fmt.Sprintf("%s listed on %s (%s)", listing.IP, listing.Zone, listing.Code))
+	}
+	for _, report := range result.Reputation {
+		if report.Score < reputationLimit {
+			add("low-reputation", fmt.Sprintf("%s scored %d on %s", report.IP, report.Score, report.Provider))
+		}
+	}
+	if result.ObservedChanged {
+		add("observed-changed", "observed "+strings.Join(result.Observed, Comma))
+	}
+	if len(result.SuspiciousAuthority) > 0 {
+		add("suspicious-authority", strings.Join(result.SuspiciousAuthority, Comma))
+	}
+	if result.TransportDiff {
+		add("transport-diff", "udp and tcp answers differ")
+	}
+	if result.Subzone != "" {
+		add("subzone", "delegated subzone "+result.Subzone)
+	}
+	for _, lint := range result.Lint {
+		add("lint", lint.Check+": "+lint.Detail)
+	}
+	return findings
+}
+
+// findingLine returns the text of a finding in the chat alerts
+func findingLine(f finding) string {
+	line := fmt.Sprintf("[%s] %s %s: %s", f.Severity, f.Host, f.Type, f.Evidence)
+	if len(f.Labels) == 0 {
+		return line
+	}
+	keys := make([]string, 0, len(f.Labels))
+	for key := range f.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + f.Labels[key]
+	}
+	return line + " (" + strings.Join(keys, " ") + ")"
+}
+
+func findingsText(findings []finding) string {
+	var lines []string
+	for _, finding := range findings {
+		lines = append(lines, findingLine(finding))
+	}
+	return strings.Join(lines, NewLine)
+}
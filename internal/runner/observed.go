@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/dnsx/internal/capture"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	inputFormatPcap   = "pcap"
+	inputFormatDnstap = "dnstap"
+)
+
+// inputScanner returns a scanner over the hosts of the list input, captures are
+// parsed upfront and their observed answers kept for compare-observed
+func (r *Runner) inputScanner(input io.Reader) (*bufio.Scanner, error) {
+	var (
+		c   *capture.Capture
+		err error
+	)
+	switch r.options.InputFormat {
+	case inputFormatPcap:
+		c, err = capture.ReadPcap(bufio.NewReader(input))
+	case inputFormatDnstap:
+		c, err = capture.ReadDnstap(bufio.NewReader(input))
+	default:
+		return newLineScanner(input, r.options.MaxLineLength), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s input", r.options.InputFormat)
+	}
+	if c.Malformed > 0 {
+		gologger.Warning().Msgf("Skipped %d malformed %s frames\n", c.Malformed, r.options.InputFormat)
+	}
+	gologger.Info().Msgf("Extracted %d names from %s input\n", len(c.Names), r.options.InputFormat)
+	r.observed = c.Observed
+	return bufio.NewScanner(strings.NewReader(strings.Join(c.Names, NewLine))), nil
+}
+
+// compareObserved annotates the result with the answers observed in the capture when they differ
+func (r *Runner) compareObserved(result *dnsResult) {
+	observed, ok := r.observed[strings.ToLower(result.Host)]
+	if !ok {
+		return
+	}
+	var current []string
+	current = append(current, result.A...)
+	current = append(current, result.AAAA...)
+	current = append(current, result.CNAME...)
+	result.Observed = observed
+	result.ObservedChanged = strings.Join(normalizeAnswers(observed), Comma) != strings.Join(normalizeAnswers(current), Comma)
+}
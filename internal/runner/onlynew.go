@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+)
+
+// maxPreviousLine bounds the length of the lines of a previous output, json results with raw
+// responses can be long
+const maxPreviousLine = 16 << 20
+
+// loadPreviousOutput returns the names found in the plain or json output of a previous run,
+// the host of json lines and the first value of plain lines are used
+func loadPreviousOutput(path, separator string) (map[string]struct{}, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := make(map[string]struct{})
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 4096), maxPreviousLine)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var name string
+		if strings.HasPrefix(line, "{") {
+			var result struct {
+				Host string `json:"host"`
+			}
+			if err := json.Unmarshal([]byte(line), &result); err == nil {
+				name = result.Host
+			}
+		} else if separator != "" {
+			name = strings.SplitN(line, separator, 2)[0]
+		} else {
+			name = strings.Fields(line)[0]
+		}
+		if name != "" {
+			names[strings.ToLower(name)] = struct{}{}
+		}
+	}
+	return names, sc.Err()
+}
+
+// isPrevious reports whether the name was already in the output of the previous run
+func (r *Runner) isPrevious(name string) bool {
+	if r.previous == nil {
+		return false
+	}
+	_, ok := r.previous[strings.ToLower(name)]
+	return ok
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"sync"
+
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+)
+
+// filterOpenResolvers probes the resolvers for unrestricted recursion and warns about the open
+// ones, they are removed from the returned list when skipping is requested. The default
+// resolvers are public services and are never probed.
+func filterOpenResolvers(dnsxOptions dnsx.Options, threads int, skip bool) ([]string, error) {
+	prober, err := dnsx.New(dnsxOptions)
+	if err != nil {
+		return nil, err
+	}
+	public := make(map[string]struct{}, len(dnsx.DefaultResolvers))
+	for _, resolver := range dnsx.DefaultResolvers {
+		public[resolver] = struct{}{}
+	}
+
+	if threads < 1 {
+		threads = 1
+	}
+	resolvers := dnsxOptions.BaseResolvers
+	open := make([]bool, len(resolvers))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, threads)
+	for i, resolver := range resolvers {
+		if _, ok := public[resolver]; ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, resolver string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			isOpen, err := prober.IsOpenResolver(resolver)
+			if err != nil {
+				gologger.Debug().Msgf("Could not probe resolver %s for recursion: %s\n", resolver, err)
+				return
+			}
+			open[i] = isOpen
+		}(i, resolver)
+	}
+	wg.Wait()
+
+	var kept []string
+	var found int
+	for i, resolver := range resolvers {
+		if !open[i] {
+			kept = append(kept, resolver)
+			continue
+		}
+		found++
+		gologger.Warning().Msgf("Resolver %s is an open resolver, it performs recursion for external queries\n", resolver)
+		if !skip {
+			kept = append(kept, resolver)
+		}
+	}
+	if found > 0 && skip {
+		gologger.Info().Msgf("Skipped %d open resolvers\n", found)
+	}
+	return kept, nil
+}
@@ -1,12 +1,21 @@
 package runner
 
 import (
-	"errors"
+	"flag"
+	"fmt"
 	"math"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/internal/reputation"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
 	"github.com/projectdiscovery/fileutil"
 	"github.com/projectdiscovery/goconfig"
 	"github.com/projectdiscovery/goflags"
@@ -18,6 +27,12 @@ const (
 	DefaultResumeFile = "resume.cfg"
 )
 
+// Values of the key-by option
+const (
+	keyByHost  = "host"
+	keyByInput = "input"
+)
+
 type Options struct {
 	Resolvers         string
 	Hosts             string
@@ -26,8 +41,15 @@ type Options struct {
 	Threads           int
 	RateLimit         int
 	Retries           int
+	TimeoutPerType    string
+	RetriesPerType    string
+	typeTimeouts      map[uint16]time.Duration
+	typeRetries       map[uint16]int
 	OutputFormat      string
-	OutputFile        string
+	Output            goflags.StringSlice
+	OutputFilter      goflags.StringSlice
+	Labels            goflags.StringSlice
+	labels            []resultLabel
 	Raw               bool
 	Silent            bool
 	Verbose           bool
@@ -42,20 +64,182 @@ type Options struct {
 	MX                bool
 	SOA               bool
 	TXT               bool
+	DNAME             bool
+	FollowDNAME       bool
 	JSON              bool
 	Trace             bool
 	TraceMaxRecursion int
+	TraceStartServer  string
 	WildcardThreshold int
+	WildcardProbes    int
 	WildcardDomain    string
 	ShowStatistics    bool
+	NoRecover         bool
 	rcodes            map[int]struct{}
 	RCode             string
 	hasRCodes         bool
+	RCodeDisplay      bool
+	hasRecordFlags    bool
+	sinks             []sinkSpec
+	separator         string
+	zoneOverrides     map[string][]string
+	sourcePortMin     int
+	sourcePortMax     int
+	outputsZone       bool
 	Resume            bool
 	resumeCfg         *ResumeCfg
 	FlushInterval     int
 	HostsFile         bool
 	Stream            bool
+	ControlSocket     string
+	Typo              bool
+	TypoMax           int
+	SLDPermute        bool
+	SLDDict           string
+	TLDEnum           bool
+	TLDList           string
+	SmartBrute        bool
+	SmartBruteMax     int
+	DiscoverSubzones  bool
+	TTLWatch          string
+	ttlWatchInterval  time.Duration
+	TTLThreshold      int
+	TTLWatchPasses    int
+	MonitorFull       bool
+	DiscoverResolvers string
+	OpenResolverCheck bool
+	SkipOpenResolver  bool
+	MinResolvers      int
+	Repeat            int
+	RepeatDelay       string
+	repeatDelay       time.Duration
+	MaxRuntime        string
+	MaxDomainQueries  int
+	maxRuntime        time.Duration
+	MDNS              bool
+	LLMNR             bool
+	GlueCheck         bool
+	NBNS              bool
+	NBNSTarget        string
+	LocalResolve      string
+	CacheSize         int
+	HostsOutput       string
+	OnlyNew           string
+	Timings           string
+	Syslog            bool
+	UniqueIPs         bool
+	PTRCSV            bool
+	SummarizeCIDRs    bool
+	PreservePort      bool
+	ZoneFile          string
+	ZoneOrigin        string
+	ZoneOverride      string
+	PTRZonePrecheck   bool
+	Precheck          bool
+	PrecheckSample    int
+	Yes               bool
+	ZoneOutput        bool
+	OutputZone        string
+	ZoneTTL           bool
+	ShowResolver      bool
+	ServerCaps        bool
+	RcodeStats        bool
+	ShowLatency       bool
+	ShowRetries       bool
+	FirstOnly         bool
+	PreferIPv4        bool
+	PreferIPv6        bool
+	Exec              string
+	ExecStdin         bool
+	ExecThreads       int
+	SplunkURL         string
+	SplunkToken       string
+	SplunkBatchSize   int
+	DomainConcurrency int
+	MaxLineLength     int
+	ForceLargeCIDR    bool
+	CIDRSampleDensity int
+	UDP               bool
+	TCP               bool
+	UDPTCP            bool
+	TransportDiff     bool
+	CacheSnoop        bool
+	TransportDiffTTL  int
+	ValidateAuthority bool
+	ValidateNames     bool
+	Lint              bool
+	DNSBL             string
+	IPReputation      bool
+	ReputationService string
+	ReputationKey     string
+	ReputationLimit   int
+	ReputationRate    int
+	ReputationFilter  bool
+	Categorize        string
+	RankDB            string
+	dnsblZones        []string
+	Geo               bool
+	GeoDB             string
+	GeoFilter         string
+	geoInclude        map[string]struct{}
+	geoExclude        map[string]struct{}
+	Scope             string
+	ScopeFile         string
+	ScopeCIDR         string
+	SourcePort        int
+	SourcePortRange   string
+	DetectSpoofing    bool
+	DNSCookie         bool
+	PinResolver       bool
+	SpoofWindow       string
+	spoofWindow       time.Duration
+	InputFormat       string
+	CompareObserved   bool
+	KeyBy             string
+	Separator         string
+	DNSUpdateAdd      goflags.StringSlice
+	DNSUpdateDelete   goflags.StringSlice
+	DNSUpdateZone     string
+	Split             int
+	StateDir          string
+	Coordinator       string
+	CoordinatorToken  string
+	Worker            string
+	JobSize           int
+	JobLease          string
+	Serve             string
+	ServeFrom         string
+	Manifest          string
+	Replay            string
+	seed              int64
+	replay            *runManifest
+	flagValues        map[string][]string
+	NotifyConfig      string
+	NotifyMax         int
+	jobLease          time.Duration
+	updateInsert      []dns.RR
+	updateRemove      []dns.RR
+	TSIGKey           string
+	tsigKey           *dnsx.TSIGKey
+	Targets           []string
+	OnResult          func(*Result)
+}
+
+// dnsUpdate reports whether the options request a dynamic update instead of a scan
+func (options *Options) dnsUpdate() bool {
+	return len(options.updateInsert) > 0 || len(options.updateRemove) > 0
+}
+
+// transport returns the protocol of the queries selected by the options
+func (options *Options) transport() string {
+	switch {
+	case options.UDP:
+		return dnsx.TransportUDP
+	case options.TCP:
+		return dnsx.TransportTCP
+	default:
+		return dnsx.TransportUDPTCP
+	}
 }
 
 // ShouldLoadResume resume file
@@ -63,15 +247,169 @@ func (options *Options) ShouldLoadResume() bool {
 	return options.Resume && fileutil.FileExists(DefaultResumeFile)
 }
 
-// ShouldSaveResume file
+// ShouldSaveResume file, the position of the input isn't tracked when several domains
+// are expanded in parallel
 func (options *Options) ShouldSaveResume() bool {
-	return true
+	return options.Domains == "" || options.DomainConcurrency <= 1
 }
 
 // ParseOptions parses the command line options for application
 func ParseOptions() *Options {
 	options := &Options{}
+	flagSet := newFlagSet(options)
+	_ = flagSet.Parse()
+
+	// the replayed options are configured like the command line ones
+	err := options.configureReplay(flag.CommandLine)
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+	options.flagValues = commandLineOptions(flag.CommandLine)
+
+	// Read the inputs and configure the logging
+	options.configureOutput()
+
+	err = options.configureRcodes()
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+
+	err = options.configureResume()
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+
+	err = options.configureTypeOverrides()
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+
+	err = options.configureLabels()
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+
+	showBanner()
+
+	if options.Version {
+		gologger.Info().Msgf("Current Version: %s\n", Version)
+		os.Exit(0)
+	}
+
+	err = options.validateOptions()
+	if err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+
+	return options
+}
+
+// DefaultOptions returns the options of a run without command line arguments. The flags are
+// defined on a private flag set, the global command line is left untouched.
+func DefaultOptions() *Options {
+	options := &Options{}
+	defineFlags(newDefaultsFlagSet(), options)
+	return options
+}
+
+// Configure parses and validates the options built outside of the command line
+func (options *Options) Configure() error {
+	if err := options.configureRcodes(); err != nil {
+		return err
+	}
+	if err := options.configureResume(); err != nil {
+		return err
+	}
+	if err := options.configureTypeOverrides(); err != nil {
+		return err
+	}
+	if err := options.configureLabels(); err != nil {
+		return err
+	}
+	return options.validateOptions()
+}
+
+// flagDefiner defines the flags of the options, implemented by the goflags command line and
+// by the private flag set of DefaultOptions
+type flagDefiner interface {
+	SetDescription(description string)
+	SetGroup(name, description string)
+	BoolVar(field *bool, long string, defaultValue bool, usage string) *goflags.FlagData
+	BoolVarP(field *bool, long, short string, defaultValue bool, usage string) *goflags.FlagData
+	IntVar(field *int, long string, defaultValue int, usage string) *goflags.FlagData
+	IntVarP(field *int, long, short string, defaultValue int, usage string) *goflags.FlagData
+	StringVar(field *string, long, defaultValue, usage string) *goflags.FlagData
+	StringVarP(field *string, long, short, defaultValue, usage string) *goflags.FlagData
+	StringSliceVar(field *goflags.StringSlice, long string, defaultValue goflags.StringSlice, usage string) *goflags.FlagData
+	StringSliceVarP(field *goflags.StringSlice, long, short string, defaultValue goflags.StringSlice, usage string) *goflags.FlagData
+}
+
+// defaultsFlagSet defines the flags on a private flag set, goflags only defines them on the
+// global command line
+type defaultsFlagSet struct {
+	flags *flag.FlagSet
+}
+
+func newDefaultsFlagSet() *defaultsFlagSet {
+	return &defaultsFlagSet{flags: flag.NewFlagSet("dnsx", flag.ContinueOnError)}
+}
+
+func (d *defaultsFlagSet) SetDescription(string) {}
+
+func (d *defaultsFlagSet) SetGroup(string, string) {}
+
+func (d *defaultsFlagSet) BoolVar(field *bool, long string, defaultValue bool, usage string) *goflags.FlagData {
+	d.flags.BoolVar(field, long, defaultValue, usage)
+	return &goflags.FlagData{}
+}
+
+func (d *defaultsFlagSet) BoolVarP(field *bool, long, short string, defaultValue bool, usage string) *goflags.FlagData {
+	d.flags.BoolVar(field, short, defaultValue, usage)
+	return d.BoolVar(field, long, defaultValue, usage)
+}
+
+func (d *defaultsFlagSet) IntVar(field *int, long string, defaultValue int, usage string) *goflags.FlagData {
+	d.flags.IntVar(field, long, defaultValue, usage)
+	return &goflags.FlagData{}
+}
+
+func (d *defaultsFlagSet) IntVarP(field *int, long, short string, defaultValue int, usage string) *goflags.FlagData {
+	d.flags.IntVar(field, short, defaultValue, usage)
+	return d.IntVar(field, long, defaultValue, usage)
+}
+
+func (d *defaultsFlagSet) StringVar(field *string, long, defaultValue, usage string) *goflags.FlagData {
+	d.flags.StringVar(field, long, defaultValue, usage)
+	return &goflags.FlagData{}
+}
+
+func (d *defaultsFlagSet) StringVarP(field *string, long, short, defaultValue, usage string) *goflags.FlagData {
+	d.flags.StringVar(field, short, defaultValue, usage)
+	return d.StringVar(field, long, defaultValue, usage)
+}
+
+func (d *defaultsFlagSet) StringSliceVar(field *goflags.StringSlice, long string, defaultValue goflags.StringSlice, usage string) *goflags.FlagData {
+	for _, item := range defaultValue {
+		_ = field.Set(item)
+	}
+	d.flags.Var(field, long, usage)
+	return &goflags.FlagData{}
+}
+
+func (d *defaultsFlagSet) StringSliceVarP(field *goflags.StringSlice, long, short string, defaultValue goflags.StringSlice, usage string) *goflags.FlagData {
+	d.flags.Var(field, short, usage)
+	return d.StringSliceVar(field, long, defaultValue, usage)
+}
+
+// newFlagSet defines the command line flags, their default values are set on the options
+func newFlagSet(options *Options) *goflags.FlagSet {
 	flagSet := goflags.NewFlagSet()
+	defineFlags(flagSet, options)
+	return flagSet
+}
+
+// defineFlags defines the flags of the options on the flag set
+func defineFlags(flagSet flagDefiner, options *Options) {
 	flagSet.SetDescription(`dnsx is a fast and multi-purpose DNS toolkit allow to run multiple probes using retryabledns library.`)
 
 	createGroup(flagSet, "input", "Input",
@@ -79,6 +417,27 @@ func ParseOptions() *Options {
 		flagSet.StringVarP(&options.Hosts, "list", "l", "", "list of sub(domains)/hosts to resolve (file or stdin)"),
 		flagSet.StringVarP(&options.Domains, "domain", "d", "", "list of domain to bruteforce (file or comma separated or stdin)"),
 		flagSet.StringVarP(&options.WordList, "wordlist", "w", "", "list of words to bruteforce (file or comma separated or stdin)"),
+		flagSet.StringVar(&options.ZoneFile, "zone-file", "", "bind format zone file whose record names are resolved"),
+		flagSet.StringVar(&options.ZoneOrigin, "zone-origin", "", "origin of the relative names of the zone file (default $ORIGIN or the file name, eg. example.com.zone)"),
+		flagSet.IntVar(&options.Split, "split", 0, "write the input hosts to N shard files (hosts_shard_1.txt...) without querying them"),
+		flagSet.StringVar(&options.InputFormat, "input-format", "", "format of the list input, the query names are extracted from captures (pcap, dnstap)"),
+		flagSet.BoolVar(&options.CompareObserved, "compare-observed", false, "flag names whose answers differ from the ones observed in the capture"),
+		flagSet.BoolVar(&options.Typo, "typo", false, "resolve typosquatting permutations of the input domains"),
+		flagSet.IntVar(&options.TypoMax, "typo-max", 100, "max number of typo and sld-permute permutations per domain"),
+		flagSet.BoolVar(&options.SLDPermute, "sld-permute", false, "resolve lookalike permutations of the second level label of the input domains (examp1e, examplee, exam-ple...)"),
+		flagSet.StringVar(&options.SLDDict, "sld-dict", "", "homoglyph dictionary of sld-permute, one source:lookalike[,lookalike...] per line (eg. o:0)"),
+		flagSet.BoolVar(&options.TLDEnum, "tld-enum", false, "resolve the input names under every top level domain (example -> example.com, example.net...) and report the registered ones"),
+		flagSet.StringVar(&options.TLDList, "tld-list", "", "file or url of the top level domains of tld-enum, one per line (default bundled list)"),
+		flagSet.BoolVar(&options.SmartBrute, "smart-brute", false, "resolve a second pass of names mutating the numbers, environments and regions of the resolved hosts"),
+		flagSet.IntVar(&options.SmartBruteMax, "smart-brute-max", 1000, "max number of names generated by smart-brute"),
+		flagSet.BoolVar(&options.DiscoverSubzones, "discover-subzones", false, "detect delegated subzones from the authority of empty responses and resolve the wordlist under them"),
+		flagSet.BoolVar(&options.PreservePort, "preserve-port", false, "keep the port of host:port inputs in the output"),
+		flagSet.IntVar(&options.MaxLineLength, "max-line-length", 4096, "maximum length of the input lines, longer lines are skipped"),
+		flagSet.BoolVar(&options.ForceLargeCIDR, "force-large-cidr", false, "expand every address of the cidrs larger than /24, they are skipped otherwise"),
+		flagSet.IntVar(&options.CIDRSampleDensity, "cidr-sample-density", 0, "resolve one random address in every N of the cidrs larger than /24"),
+		flagSet.StringVar(&options.Scope, "scope", "", "registered domains in scope, other input hosts are dropped (comma separated)"),
+		flagSet.StringVar(&options.ScopeFile, "scope-file", "", "file with the registered domains in scope"),
+		flagSet.StringVar(&options.ScopeCIDR, "scope-cidr", "", "ip ranges in scope, other input ips are dropped (file or comma separated)"),
 	)
 
 	createGroup(flagSet, "query", "Query",
@@ -90,22 +449,91 @@ func ParseOptions() *Options {
 		flagSet.BoolVar(&options.PTR, "ptr", false, "query PTR record"),
 		flagSet.BoolVar(&options.MX, "mx", false, "query MX record"),
 		flagSet.BoolVar(&options.SOA, "soa", false, "query SOA record"),
+		flagSet.BoolVar(&options.DNAME, "dname", false, "query DNAME record"),
+		flagSet.BoolVar(&options.FollowDNAME, "follow-dname", false, "resolve the names rewritten by the DNAME records covering the hosts"),
+		flagSet.BoolVar(&options.MDNS, "mdns", false, "query using multicast dns (.local names)"),
+		flagSet.BoolVar(&options.LLMNR, "llmnr", false, "query using link-local multicast name resolution"),
+		flagSet.BoolVar(&options.NBNS, "nbns", false, "query using netbios name service (ip inputs return their netbios names)"),
+		flagSet.StringVar(&options.NBNSTarget, "nbns-target", dnsx.NBNSBroadcastAddress, "broadcast or unicast address receiving netbios name queries"),
+		flagSet.BoolVar(&options.UDP, "udp", false, "send all queries over udp, truncated responses are not retried over tcp"),
+		flagSet.BoolVar(&options.TCP, "tcp", false, "send all queries over tcp"),
+		flagSet.BoolVar(&options.UDPTCP, "udp-tcp", false, "send queries with the protocol of each resolver, truncated udp responses are retried over tcp (default)"),
+		flagSet.BoolVar(&options.TransportDiff, "transport-diff", false, "send each query over both udp and tcp and flag the hosts whose answers differ"),
+		flagSet.IntVar(&options.TransportDiffTTL, "transport-diff-ttl", 5, "ttl difference in seconds ignored by transport-diff"),
+		flagSet.BoolVar(&options.CacheSnoop, "cache-snoop", false, "send the queries without recursion to each resolver and report whether the hosts are in their cache (CACHED, NOT_CACHED)"),
+		flagSet.BoolVar(&options.ValidateAuthority, "validate-authority", false, "flag responses whose authority section claims a zone unrelated to the queried host"),
+		flagSet.BoolVar(&options.ValidateNames, "validate-names", false, "report the names of the responses having illegal characters, labels over 63 octets, names over 255 octets or embedded dots and nulls (name_violations)"),
+		flagSet.StringVar(&options.DNSBL, "dnsbl", "", "dns blacklist zones checked for the resolved addresses (eg. -dnsbl zen.spamhaus.org,bl.spamcop.net)"),
+		flagSet.BoolVar(&options.IPReputation, "ip-reputation", false, "look up the reputation of the resolved addresses on the reputation-provider service"),
+		flagSet.StringVar(&options.ReputationService, "reputation-provider", reputation.ProviderAbuseIPDB, "service looking up the reputation of the addresses (abuseipdb, virustotal)"),
+		flagSet.StringVar(&options.ReputationKey, "reputation-key", "", "api key of the ip reputation service"),
+		flagSet.IntVar(&options.ReputationLimit, "reputation-threshold", 50, "reputation score (0-100) below which the addresses are flagged"),
+		flagSet.IntVar(&options.ReputationRate, "reputation-rate", 60, "maximum number of reputation requests per minute"),
+		flagSet.BoolVar(&options.ReputationFilter, "reputation-filter", false, "remove the addresses scoring below the reputation threshold, implies ip-reputation"),
+		flagSet.StringVar(&options.Categorize, "categorize", "", "yaml config of the dns categorization providers whose categories of the hosts are added to the json output"),
+		flagSet.StringVar(&options.RankDB, "rank-db", "", "tranco or alexa top sites csv (rank,domain) whose rank of the hosts is added to the json output, -1 when unranked"),
+		flagSet.BoolVar(&options.Geo, "geo", false, "geolocate the A and AAAA records (country, city, asn) with the geo-db database"),
+		flagSet.StringVar(&options.GeoDB, "geo-db", "", "maxmind db file used by geo (eg. GeoLite2-City.mmdb)"),
+		flagSet.StringVar(&options.GeoFilter, "geo-filter", "", "countries whose addresses are kept, ! excludes a country (eg. -geo-filter US,DE or -geo-filter '!CN'), implies geo"),
+		flagSet.BoolVar(&options.Lint, "lint", false, "flag protocol violations of the responses (cname-and-other, cname-at-apex, mx-to-cname, ns-to-cname, mx-no-address, spf-lookups, soa-rname-at)"),
+		flagSet.BoolVar(&options.DetectSpoofing, "detect-spoofing", false, "listen for conflicting late udp responses to each query (spoofing attempts), adds spoof-window to each query"),
+		flagSet.StringVar(&options.SpoofWindow, "spoof-window", "250ms", "time to listen for conflicting responses after the first one"),
+		flagSet.BoolVar(&options.DNSCookie, "dns-cookie", false, "send dns cookies (rfc 7873) echoing the server cookie of each resolver"),
 	)
 
 	createGroup(flagSet, "filters", "Filters",
 		flagSet.BoolVar(&options.Response, "resp", false, "display dns response"),
 		flagSet.BoolVar(&options.ResponseOnly, "resp-only", false, "display dns response only"),
-		flagSet.StringVarP(&options.RCode, "rc", "rcode", "", "filter result by dns status code (eg. -rcode noerror,servfail,refused)"),
+		flagSet.StringVarP(&options.RCode, "rc", "rcode", "", "filter result by dns status code names or values (eg. -rcode noerror,servfail,refused or -rcode all-errors)"),
+		flagSet.BoolVar(&options.RCodeDisplay, "rcode-display", false, "append the dns status code to the record output"),
+		flagSet.BoolVar(&options.GlueCheck, "glue-check", false, "flag ns glue records differing from a fresh lookup of the name server (requires -ns)"),
+	)
+
+	createGroup(flagSet, "ttl-watch", "TTL-Watch",
+		flagSet.StringVar(&options.TTLWatch, "ttl-watch", "", "periodically re-query the hosts and display ttl changes (eg. -ttl-watch 5m)"),
+		flagSet.IntVar(&options.TTLThreshold, "ttl-threshold", 10, "ttl change percentage to display in ttl-watch mode"),
+		flagSet.IntVar(&options.TTLWatchPasses, "ttl-watch-passes", 0, "number of ttl-watch passes before exiting (0 for unlimited)"),
+		flagSet.BoolVar(&options.MonitorFull, "monitor-full", false, "re-query every host in ttl-watch mode, including the ones of zones whose soa serial didn't change"),
 	)
 
 	createGroup(flagSet, "rate-limit", "Rate-limit",
 		flagSet.IntVarP(&options.Threads, "c", "t", 100, "number of concurrent threads to use"),
+		flagSet.IntVar(&options.DomainConcurrency, "domain-concurrency", 1, "number of domains expanded in parallel with the wordlist"),
 		flagSet.IntVarP(&options.RateLimit, "rate-limit", "rl", -1, "number of dns request/second to make (disabled as default)"),
+		flagSet.IntVar(&options.MaxDomainQueries, "max-queries-per-domain", 0, "maximum number of queries sent for the hosts of each apex domain, the remaining hosts are dropped"),
 	)
 
 	createGroup(flagSet, "output", "Output",
-		flagSet.StringVarP(&options.OutputFile, "output", "o", "", "file to write output"),
+		flagSet.StringSliceVarP(&options.Output, "output", "o", nil, "file to write output, optionally with format and filter (file[:plain|json|raw|zone[:matched|all|resolved|failed]], stdout configures the screen)"),
+		flagSet.StringSliceVar(&options.OutputFilter, "output-filter", nil, "file receiving only the results matching the expression, conditions on type, rcode and host joined by && (eg. -output-filter a.txt:type==A -output-filter cnames.txt:type==CNAME)"),
 		flagSet.BoolVar(&options.JSON, "json", false, "write output in JSONL(ines) format"),
+		flagSet.StringSliceVar(&options.Labels, "label", nil, "key=value label added to every json and csv result and alert payload, repeat the flag for several labels (eg. -label program=acme -label run_id=2024-06-01)"),
+		flagSet.BoolVar(&options.ZoneOutput, "zone-output", false, "write output in bind zone file format"),
+		flagSet.BoolVar(&options.ZoneTTL, "ttl", false, "use the ttl of the responses in zone output"),
+		flagSet.StringVar(&options.OutputZone, "o-zone", "", "file to write the resolved records as a zone fragment with the ttl of the responses"),
+		flagSet.StringVar(&options.KeyBy, "key-by", keyByHost, "name displayed in plain output (host, input)"),
+		flagSet.StringVar(&options.Separator, "separator", "", "separator of the host and values in plain output instead of brackets (eg. -separator ',' or -separator '\\t')"),
+		flagSet.BoolVar(&options.ShowResolver, "show-resolver", false, "append the responding resolver to the output"),
+		flagSet.BoolVar(&options.ShowLatency, "show-latency", false, "append the query round-trip time to the output"),
+		flagSet.BoolVar(&options.ShowRetries, "show-retries", false, "append the number of retries needed to get the response to the output"),
+		flagSet.BoolVar(&options.FirstOnly, "first-only", false, "display only the first value of each record type in plain output (json output stays complete)"),
+		flagSet.BoolVar(&options.PreferIPv4, "prefer-ipv4", false, "display only the first ipv4 address with first-only when both A and AAAA are requested"),
+		flagSet.BoolVar(&options.PreferIPv6, "prefer-ipv6", false, "display only the first ipv6 address with first-only when both A and AAAA are requested"),
+		flagSet.StringVar(&options.HostsOutput, "hosts-output", "", "file to write resolved A/AAAA records in hosts file format"),
+		flagSet.BoolVar(&options.Syslog, "syslog", false, "send each query with its response code, resolver and latency to the local syslog daemon"),
+		flagSet.StringVar(&options.Timings, "timings", "", "csv file to write the timing of every query (host, qtype, resolver, attempt, rtt_ms, rcode, transport)"),
+		flagSet.StringVar(&options.OnlyNew, "only-new", "", "output of a previous run (plain or json), only the hosts missing from it are written"),
+		flagSet.BoolVar(&options.PTRCSV, "ptr-csv", false, "display the ptr records of the ip inputs as ip,ptr csv lines sorted by ip (implies -ptr)"),
+		flagSet.BoolVar(&options.SummarizeCIDRs, "summarize-cidrs", false, "display the minimal set of cidrs covering the resolved ips at the end of the run, wildcard ips are excluded"),
+		flagSet.BoolVar(&options.UniqueIPs, "unique-ips", false, "display the unique resolved ips instead of the hosts, wildcard ips are excluded"),
+		flagSet.StringVar(&options.Exec, "exec", "", "command to run for each output line, {} is replaced with the line passed as a shell argument (eg. -exec 'notify {}')"),
+		flagSet.BoolVar(&options.ExecStdin, "exec-stdin", false, "write the json result to the exec command stdin, {} is replaced with the host"),
+		flagSet.IntVar(&options.ExecThreads, "exec-threads", 10, "number of exec commands to run concurrently"),
+		flagSet.StringVar(&options.SplunkURL, "splunk-url", "", "splunk http event collector url the results are sent to"),
+		flagSet.StringVar(&options.SplunkToken, "splunk-token", "", "splunk http event collector token"),
+		flagSet.IntVar(&options.SplunkBatchSize, "splunk-batch-size", 100, "number of results sent to splunk in a single request"),
+		flagSet.StringVar(&options.NotifyConfig, "notify-config", "", "notify style config (slack, discord, webhook) receiving alerts for the findings of the results (stale-glue, spoof-suspect, dnsbl...)"),
+		flagSet.IntVar(&options.NotifyMax, "notify-max", 100, "maximum number of findings sent as alerts per run (0 for no limit)"),
 	)
 
 	createGroup(flagSet, "debug", "Debug",
@@ -113,96 +541,507 @@ func ParseOptions() *Options {
 		flagSet.BoolVarP(&options.Verbose, "verbose", "v", false, "display verbose output"),
 		flagSet.BoolVarP(&options.Raw, "debug", "raw", false, "display raw dns response"),
 		flagSet.BoolVar(&options.ShowStatistics, "stats", false, "display stats of the running scan"),
+		flagSet.BoolVar(&options.RcodeStats, "rcode-stats", false, "display the number of responses of each response code at the end of the run (NOERROR: 45000, NXDOMAIN: 12000...)"),
 		flagSet.BoolVar(&options.Version, "version", false, "display version of dnsx"),
+		flagSet.BoolVar(&options.NoRecover, "no-recover", false, "crash on the panics of the workers instead of recovering them (development)"),
 	)
 
 	createGroup(flagSet, "optimization", "Optimization",
 		flagSet.IntVar(&options.Retries, "retry", 2, "number of dns retries to make"),
+		flagSet.StringVar(&options.TimeoutPerType, "timeout-per-type", "", "query timeout of specific question types (eg. -timeout-per-type txt=5s,any=8s)"),
+		flagSet.StringVar(&options.RetriesPerType, "retries-per-type", "", "number of dns retries of specific question types (eg. -retries-per-type txt=4)"),
+		flagSet.IntVar(&options.Repeat, "repeat", 1, "number of times to query each host to check answers consistency"),
+		flagSet.StringVar(&options.RepeatDelay, "repeat-delay", "500ms", "delay between repeated queries"),
 		flagSet.BoolVarP(&options.HostsFile, "hostsfile", "hf", false, "use system host file"),
+		flagSet.StringVar(&options.LocalResolve, "local-resolve", "", "resolve only from the given hosts file without dns queries"),
+		flagSet.IntVar(&options.CacheSize, "cache-size", 0, "number of responses answered from memory until their ttl expires (0 to disable)"),
+		flagSet.BoolVar(&options.PTRZonePrecheck, "ptr-zone-precheck", false, "skip ptr queries of addresses whose reverse zone is missing or refused"),
+		flagSet.BoolVar(&options.Precheck, "precheck", false, "query a sample of the input with aggressive timeouts and report the expected failure rate and runtime before the scan"),
+		flagSet.IntVar(&options.PrecheckSample, "precheck-sample", 1, "percentage of the input hosts queried by precheck (10 to 1000 hosts)"),
+		flagSet.BoolVar(&options.Yes, "yes", false, "start the scan after the precheck estimate without asking for confirmation"),
 		flagSet.BoolVar(&options.Trace, "trace", false, "perform dns tracing"),
 		flagSet.IntVar(&options.TraceMaxRecursion, "trace-max-recursion", math.MaxInt16, "Max recursion for dns trace"),
+		flagSet.StringVar(&options.TraceStartServer, "trace-start-server", "", "comma separated servers the trace starts from instead of the root servers"),
 		flagSet.IntVar(&options.FlushInterval, "flush-interval", 10, "flush interval of output file"),
 		flagSet.BoolVar(&options.Resume, "resume", false, "resume existing scan"),
+		flagSet.StringVar(&options.StateDir, "state-dir", filepath.Join(os.TempDir(), "dnsx"), "base directory of the temporary files of the runs, the ones left by crashed runs are removed"),
+		flagSet.StringVar(&options.MaxRuntime, "max-runtime", "", "maximum duration of the scan (eg. 6h), in-flight queries are drained and the resume file is written when it's reached"),
 	)
 
 	createGroup(flagSet, "configs", "Configurations",
 		flagSet.StringVarP(&options.Resolvers, "resolver", "r", "", "list of resolvers to use (file or comma separated)"),
+		flagSet.BoolVar(&options.PinResolver, "pin-resolver-per-domain", false, "send the queries of the hosts of a registered domain to the same resolver, falling back to the others on failure"),
+		flagSet.BoolVar(&options.ServerCaps, "server-caps", false, "record edns, cookies, tcp and minimal responses support of the resolvers"),
+		flagSet.StringVar(&options.ZoneOverride, "zone-override", "", "resolvers to use for names under the given suffixes (eg. -zone-override corp=10.0.0.53:53,hns=127.0.0.1:5350)"),
+		flagSet.IntVar(&options.SourcePort, "source-port", 0, "source port of the queries (single thread recommended)"),
+		flagSet.StringVar(&options.SourcePortRange, "source-port-range", "", "range of the random source ports of the queries (eg. -source-port-range 20000-30000)"),
+		flagSet.StringVar(&options.DiscoverResolvers, "discover-resolvers", "", "discover resolvers published via SRV records for the domain (_dns._udp/_dns._tcp)"),
+		flagSet.BoolVar(&options.OpenResolverCheck, "check-open-resolvers", false, "warn about the resolvers performing recursion for external queries (open resolvers)"),
+		flagSet.BoolVar(&options.SkipOpenResolver, "skip-open-resolvers", false, "remove the open resolvers from the resolvers list"),
+		flagSet.IntVar(&options.MinResolvers, "min-resolvers", 0, "warn when fewer usable resolvers are left (0 to disable)"),
 		flagSet.IntVarP(&options.WildcardThreshold, "wildcard-threshold", "wt", 5, "wildcard filter threshold"),
+		flagSet.IntVar(&options.WildcardProbes, "wildcard-probes", 1, "random names resolved for each level in wildcard filtering (more for rotating wildcard pools)"),
 		flagSet.StringVarP(&options.WildcardDomain, "wildcard-domain", "wd", "", "domain name for wildcard filtering (other flags will be ignored)"),
+		flagSet.StringVar(&options.ControlSocket, "control-socket", "", "unix socket exposing runtime controls (status, pause/resume, rate-limit, threads, resolvers)"),
+		flagSet.StringVar(&options.TSIGKey, "tsig-key", "", "key signing the dynamic updates ([algorithm:]name:base64-secret, hmac-sha256 by default)"),
+		flagSet.StringSliceVar(&options.DNSUpdateAdd, "dns-update-add", nil, "record added by a dynamic update sent to the first resolver instead of scanning (eg. 'test.corp.local 300 A 10.0.0.1')"),
+		flagSet.StringSliceVar(&options.DNSUpdateDelete, "dns-update-delete", nil, "record deleted by a dynamic update, a name and type without data deletes the rrset, a name alone all of its rrsets"),
+		flagSet.StringVar(&options.DNSUpdateZone, "dns-update-zone", "", "zone of the dynamic update (default registered domain of the first record)"),
+		flagSet.StringVar(&options.Coordinator, "coordinator", "", "address serving the input as jobs to the worker instances and writing their results (eg. -coordinator :8787)"),
+		flagSet.StringVar(&options.CoordinatorToken, "coordinator-token", "", "shared token authenticating the workers to the coordinator"),
+		flagSet.StringVar(&options.Worker, "worker", "", "url of the coordinator whose jobs are resolved (eg. -worker http://10.0.0.1:8787)"),
+		flagSet.IntVar(&options.JobSize, "job-size", 1000, "number of hosts of the coordinator jobs"),
+		flagSet.StringVar(&options.JobLease, "job-lease", "5m", "time given to a worker to complete a job before it's handed to another worker"),
+		flagSet.StringVar(&options.Serve, "serve", "", "address answering the A, AAAA, CNAME and PTR queries from the results of a completed run instead of scanning (eg. -serve :5353)"),
+		flagSet.StringVar(&options.ServeFrom, "serve-from", "", "json output of the completed run served by serve"),
+		flagSet.StringVar(&options.Manifest, "manifest", "", "file to write the manifest of the run (options, resolvers, input hash, random seed) to replay it"),
+		flagSet.StringVar(&options.Replay, "replay", "", "manifest of a previous run whose options, resolvers and random seed are reused, the input is given as usual"),
 	)
+}
 
-	_ = flagSet.Parse()
+func (options *Options) validateOptions() error {
+	if options.Response && options.ResponseOnly {
+		return fmt.Errorf("resp and resp-only can't be used at the same time")
+	}
 
-	// Read the inputs and configure the logging
-	options.configureOutput()
+	wordListPresent := options.WordList != ""
+	domainsPresent := options.Domains != ""
+	hostsPresent := options.Hosts != "" || len(options.Targets) > 0
 
-	err := options.configureRcodes()
-	if err != nil {
-		gologger.Fatal().Msgf("%s\n", err)
+	// the wordlist alone expands the glob patterns of the list input (*.example.com)
+	if hostsPresent && domainsPresent {
+		return fmt.Errorf("list(l) flag can not be used with domain(d) flag")
 	}
 
-	err = options.configureResume()
-	if err != nil {
-		gologger.Fatal().Msgf("%s\n", err)
+	if options.ZoneFile != "" && (hostsPresent || domainsPresent) {
+		return fmt.Errorf("zone-file can not be used with list(l) or domain(d) flag")
+	}
+	if options.ZoneOrigin != "" {
+		if options.ZoneFile == "" {
+			return fmt.Errorf("zone-origin requires the zone-file flag")
+		}
+		if _, ok := dns.IsDomainName(options.ZoneOrigin); !ok {
+			return fmt.Errorf("invalid zone-origin value: %s", options.ZoneOrigin)
+		}
 	}
 
-	showBanner()
+	switch options.InputFormat {
+	case "", inputFormatPcap, inputFormatDnstap:
+	default:
+		return fmt.Errorf("invalid input-format value: %s (allowed: %s, %s)", options.InputFormat, inputFormatPcap, inputFormatDnstap)
+	}
+	if options.InputFormat != "" && (domainsPresent || options.ZoneFile != "") {
+		return fmt.Errorf("input-format can only be used with list(l) input")
+	}
+	if options.CompareObserved && options.InputFormat == "" {
+		return fmt.Errorf("compare-observed requires the input-format flag")
+	}
 
-	if options.Version {
-		gologger.Info().Msgf("Current Version: %s\n", Version)
-		os.Exit(0)
+	if domainsPresent && !wordListPresent {
+		return fmt.Errorf("missing wordlist(w) flag required with domain(d) input")
+	}
+	// the hosts of domains expanded in parallel are queued in no fixed order
+	if options.Resume && domainsPresent && options.DomainConcurrency > 1 {
+		return fmt.Errorf("resume can't be used with domain-concurrency greater than 1")
 	}
 
-	options.validateOptions()
+	// stdin can be read by one input only
+	if argumentHasStdin(options.WordList) {
+		if options.Stream {
+			return fmt.Errorf("argument stdin not supported in stream mode")
+		}
+		if argumentHasStdin(options.Domains) {
+			return fmt.Errorf("stdin can't be used by both the wordlist(w) and domain(d) flags")
+		}
+		if !domainsPresent && options.ZoneFile == "" && (!hostsPresent || argumentHasStdin(options.Hosts)) {
+			return fmt.Errorf("wordlist(w) can't be read from stdin when the list(l) input is read from it, pass the list or the wordlist as a file")
+		}
+	}
 
-	return options
-}
+	// the wordlist is combined with the domains or the glob patterns of the list input
+	if wordListPresent && !domainsPresent && !hostsPresent && options.ZoneFile == "" && !hasStdin() {
+		return fmt.Errorf("missing domain(d) or list(l) flag required with wordlist(w) input")
+	}
 
-func (options *Options) validateOptions() {
-	if options.Response && options.ResponseOnly {
-		gologger.Fatal().Msgf("resp and resp-only can't be used at the same time")
+	if options.MaxRuntime != "" {
+		maxRuntime, err := time.ParseDuration(options.MaxRuntime)
+		if err != nil || maxRuntime <= 0 {
+			return fmt.Errorf("invalid max-runtime value: %s", options.MaxRuntime)
+		}
+		options.maxRuntime = maxRuntime
 	}
 
-	wordListPresent := options.WordList != ""
-	domainsPresent := options.Domains != ""
-	hostsPresent := options.Hosts != ""
+	for _, zone := range strings.Split(options.DNSBL, Comma) {
+		zone = strings.ToLower(strings.Trim(strings.TrimSpace(zone), "."))
+		if zone != "" {
+			options.dnsblZones = append(options.dnsblZones, zone)
+		}
+	}
 
-	if hostsPresent && (wordListPresent || domainsPresent) {
-		gologger.Fatal().Msgf("list(l) flag can not be used domain(d) or wordlist(w) flag")
+	for _, value := range options.DNSUpdateAdd {
+		rr, err := parseUpdateRecord(value)
+		if err != nil {
+			return fmt.Errorf("invalid dns-update-add record %s: %s", value, err)
+		}
+		options.updateInsert = append(options.updateInsert, rr)
+	}
+	for _, value := range options.DNSUpdateDelete {
+		rr, err := parseUpdateRecord(value)
+		if err != nil {
+			return fmt.Errorf("invalid dns-update-delete record %s: %s", value, err)
+		}
+		options.updateRemove = append(options.updateRemove, rr)
+	}
+	if options.DNSUpdateZone != "" && !options.dnsUpdate() {
+		return fmt.Errorf("dns-update-zone requires dns-update-add or dns-update-delete")
+	}
+	if options.TSIGKey != "" {
+		key, err := dnsx.ParseTSIGKey(options.TSIGKey)
+		if err != nil {
+			return err
+		}
+		options.tsigKey = key
 	}
 
-	if wordListPresent && !domainsPresent {
-		gologger.Fatal().Msg("missing domain(d) flag required with wordlist(w) input")
+	if options.ReputationFilter {
+		options.IPReputation = true
 	}
-	if domainsPresent && !wordListPresent {
-		gologger.Fatal().Msgf("missing wordlist(w) flag required with domain(d) input")
+	if options.IPReputation {
+		if !contains(reputation.Providers, options.ReputationService) {
+			return fmt.Errorf("invalid reputation-provider value: %s (allowed: %s)", options.ReputationService, strings.Join(reputation.Providers, ", "))
+		}
+		if options.ReputationKey == "" {
+			return fmt.Errorf("ip-reputation requires the reputation-key flag")
+		}
+		if options.ReputationRate <= 0 {
+			return fmt.Errorf("invalid reputation-rate value: %d", options.ReputationRate)
+		}
 	}
 
-	// stdin can be set only on one flag
-	if argumentHasStdin(options.Domains) && argumentHasStdin(options.WordList) {
-		if options.Stream {
-			gologger.Fatal().Msgf("argument stdin not supported in stream mode")
+	if options.GeoFilter != "" {
+		options.Geo = true
+		options.geoInclude = make(map[string]struct{})
+		options.geoExclude = make(map[string]struct{})
+		for _, country := range strings.Split(options.GeoFilter, Comma) {
+			country = strings.ToUpper(strings.TrimSpace(country))
+			if strings.HasPrefix(country, "!") {
+				options.geoExclude[strings.TrimPrefix(country, "!")] = struct{}{}
+			} else if country != "" {
+				options.geoInclude[country] = struct{}{}
+			}
+		}
+	}
+	if options.Geo && options.GeoDB == "" {
+		return fmt.Errorf("geo requires the geo-db flag")
+	}
+
+	if options.DiscoverSubzones && !wordListPresent {
+		return fmt.Errorf("discover-subzones requires a wordlist(w)")
+	}
+
+	if options.MaxDomainQueries < 0 {
+		return fmt.Errorf("invalid max-queries-per-domain value: %d", options.MaxDomainQueries)
+	}
+
+	if options.Typo && wordListPresent {
+		return fmt.Errorf("typo can't be used with wordlist(w) input")
+	}
+
+	if options.SLDPermute && (wordListPresent || options.Typo) {
+		return fmt.Errorf("sld-permute can't be used with wordlist(w) input or typo")
+	}
+	if options.SLDDict != "" && !options.SLDPermute {
+		return fmt.Errorf("sld-dict requires the sld-permute flag")
+	}
+
+	if options.TLDEnum && (wordListPresent || options.Typo || options.SLDPermute) {
+		return fmt.Errorf("tld-enum can't be used with wordlist(w) input, typo or sld-permute")
+	}
+	if options.TLDList != "" && !options.TLDEnum {
+		return fmt.Errorf("tld-list requires the tld-enum flag")
+	}
+
+	if options.Repeat > 1 {
+		delay, err := time.ParseDuration(options.RepeatDelay)
+		if err != nil || delay < 0 {
+			return fmt.Errorf("invalid repeat-delay value: %s", options.RepeatDelay)
+		}
+		options.repeatDelay = delay
+		if options.WildcardDomain != "" {
+			return fmt.Errorf("repeat can't be used with wildcard filtering")
+		}
+	}
+
+	if options.WildcardProbes < 1 {
+		return fmt.Errorf("invalid wildcard-probes value: %d", options.WildcardProbes)
+	}
+	if options.CacheSize < 0 {
+		return fmt.Errorf("invalid cache-size value: %d", options.CacheSize)
+	}
+
+	if options.CIDRSampleDensity < 0 {
+		return fmt.Errorf("invalid cidr-sample-density value: %d", options.CIDRSampleDensity)
+	}
+
+	if options.KeyBy != keyByHost && options.KeyBy != keyByInput {
+		return fmt.Errorf("invalid key-by value: %s (allowed: %s, %s)", options.KeyBy, keyByHost, keyByInput)
+	}
+
+	if options.PreferIPv4 && options.PreferIPv6 {
+		return fmt.Errorf("prefer-ipv4 and prefer-ipv6 can't be used together")
+	}
+	if (options.PreferIPv4 || options.PreferIPv6) && !options.FirstOnly {
+		return fmt.Errorf("prefer-ipv4 and prefer-ipv6 require the first-only flag")
+	}
+
+	if options.Separator != "" {
+		separator, err := strconv.Unquote(`"` + options.Separator + `"`)
+		if err != nil {
+			return fmt.Errorf("invalid separator %s: %s", options.Separator, err)
+		}
+		options.separator = separator
+	}
+
+	for _, value := range options.Output {
+		spec, err := parseSinkSpec(value, options.defaultFormat())
+		if err != nil {
+			return err
+		}
+		if spec.format == sinkFormatRaw && !options.Raw {
+			return fmt.Errorf("raw output format requires the debug(raw) flag")
+		}
+		spec.responseTTL = options.ZoneTTL
+		options.sinks = append(options.sinks, spec)
+	}
+	for _, value := range options.OutputFilter {
+		spec, err := parseOutputFilter(value, options.defaultFormat())
+		if err != nil {
+			return err
+		}
+		spec.responseTTL = options.ZoneTTL
+		options.sinks = append(options.sinks, spec)
+	}
+
+	if options.OutputZone != "" {
+		// the zone fragment always has the ttl of the responses
+		options.sinks = append(options.sinks, sinkSpec{path: options.OutputZone, format: sinkFormatZone, filter: sinkFilterResolved, responseTTL: true})
+	}
+
+	if options.ZoneOutput && (options.JSON || options.Raw) {
+		return fmt.Errorf("zone-output can't be used with json or raw output")
+	}
+	options.outputsZone = options.usesFormat(sinkFormatZone)
+
+	if options.ZoneOverride != "" {
+		overrides, err := parseZoneOverrides(options.ZoneOverride)
+		if err != nil {
+			return err
+		}
+		options.zoneOverrides = overrides
+	}
+
+	if options.DetectSpoofing {
+		window, err := time.ParseDuration(options.SpoofWindow)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("invalid spoof-window value: %s", options.SpoofWindow)
+		}
+		options.spoofWindow = window
+	}
+
+	if options.SourcePort != 0 && options.SourcePortRange != "" {
+		return fmt.Errorf("source-port and source-port-range can't be used together")
+	}
+	if options.SourcePort != 0 {
+		if !isValidPort(options.SourcePort) {
+			return fmt.Errorf("invalid source-port value: %d", options.SourcePort)
+		}
+		if options.Threads > 1 {
+			gologger.Warning().Msgf("Concurrent queries may fail to bind the source port, consider using a single thread (-t 1)\n")
+		}
+		options.sourcePortMin, options.sourcePortMax = options.SourcePort, options.SourcePort
+	}
+	if options.SourcePortRange != "" {
+		min, max, err := parsePortRange(options.SourcePortRange)
+		if err != nil {
+			return err
+		}
+		options.sourcePortMin, options.sourcePortMax = min, max
+	}
+
+	transports := 0
+	for _, transport := range []bool{options.UDP, options.TCP, options.UDPTCP} {
+		if transport {
+			transports++
+		}
+	}
+	if transports > 1 {
+		return fmt.Errorf("udp, tcp and udp-tcp can't be used together")
+	}
+	if options.TransportDiff && transports > 0 {
+		return fmt.Errorf("transport-diff can't be used with udp, tcp or udp-tcp")
+	}
+
+	if options.ExecStdin && options.Exec == "" {
+		return fmt.Errorf("exec-stdin requires the exec flag")
+	}
+	if options.Exec != "" && runtime.GOOS == "windows" {
+		return fmt.Errorf("exec is not supported on windows")
+	}
+
+	if options.SplunkURL != "" && options.SplunkToken == "" {
+		return fmt.Errorf("splunk-url requires the splunk-token flag")
+	}
+	if options.SplunkBatchSize <= 0 {
+		return fmt.Errorf("splunk-batch-size must be positive")
+	}
+
+	if options.PTRCSV && (options.JSON || options.Raw || options.ZoneOutput || options.UniqueIPs || options.Repeat > 1) {
+		return fmt.Errorf("ptr-csv can't be used with json, raw or zone output, unique-ips or repeat")
+	}
+	if options.SummarizeCIDRs && (options.JSON || options.Raw || options.ZoneOutput || options.UniqueIPs || options.PTRCSV || options.Repeat > 1) {
+		return fmt.Errorf("summarize-cidrs can't be used with json, raw or zone output, unique-ips, ptr-csv or repeat")
+	}
+
+	if options.PTRZonePrecheck && !options.PTR && !options.PTRCSV {
+		return fmt.Errorf("ptr-zone-precheck requires the ptr flag")
+	}
+
+	if options.GlueCheck && !options.NS {
+		return fmt.Errorf("glue-check requires the ns flag")
+	}
+
+	localModes := 0
+	for _, enabled := range []bool{options.MDNS, options.LLMNR, options.NBNS, options.LocalResolve != ""} {
+		if enabled {
+			localModes++
+		}
+	}
+	if localModes > 1 {
+		return fmt.Errorf("mdns, llmnr, nbns and local-resolve can't be used at the same time")
+	}
+	if options.CacheSnoop && (localModes > 0 || options.Trace || options.TransportDiff || options.Repeat > 1 || options.WildcardDomain != "") {
+		return fmt.Errorf("cache-snoop can't be used with local resolution, trace, transport-diff, repeat or wildcard filtering")
+	}
+	if localModes > 0 && options.Trace {
+		return fmt.Errorf("trace not supported with mdns, llmnr, nbns or local-resolve")
+	}
+	if options.TraceStartServer != "" {
+		if !options.Trace {
+			return fmt.Errorf("trace-start-server can only be used with trace")
+		}
+		for _, server := range strings.Split(options.TraceStartServer, Comma) {
+			if _, _, err := net.SplitHostPort(prepareResolver(server)); err != nil || strings.TrimSpace(server) == "" {
+				return fmt.Errorf("invalid trace start server %q", server)
+			}
+		}
+	}
+	if options.Precheck {
+		if options.PrecheckSample <= 0 || options.PrecheckSample > 100 {
+			return fmt.Errorf("invalid precheck-sample value: %d (allowed: 1-100)", options.PrecheckSample)
 		}
-		gologger.Fatal().Msgf("stdin can be set for one flag")
+		if options.Stream || options.TTLWatch != "" || options.Split > 0 || options.Coordinator != "" || options.Worker != "" || options.dnsUpdate() || localModes > 0 {
+			return fmt.Errorf("precheck can't be used with stream, ttl-watch, split, coordinator, worker, dynamic updates or local resolution")
+		}
+	}
+
+	if options.TTLWatch != "" {
+		interval, err := time.ParseDuration(options.TTLWatch)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid ttl-watch interval: %s", options.TTLWatch)
+		}
+		options.ttlWatchInterval = interval
+		if options.WildcardDomain != "" {
+			return fmt.Errorf("ttl-watch can't be used with wildcard filtering")
+		}
+	} else if options.MonitorFull || options.TTLWatchPasses != 0 {
+		return fmt.Errorf("monitor-full and ttl-watch-passes require the ttl-watch flag")
+	}
+	if options.TTLWatchPasses < 0 {
+		return fmt.Errorf("invalid ttl-watch-passes value: %d", options.TTLWatchPasses)
+	}
+
+	if options.Split < 0 {
+		return fmt.Errorf("invalid split value: %d", options.Split)
+	}
+	if options.Split > 0 && (options.Stream || options.TTLWatch != "" || options.dnsUpdate()) {
+		return fmt.Errorf("split can't be used with stream, ttl-watch or dynamic updates")
+	}
+
+	if options.Coordinator != "" || options.Worker != "" {
+		if options.Coordinator != "" && options.Worker != "" {
+			return fmt.Errorf("coordinator and worker can't be used at the same time")
+		}
+		if options.Stream || options.TTLWatch != "" || options.WildcardDomain != "" || options.Split > 0 || options.dnsUpdate() {
+			return fmt.Errorf("coordinator and worker can't be used with stream, ttl-watch, wildcard filtering, split or dynamic updates")
+		}
+		if options.CoordinatorToken == "" {
+			return fmt.Errorf("coordinator and worker require the coordinator-token flag")
+		}
+		if options.JobSize <= 0 {
+			return fmt.Errorf("invalid job-size value: %d", options.JobSize)
+		}
+		lease, err := time.ParseDuration(options.JobLease)
+		if err != nil || lease <= 0 {
+			return fmt.Errorf("invalid job-lease value: %s", options.JobLease)
+		}
+		options.jobLease = lease
+	}
+
+	if options.Serve != "" || options.ServeFrom != "" {
+		if options.Serve == "" || options.ServeFrom == "" {
+			return fmt.Errorf("serve and serve-from must be used together")
+		}
+		if options.Stream || options.TTLWatch != "" || options.Split > 0 || options.Coordinator != "" || options.Worker != "" || options.dnsUpdate() || options.Precheck {
+			return fmt.Errorf("serve can't be used with stream, ttl-watch, split, coordinator, worker, dynamic updates or precheck")
+		}
+	}
+
+	if (options.Manifest != "" || options.Replay != "") && (options.Stream || options.TTLWatch != "" || options.Split > 0 || options.Coordinator != "" || options.Worker != "" || options.Serve != "" || options.dnsUpdate()) {
+		return fmt.Errorf("manifest and replay can't be used with stream, ttl-watch, split, coordinator, worker, serve or dynamic updates")
 	}
 
 	if options.Stream {
+		if options.TTLWatch != "" {
+			return fmt.Errorf("ttl-watch not supported in stream mode")
+		}
+		if options.Typo {
+			return fmt.Errorf("typo not supported in stream mode")
+		}
+		if options.SLDPermute {
+			return fmt.Errorf("sld-permute not supported in stream mode")
+		}
 		if wordListPresent {
-			gologger.Fatal().Msgf("wordlist not supported in stream mode")
+			return fmt.Errorf("wordlist not supported in stream mode")
 		}
 		if domainsPresent {
-			gologger.Fatal().Msgf("domains not supported in stream mode")
+			return fmt.Errorf("domains not supported in stream mode")
+		}
+		if options.ZoneFile != "" {
+			return fmt.Errorf("zone-file not supported in stream mode")
+		}
+		if options.InputFormat != "" {
+			return fmt.Errorf("input-format not supported in stream mode")
 		}
 		if options.Resume {
-			gologger.Fatal().Msgf("resume not supported in stream mode")
+			return fmt.Errorf("resume not supported in stream mode")
+		}
+		if options.MaxRuntime != "" {
+			return fmt.Errorf("max-runtime not supported in stream mode")
+		}
+		if options.SmartBrute {
+			return fmt.Errorf("smart-brute not supported in stream mode")
+		}
+		if options.DiscoverSubzones {
+			return fmt.Errorf("discover-subzones not supported in stream mode")
 		}
 		if options.WildcardDomain != "" {
-			gologger.Fatal().Msgf("wildcard not supported in stream mode")
+			return fmt.Errorf("wildcard not supported in stream mode")
 		}
 		if options.ShowStatistics {
-			gologger.Fatal().Msgf("stats not supported in stream mode")
+			return fmt.Errorf("stats not supported in stream mode")
 		}
 	}
+	return nil
 }
 
 func argumentHasStdin(arg string) bool {
@@ -220,60 +1059,41 @@ func (options *Options) configureOutput() {
 	}
 }
 
+// rcodeAllErrors is the rcode group of every known response code except NOERROR
+const rcodeAllErrors = "all-errors"
+
+// maxRcode is the highest extended response code (12 bits)
+const maxRcode = 4095
+
+// configureRcodes parses the rcode filter, names are matched case-insensitively
+// and numeric values are accepted as well
 func (options *Options) configureRcodes() error {
 	options.rcodes = make(map[int]struct{})
-	rcodes := strings.Split(options.RCode, ",")
-	for _, rcode := range rcodes {
-		var rc int
-		switch strings.ToLower(rcode) {
-		case "":
+	for _, rcode := range strings.Split(options.RCode, ",") {
+		rcode = strings.ToUpper(strings.TrimSpace(rcode))
+		if rcode == "" {
 			continue
-		case "noerror":
-			rc = 0
-		case "formerr":
-			rc = 1
-		case "servfail":
-			rc = 2
-		case "nxdomain":
-			rc = 3
-		case "notimp":
-			rc = 4
-		case "refused":
-			rc = 5
-		case "yxdomain":
-			rc = 6
-		case "yxrrset":
-			rc = 7
-		case "nxrrset":
-			rc = 8
-		case "notauth":
-			rc = 9
-		case "notzone":
-			rc = 10
-		case "badsig", "badvers":
-			rc = 16
-		case "badkey":
-			rc = 17
-		case "badtime":
-			rc = 18
-		case "badmode":
-			rc = 19
-		case "badname":
-			rc = 20
-		case "badalg":
-			rc = 21
-		case "badtrunc":
-			rc = 22
-		case "badcookie":
-			rc = 23
-		default:
-			var err error
-			rc, err = strconv.Atoi(rcode)
-			if err != nil {
-				return errors.New("invalid rcode value")
+		}
+		if strings.EqualFold(rcode, rcodeAllErrors) {
+			for rc := range dns.RcodeToString {
+				if rc != dns.RcodeSuccess {
+					options.rcodes[rc] = struct{}{}
+				}
 			}
+			continue
+		}
+		// BADVERS shares its value with BADSIG
+		if rcode == "BADVERS" {
+			rcode = "BADSIG"
+		}
+		if rc, ok := dns.StringToRcode[rcode]; ok {
+			options.rcodes[rc] = struct{}{}
+			continue
+		}
+		rc, err := strconv.Atoi(rcode)
+		if err != nil || rc < 0 || rc > maxRcode {
+			return fmt.Errorf("invalid rcode value %s (allowed: %s, %s or 0-%d)", strings.ToLower(rcode), strings.Join(rcodeNames(), ", "), rcodeAllErrors, maxRcode)
 		}
-
 		options.rcodes[rc] = struct{}{}
 	}
 
@@ -287,16 +1107,109 @@ func (options *Options) configureRcodes() error {
 	return nil
 }
 
+// rcodeNames returns the lowercase names of the known response codes in numeric order
+func rcodeNames() []string {
+	var codes []int
+	for rc := range dns.RcodeToString {
+		codes = append(codes, rc)
+	}
+	sort.Ints(codes)
+	names := make([]string, 0, len(codes))
+	for _, rc := range codes {
+		names = append(names, strings.ToLower(dns.RcodeToString[rc]))
+	}
+	return names
+}
+
+// configureTypeOverrides parses the type=value timeout and retries overrides of the question types
+func (options *Options) configureTypeOverrides() error {
+	timeouts, err := parseTypeValues(options.TimeoutPerType)
+	if err != nil {
+		return fmt.Errorf("invalid timeout-per-type value: %s", err)
+	}
+	options.typeTimeouts = make(map[uint16]time.Duration)
+	for questionType, value := range timeouts {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout-per-type value: %s=%s", strings.ToLower(dns.TypeToString[questionType]), value)
+		}
+		options.typeTimeouts[questionType] = timeout
+	}
+
+	retries, err := parseTypeValues(options.RetriesPerType)
+	if err != nil {
+		return fmt.Errorf("invalid retries-per-type value: %s", err)
+	}
+	options.typeRetries = make(map[uint16]int)
+	for questionType, value := range retries {
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			return fmt.Errorf("invalid retries-per-type value: %s=%s", strings.ToLower(dns.TypeToString[questionType]), value)
+		}
+		options.typeRetries[questionType] = count
+	}
+	return nil
+}
+
+// configureLabels parses the key=value labels, the keys must be identifiers
+func (options *Options) configureLabels() error {
+	options.labels = nil
+	seen := make(map[string]struct{})
+	for _, item := range options.Labels {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid label %s (expected key=value)", item)
+		}
+		key := strings.TrimSpace(parts[0])
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid label key %s (letters, digits and underscores, not starting with a digit)", key)
+		}
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("duplicate label key %s", key)
+		}
+		seen[key] = struct{}{}
+		options.labels = append(options.labels, resultLabel{key: key, value: parts[1]})
+	}
+	return nil
+}
+
+// parseTypeValues parses the comma separated type=value pairs, types are case-insensitive
+func parseTypeValues(value string) (map[uint16]string, error) {
+	values := make(map[uint16]string)
+	for _, item := range strings.Split(value, Comma) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s (expected type=value)", item)
+		}
+		questionType, ok := dns.StringToType[strings.ToUpper(strings.TrimSpace(parts[0]))]
+		if !ok {
+			return nil, fmt.Errorf("unknown question type %s", parts[0])
+		}
+		values[questionType] = strings.TrimSpace(parts[1])
+	}
+	return values, nil
+}
+
 func (options *Options) configureResume() error {
 	options.resumeCfg = &ResumeCfg{}
 	if options.Resume && fileutil.FileExists(DefaultResumeFile) {
-		return goconfig.Load(&options.resumeCfg, DefaultResumeFile)
-
+		if err := goconfig.Load(&options.resumeCfg, DefaultResumeFile); err != nil {
+			return err
+		}
+		// the position of an older resume file points to another host, the scan restarts
+		if options.resumeCfg.Version != resumeVersion && options.resumeCfg.Index > 0 {
+			gologger.Warning().Msgf("Resume file %s was written by an older version, restarting the scan from the beginning\n", DefaultResumeFile)
+			options.resumeCfg = &ResumeCfg{}
+		}
 	}
 	return nil
 }
 
-func createGroup(flagSet *goflags.FlagSet, groupName, description string, flags ...*goflags.FlagData) {
+func createGroup(flagSet flagDefiner, groupName, description string, flags ...*goflags.FlagData) {
 	flagSet.SetGroup(groupName, description)
 	for _, currentFlag := range flags {
 		currentFlag.Group(groupName)
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// withoutStdin runs the test with an empty terminal-like stdin
+func withoutStdin(t *testing.T) {
+	t.Helper()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = devNull
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		devNull.Close()
+	})
+}
+
+// validOptions returns the options of a plain run with the defaults of the flags
+func validOptions() *Options {
+	return &Options{KeyBy: keyByHost, WildcardProbes: 1, Threads: 100, RepeatDelay: "500ms", SplunkBatchSize: 100}
+}
+
+func TestValidateInputOptions(t *testing.T) {
+	withoutStdin(t)
+	tests := []struct {
+		name   string
+		modify func(options *Options)
+		err    string
+	}{
+		{name: "list", modify: func(options *Options) { options.Hosts = "hosts.txt" }},
+		{name: "domain and wordlist", modify: func(options *Options) { options.Domains, options.WordList = "example.com", "www,mail" }},
+		{name: "glob list and wordlist", modify: func(options *Options) { options.Hosts, options.WordList = "globs.txt", "www,mail" }},
+		{name: "list file and wordlist stdin", modify: func(options *Options) { options.Hosts, options.WordList = "globs.txt", stdinMarker }},
+		{name: "wordlist alone", modify: func(options *Options) { options.WordList = "www,mail" }, err: "missing domain(d) or list(l) flag required with wordlist(w) input"},
+		{name: "domain alone", modify: func(options *Options) { options.Domains = "example.com" }, err: "missing wordlist(w) flag"},
+		{name: "list and domain", modify: func(options *Options) {
+			options.Hosts, options.Domains, options.WordList = "hosts.txt", "example.com", "www"
+		}, err: "list(l) flag can not be used with domain(d) flag"},
+		{name: "domain and wordlist stdin", modify: func(options *Options) { options.Domains, options.WordList = stdinMarker, stdinMarker }, err: "stdin can't be used by both the wordlist(w) and domain(d) flags"},
+		{name: "list and wordlist stdin", modify: func(options *Options) { options.Hosts, options.WordList = stdinMarker, stdinMarker }, err: "wordlist(w) can't be read from stdin when the list(l) input is read from it"},
+		{name: "resume of sequential domains", modify: func(options *Options) {
+			options.Domains, options.WordList, options.Resume, options.DomainConcurrency = "example.com", "www", true, 1
+		}},
+		{name: "resume of parallel domains", modify: func(options *Options) {
+			options.Domains, options.WordList, options.Resume, options.DomainConcurrency = "example.com", "www", true, 4
+		}, err: "resume can't be used with domain-concurrency greater than 1"},
+		{name: "implicit list and wordlist stdin", modify: func(options *Options) { options.WordList = stdinMarker }, err: "wordlist(w) can't be read from stdin when the list(l) input is read from it"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := validOptions()
+			test.modify(options)
+			err := options.validateOptions()
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestConfigureTypeOverrides(t *testing.T) {
+	tests := []struct {
+		timeouts string
+		retries  string
+		// the overrides formatted as type=value, sorted by type
+		want string
+		err  string
+	}{
+		{want: "timeouts: retries:"},
+		{timeouts: "txt=5s, ANY=8s", retries: "Txt=4", want: "timeouts:TXT=5s,ANY=8s retries:TXT=4"},
+		{timeouts: "txt=1s,txt=2s", want: "timeouts:TXT=2s retries:"},
+		{timeouts: "txt", err: "invalid timeout-per-type value: txt (expected type=value)"},
+		{timeouts: "foo=1s", err: "unknown question type foo"},
+		{timeouts: "txt=5", err: "invalid timeout-per-type value: txt=5"},
+		{timeouts: "txt=-1s", err: "invalid timeout-per-type value: txt=-1s"},
+		{retries: "mx=0", err: "invalid retries-per-type value: mx=0"},
+		{retries: "mx=two", err: "invalid retries-per-type value: mx=two"},
+	}
+	for _, test := range tests {
+		options := &Options{TimeoutPerType: test.timeouts, RetriesPerType: test.retries}
+		err := options.configureTypeOverrides()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q %q: got error %v, want %s", test.timeouts, test.retries, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q %q: %s", test.timeouts, test.retries, err)
+		}
+		var timeouts, retries []string
+		for _, questionType := range sortedTypes(options.typeTimeouts) {
+			timeouts = append(timeouts, fmt.Sprintf("%s=%s", dns.TypeToString[questionType], options.typeTimeouts[questionType]))
+		}
+		for questionType, count := range options.typeRetries {
+			retries = append(retries, fmt.Sprintf("%s=%d", dns.TypeToString[questionType], count))
+		}
+		if got := "timeouts:" + strings.Join(timeouts, ",") + " retries:" + strings.Join(retries, ","); got != test.want {
+			t.Errorf("%q %q: got %s, want %s", test.timeouts, test.retries, got, test.want)
+		}
+	}
+}
+
+func sortedTypes(values map[uint16]time.Duration) []uint16 {
+	var questionTypes []uint16
+	for questionType := range values {
+		questionTypes = append(questionTypes, questionType)
+	}
+	sort.Slice(questionTypes, func(i, j int) bool { return questionTypes[i] < questionTypes[j] })
+	return questionTypes
+}
+
+func TestConfigureRcodes(t *testing.T) {
+	allErrors := len(dns.RcodeToString) - 1
+	tests := []struct {
+		rcode string
+		// the sorted codes, compared to their number for the groups
+		want      string
+		count     int
+		hasRCodes bool
+		err       string
+	}{
+		// the filter defaults to NOERROR
+		{want: "0"},
+		{rcode: "noerror", want: "0", hasRCodes: true},
+		{rcode: "NXDomain, ServFail", want: "2,3", hasRCodes: true},
+		{rcode: "0,3,4095", want: "0,3,4095", hasRCodes: true},
+		{rcode: "badvers", want: "16", hasRCodes: true},
+		{rcode: "badsig,BADVERS,16", want: "16", hasRCodes: true},
+		{rcode: "servfail,SERVFAIL,2, 2", want: "2", hasRCodes: true},
+		{rcode: "refused,,", want: "5", hasRCodes: true},
+		{rcode: "all-errors", count: allErrors, hasRCodes: true},
+		{rcode: "ALL-ERRORS,noerror,servfail", count: allErrors + 1, hasRCodes: true},
+		{rcode: "foo", err: "invalid rcode value foo (allowed: noerror, formerr, servfail, nxdomain"},
+		{rcode: "noerror,Bogus", err: "invalid rcode value bogus"},
+		{rcode: "-1", err: "invalid rcode value -1"},
+		{rcode: "4096", err: "invalid rcode value 4096"},
+		{rcode: "1.5", err: "invalid rcode value 1.5"},
+		{rcode: "all", err: "all-errors or 0-4095)"},
+	}
+	for _, test := range tests {
+		options := &Options{RCode: test.rcode}
+		err := options.configureRcodes()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %s", test.rcode, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %s", test.rcode, err)
+		}
+		if options.hasRCodes != test.hasRCodes {
+			t.Errorf("%q: got hasRCodes %v, want %v", test.rcode, options.hasRCodes, test.hasRCodes)
+		}
+		if test.count > 0 {
+			if len(options.rcodes) != test.count {
+				t.Errorf("%q: got %d codes, want %d", test.rcode, len(options.rcodes), test.count)
+			}
+			continue
+		}
+		var codes []int
+		for rcode := range options.rcodes {
+			codes = append(codes, rcode)
+		}
+		sort.Ints(codes)
+		if got := strings.Trim(strings.Join(strings.Fields(fmt.Sprint(codes)), ","), "[]"); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.rcode, got, test.want)
+		}
+	}
+}
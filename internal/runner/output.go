@@ -0,0 +1,322 @@
+package runner
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	sinkFormatPlain = "plain"
+	sinkFormatJSON  = "json"
+	sinkFormatRaw   = "raw"
+	sinkFormatZone  = "zone"
+
+	// sinkFilterMatched is the default filter: responses passing the rcode filter
+	sinkFilterMatched  = "matched"
+	sinkFilterAll      = "all"
+	sinkFilterResolved = "resolved"
+	sinkFilterFailed   = "failed"
+
+	// stdoutSink is the sink path configuring the standard output
+	stdoutSink = "stdout"
+)
+
+var (
+	sinkFormats = []string{sinkFormatPlain, sinkFormatJSON, sinkFormatRaw, sinkFormatZone}
+	sinkFilters = []string{sinkFilterMatched, sinkFilterAll, sinkFilterResolved, sinkFilterFailed}
+)
+
+// resultStatus is the outcome of the resolution of a host
+type resultStatus int
+
+const (
+	statusMatched resultStatus = iota
+	statusFiltered
+	statusFailed
+)
+
+// outputEvent is a unit of output, the result is nil for lines not bound to a dns response
+type outputEvent struct {
+	result *dnsResult
+	status resultStatus
+	lines  []string
+	zone   []dns.RR
+}
+
+// host returns the host of the result of the event, the first line otherwise
+func (event *outputEvent) host() string {
+	switch {
+	case event.result != nil && event.result.DNSData != nil:
+		return event.result.Host
+	case len(event.lines) > 0:
+		return event.lines[0]
+	}
+	return ""
+}
+
+// sinkSpec is an output destination in the path[:format[:filter]] format, the output
+// filters restrict it to the results matching their expression
+type sinkSpec struct {
+	path       string
+	format     string
+	filter     string
+	expression outputExpression
+	// responseTTL keeps the ttl of the responses in zone format
+	responseTTL bool
+}
+
+// parseSinkSpec parses a sink specification, the plain path form uses the default format and filter
+func parseSinkSpec(value, defaultFormat string) (sinkSpec, error) {
+	spec := sinkSpec{path: value, format: defaultFormat, filter: sinkFilterMatched}
+	parts := strings.Split(value, ":")
+	switch {
+	case len(parts) >= 3 && contains(sinkFormats, parts[len(parts)-2]) && contains(sinkFilters, parts[len(parts)-1]):
+		spec.path = strings.Join(parts[:len(parts)-2], ":")
+		spec.format = parts[len(parts)-2]
+		spec.filter = parts[len(parts)-1]
+	case len(parts) >= 2 && contains(sinkFormats, parts[len(parts)-1]):
+		spec.path = strings.Join(parts[:len(parts)-1], ":")
+		spec.format = parts[len(parts)-1]
+	}
+	if spec.path == "" {
+		return spec, errors.Errorf("missing output path in %s", value)
+	}
+	return spec, nil
+}
+
+// sink writes the events accepted by its filter with its own format
+type sink struct {
+	sinkSpec
+	file *os.File
+	w    *bufio.Writer
+	// finalize is set on the sinks buffering the lines until they're closed, the lines it
+	// returns (sorted by ip, summarized to cidrs) are written instead
+	finalize func(lines []string) []string
+	lines    []string
+}
+
+func newSink(spec sinkSpec) (*sink, error) {
+	s := &sink{sinkSpec: spec}
+	if spec.path == stdoutSink {
+		return s, nil
+	}
+	file, err := os.OpenFile(spec.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+	s.w = bufio.NewWriter(file)
+	return s, nil
+}
+
+// accepts reports whether the event passes the sink filter
+func (s *sink) accepts(event *outputEvent) bool {
+	if s.expression != nil && (event.result == nil || !s.expression.match(event.result)) {
+		return false
+	}
+	switch s.filter {
+	case sinkFilterAll:
+		return true
+	case sinkFilterFailed:
+		return event.status != statusMatched
+	case sinkFilterResolved:
+		return event.status == statusMatched && (event.result == nil || isResolved(event.result))
+	default:
+		return event.status == statusMatched
+	}
+}
+
+// render returns the lines of the event in the sink format
+func (s *sink) render(event *outputEvent) []string {
+	if event.result == nil {
+		return event.lines
+	}
+	switch s.format {
+	case sinkFormatJSON:
+		data, err := event.result.JSON()
+		if err != nil {
+			return nil
+		}
+		return []string{data}
+	case sinkFormatRaw:
+		if event.result.Raw == "" {
+			return nil
+		}
+		return []string{event.result.Raw}
+	case sinkFormatZone:
+		return zoneLines(event.zone, s.responseTTL)
+	default:
+		return event.lines
+	}
+}
+
+func (s *sink) write(event *outputEvent) {
+	if !s.accepts(event) {
+		return
+	}
+	lines := s.render(event)
+	if s.finalize != nil {
+		s.lines = append(s.lines, lines...)
+		return
+	}
+	for _, line := range lines {
+		s.writeLine(line)
+	}
+}
+
+func (s *sink) writeLine(line string) {
+	if s.w == nil {
+		gologger.Silent().Msgf("%s\n", line)
+		return
+	}
+	// nolint:errcheck
+	s.w.WriteString(line + "\n")
+}
+
+func (s *sink) flush() {
+	if s.w != nil {
+		// nolint:errcheck
+		s.w.Flush()
+	}
+}
+
+func (s *sink) close() {
+	if s.finalize != nil {
+		for _, line := range s.finalize(s.lines) {
+			s.writeLine(line)
+		}
+		s.lines = nil
+	}
+	if s.file != nil {
+		s.flush()
+		s.file.Close()
+	}
+}
+
+// openSinks opens the configured sinks, the standard output uses the global format
+// and the default filter unless configured explicitly or the results go to a callback.
+// The sinks already opened are closed when one of them can't be opened.
+func (r *Runner) openSinks() ([]*sink, error) {
+	specs := r.options.sinks
+	hasStdout := false
+	for _, spec := range specs {
+		if spec.path == stdoutSink {
+			hasStdout = true
+		}
+	}
+	if !hasStdout && r.options.OnResult == nil {
+		specs = append([]sinkSpec{{path: stdoutSink, format: r.options.defaultFormat(), filter: sinkFilterMatched, responseTTL: r.options.ZoneTTL}}, specs...)
+	}
+
+	var sinks []*sink
+	for _, spec := range specs {
+		s, err := newSink(spec)
+		if err != nil {
+			for _, opened := range sinks {
+				opened.close()
+			}
+			return nil, errors.Wrap(err, "could not open output")
+		}
+		// the ip,ptr lines are written sorted by ip and the addresses summarized to cidrs
+		// at the end of the run
+		if spec.format == sinkFormatPlain {
+			switch {
+			case r.options.PTRCSV:
+				s.finalize = func(lines []string) []string {
+					sortLinesByIP(lines)
+					return lines
+				}
+			case r.options.SummarizeCIDRs:
+				s.finalize = summarizeCIDRs
+			}
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// outputsUnmatched reports whether any sink receives the failed or filtered hosts
+func (options *Options) outputsUnmatched() bool {
+	for _, spec := range options.sinks {
+		if spec.filter == sinkFilterAll || spec.filter == sinkFilterFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// usesFormat reports whether the screen or any sink uses the format
+func (options *Options) usesFormat(format string) bool {
+	if options.defaultFormat() == format {
+		return true
+	}
+	for _, spec := range options.sinks {
+		if spec.format == format {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultFormat returns the format selected by the global output flags
+func (options *Options) defaultFormat() string {
+	switch {
+	case options.JSON:
+		return sinkFormatJSON
+	case options.Raw:
+		return sinkFormatRaw
+	case options.ZoneOutput:
+		return sinkFormatZone
+	default:
+		return sinkFormatPlain
+	}
+}
+
+// firstRecords limits the values of a record type to the first one in first-only mode
+func (options *Options) firstRecords(items []string) []string {
+	if options.FirstOnly && len(items) > 1 {
+		return items[:1]
+	}
+	return items
+}
+
+// firstAddresses applies first-only to the A and AAAA values, with prefer-ipv4 or prefer-ipv6
+// only the address of the preferred family is kept, the other family being used when the
+// preferred one has no address
+func (options *Options) firstAddresses(a, aaaa []string) ([]string, []string) {
+	a, aaaa = options.firstRecords(a), options.firstRecords(aaaa)
+	switch {
+	case options.PreferIPv4 && len(a) > 0 && len(aaaa) > 0:
+		return a, nil
+	case options.PreferIPv6 && len(a) > 0 && len(aaaa) > 0:
+		return nil, aaaa
+	}
+	return a, aaaa
+}
+
+// isResolved reports whether the response is successful and has at least one record
+func isResolved(result *dnsResult) bool {
+	if result.DNSData == nil || result.StatusCodeRaw != dns.RcodeSuccess {
+		return false
+	}
+	for _, records := range [][]string{result.A, result.AAAA, result.CNAME, result.PTR, result.MX, result.NS, result.SOA, result.TXT} {
+		if len(records) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+func TestOutputRecords(t *testing.T) {
+	noerror := &retryabledns.DNSData{Host: "example.com", StatusCodeRaw: dns.RcodeSuccess, A: []string{"192.0.2.1", "192.0.2.2"}, MX: []string{"MAIL.example.com"}}
+	nxdomain := &retryabledns.DNSData{Host: "example.com", StatusCodeRaw: dns.RcodeNameError}
+	tests := []struct {
+		name    string
+		options Options
+		data    *retryabledns.DNSData
+		want    []string
+	}{
+		// no record flags, the A question is asked by default
+		{name: "default", options: Options{A: true}, data: noerror, want: []string{"example.com"}},
+		{name: "default resp", options: Options{A: true, Response: true}, data: noerror, want: []string{"example.com [192.0.2.1]", "example.com [192.0.2.2]"}},
+		{name: "default rcode-display", options: Options{A: true, RCodeDisplay: true}, data: noerror, want: []string{"example.com"}},
+		{name: "default resp rcode-display", options: Options{A: true, Response: true, RCodeDisplay: true}, data: noerror, want: []string{"example.com [192.0.2.1]", "example.com [192.0.2.2]"}},
+		{name: "default no records", options: Options{A: true}, data: nxdomain},
+		// no record flags with an rcode filter
+		{name: "rcode", options: Options{A: true, hasRCodes: true}, data: nxdomain, want: []string{"example.com [NXDOMAIN]"}},
+		{name: "rcode rcode-display", options: Options{A: true, hasRCodes: true, RCodeDisplay: true}, data: noerror, want: []string{"example.com [NOERROR]"}},
+		// record flags, the rcode filter doesn't change the output
+		{name: "flags", options: Options{MX: true, hasRecordFlags: true}, data: noerror, want: []string{"example.com"}},
+		{name: "flags rcode", options: Options{MX: true, hasRecordFlags: true, hasRCodes: true}, data: noerror, want: []string{"example.com"}},
+		{name: "flags resp", options: Options{A: true, MX: true, hasRecordFlags: true, Response: true}, data: noerror, want: []string{"example.com [192.0.2.1]", "example.com [192.0.2.2]", "example.com [mail.example.com]"}},
+		{name: "flags resp-only", options: Options{MX: true, hasRecordFlags: true, ResponseOnly: true}, data: noerror, want: []string{"mail.example.com"}},
+		{name: "flags rcode-display", options: Options{MX: true, hasRecordFlags: true, RCodeDisplay: true}, data: noerror, want: []string{"example.com [NOERROR]"}},
+		{name: "flags resp rcode-display", options: Options{A: true, hasRecordFlags: true, Response: true, RCodeDisplay: true, hasRCodes: true}, data: noerror, want: []string{"example.com [192.0.2.1] [NOERROR]", "example.com [192.0.2.2] [NOERROR]"}},
+		{name: "flags missing type", options: Options{TXT: true, hasRecordFlags: true, RCodeDisplay: true}, data: noerror},
+		{name: "first-only", options: Options{A: true, hasRecordFlags: true, Response: true, FirstOnly: true}, data: noerror, want: []string{"example.com [192.0.2.1]"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r := &Runner{options: &test.options}
+			got := r.outputRecords("example.com", &dnsResult{DNSData: test.data})
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFirstOnlyRecords(t *testing.T) {
+	data := &retryabledns.DNSData{
+		Host:          "example.com",
+		StatusCodeRaw: dns.RcodeSuccess,
+		A:             []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		AAAA:          []string{"2001:db8::1", "2001:db8::2"},
+		MX:            []string{"mx1.example.com", "mx2.example.com"},
+		TXT:           []string{"v=spf1 -all", "verification"},
+	}
+	ipv6Only := &retryabledns.DNSData{Host: "example.com", StatusCodeRaw: dns.RcodeSuccess, AAAA: []string{"2001:db8::1", "2001:db8::2"}}
+	tests := []struct {
+		name    string
+		options Options
+		data    *retryabledns.DNSData
+		want    []string
+	}{
+		{name: "all values", options: Options{A: true, AAAA: true}, want: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "2001:db8::1", "2001:db8::2"}},
+		{name: "first-only", options: Options{A: true, AAAA: true, FirstOnly: true}, want: []string{"192.0.2.1", "2001:db8::1"}},
+		{name: "prefer-ipv4", options: Options{A: true, AAAA: true, FirstOnly: true, PreferIPv4: true}, want: []string{"192.0.2.1"}},
+		{name: "prefer-ipv6", options: Options{A: true, AAAA: true, FirstOnly: true, PreferIPv6: true}, want: []string{"2001:db8::1"}},
+		// the other family is displayed when the preferred one has no address
+		{name: "prefer-ipv4 without A", options: Options{A: true, AAAA: true, FirstOnly: true, PreferIPv4: true}, data: ipv6Only, want: []string{"2001:db8::1"}},
+		{name: "first-only A", options: Options{A: true, FirstOnly: true}, want: []string{"192.0.2.1"}},
+		{name: "first-only MX", options: Options{MX: true, FirstOnly: true}, want: []string{"mx1.example.com"}},
+		{name: "first-only TXT", options: Options{TXT: true, FirstOnly: true}, want: []string{"v=spf1 -all"}},
+		{name: "first-only types", options: Options{A: true, MX: true, TXT: true, FirstOnly: true}, want: []string{"192.0.2.1", "mx1.example.com", "v=spf1 -all"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.options.hasRecordFlags = true
+			test.options.ResponseOnly = true
+			if test.data == nil {
+				test.data = data
+			}
+			r := &Runner{options: &test.options}
+			got := r.outputRecords("example.com", &dnsResult{DNSData: test.data})
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// answerAddresses answers the A and AAAA questions with several addresses
+func answerAddresses(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	question := req.Question[0]
+	header := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: 60}
+	switch question.Qtype {
+	case dns.TypeA:
+		for i := 1; i <= 3; i++ {
+			resp.Answer = append(resp.Answer, &dns.A{Hdr: header, A: net.IPv4(192, 0, 2, byte(i))})
+		}
+	case dns.TypeAAAA:
+		for _, address := range []string{"2001:db8::1", "2001:db8::2"} {
+			resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: header, AAAA: net.ParseIP(address)})
+		}
+	}
+	w.WriteMsg(resp) // nolint:errcheck
+}
+
+// TestFirstOnlyJSON checks that first-only limits the plain output and leaves the json results complete
+func TestFirstOnlyJSON(t *testing.T) {
+	server := newTestDNSServer(t, answerAddresses)
+	tests := []struct {
+		name      string
+		configure func(*Options)
+		// the plain lines, the json line holds every address
+		lines []string
+	}{
+		{name: "first-only", configure: func(o *Options) { o.FirstOnly = true }, lines: []string{"192.0.2.1", "2001:db8::1"}},
+		{name: "prefer-ipv4", configure: func(o *Options) { o.FirstOnly = true; o.PreferIPv4 = true }, lines: []string{"192.0.2.1"}},
+		{name: "prefer-ipv6", configure: func(o *Options) { o.FirstOnly = true; o.PreferIPv6 = true }, lines: []string{"2001:db8::1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "output.txt")
+			var jsonLines []string
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.Targets = []string{"www.example.com"}
+				options.Output = []string{output}
+				options.A = true
+				options.AAAA = true
+				options.ResponseOnly = true
+				test.configure(options)
+			}, func(result *Result) {
+				line, err := result.JSON()
+				if err != nil {
+					t.Error(err)
+				}
+				jsonLines = append(jsonLines, line)
+			})
+			defer r.Close()
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Fields(string(data)); fmt.Sprint(lines) != fmt.Sprint(test.lines) {
+				t.Errorf("got lines %v, want %v", lines, test.lines)
+			}
+			if len(jsonLines) != 1 {
+				t.Fatalf("got json results %v, want 1", jsonLines)
+			}
+			for _, field := range []string{`"a":["192.0.2.1","192.0.2.2","192.0.2.3"]`, `"aaaa":["2001:db8::1","2001:db8::2"]`} {
+				if !strings.Contains(jsonLines[0], field) {
+					t.Errorf("result %s doesn't contain %s", jsonLines[0], field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// filterableTypes are the record types stored in the results, the ones the type field
+// of the output filters can match
+var filterableTypes = map[uint16]struct{}{
+	dns.TypeA: {}, dns.TypeAAAA: {}, dns.TypeCNAME: {}, dns.TypePTR: {}, dns.TypeMX: {}, dns.TypeNS: {}, dns.TypeSOA: {}, dns.TypeTXT: {},
+}
+
+// outputCondition compares a field of the results with a value
+type outputCondition struct {
+	field  string
+	negate bool
+	value  string
+}
+
+// outputExpression is a conjunction of conditions on the results written to an output
+// (type==A && rcode!=NXDOMAIN), the supported fields are:
+//   - type: the result holds records of the type
+//   - rcode: the response code of the result
+//   - host: the host of the result, a leading *. matching the names below it
+type outputExpression []outputCondition
+
+// parseOutputExpression parses the field==value and field!=value conditions joined by &&
+func parseOutputExpression(value string) (outputExpression, error) {
+	var expression outputExpression
+	for _, term := range strings.Split(value, "&&") {
+		term = strings.TrimSpace(term)
+		operator := "=="
+		if strings.Contains(term, "!=") {
+			operator = "!="
+		}
+		parts := strings.SplitN(term, operator, 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.Errorf("invalid condition %q", term)
+		}
+		condition := outputCondition{
+			field:  strings.ToLower(strings.TrimSpace(parts[0])),
+			negate: operator == "!=",
+			value:  strings.TrimSpace(parts[1]),
+		}
+		switch condition.field {
+		case "type":
+			questionType := dns.StringToType[strings.ToUpper(condition.value)]
+			if _, ok := filterableTypes[questionType]; !ok {
+				return nil, errors.Errorf("unsupported record type %q", condition.value)
+			}
+			condition.value = dns.TypeToString[questionType]
+		case "rcode":
+			if _, ok := dns.StringToRcode[strings.ToUpper(condition.value)]; !ok {
+				return nil, errors.Errorf("unknown rcode %q", condition.value)
+			}
+			condition.value = strings.ToUpper(condition.value)
+		case "host":
+			condition.value = strings.ToLower(strings.TrimSuffix(condition.value, "."))
+		default:
+			return nil, errors.Errorf("unknown field %q", condition.field)
+		}
+		expression = append(expression, condition)
+	}
+	return expression, nil
+}
+
+// match reports whether the result satisfies every condition of the expression
+func (expression outputExpression) match(result *dnsResult) bool {
+	for _, condition := range expression {
+		if condition.match(result) == condition.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (condition outputCondition) match(result *dnsResult) bool {
+	switch condition.field {
+	case "type":
+		return len(recordValues(result.DNSData, dns.StringToType[condition.value])) > 0
+	case "rcode":
+		return strings.EqualFold(result.StatusCode, condition.value)
+	case "host":
+		host := strings.ToLower(strings.TrimSuffix(result.Host, "."))
+		if strings.HasPrefix(condition.value, "*.") {
+			return strings.HasSuffix(host, condition.value[1:])
+		}
+		return host == condition.value
+	}
+	return false
+}
+
+// parseOutputFilter parses an output filter in the file:expression format
+func parseOutputFilter(value, defaultFormat string) (sinkSpec, error) {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return sinkSpec{}, errors.Errorf("invalid output filter %s (file:expression)", value)
+	}
+	expression, err := parseOutputExpression(value[i+1:])
+	if err != nil {
+		return sinkSpec{}, errors.Wrapf(err, "invalid output filter %s", value)
+	}
+	return sinkSpec{path: value[:i], format: defaultFormat, filter: sinkFilterMatched, expression: expression}, nil
+}
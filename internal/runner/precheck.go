@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+)
+
+// ErrPrecheckAborted is returned by Run when the scan is declined after the precheck estimate
+var ErrPrecheckAborted = errors.New("scan aborted after precheck")
+
+const (
+	// precheckTimeout is the aggressive timeout of the sampled queries, the slower
+	// responses are counted as failures
+	precheckTimeout = time.Second
+	// bounds of the number of sampled hosts
+	precheckMinSample = 10
+	precheckMaxSample = 1000
+)
+
+// precheckInput holds the input read ahead of the precheck, fed to the resolve
+// workers once the scan is confirmed
+type precheckInput struct {
+	hosts *hybrid.HybridMap
+	count int
+}
+
+// precheckEstimate is the outcome of the sampled queries
+type precheckEstimate struct {
+	sampled  int
+	queries  int
+	failures int
+	latency  time.Duration
+	runtime  time.Duration
+}
+
+// precheck reads the whole input, queries a sample of it with aggressive timeouts and
+// reports the expected failure rate and runtime, asking for confirmation on a terminal
+func (r *Runner) precheck() error {
+	hosts, err := r.state.hmap("precheck")
+	if err != nil {
+		return err
+	}
+	input := &precheckInput{hosts: hosts}
+	reservoir := make([]string, 0, precheckMaxSample)
+	err = r.prepareInput(func(host, item string) {
+		// nolint:errcheck
+		hosts.Set(host, []byte(item))
+		input.count++
+		// reservoir sampling keeps a uniform sample of the hosts read so far
+		if len(reservoir) < precheckMaxSample {
+			reservoir = append(reservoir, host)
+		} else if i := rand.Intn(input.count); i < precheckMaxSample {
+			reservoir[i] = host
+		}
+	})
+	if err != nil {
+		hosts.Close()
+		return err
+	}
+	r.precheckInput = input
+	if input.count == 0 {
+		return nil
+	}
+
+	size := int(math.Ceil(float64(input.count) * float64(r.options.PrecheckSample) / 100))
+	if size < precheckMinSample {
+		size = precheckMinSample
+	}
+	if size > len(reservoir) {
+		size = len(reservoir)
+	}
+	rand.Shuffle(len(reservoir), func(i, j int) { reservoir[i], reservoir[j] = reservoir[j], reservoir[i] })
+	estimate, err := r.sampleQueries(reservoir[:size], input.count)
+	if err != nil {
+		return err
+	}
+
+	failureRate := 0.0
+	if estimate.queries > 0 {
+		failureRate = float64(estimate.failures) / float64(estimate.queries) * 100
+	}
+	gologger.Info().Msgf("Precheck of %d/%d hosts (%d queries): %.1f%% failures, %s average latency, estimated runtime %s\n",
+		estimate.sampled, input.count, estimate.queries, failureRate, estimate.latency.Round(time.Millisecond), estimate.runtime.Round(time.Second))
+
+	// the estimate is only logged when the input or the answer can't come from a terminal
+	if r.options.Yes || hasStdin() {
+		return nil
+	}
+	fmt.Fprint(os.Stderr, "Continue with the scan? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrPrecheckAborted
+}
+
+// sampleQueries sends the queries of every question type for the sampled hosts and
+// estimates the runtime of the whole input, a failed query costing the timeout of each
+// of its attempts in the real scan
+func (r *Runner) sampleQueries(sample []string, total int) (*precheckEstimate, error) {
+	options := *r.dnsx.Options
+	options.Timeout = precheckTimeout
+	options.TypeTimeouts = nil
+	options.MaxRetries = 1
+	options.OnAttempt = nil
+	client, err := dnsx.New(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mutex   sync.Mutex
+		elapsed time.Duration
+	)
+	estimate := &precheckEstimate{sampled: len(sample)}
+	hosts := make(chan string)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < r.options.Threads && i < len(sample); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				r.takeLimiter()
+				for _, questionType := range options.QuestionTypes {
+					start := time.Now()
+					msg, err := client.QueryMsg(host, questionType)
+					took := time.Since(start)
+					failed := err != nil || msg == nil || msg.Rcode == dns.RcodeServerFailure || msg.Rcode == dns.RcodeRefused
+					mutex.Lock()
+					estimate.queries++
+					if failed {
+						estimate.failures++
+					} else {
+						elapsed += took
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, host := range sample {
+		hosts <- host
+	}
+	close(hosts)
+	wg.Wait()
+
+	if succeeded := estimate.queries - estimate.failures; succeeded > 0 {
+		estimate.latency = elapsed / time.Duration(succeeded)
+	}
+	estimate.runtime = r.estimateRuntime(estimate, total)
+	return estimate, nil
+}
+
+// estimateRuntime returns the time needed by the threads to resolve the input with the
+// observed latency and failure rate, bounded by the rate limit of the hosts
+func (r *Runner) estimateRuntime(estimate *precheckEstimate, total int) time.Duration {
+	if estimate.queries == 0 || total == 0 {
+		return 0
+	}
+	attempts := r.dnsx.Options.MaxRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+	failureRate := float64(estimate.failures) / float64(estimate.queries)
+	perQuery := (1-failureRate)*float64(estimate.latency) + failureRate*float64(r.dnsx.Options.Timeout)*float64(attempts)
+	perHost := perQuery * float64(len(r.dnsx.Options.QuestionTypes))
+	threads := r.options.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	runtime := time.Duration(perHost * float64(total) / float64(threads))
+	if r.options.RateLimit > 0 {
+		if limited := time.Duration(float64(total) / float64(r.options.RateLimit) * float64(time.Second)); limited > runtime {
+			runtime = limited
+		}
+	}
+	return runtime
+}
+
+// feedPrecheckInput sends the hosts read by the precheck to the resolve workers
+func (r *Runner) feedPrecheckInput() error {
+	defer close(r.workerchan)
+	defer r.closePrecheckInput()
+	r.precheckInput.hosts.Scan(func(k, v []byte) error {
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("hosts", 1)
+			r.stats.IncrementCounter("total", r.requestsPerHost())
+		}
+		r.queueHost(string(k), string(v))
+		return nil
+	})
+	return nil
+}
+
+// closePrecheckInput releases the input read by the precheck, the next runs read the input again
+func (r *Runner) closePrecheckInput() {
+	if r.precheckInput != nil {
+		r.precheckInput.hosts.Close()
+		r.precheckInput = nil
+	}
+}
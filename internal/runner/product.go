@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"bufio"
+	"strings"
+	"sync"
+)
+
+// streamProduct generates the wordlist and domains product one domain at a time and hands
+// each name to onHost as soon as it's generated, so that the product is never materialized.
+// Up to DomainConcurrency domains are expanded in parallel.
+func (r *Runner) streamProduct(sc *bufio.Scanner, prefixs []string, onHost func(host, input string)) error {
+	words := uniqueWords(prefixs)
+	concurrency := r.options.DomainConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	seen := make(map[string]struct{})
+	for r.ingesting() && sc.Scan() {
+		item := strings.TrimSpace(sc.Text())
+		domain, _ := splitHostPort(item)
+		if domain == "" {
+			continue
+		}
+		if _, ok := seen[domain]; ok {
+			continue
+		}
+		seen[domain] = struct{}{}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item, domain string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.expandTarget(domain, words, func(host string) {
+				if !r.inScope(host) {
+					return
+				}
+				if r.options.ShowStatistics {
+					r.stats.IncrementCounter("hosts", 1)
+					r.stats.IncrementCounter("total", r.requestsPerHost())
+				}
+				onHost(host, item)
+			})
+		}(item, domain)
+	}
+	wg.Wait()
+	return sc.Err()
+}
+
+// productHost combines the word with the domain, the first * of glob domains is replaced by the word
+func productHost(word, domain string) string {
+	if strings.Contains(domain, "*") {
+		return strings.Replace(domain, "*", word, 1)
+	}
+	return word + "." + domain
+}
+
+// uniqueWords returns the trimmed non empty words without duplicates
+func uniqueWords(prefixs []string) []string {
+	seen := make(map[string]struct{}, len(prefixs))
+	var words []string
+	for _, prefix := range prefixs {
+		word := strings.TrimSpace(prefix)
+		if word == "" {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		words = append(words, word)
+	}
+	return words
+}
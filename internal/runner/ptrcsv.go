@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ptrCSVLines returns the ip,ptr lines of the PTR records of the result, followed by the
+// values of the labels
+func (r *Runner) ptrCSVLines(key string, result *dnsResult) []string {
+	var lines []string
+	for _, ptr := range result.PTR {
+		lines = append(lines, r.options.csvLine(key, strings.TrimSuffix(strings.ToLower(ptr), ".")))
+	}
+	return lines
+}
+
+// sortLinesByIP sorts the csv lines numerically by the address of their first field, the ipv4
+// addresses first, the lines not starting with an address last
+func sortLinesByIP(lines []string) {
+	keys := make(map[string]net.IP, len(lines))
+	for _, line := range lines {
+		address := line
+		if i := strings.Index(line, Comma); i >= 0 {
+			address = line[:i]
+		}
+		keys[line] = net.ParseIP(address)
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := keys[lines[i]], keys[lines[j]]
+		switch {
+		case a == nil || b == nil:
+			if a == nil && b == nil {
+				return lines[i] < lines[j]
+			}
+			return b == nil
+		case (a.To4() == nil) != (b.To4() == nil):
+			return a.To4() != nil
+		}
+		return bytes.Compare(a.To16(), b.To16()) < 0
+	})
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/iputil"
+)
+
+// reverseZoneCheck is the verdict of a reverse zone, computed once and shared by the workers
+type reverseZoneCheck struct {
+	once    sync.Once
+	missing bool
+}
+
+// skipMissingReverseZone reports whether the PTR query of the IP can be skipped because its
+// /16 reverse zone doesn't exist or its /24 reverse zone doesn't exist or refuses queries
+func (r *Runner) skipMissingReverseZone(host string) bool {
+	if !iputil.IsIPv4(host) {
+		return false
+	}
+	octets := strings.Split(host, ".")
+	zone16 := octets[1] + "." + octets[0] + ".in-addr.arpa"
+	// NXDOMAIN means that nothing exists below the name, the whole /16 is skipped
+	missing := r.reverseZoneMissing(zone16, false)
+	if !missing {
+		missing = r.reverseZoneMissing(octets[2]+"."+zone16, true)
+	}
+	if missing {
+		atomic.AddUint64(&r.reverseSkipped, 1)
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("skipped", 1)
+		}
+	}
+	return missing
+}
+
+func (r *Runner) reverseZoneMissing(zone string, refusedIsMissing bool) bool {
+	value, _ := r.reverseZones.LoadOrStore(zone, &reverseZoneCheck{})
+	check := value.(*reverseZoneCheck)
+	check.once.Do(func() {
+		msg, err := r.dnsx.QueryMsg(zone, dns.TypeSOA)
+		if err != nil {
+			return
+		}
+		switch msg.Rcode {
+		case dns.RcodeNameError:
+			check.missing = true
+		case dns.RcodeRefused:
+			check.missing = refusedIsMissing
+		}
+	})
+	return check.missing
+}
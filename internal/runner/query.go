@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// query resolves the host with the resolution mode selected by the options,
+// the metadata is only available for dns queries and local answers. The dns
+// queries in flight are aborted when the workers are stopped.
+func (r *Runner) query(domain string) (*retryabledns.DNSData, *dnsx.Metadata, error) {
+	var (
+		dnsData  *retryabledns.DNSData
+		metadata *dnsx.Metadata
+		err      error
+	)
+	switch {
+	case r.localHosts != nil:
+		dnsData, metadata = r.localHosts.Answer(domain)
+	case r.options.MDNS:
+		dnsData, err = r.dnsx.QueryMulticast(domain, dnsx.MDNSAddresses)
+	case r.options.LLMNR:
+		dnsData, err = r.dnsx.QueryMulticast(domain, dnsx.LLMNRAddresses)
+	case r.options.NBNS:
+		dnsData, err = dnsx.QueryNBNS(domain, r.options.NBNSTarget, dnsx.MulticastTimeout)
+	default:
+		dnsData, metadata, err = r.dnsx.QueryMultipleWithMetadataContext(r.workerctx, domain)
+	}
+	return dnsData, metadata, err
+}
+
+// queryFresh resolves the host like query but the dns questions always reach the resolvers,
+// the local answers and the response cache are bypassed so that repeated queries observe the network
+func (r *Runner) queryFresh(domain string) (*retryabledns.DNSData, error) {
+	if r.localHosts != nil || r.options.MDNS || r.options.LLMNR || r.options.NBNS {
+		dnsData, _, err := r.query(domain)
+		return dnsData, err
+	}
+	dnsData, _, err := r.dnsx.QueryMultipleFreshContext(r.workerctx, domain)
+	return dnsData, err
+}
+
+// queryMsg sends a follow-up question about a host, like the main queries it takes the rate
+// limiter and is counted in the statistics
+func (r *Runner) queryMsg(hostname string, questionType uint16) (*dns.Msg, error) {
+	r.takeLimiter()
+	if r.options.ShowStatistics {
+		r.stats.IncrementCounter("queries", 1)
+	}
+	return r.dnsx.QueryMsgContext(r.workerctx, hostname, questionType)
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// TestLocalAnswersFilters runs the filters and output formats against the answers of the
+// network, the response cache and a hosts file, which must all be treated the same way
+func TestLocalAnswersFilters(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsFile, []byte("192.0.2.1 www.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := []struct {
+		name      string
+		configure func(*Options)
+		// the lines written to the output file, none when the host is filtered
+		lines []string
+	}{
+		{name: "plain", configure: func(*Options) {}, lines: []string{"www.example.com"}},
+		{name: "rcode noerror", configure: func(o *Options) { o.RCode = "noerror" }, lines: []string{"www.example.com [NOERROR]"}},
+		{name: "rcode nxdomain", configure: func(o *Options) { o.RCode = "nxdomain" }},
+		{name: "resp", configure: func(o *Options) { o.Response = true }, lines: []string{"www.example.com [192.0.2.1]"}},
+		{name: "resp-only", configure: func(o *Options) { o.ResponseOnly = true }, lines: []string{"192.0.2.1"}},
+		{name: "output filter", configure: func(o *Options) { o.OutputFilter = []string{o.Output[0] + ":rcode==NOERROR&&type==A"}; o.Output = nil }, lines: []string{"www.example.com"}},
+	}
+	sources := []struct {
+		source    string
+		configure func(*Options)
+	}{
+		{source: dnsx.SourceNetwork, configure: func(*Options) {}},
+		{source: dnsx.SourceCache, configure: func(o *Options) { o.CacheSize = 10 }},
+		{source: dnsx.SourceHostsFile, configure: func(o *Options) { o.LocalResolve = hostsFile }},
+	}
+	for _, filter := range filters {
+		for _, source := range sources {
+			t.Run(filter.name+"/"+source.source, func(t *testing.T) {
+				output := filepath.Join(t.TempDir(), "output.txt")
+				var results []*Result
+				r := newConfiguredRunner(t, server, func(options *Options) {
+					options.Output = []string{output}
+					filter.configure(options)
+					source.configure(options)
+				}, func(result *Result) {
+					results = append(results, result)
+				})
+				defer r.Close()
+
+				// the first run fills the cache
+				if source.source == dnsx.SourceCache {
+					if err := r.RunHosts([]string{"www.example.com"}); err != nil {
+						t.Fatal(err)
+					}
+					if err := r.Reset(); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.Truncate(output, 0); err != nil && !os.IsNotExist(err) {
+						t.Fatal(err)
+					}
+					results = nil
+				}
+				if err := r.RunHosts([]string{"www.example.com"}); err != nil {
+					t.Fatal(err)
+				}
+
+				data, err := os.ReadFile(output)
+				if err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+				if got := strings.TrimSpace(string(data)); got != strings.Join(filter.lines, "\n") {
+					t.Errorf("got output %q, want %q", got, filter.lines)
+				}
+				if len(results) != len(filter.lines) {
+					t.Fatalf("got %d results, want %d", len(results), len(filter.lines))
+				}
+				for _, result := range results {
+					if result.Source != source.source || result.StatusCode != "NOERROR" {
+						t.Errorf("got source %s and status %s", result.Source, result.StatusCode)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestLocalAnswersJSON checks that the json results of the cache and the hosts file carry
+// their source and response code
+func TestLocalAnswersJSON(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsFile, []byte("192.0.2.1 www.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source    string
+		configure func(*Options)
+	}{
+		{source: dnsx.SourceNetwork, configure: func(*Options) {}},
+		{source: dnsx.SourceCache, configure: func(o *Options) { o.CacheSize = 10 }},
+		{source: dnsx.SourceHostsFile, configure: func(o *Options) { o.LocalResolve = hostsFile }},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			var lines []string
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.JSON = true
+				test.configure(options)
+			}, func(result *Result) {
+				line, err := result.JSON()
+				if err != nil {
+					t.Error(err)
+				}
+				lines = append(lines, line)
+			})
+			defer r.Close()
+			// the second run is answered from the cache when it's enabled
+			for i := 0; i < 2; i++ {
+				lines = nil
+				if err := r.Reset(); err != nil {
+					t.Fatal(err)
+				}
+				if err := r.RunHosts([]string{"www.example.com"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(lines) != 1 {
+				t.Fatalf("got results %v, want 1", lines)
+			}
+			for _, field := range []string{`"source":"` + test.source + `"`, `"status_code":"NOERROR"`, `"a":["192.0.2.1"]`} {
+				if !strings.Contains(lines[0], field) {
+					t.Errorf("result %s doesn't contain %s", lines[0], field)
+				}
+			}
+		})
+	}
+}
+
+// countingLimiter counts the requests allowed by the rate limiter
+type countingLimiter struct {
+	takes int32
+}
+
+func (l *countingLimiter) Take() time.Time {
+	atomic.AddInt32(&l.takes, 1)
+	return time.Now()
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+)
+
+// unranked is the rank of the domains missing from the rank database
+const unranked = -1
+
+// rankDB maps the domains of a top sites list (tranco, alexa) to their rank
+type rankDB map[string]int
+
+// loadRankDB reads a top sites list in the rank,domain csv format, the lines not starting
+// with a rank (eg. a header) are skipped
+func loadRankDB(path string) (rankDB, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := make(rankDB)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(sc.Text()), Comma, 3)
+		if len(fields) < 2 {
+			continue
+		}
+		rank, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || rank <= 0 {
+			continue
+		}
+		domain := strings.ToLower(strings.Trim(strings.TrimSpace(fields[1]), "."))
+		if previous, ok := db[domain]; domain == "" || ok && previous <= rank {
+			continue
+		}
+		db[domain] = rank
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(db) == 0 {
+		return nil, errors.New("no ranked domains found")
+	}
+	return db, nil
+}
+
+// rank returns the rank of the host, the one of its registered domain when the host itself
+// isn't listed, or -1
+func (db rankDB) rank(host string) int {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if rank, ok := db[host]; ok {
+		return rank
+	}
+	if apex, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		if rank, ok := db[apex]; ok {
+			return rank
+		}
+	}
+	return unranked
+}
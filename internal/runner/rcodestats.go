@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+)
+
+// noResponse is the name of the queries left without any response
+const noResponse = "NO_RESPONSE"
+
+// rcodeStats counts the response codes of the queries sent during the run
+type rcodeStats struct {
+	mutex  sync.Mutex
+	counts map[int]uint64
+}
+
+func newRcodeStats() *rcodeStats {
+	return &rcodeStats{counts: make(map[int]uint64)}
+}
+
+// record counts the response code of a query attempt
+func (s *rcodeStats) record(timing dnsx.QueryTiming) {
+	s.mutex.Lock()
+	s.counts[timing.Rcode]++
+	s.mutex.Unlock()
+}
+
+// String returns the response codes from the most to the least frequent
+// (eg. NOERROR: 45000, NXDOMAIN: 12000)
+func (s *rcodeStats) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rcodes := make([]int, 0, len(s.counts))
+	for rcode := range s.counts {
+		rcodes = append(rcodes, rcode)
+	}
+	sort.Slice(rcodes, func(i, j int) bool {
+		if s.counts[rcodes[i]] != s.counts[rcodes[j]] {
+			return s.counts[rcodes[i]] > s.counts[rcodes[j]]
+		}
+		return rcodes[i] < rcodes[j]
+	})
+	items := make([]string, 0, len(rcodes))
+	for _, rcode := range rcodes {
+		items = append(items, fmt.Sprintf("%s: %d", rcodeName(rcode), s.counts[rcode]))
+	}
+	return strings.Join(items, ", ")
+}
+
+// rcodeName returns the name of the response code, its value when unknown
+func rcodeName(rcode int) string {
+	if rcode < 0 {
+		return noResponse
+	}
+	if name, ok := dns.RcodeToString[rcode]; ok {
+		return name
+	}
+	return fmt.Sprint(rcode)
+}
+
+// reportRcodeStats logs the distribution of the response codes seen during the run
+func (r *Runner) reportRcodeStats() {
+	if r.rcodeStats == nil {
+		return
+	}
+	summary := r.rcodeStats.String()
+	if summary == "" {
+		summary = "no queries sent"
+	}
+	gologger.Info().Msgf("Response codes: %s\n", summary)
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+// ExitPanicsRecovered is the exit code of dnsx when panics were recovered during the run
+const ExitPanicsRecovered = 4
+
+// ErrPanicsRecovered is returned by Run when panics were recovered, the results of the other
+// hosts are complete
+var ErrPanicsRecovered = errors.New("panics recovered during the run")
+
+// errPanic is the error of the hosts whose processing panicked
+var errPanic = errors.New("panic while processing the host")
+
+// recoverPanic runs fn and reports whether it panicked, the panic is logged along with the
+// host being processed and counted instead of crashing the run unless no-recover is set
+func (r *Runner) recoverPanic(host string, fn func()) (recovered bool) {
+	if r.options.NoRecover {
+		fn()
+		return false
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			atomic.AddUint64(&r.panics, 1)
+			gologger.Error().Msgf("Recovered panic while processing %s: %v\n", host, p)
+			gologger.Debug().Msgf("%s\n", debug.Stack())
+			recovered = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// PanicsRecovered returns the number of panics recovered during the run
+func (r *Runner) PanicsRecovered() uint64 {
+	return atomic.LoadUint64(&r.panics)
+}
+
+// reportPanics logs the number of panics recovered during the run
+func (r *Runner) reportPanics() error {
+	panics := r.PanicsRecovered()
+	if panics == 0 {
+		return nil
+	}
+	gologger.Info().Msgf("%d panics recovered during the run\n", panics)
+	return ErrPanicsRecovered
+}
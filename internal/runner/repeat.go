@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// Consistency of the answers across repeated queries
+const (
+	consistencyIdentical = "identical"
+	consistencyPartial   = "partial"
+	consistencyRotating  = "rotating"
+)
+
+// repeatAnswers contains the answers observed for a question type across repeated queries
+type repeatAnswers struct {
+	Consistency  string     `json:"consistency"`
+	Answers      [][]string `json:"answers"`
+	Union        []string   `json:"union,omitempty"`
+	Intersection []string   `json:"intersection,omitempty"`
+}
+
+// repeatItem is a host whose repeated queries are pending
+type repeatItem struct {
+	domain   string
+	input    string
+	attempts int
+	answers  map[uint16][][]string
+	complete func(consistency string, answers map[string]*repeatAnswers)
+}
+
+// collect adds the answers of an attempt for each question type
+func (item *repeatItem) collect(questionTypes []uint16, dnsData *retryabledns.DNSData) {
+	for _, questionType := range questionTypes {
+		var values []string
+		if dnsData != nil {
+			values = recordValues(dnsData, questionType)
+		}
+		item.answers[questionType] = append(item.answers[questionType], normalizeAnswers(values))
+	}
+	item.attempts++
+}
+
+// scheduleRepeats queries the host again after the repeat delay until the configured number
+// of attempts is reached, then calls complete with the overall consistency and the answers
+// for each question type. The delays are timers so the worker moves on to the next host, the
+// pending host is counted in the resolve workers wait group until it completes.
+func (r *Runner) scheduleRepeats(domain, input string, first *retryabledns.DNSData, complete func(string, map[string]*repeatAnswers)) {
+	item := &repeatItem{domain: domain, input: input, answers: make(map[uint16][][]string), complete: complete}
+	item.collect(r.dnsx.Options.QuestionTypes, first)
+	r.wgresolveworkers.Add(1)
+	time.AfterFunc(r.options.repeatDelay, func() { r.repeat(item) })
+}
+
+// repeat sends the next query of a repeated host, at most threads repeated queries are in
+// flight at once. Every attempt is sent to the resolvers as the local answers are bypassed.
+func (r *Runner) repeat(item *repeatItem) {
+	pending := false
+	defer func() {
+		if !pending {
+			r.wgresolveworkers.Done()
+		}
+	}()
+	if r.ctx.Err() != nil {
+		return
+	}
+	recovered := r.recoverPanic(item.domain, func() {
+		r.repeatslots <- struct{}{}
+		r.takeLimiter()
+		dnsData, _ := r.queryFresh(item.domain)
+		<-r.repeatslots
+		item.collect(r.dnsx.Options.QuestionTypes, dnsData)
+		if item.attempts < r.options.Repeat {
+			pending = true
+			time.AfterFunc(r.options.repeatDelay, func() { r.repeat(item) })
+			return
+		}
+		item.complete(repeatResults(item.answers))
+	})
+	if recovered && r.outputsUnmatched {
+		r.emitFailure(item.domain, item.input, errPanic)
+	}
+}
+
+// repeatResults returns the overall consistency and the answers for each question type
+func repeatResults(answers map[uint16][][]string) (string, map[string]*repeatAnswers) {
+	overall := consistencyIdentical
+	results := make(map[string]*repeatAnswers)
+	for questionType, sets := range answers {
+		result := newRepeatAnswers(sets)
+		results[dns.TypeToString[questionType]] = result
+		if consistencyRank(result.Consistency) > consistencyRank(overall) {
+			overall = result.Consistency
+		}
+	}
+	return overall, results
+}
+
+func newRepeatAnswers(sets [][]string) *repeatAnswers {
+	result := &repeatAnswers{Answers: sets}
+	counts := make(map[string]int)
+	identical := true
+	for _, set := range sets {
+		if strings.Join(set, Comma) != strings.Join(sets[0], Comma) {
+			identical = false
+		}
+		for _, value := range set {
+			counts[value]++
+		}
+	}
+	for value, count := range counts {
+		result.Union = append(result.Union, value)
+		if count == len(sets) {
+			result.Intersection = append(result.Intersection, value)
+		}
+	}
+	sort.Strings(result.Union)
+	sort.Strings(result.Intersection)
+
+	switch {
+	case identical:
+		result.Consistency = consistencyIdentical
+	case len(result.Intersection) > 0:
+		result.Consistency = consistencyPartial
+	default:
+		result.Consistency = consistencyRotating
+	}
+	return result
+}
+
+// normalizeAnswers returns the sorted unique lowercase values
+func normalizeAnswers(values []string) []string {
+	seen := make(map[string]struct{})
+	normalized := []string{}
+	for _, value := range values {
+		value = strings.ToLower(value)
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		normalized = append(normalized, value)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func consistencyRank(consistency string) int {
+	switch consistency {
+	case consistencyPartial:
+		return 1
+	case consistencyRotating:
+		return 2
+	default:
+		return 0
+	}
+}
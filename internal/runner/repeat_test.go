@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+	"go.uber.org/ratelimit"
+)
+
+func TestNewRepeatAnswers(t *testing.T) {
+	tests := []struct {
+		sets         [][]string
+		consistency  string
+		union        []string
+		intersection []string
+	}{
+		{sets: [][]string{{"192.0.2.1"}, {"192.0.2.1"}}, consistency: consistencyIdentical, union: []string{"192.0.2.1"}, intersection: []string{"192.0.2.1"}},
+		{sets: [][]string{{"192.0.2.1", "192.0.2.2"}, {"192.0.2.1"}}, consistency: consistencyPartial, union: []string{"192.0.2.1", "192.0.2.2"}, intersection: []string{"192.0.2.1"}},
+		{sets: [][]string{{"192.0.2.1"}, {"192.0.2.2"}}, consistency: consistencyRotating, union: []string{"192.0.2.1", "192.0.2.2"}},
+		{sets: [][]string{{}, {}}, consistency: consistencyIdentical},
+	}
+	for _, test := range tests {
+		result := newRepeatAnswers(test.sets)
+		if result.Consistency != test.consistency {
+			t.Errorf("%v: got consistency %s, want %s", test.sets, result.Consistency, test.consistency)
+		}
+		if fmt.Sprint(result.Union) != fmt.Sprint(test.union) || fmt.Sprint(result.Intersection) != fmt.Sprint(test.intersection) {
+			t.Errorf("%v: got union %v and intersection %v", test.sets, result.Union, result.Intersection)
+		}
+	}
+}
+
+func TestNormalizeAnswers(t *testing.T) {
+	got := normalizeAnswers([]string{"B.example.com", "a.example.com", "b.example.com"})
+	if fmt.Sprint(got) != "[a.example.com b.example.com]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestScheduleRepeats(t *testing.T) {
+	var queries int32
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		n := atomic.AddInt32(&queries, 1)
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.IPv4(192, 0, 2, byte(n)),
+		})
+		w.WriteMsg(resp) // nolint:errcheck
+	})
+	r := &Runner{
+		options: &Options{Threads: 2, Repeat: 3, repeatDelay: 50 * time.Millisecond},
+		dnsx:    newTestDNSX(t, server),
+		limiter: ratelimit.NewUnlimited(),
+	}
+	r.prepareRun()
+	defer r.cancel()
+
+	var consistency string
+	var answers map[string]*repeatAnswers
+	var completed sync.WaitGroup
+	completed.Add(1)
+	start := time.Now()
+	// localhost is answered by the hosts file, the repeats must reach the server anyway
+	r.scheduleRepeats("localhost", "localhost", &retryabledns.DNSData{A: []string{"127.0.0.1"}}, func(c string, a map[string]*repeatAnswers) {
+		consistency, answers = c, a
+		completed.Done()
+	})
+	if elapsed := time.Since(start); elapsed >= r.options.repeatDelay {
+		t.Fatalf("scheduling blocked for %s", elapsed)
+	}
+	r.wgresolveworkers.Wait()
+	completed.Wait()
+
+	if got := atomic.LoadInt32(&queries); got != 2 {
+		t.Fatalf("got %d queries at the server, want 2", got)
+	}
+	if consistency != consistencyRotating {
+		t.Fatalf("got consistency %s, want %s", consistency, consistencyRotating)
+	}
+	if got := fmt.Sprint(answers["A"].Answers); got != "[[127.0.0.1] [192.0.2.1] [192.0.2.2]]" {
+		t.Fatalf("got answers %s", got)
+	}
+}
+
+func TestScheduleRepeatsCanceled(t *testing.T) {
+	r := &Runner{
+		options: &Options{Threads: 1, Repeat: 2, repeatDelay: 10 * time.Millisecond},
+		dnsx:    newTestDNSX(t, "127.0.0.1:1"),
+		limiter: ratelimit.NewUnlimited(),
+	}
+	r.prepareRun()
+	r.cancel()
+	r.scheduleRepeats("example.com", "example.com", nil, func(string, map[string]*repeatAnswers) {
+		t.Error("a canceled repeat completed")
+	})
+	r.wgresolveworkers.Wait()
+}
@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/projectdiscovery/dnsx/internal/reputation"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+const (
+	// reputationTimeout is the timeout of the requests to the reputation service
+	reputationTimeout = 10 * time.Second
+	// maxPendingReputation bounds the hosts waiting for the reputation of their addresses,
+	// the resolve workers wait for a slot once it's reached
+	maxPendingReputation = 1000
+)
+
+// reputationService looks up the reputation of the addresses
+type reputationService interface {
+	Lookup(ctx context.Context, ip string) (*reputation.Report, error)
+}
+
+// reputationEntry is the lookup of an address, shared by the hosts resolving to it
+type reputationEntry struct {
+	done   chan struct{}
+	report *reputation.Report
+}
+
+// scheduleReputation looks up the reputation of the addresses of the host off the resolve
+// worker, which moves on to the next host while the requests wait for the rate limit of the
+// service. The pending host is counted in the resolve workers wait group until it completes.
+func (r *Runner) scheduleReputation(item inputItem, domain string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata, result *dnsResult) {
+	select {
+	case r.reputationslots <- struct{}{}:
+	case <-r.workerctx.Done():
+		return
+	}
+	r.wgresolveworkers.Add(1)
+	go func() {
+		defer func() {
+			<-r.reputationslots
+			r.wgresolveworkers.Done()
+		}()
+		recovered := r.recoverPanic(domain, func() {
+			result.Reputation = r.reputationLookups(dnsData)
+			if r.workerctx.Err() != nil {
+				return
+			}
+			// hosts left without addresses by the reputation filter are treated as filtered
+			if r.options.ReputationFilter && !r.filterReputation(dnsData, result.Reputation) {
+				if r.outputsUnmatched {
+					r.emit(&outputEvent{result: result, status: statusFiltered})
+				}
+				return
+			}
+			r.repeatItem(item, domain, dnsData, metadata, result)
+		})
+		if recovered && r.outputsUnmatched {
+			r.emitFailure(domain, item.input, errPanic)
+		}
+	}()
+}
+
+// reputationLookups returns the reputation reports of the A and AAAA records of the response,
+// each address is looked up once per run. The failed lookups aren't cached, the addresses
+// are looked up again for the next hosts.
+func (r *Runner) reputationLookups(dnsData *retryabledns.DNSData) []*reputation.Report {
+	var reports []*reputation.Report
+	for _, records := range [][]string{dnsData.A, dnsData.AAAA} {
+		for _, ip := range records {
+			if report := r.reputationReport(ip); report != nil {
+				reports = append(reports, report)
+			}
+		}
+	}
+	return reports
+}
+
+// reputationReport returns the report of the address, the hosts resolving to an address
+// being looked up wait for its lookup
+func (r *Runner) reputationReport(ip string) *reputation.Report {
+	entry := &reputationEntry{done: make(chan struct{})}
+	if cached, loaded := r.reputationCache.LoadOrStore(ip, entry); loaded {
+		entry = cached.(*reputationEntry)
+		select {
+		case <-entry.done:
+			return entry.report
+		case <-r.workerctx.Done():
+			return nil
+		}
+	}
+	defer close(entry.done)
+	report, err := r.reputation.Lookup(r.workerctx, ip)
+	if err != nil {
+		r.reputationCache.Delete(ip)
+		// the errors are logged once, they don't depend on the address
+		if r.workerctx.Err() == nil {
+			if _, logged := r.reputationErrors.LoadOrStore(err.Error(), struct{}{}); !logged {
+				gologger.Warning().Msgf("Could not look up the reputation of %s: %s\n", ip, err)
+			}
+		}
+		return nil
+	}
+	entry.report = report
+	return report
+}
+
+// filterReputation removes the addresses scoring below the threshold from the response and
+// reports whether the host still has addresses. The addresses whose lookup failed are kept.
+func (r *Runner) filterReputation(dnsData *retryabledns.DNSData, reports []*reputation.Report) bool {
+	low := make(map[string]struct{})
+	for _, report := range reports {
+		if report.Score < r.options.ReputationLimit {
+			low[report.IP] = struct{}{}
+		}
+	}
+	filter := func(records []string) []string {
+		var kept []string
+		for _, ip := range records {
+			if _, ok := low[ip]; !ok {
+				kept = append(kept, ip)
+			}
+		}
+		return kept
+	}
+	dnsData.A = filter(dnsData.A)
+	dnsData.AAAA = filter(dnsData.AAAA)
+	return len(dnsData.A) > 0 || len(dnsData.AAAA) > 0
+}
+
+// lowReputationTags returns the plain output tags of the addresses scoring below the threshold
+func (r *Runner) lowReputationTags(reports []*reputation.Report) string {
+	var tags string
+	for _, report := range reports {
+		if report.Score < r.options.ReputationLimit {
+			tags += r.field(fmt.Sprintf("low-reputation:%s:%d", report.IP, report.Score))
+		}
+	}
+	return tags
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/internal/reputation"
+)
+
+// answerReputation answers low.example.com with a low reputation address, mixed.example.com
+// with a low and a good one and failing.example.com with an address whose lookup fails
+func answerReputation(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	name := req.Question[0].Name
+	addresses := map[string][]string{
+		"low.example.com.":      {"192.0.2.1"},
+		"low2.example.com.":     {"192.0.2.1"},
+		"mixed.example.com.":    {"192.0.2.1", "192.0.2.2"},
+		"failing.example.com.":  {"192.0.2.3"},
+		"failing2.example.com.": {"192.0.2.3"},
+	}
+	if req.Question[0].Qtype == dns.TypeA {
+		for _, ip := range addresses[name] {
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP(ip),
+			})
+		}
+	}
+	w.WriteMsg(resp) // nolint:errcheck
+}
+
+// stubReputation scores 192.0.2.1 10 and 192.0.2.2 90, the other lookups fail
+type stubReputation struct {
+	sync.Mutex
+	lookups map[string]int
+}
+
+func (s *stubReputation) Lookup(ctx context.Context, ip string) (*reputation.Report, error) {
+	s.Lock()
+	s.lookups[ip]++
+	s.Unlock()
+	scores := map[string]int{"192.0.2.1": 10, "192.0.2.2": 90}
+	score, ok := scores[ip]
+	if !ok {
+		return nil, errors.New("abuseipdb returned status 500")
+	}
+	return &reputation.Report{IP: ip, Provider: reputation.ProviderAbuseIPDB, Score: score}, nil
+}
+
+func TestReputation(t *testing.T) {
+	server := newTestDNSServer(t, answerReputation)
+	tests := []struct {
+		name   string
+		filter bool
+		hosts  []string
+		// the addresses and the scored addresses of the results, by host
+		want    map[string]string
+		lookups map[string]int
+	}{
+		{
+			name:    "annotate",
+			hosts:   []string{"low.example.com", "low2.example.com", "mixed.example.com"},
+			want:    map[string]string{"low.example.com": "[192.0.2.1] [192.0.2.1:10]", "low2.example.com": "[192.0.2.1] [192.0.2.1:10]", "mixed.example.com": "[192.0.2.1 192.0.2.2] [192.0.2.1:10 192.0.2.2:90]"},
+			lookups: map[string]int{"192.0.2.1": 1, "192.0.2.2": 1},
+		},
+		{
+			name:    "filter",
+			filter:  true,
+			hosts:   []string{"low.example.com", "mixed.example.com"},
+			want:    map[string]string{"mixed.example.com": "[192.0.2.2] [192.0.2.1:10 192.0.2.2:90]"},
+			lookups: map[string]int{"192.0.2.1": 1, "192.0.2.2": 1},
+		},
+		// the failed lookups are kept unscored and aren't cached
+		{
+			name:    "failing",
+			filter:  true,
+			hosts:   []string{"failing.example.com", "failing2.example.com"},
+			want:    map[string]string{"failing.example.com": "[192.0.2.3] []", "failing2.example.com": "[192.0.2.3] []"},
+			lookups: map[string]int{"192.0.2.3": 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make(map[string]string)
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.IPReputation = true
+				options.ReputationKey = "secret"
+				options.ReputationFilter = test.filter
+				// the failing hosts are resolved one after the other
+				options.Threads = 1
+			}, func(result *Result) {
+				var scores []string
+				for _, report := range result.Reputation {
+					scores = append(scores, fmt.Sprintf("%s:%d", report.IP, report.Score))
+				}
+				sort.Strings(scores)
+				got[result.Host] = fmt.Sprintf("%v %v", result.A, scores)
+			})
+			defer r.Close()
+			stub := &stubReputation{lookups: make(map[string]int)}
+			r.reputation = stub
+
+			if err := r.RunHosts(test.hosts); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got results %v, want %v", got, test.want)
+			}
+			if fmt.Sprint(stub.lookups) != fmt.Sprint(test.lookups) {
+				t.Errorf("got lookups %v, want %v", stub.lookups, test.lookups)
+			}
+		})
+	}
+}
+
+func TestLowReputationTags(t *testing.T) {
+	r := &Runner{options: &Options{ReputationLimit: 50}}
+	reports := []*reputation.Report{{IP: "192.0.2.1", Score: 10}, {IP: "192.0.2.2", Score: 50}}
+	if got := strings.TrimSpace(r.lowReputationTags(reports)); got != "[low-reputation:192.0.2.1:10]" {
+		t.Errorf("got tags %q", got)
+	}
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// ExitNoResolvers is the exit code of dnsx when no usable resolver is left
+const ExitNoResolvers = 3
+
+// NoResolversError is returned by New when every resolver entry was dropped, the message is
+// kept machine parseable for scripts running in silent mode
+type NoResolversError struct {
+	Parsed  int
+	Dropped int
+}
+
+func (e *NoResolversError) Error() string {
+	return fmt.Sprintf("no usable resolvers: parsed=%d dropped=%d", e.Parsed, e.Dropped)
+}
+
+// parseResolvers returns the valid resolvers of the entries along with the number of entries
+// parsed, blank lines and comments aren't counted
+func parseResolvers(entries []string) ([]string, int) {
+	var (
+		resolvers []string
+		parsed    int
+	)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		parsed++
+		resolver := prepareResolver(entry)
+		if !validResolver(resolver) {
+			gologger.Warning().Msgf("Dropping invalid resolver %s\n", entry)
+			continue
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	return resolvers, parsed
+}
+
+// validResolver reports whether the resolver is an ip address or a hostname with an optional
+// protocol and port, the hostnames are resolved by the system when the queries are sent
+func validResolver(resolver string) bool {
+	for _, prefix := range []string{"udp:", "tcp:"} {
+		resolver = strings.TrimPrefix(resolver, prefix)
+	}
+	host, port, err := net.SplitHostPort(resolver)
+	if err != nil {
+		// ipv6 addresses without port
+		host, port = resolver, "53"
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return false
+	}
+	host = strings.Trim(host, "[]")
+	return net.ParseIP(host) != nil || validHostname(host)
+}
+
+// validHostname reports whether the name is made of labels of letters, digits and hyphens,
+// the last label isn't numeric so that invalid addresses aren't taken for names
+func validHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidResolver(t *testing.T) {
+	tests := []struct {
+		resolver string
+		valid    bool
+	}{
+		{resolver: "1.1.1.1:53", valid: true},
+		{resolver: "udp:1.1.1.1:5353", valid: true},
+		{resolver: "tcp:[2606:4700:4700::1111]:53", valid: true},
+		{resolver: "2606:4700:4700::1111", valid: true},
+		{resolver: "dns.google:53", valid: true},
+		{resolver: "tcp:resolver-1.example.com.:853", valid: true},
+		{resolver: "localhost:53", valid: true},
+		{resolver: "1.1.1.1:0", valid: false},
+		{resolver: "1.1.1.1:65536", valid: false},
+		{resolver: "300.1.1.1:53", valid: false},
+		{resolver: "not a resolver:53", valid: false},
+		{resolver: "-bad.example.com:53", valid: false},
+		{resolver: "bad..example.com:53", valid: false},
+		{resolver: "https://dns.google/dns-query", valid: false},
+	}
+	for _, test := range tests {
+		if got := validResolver(test.resolver); got != test.valid {
+			t.Errorf("%s: got %v, want %v", test.resolver, got, test.valid)
+		}
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		resolvers []string
+		parsed    int
+	}{
+		{name: "empty"},
+		{name: "blank lines and comments", entries: []string{"", "  ", "\t", "# comment"}},
+		{name: "garbage", entries: []string{"garbage!", "@@@", "1.1.1.1:x", "999.999.999.999"}, parsed: 4},
+		{
+			name:      "mixed",
+			entries:   []string{"1.1.1.1", " dns.google ", "tcp:8.8.8.8:53", "in valid"},
+			resolvers: []string{"1.1.1.1:53", "dns.google:53", "tcp:8.8.8.8:53"},
+			parsed:    4,
+		},
+	}
+	for _, test := range tests {
+		resolvers, parsed := parseResolvers(test.entries)
+		if fmt.Sprint(resolvers) != fmt.Sprint(test.resolvers) || parsed != test.parsed {
+			t.Errorf("%s: got %v and %d parsed, want %v and %d", test.name, resolvers, parsed, test.resolvers, test.parsed)
+		}
+	}
+}
+
+// TestNoResolvers checks that New fails with the counts of the entries when the resolvers
+// file leaves no usable resolver
+func TestNoResolvers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		parsed  int
+	}{
+		{name: "empty", content: ""},
+		{name: "whitespace", content: "  \n\t\n\n"},
+		{name: "garbage", content: "not a resolver\n# comment\n1.1.1.1:99999\n$$$\n", parsed: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resolvers.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			options := DefaultOptions()
+			options.Resolvers = path
+			options.Silent = true
+			if err := options.Configure(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := New(options)
+			if err == nil {
+				r.Close()
+				t.Fatal("New succeeded without resolvers")
+			}
+			var noResolvers *NoResolversError
+			if !errors.As(err, &noResolvers) {
+				t.Fatalf("got error %v, want a NoResolversError", err)
+			}
+			if noResolvers.Parsed != test.parsed || noResolvers.Dropped != test.parsed {
+				t.Errorf("got %s, want %d parsed and dropped", err, test.parsed)
+			}
+		})
+	}
+}
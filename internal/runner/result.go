@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"encoding/json"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/internal/geo"
+	"github.com/projectdiscovery/dnsx/internal/reputation"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// inputItem is a host to resolve along with the verbatim input line it was generated from
+type inputItem struct {
+	input string
+	host  string
+	// index is the position of the host in the input tracked by resume, zero for the hosts
+	// found by the other phases
+	index int
+}
+
+// dnsResult extends the dns data with the annotations added by the runner
+type dnsResult struct {
+	*retryabledns.DNSData
+	Input                  string                    `json:"input,omitempty"`
+	Labels                 map[string]string         `json:"labels,omitempty"`
+	Source                 string                    `json:"source,omitempty"`
+	Permutation            string                    `json:"permutation,omitempty"`
+	Consistency            string                    `json:"consistency,omitempty"`
+	RepeatAnswers          map[string]*repeatAnswers `json:"repeat_answers,omitempty"`
+	Glue                   map[string][]string       `json:"glue,omitempty"`
+	StaleGlue              []string                  `json:"stale_glue,omitempty"`
+	ZoneOverride           string                    `json:"zone_override,omitempty"`
+	LatencyMs              int64                     `json:"latency_ms,omitempty"`
+	Retries                int                       `json:"retries,omitempty"`
+	Observed               []string                  `json:"observed,omitempty"`
+	ObservedChanged        bool                      `json:"observed_changed,omitempty"`
+	WildcardInherited      bool                      `json:"wildcard_inherited,omitempty"`
+	WildcardInheritedTypes []string                  `json:"wildcard_inherited_types,omitempty"`
+	TransportDiff          bool                      `json:"transport_diff,omitempty"`
+	TransportAnswers       map[string][]string       `json:"transport_answers,omitempty"`
+	SuspiciousAuthority    []string                  `json:"suspicious_authority,omitempty"`
+	Subzone                string                    `json:"subzone,omitempty"`
+	DNAME                  []string                  `json:"dname,omitempty"`
+	DNAMERewrites          []string                  `json:"dname_rewrites,omitempty"`
+	Lint                   []lintFinding             `json:"lint,omitempty"`
+	DNSBL                  []dnsblListing            `json:"dnsbl,omitempty"`
+	Reputation             []*reputation.Report      `json:"reputation,omitempty"`
+	Geo                    map[string]*geo.Location  `json:"geo,omitempty"`
+	Categories             map[string][]string       `json:"categories,omitempty"`
+	Rank                   *int                      `json:"rank,omitempty"`
+	SpoofSuspect           bool                      `json:"spoof_suspect,omitempty"`
+	SpoofResponses         []string                  `json:"spoof_responses,omitempty"`
+	TruncatedFinal         bool                      `json:"truncated_final,omitempty"`
+	NameViolations         []dnsx.NameViolation      `json:"name_violations,omitempty"`
+	CacheStatus            string                    `json:"cache_status,omitempty"`
+	CachedBy               []string                  `json:"cached_by,omitempty"`
+	Error                  string                    `json:"error,omitempty"`
+}
+
+// Result is the result of a host passed to the OnResult callback
+type Result = dnsResult
+
+// newResult wraps the dns data along with the runner annotations for the host
+func (r *Runner) newResult(dnsData *retryabledns.DNSData) *dnsResult {
+	result := &dnsResult{DNSData: dnsData, Labels: r.labels}
+	if technique, ok := r.permutations.Load(dnsData.Host); ok {
+		result.Permutation = technique.(string)
+	}
+	result.ZoneOverride = r.dnsx.ZoneOverride(dnsData.Host)
+	return result
+}
+
+// JSON returns the json representation of the result
+func (d *dnsResult) JSON() (string, error) {
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+// recordValues returns the values of the records matching the question type
+func recordValues(dnsData *retryabledns.DNSData, questionType uint16) []string {
+	switch questionType {
+	case dns.TypeA:
+		return dnsData.A
+	case dns.TypeAAAA:
+		return dnsData.AAAA
+	case dns.TypeCNAME:
+		return dnsData.CNAME
+	case dns.TypePTR:
+		return dnsData.PTR
+	case dns.TypeMX:
+		return dnsData.MX
+	case dns.TypeNS:
+		return dnsData.NS
+	case dns.TypeSOA:
+		return dnsData.SOA
+	case dns.TypeTXT:
+		return dnsData.TXT
+	}
+	return nil
+}
@@ -1,8 +1,54 @@
 package runner
 
+// resumeVersion is the version of the resume files written by this release. Since version 2
+// the index counts the unique hosts in input order, the resume files without version counted
+// them in the order of the on-disk map and their index can't be used.
+const resumeVersion = 2
+
 type ResumeCfg struct {
-	ResumeFrom   string
-	Index        int
-	current      string
-	currentIndex int
+	Version       int
+	ResumeFrom    string
+	Index         int
+	ResolversHash string
+	// CompletedJobs are the comma separated ids of the jobs completed by the workers of a
+	// coordinator, they depend on the JobSize the input was split with
+	CompletedJobs string
+	JobSize       int
+	current       string
+	currentIndex  int
+	// completedIndex is the position up to which every host was resolved, the hosts resolved
+	// while the previous ones are in flight wait in completed
+	completedIndex int
+	completed      map[int]string
+}
+
+// complete records the resolution of the host at the position of the input. The saved
+// position only covers the hosts resolved in a row, so the hosts still in flight when a
+// scan is interrupted are resolved again by the resumed one.
+func (cfg *ResumeCfg) complete(index int, host string) {
+	// the hosts skipped by a resumed scan are completed already
+	if cfg.completedIndex < cfg.Index {
+		cfg.completedIndex = cfg.Index
+	}
+	if cfg.completed == nil {
+		cfg.completed = make(map[int]string)
+	}
+	cfg.completed[index] = host
+	for {
+		next, ok := cfg.completed[cfg.completedIndex+1]
+		if !ok {
+			return
+		}
+		delete(cfg.completed, cfg.completedIndex+1)
+		cfg.completedIndex++
+		cfg.current = next
+	}
+}
+
+// resumeIndex returns the position saved in the resume file
+func (cfg *ResumeCfg) resumeIndex() int {
+	if cfg.completedIndex < cfg.Index {
+		return cfg.Index
+	}
+	return cfg.completedIndex
 }
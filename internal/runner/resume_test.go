@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// inTempDir runs the test from a temporary directory holding the resume file
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) }) // nolint:errcheck
+}
+
+func TestConfigureResume(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		index      int
+		resumeFrom string
+	}{
+		{name: "current version", content: "version = 2\nresume_from = b.example.com\nindex = 2\n", index: 2, resumeFrom: "b.example.com"},
+		{name: "no version", content: "resume_from = b.example.com\nindex = 2\n"},
+		{name: "no position", content: "resolvers_hash = abc\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			if err := os.WriteFile(DefaultResumeFile, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			options := &Options{Resume: true}
+			if err := options.configureResume(); err != nil {
+				t.Fatal(err)
+			}
+			if options.resumeCfg.Index != test.index || options.resumeCfg.ResumeFrom != test.resumeFrom {
+				t.Fatalf("got position %d (%s), want %d (%s)", options.resumeCfg.Index, options.resumeCfg.ResumeFrom, test.index, test.resumeFrom)
+			}
+		})
+	}
+}
+
+func TestResumeRoundTrip(t *testing.T) {
+	inTempDir(t)
+	r := &Runner{options: &Options{resumeCfg: &ResumeCfg{}}, dnsx: newTestDNSX(t, "127.0.0.1:1")}
+	r.prepareRun()
+	go func() {
+		for item := range r.workerchan {
+			r.markResolved(item)
+		}
+	}()
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		r.queueHost(host, host)
+	}
+	close(r.workerchan)
+	if err := r.SaveResumeConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &Options{Resume: true}
+	if err := options.configureResume(); err != nil {
+		t.Fatal(err)
+	}
+	resumed := &Runner{options: options}
+	resumed.prepareRun()
+	var queued []string
+	done := make(chan struct{})
+	go func() {
+		for item := range resumed.workerchan {
+			queued = append(queued, item.host)
+		}
+		close(done)
+	}()
+	// the hosts already processed are skipped in input order
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"} {
+		resumed.queueHost(host, host)
+	}
+	close(resumed.workerchan)
+	<-done
+	if len(queued) != 1 || queued[0] != "d.example.com" {
+		t.Fatalf("got queued hosts %v, want [d.example.com]", queued)
+	}
+}
+
+func TestResumeCfgComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     int
+		completed []int
+		want      int
+		current   string
+	}{
+		{name: "in order", completed: []int{1, 2, 3}, want: 3, current: "host3"},
+		{name: "out of order", completed: []int{2, 3, 1}, want: 3, current: "host3"},
+		// the hosts after one still in flight are resolved again
+		{name: "in flight", completed: []int{1, 3, 4}, want: 1, current: "host1"},
+		{name: "nothing completed", want: 0},
+		{name: "resumed", index: 5, completed: []int{7, 6}, want: 7, current: "host7"},
+		{name: "resumed in flight", index: 5, completed: []int{7}, want: 5},
+	}
+	for _, test := range tests {
+		cfg := &ResumeCfg{Index: test.index}
+		for _, index := range test.completed {
+			cfg.complete(index, fmt.Sprintf("host%d", index))
+		}
+		if got := cfg.resumeIndex(); got != test.want || cfg.current != test.current {
+			t.Errorf("%s: got position %d (%s), want %d (%s)", test.name, got, cfg.current, test.want, test.current)
+		}
+	}
+}
+
+func TestShouldSaveResume(t *testing.T) {
+	tests := []struct {
+		options Options
+		want    bool
+	}{
+		{options: Options{Hosts: "hosts.txt", DomainConcurrency: 4}, want: true},
+		{options: Options{Domains: "example.com", DomainConcurrency: 1}, want: true},
+		{options: Options{Domains: "example.com", DomainConcurrency: 4}, want: false},
+	}
+	for _, test := range tests {
+		if got := test.options.ShouldSaveResume(); got != test.want {
+			t.Errorf("ShouldSaveResume(domains=%q, domain-concurrency=%d) = %v, want %v", test.options.Domains, test.options.DomainConcurrency, got, test.want)
+		}
+	}
+}
@@ -3,73 +3,235 @@ package runner
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/clistats"
+	"github.com/projectdiscovery/dnsx/internal/geo"
+	"github.com/projectdiscovery/dnsx/internal/reputation"
+	"github.com/projectdiscovery/dnsx/internal/typo"
 	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/dnsx/libs/wildcards"
 	"github.com/projectdiscovery/fileutil"
 	"github.com/projectdiscovery/goconfig"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/hmap/store/hybrid"
-	"github.com/projectdiscovery/iputil"
-	"github.com/projectdiscovery/mapcidr"
 	retryabledns "github.com/projectdiscovery/retryabledns"
 	"go.uber.org/ratelimit"
 )
 
 // Runner is a client for running the enumeration process.
 type Runner struct {
-	options             *Options
-	dnsx                *dnsx.DNSX
-	wgoutputworker      *sync.WaitGroup
-	wgresolveworkers    *sync.WaitGroup
-	wgwildcardworker    *sync.WaitGroup
-	workerchan          chan string
-	outputchan          chan string
-	wildcardworkerchan  chan string
-	wildcards           map[string]struct{}
-	wildcardsmutex      sync.RWMutex
-	wildcardscache      map[string][]string
-	wildcardscachemutex sync.Mutex
-	limiter             ratelimit.Limiter
-	hm                  *hybrid.HybridMap
-	stats               clistats.StatisticsClient
+	options            *Options
+	dnsx               *dnsx.DNSX
+	wgoutputworker     *sync.WaitGroup
+	wgresolveworkers   *sync.WaitGroup
+	wgwildcardworker   *sync.WaitGroup
+	workerchan         chan inputItem
+	repeatslots        chan struct{}
+	reputationslots    chan struct{}
+	outputchan         chan *outputEvent
+	outputchanmutex    sync.RWMutex
+	wildcardworkerchan chan string
+	wildcards          *wildcards.Detector
+	limiter            ratelimit.Limiter
+	limitermutex       sync.RWMutex
+	ratelimit          int32
+	threads            int32
+	threadsmutex       sync.Mutex
+	pendingstops       int32
+	paused             bool
+	pausestatemutex    sync.Mutex
+	pausemutex         sync.RWMutex
+	control            *controlServer
+	controlmutex       sync.Mutex
+	statsrunning       int32
+	statsstop          chan struct{}
+	statsdone          chan struct{}
+	closeonce          sync.Once
+	resumemutex        sync.Mutex
+	runmutex           sync.Mutex
+	runhosts           []string
+	ctx                context.Context
+	cancel             context.CancelFunc
+	workerctx          context.Context
+	workercancel       context.CancelFunc
+	closectx           context.Context
+	closecancel        context.CancelFunc
+	deadline           *time.Timer
+	scheduleUntil      time.Time
+	deadlinereached    int32
+	runstarted         time.Time
+	firstresult        int64
+	permutations       sync.Map
+	uniqueips          sync.Map
+	lintTargets        sync.Map
+	domainQueries      sync.Map
+	dnsblCache         sync.Map
+	dnsblErrors        sync.Map
+	reputation         reputationService
+	categorizer        *categorizer
+	geo                *geo.Reader
+	previous           map[string]struct{}
+	ranks              rankDB
+	serveStore         resultStore
+	manifest           *runManifest
+	panics             uint64
+	inputDigest        *inputDigest
+	tlds               []string
+	sldDictionary      map[string][]string
+	zoneSerials        *zoneSerials
+	reputationCache    sync.Map
+	reputationErrors   sync.Map
+	subzones           sync.Map
+	quotaDropped       uint64
+	glueCache          sync.Map
+	localHosts         *dnsx.HostsFile
+	hostsOutput        *hostsWriter
+	timings            *timingsWriter
+	syslog             *syslogWriter
+	rcodeStats         *rcodeStats
+	execHook           *execHook
+	splunk             *splunkHEC
+	notifier           *notifier
+	scope              *scope
+	scopeDropped       uint64
+	outputsUnmatched   bool
+	reverseZones       sync.Map
+	reverseSkipped     uint64
+	precheckInput      *precheckInput
+	labels             map[string]string
+	observed           map[string][]string
+	state              *stateDir
+	collector          *jobCollector
+	hm                 *hybrid.HybridMap
+	wildcardhm         *hybrid.HybridMap
+	stats              clistats.StatisticsClient
 }
 
 func New(options *Options) (*Runner, error) {
 	retryabledns.CheckInternalIPs = true
 
+	options.seedRandom()
+
 	dnsxOptions := dnsx.DefaultOptions
 	dnsxOptions.MaxRetries = options.Retries
+	dnsxOptions.TypeTimeouts = options.typeTimeouts
+	dnsxOptions.TypeRetries = options.typeRetries
 	dnsxOptions.TraceMaxRecursion = options.TraceMaxRecursion
+	if options.TraceStartServer != "" {
+		for _, server := range strings.Split(options.TraceStartServer, Comma) {
+			dnsxOptions.TraceStartServers = append(dnsxOptions.TraceStartServers, prepareResolver(server))
+		}
+	}
 	dnsxOptions.Hostsfile = options.HostsFile
+	dnsxOptions.ZoneOverrides = options.zoneOverrides
+	dnsxOptions.ServerCapabilities = options.ServerCaps
+	dnsxOptions.Transport = options.transport()
+	dnsxOptions.SourcePortMin = options.sourcePortMin
+	dnsxOptions.SourcePortMax = options.sourcePortMax
+	dnsxOptions.SpoofWindow = options.spoofWindow
+	dnsxOptions.DNSCookies = options.DNSCookie
+	dnsxOptions.PinResolvers = options.PinResolver
+	dnsxOptions.CacheSize = options.CacheSize
+	if options.TransportDiff {
+		// the answers received over udp are compared with the tcp ones
+		dnsxOptions.Transport = dnsx.TransportUDP
+	}
 
+	// entries of the resolvers list, the invalid and skipped ones are dropped
+	var parsed, dropped int
 	if options.Resolvers != "" {
-		dnsxOptions.BaseResolvers = []string{}
+		var entries []string
 		// If it's a file load resolvers from it
 		if fileutil.FileExists(options.Resolvers) {
 			rs, err := linesInFile(options.Resolvers)
 			if err != nil {
-				gologger.Fatal().Msgf("%s\n", err)
-			}
-			for _, rr := range rs {
-				dnsxOptions.BaseResolvers = append(dnsxOptions.BaseResolvers, prepareResolver(rr))
+				return nil, errors.Wrap(err, "could not read resolvers")
 			}
+			entries = rs
 		} else {
 			// otherwise gets comma separated ones
-			for _, rr := range strings.Split(options.Resolvers, ",") {
-				dnsxOptions.BaseResolvers = append(dnsxOptions.BaseResolvers, prepareResolver(rr))
+			entries = strings.Split(options.Resolvers, ",")
+		}
+		dnsxOptions.BaseResolvers, parsed = parseResolvers(entries)
+		dropped = parsed - len(dnsxOptions.BaseResolvers)
+		if dropped > 0 {
+			gologger.Warning().Msgf("Dropped %d invalid resolvers out of %d\n", dropped, parsed)
+		}
+	}
+
+	if options.DiscoverResolvers != "" {
+		bootstrap, err := dnsx.New(dnsxOptions)
+		if err != nil {
+			return nil, err
+		}
+		discovered, err := bootstrap.DiscoverResolvers(options.DiscoverResolvers)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not discover resolvers")
+		}
+		if len(discovered) == 0 {
+			gologger.Warning().Msgf("No resolvers published for %s\n", options.DiscoverResolvers)
+		} else {
+			gologger.Info().Msgf("Discovered %d resolvers for %s\n", len(discovered), options.DiscoverResolvers)
+			// discovered resolvers replace the default ones and extend the user provided ones
+			if options.Resolvers == "" {
+				dnsxOptions.BaseResolvers = []string{}
 			}
+			dnsxOptions.BaseResolvers = append(dnsxOptions.BaseResolvers, discovered...)
 		}
 	}
 
+	if options.OpenResolverCheck || options.SkipOpenResolver {
+		resolvers, err := filterOpenResolvers(dnsxOptions, options.Threads, options.SkipOpenResolver)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not check open resolvers")
+		}
+		dropped += len(dnsxOptions.BaseResolvers) - len(resolvers)
+		dnsxOptions.BaseResolvers = resolvers
+	}
+
+	if len(dnsxOptions.BaseResolvers) == 0 {
+		return nil, &NoResolversError{Parsed: parsed, Dropped: dropped}
+	}
+	if len(dnsxOptions.BaseResolvers) < options.MinResolvers {
+		gologger.Warning().Msgf("Only %d usable resolvers, less than the minimum of %d\n", len(dnsxOptions.BaseResolvers), options.MinResolvers)
+	}
+
+	// warn if the resolvers changed since the resumed scan was started
+	if options.ShouldLoadResume() && options.resumeCfg.ResolversHash != "" {
+		if options.resumeCfg.ResolversHash != resolversFingerprint(dnsxOptions.BaseResolvers) {
+			gologger.Warning().Msgf("Resolvers list differs from the one used by the resumed scan, results may be inconsistent\n")
+		}
+	}
+
+	// netbios names are reported as A records and node status names as PTR records
+	if options.NBNS {
+		options.A = true
+		options.PTR = true
+	}
+
+	// ptr-csv lines are built from the PTR records
+	if options.PTRCSV {
+		options.PTR = true
+	}
+
+	// typo, sld-permute and tld-enum modes report names having A or NS records
+	if options.Typo || options.SLDPermute || options.TLDEnum {
+		options.A = true
+		options.NS = true
+	}
+
 	var questionTypes []uint16
 	if options.A {
 		questionTypes = append(questionTypes, dns.TypeA)
@@ -95,6 +257,10 @@ func New(options *Options) (*Runner, error) {
 	if options.NS {
 		questionTypes = append(questionTypes, dns.TypeNS)
 	}
+	if options.DNAME {
+		questionTypes = append(questionTypes, dns.TypeDNAME)
+	}
+	options.hasRecordFlags = len(questionTypes) > 0
 	// If no option is specified or wildcard filter has been requested use query type A
 	if len(questionTypes) == 0 || options.WildcardDomain != "" {
 		options.A = true
@@ -102,21 +268,221 @@ func New(options *Options) (*Runner, error) {
 	}
 	dnsxOptions.QuestionTypes = questionTypes
 
+	// the timing of each query is delivered to the consumers requested
+	var onAttempt []func(dnsx.QueryTiming)
+	var timings *timingsWriter
+	if options.Timings != "" {
+		var err error
+		timings, err = newTimingsWriter(options.Timings, options.FlushInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create timings file")
+		}
+		onAttempt = append(onAttempt, timings.record)
+	}
+	var syslogOutput *syslogWriter
+	if options.Syslog {
+		var err error
+		syslogOutput, err = newSyslogWriter()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not connect to syslog")
+		}
+		onAttempt = append(onAttempt, syslogOutput.record)
+	}
+	var responseCodes *rcodeStats
+	if options.RcodeStats {
+		responseCodes = newRcodeStats()
+		onAttempt = append(onAttempt, responseCodes.record)
+	}
+	if len(onAttempt) > 0 {
+		dnsxOptions.OnAttempt = func(timing dnsx.QueryTiming) {
+			for _, record := range onAttempt {
+				record(timing)
+			}
+		}
+	}
+
 	dnsX, err := dnsx.New(dnsxOptions)
 	if err != nil {
 		return nil, err
 	}
 
+	var localHosts *dnsx.HostsFile
+	if options.LocalResolve != "" {
+		localHosts, err = dnsx.LoadHostsFile(options.LocalResolve)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load hosts file")
+		}
+	}
+
+	var hostsOutput *hostsWriter
+	if options.HostsOutput != "" {
+		hostsOutput, err = newHostsWriter(options.HostsOutput)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create hosts output file")
+		}
+	}
+
+	var inputScope *scope
+	if options.Scope != "" || options.ScopeFile != "" || options.ScopeCIDR != "" {
+		var domains, cidrs []string
+		if options.Scope != "" {
+			domains = append(domains, strings.Split(options.Scope, Comma)...)
+		}
+		if options.ScopeFile != "" {
+			lines, err := linesInFile(options.ScopeFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not read scope file")
+			}
+			domains = append(domains, lines...)
+		}
+		if options.ScopeCIDR != "" {
+			if fileutil.FileExists(options.ScopeCIDR) {
+				lines, err := linesInFile(options.ScopeCIDR)
+				if err != nil {
+					return nil, errors.Wrap(err, "could not read scope cidr file")
+				}
+				cidrs = lines
+			} else {
+				cidrs = strings.Split(options.ScopeCIDR, Comma)
+			}
+		}
+		inputScope, err = newScope(domains, cidrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var hook *execHook
+	if options.Exec != "" {
+		hook = newExecHook(options.Exec, options.ExecThreads, options.ExecStdin)
+	}
+
+	var splunk *splunkHEC
+	if options.SplunkURL != "" {
+		splunk, err = newSplunkHEC(options.SplunkURL, options.SplunkToken, options.SplunkBatchSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var alerts *notifier
+	if options.NotifyConfig != "" {
+		alerts, err = newNotifier(options.NotifyConfig, options.NotifyMax, options.ReputationLimit)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load notify config")
+		}
+	}
+
 	limiter := ratelimit.NewUnlimited()
 	if options.RateLimit > 0 {
 		limiter = ratelimit.New(options.RateLimit)
 	}
 
-	hm, err := hybrid.New(hybrid.DefaultDiskOptions)
+	state, err := newStateDir(options.StateDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create state directory")
+	}
+	hm, err := state.hmap("hosts")
 	if err != nil {
 		return nil, err
 	}
 
+	// wildcard candidates and verdicts are kept on disk to bound memory usage on large runs
+	var (
+		wildcardhm *hybrid.HybridMap
+		detector   *wildcards.Detector
+	)
+	if options.WildcardDomain != "" {
+		wildcardhm, err = state.hmap("wildcards")
+		if err != nil {
+			return nil, err
+		}
+		wildcardOptions := wildcards.DefaultOptions
+		wildcardOptions.Threshold = options.WildcardThreshold
+		wildcardOptions.Probes = options.WildcardProbes
+		// wildcard TXT and MX records would otherwise appear as the configuration of every host
+		if options.TXT {
+			wildcardOptions.RecordTypes = append(wildcardOptions.RecordTypes, dns.TypeTXT)
+		}
+		if options.MX {
+			wildcardOptions.RecordTypes = append(wildcardOptions.RecordTypes, dns.TypeMX)
+		}
+		detector, err = wildcards.New(dnsX, options.WildcardDomain, wildcardOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the interface stays nil without ip-reputation
+	var reputationClient reputationService
+	if options.IPReputation {
+		client, err := reputation.New(options.ReputationService, options.ReputationKey, reputationTimeout, options.ReputationRate)
+		if err != nil {
+			return nil, err
+		}
+		reputationClient = client
+	}
+
+	var categories *categorizer
+	if options.Categorize != "" {
+		categories, err = newCategorizer(options.Categorize, dnsxOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load categorize config")
+		}
+	}
+
+	var ranks rankDB
+	if options.RankDB != "" {
+		ranks, err = loadRankDB(options.RankDB)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load rank db")
+		}
+	}
+
+	var serveStore resultStore
+	if options.ServeFrom != "" {
+		serveStore, err = loadJSONResultStore(options.ServeFrom)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load served results")
+		}
+	}
+
+	var tlds []string
+	if options.TLDEnum {
+		tlds, err = loadTLDs(options.TLDList)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load tld list")
+		}
+	}
+
+	var sldDictionary map[string][]string
+	if options.SLDDict != "" {
+		data, err := ioutil.ReadFile(options.SLDDict)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read sld dictionary")
+		}
+		sldDictionary, err = typo.ParseDictionary(string(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse sld dictionary")
+		}
+	}
+
+	var previous map[string]struct{}
+	if options.OnlyNew != "" {
+		previous, err = loadPreviousOutput(options.OnlyNew, options.separator)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read previous output")
+		}
+	}
+
+	var geoReader *geo.Reader
+	if options.Geo {
+		geoReader, err = geo.Open(options.GeoDB)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not open geo database")
+		}
+	}
+
 	var stats clistats.StatisticsClient
 	if options.ShowStatistics {
 		stats, err = clistats.New()
@@ -126,68 +492,231 @@ func New(options *Options) (*Runner, error) {
 	}
 
 	r := Runner{
-		options:            options,
-		dnsx:               dnsX,
-		wgoutputworker:     &sync.WaitGroup{},
-		wgresolveworkers:   &sync.WaitGroup{},
-		wgwildcardworker:   &sync.WaitGroup{},
-		workerchan:         make(chan string),
-		wildcardworkerchan: make(chan string),
-		wildcards:          make(map[string]struct{}),
-		wildcardscache:     make(map[string][]string),
-		limiter:            limiter,
-		hm:                 hm,
-		stats:              stats,
+		options:          options,
+		dnsx:             dnsX,
+		limiter:          limiter,
+		ratelimit:        int32(options.RateLimit),
+		localHosts:       localHosts,
+		hostsOutput:      hostsOutput,
+		timings:          timings,
+		syslog:           syslogOutput,
+		rcodeStats:       responseCodes,
+		execHook:         hook,
+		splunk:           splunk,
+		notifier:         alerts,
+		scope:            inputScope,
+		outputsUnmatched: options.outputsUnmatched(),
+		state:            state,
+		hm:               hm,
+		wildcardhm:       wildcardhm,
+		wildcards:        detector,
+		reputation:       reputationClient,
+		categorizer:      categories,
+		geo:              geoReader,
+		previous:         previous,
+		ranks:            ranks,
+		serveStore:       serveStore,
+		labels:           options.labelsMap(),
+		tlds:             tlds,
+		sldDictionary:    sldDictionary,
+		stats:            stats,
+	}
+	// ttl-watch skips the hosts of the zones whose serial didn't change unless monitor-full is set
+	if options.TTLWatch != "" && !options.MonitorFull {
+		r.zoneSerials = newZoneSerials()
 	}
+	r.prepareRun()
 
 	return &r, nil
 }
 
-func (r *Runner) InputWorkerStream() {
-	var sc *bufio.Scanner
-	// attempt to load list from file
-	if fileutil.FileExists(r.options.Hosts) {
-		f, _ := os.Open(r.options.Hosts)
-		sc = bufio.NewScanner(f)
-	} else if fileutil.HasStdin() {
-		sc = bufio.NewScanner(os.Stdin)
+// prepareRun creates the channels and wait groups of a new run, the dnsx client,
+// the caches and the resolvers statistics are kept on the runner across runs
+func (r *Runner) prepareRun() {
+	r.wgoutputworker = &sync.WaitGroup{}
+	r.wgresolveworkers = &sync.WaitGroup{}
+	r.wgwildcardworker = &sync.WaitGroup{}
+	r.workerchan = make(chan inputItem)
+	r.wildcardworkerchan = make(chan string)
+	r.repeatslots = make(chan struct{}, r.options.Threads)
+	r.reputationslots = make(chan struct{}, maxPendingReputation)
+	// the runs derive their context from the one canceled by Close
+	if r.closectx == nil {
+		r.closectx, r.closecancel = context.WithCancel(context.Background())
 	}
+	r.ctx, r.cancel = context.WithCancel(r.closectx)
+	// the resolve workers are stopped as well by the max-runtime deadline
+	r.workerctx, r.workercancel = context.WithCancel(r.ctx)
+	atomic.StoreInt64(&r.firstresult, 0)
+	atomic.StoreInt32(&r.pendingstops, 0)
+}
 
-	for sc.Scan() {
-		item := strings.TrimSpace(sc.Text())
+// Reset clears the state of the previous runs, the hosts they processed are resolved
+// again by the next Run instead of being skipped as duplicates
+func (r *Runner) Reset() error {
+	r.runmutex.Lock()
+	defer r.runmutex.Unlock()
 
-		hosts := []string{item}
-		if iputil.IsCIDR(item) {
-			hosts, _ = mapcidr.IPAddresses(item)
+	hm, err := r.state.hmap("hosts")
+	if err != nil {
+		return err
+	}
+	r.hm.Close()
+	r.hm = hm
+	if r.wildcardhm != nil {
+		wildcardhm, err := r.state.hmap("wildcards")
+		if err != nil {
+			return err
 		}
+		r.wildcardhm.Close()
+		r.wildcardhm = wildcardhm
+	}
+	r.permutations.Range(func(key, _ interface{}) bool {
+		r.permutations.Delete(key)
+		return true
+	})
+	r.uniqueips.Range(func(key, _ interface{}) bool {
+		r.uniqueips.Delete(key)
+		return true
+	})
+	r.lintTargets.Range(func(key, _ interface{}) bool {
+		r.lintTargets.Delete(key)
+		return true
+	})
+	r.domainQueries.Range(func(key, _ interface{}) bool {
+		r.domainQueries.Delete(key)
+		return true
+	})
+	r.dnsblCache.Range(func(key, _ interface{}) bool {
+		r.dnsblCache.Delete(key)
+		return true
+	})
+	r.reputationCache.Range(func(key, _ interface{}) bool {
+		r.reputationCache.Delete(key)
+		return true
+	})
+	r.subzones.Range(func(key, _ interface{}) bool {
+		r.subzones.Delete(key)
+		return true
+	})
+	atomic.StoreUint64(&r.quotaDropped, 0)
+	atomic.StoreUint64(&r.reverseSkipped, 0)
+	atomic.StoreUint64(&r.scopeDropped, 0)
+	r.observed = nil
+	if r.options.resumeCfg != nil {
+		r.resumemutex.Lock()
+		r.options.resumeCfg.Index = 0
+		r.options.resumeCfg.current = ""
+		r.options.resumeCfg.currentIndex = 0
+		r.options.resumeCfg.completedIndex = 0
+		r.options.resumeCfg.completed = nil
+		r.resumemutex.Unlock()
+	}
+	if r.execHook != nil {
+		r.execHook.reset()
+	}
+	return nil
+}
 
-		for _, host := range hosts {
-			r.workerchan <- host
+// openStreamInput opens the input of the stream mode, the returned closer is nil when the input
+// is read from stdin or when the run was closed while waiting for the writer of a named pipe
+func (r *Runner) openStreamInput() (io.Closer, io.Reader, error) {
+	if fileutil.FileExists(r.options.Hosts) {
+		f, err := openFileContext(r.ctx, r.options.Hosts)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return nil, nil, nil
+			}
+			return nil, nil, err
 		}
+		return f, f, nil
+	}
+	if argumentHasStdin(r.options.Hosts) || hasStdin() {
+		return nil, os.Stdin, nil
 	}
-	close(r.workerchan)
+	return nil, nil, errors.New("hosts file or stdin not provided")
 }
 
-func (r *Runner) InputWorker() {
-	r.hm.Scan(func(k, _ []byte) error {
-		if r.options.ShowStatistics {
-			r.stats.IncrementCounter("requests", len(r.dnsx.Options.QuestionTypes))
-		}
-		item := string(k)
-		if r.options.resumeCfg != nil {
-			r.options.resumeCfg.current = item
-			r.options.resumeCfg.currentIndex++
-			if r.options.resumeCfg.currentIndex <= r.options.resumeCfg.Index {
-				return nil
+// InputWorkerStream feeds the resolve workers with the lines of the input and closes the
+// file once read
+func (r *Runner) InputWorkerStream(f io.Closer, input io.Reader) {
+	defer close(r.workerchan)
+	if input == nil {
+		return
+	}
+	if f != nil {
+		defer f.Close()
+	}
+
+	sc := newLineScanner(input, r.options.MaxLineLength)
+	for r.ctx.Err() == nil && sc.Scan() {
+		item := strings.TrimSpace(sc.Text())
+		target, _ := splitHostPort(item)
+		r.expandTarget(target, nil, func(host string) {
+			if r.inScope(host) {
+				r.sendItem(inputItem{input: item, host: host})
 			}
+		})
+	}
+}
+
+// InputWorker reads the input and feeds the resolve workers while it's being ingested
+func (r *Runner) InputWorker() error {
+	if r.precheckInput != nil {
+		return r.feedPrecheckInput()
+	}
+	defer close(r.workerchan)
+	return r.prepareInput(r.queueHost)
+}
+
+// queueHost sends a new unique host to the resolve workers, skipping the ones already processed by a resumed scan
+func (r *Runner) queueHost(host, input string) {
+	if r.inputDigest != nil {
+		r.inputDigest.add(host)
+	}
+	if r.pastDeadline() {
+		return
+	}
+	if r.options.ShowStatistics {
+		r.stats.IncrementCounter("requests", r.requestsPerHost())
+	}
+	var index int
+	if r.options.resumeCfg != nil {
+		r.resumemutex.Lock()
+		r.options.resumeCfg.currentIndex++
+		index = r.options.resumeCfg.currentIndex
+		skip := index <= r.options.resumeCfg.Index
+		r.resumemutex.Unlock()
+		if skip {
+			return
 		}
-		r.workerchan <- item
-		return nil
-	})
-	close(r.workerchan)
+	}
+	r.sendItem(inputItem{input: input, host: host, index: index})
+}
+
+// sendItem sends the item to the resolve workers unless they were stopped
+func (r *Runner) sendItem(item inputItem) bool {
+	select {
+	case r.workerchan <- item:
+		return true
+	case <-r.workerctx.Done():
+		return false
+	}
 }
 
-func (r *Runner) prepareInput() error {
+// markResolved records the resolution of the item in the resume position
+func (r *Runner) markResolved(item inputItem) {
+	if item.index == 0 || r.options.resumeCfg == nil {
+		return
+	}
+	r.resumemutex.Lock()
+	r.options.resumeCfg.complete(item.index, item.host)
+	r.resumemutex.Unlock()
+}
+
+// prepareInput reads the input and stores the unique hosts in the hybrid map,
+// onHost is invoked for every new host as soon as it's read along with the input line it comes from
+func (r *Runner) prepareInput(onHost func(host, input string)) error {
 	var dataDomains []byte
 	var sc *bufio.Scanner
 
@@ -198,76 +727,154 @@ func (r *Runner) prepareInput() error {
 		if err != nil {
 			return err
 		}
-		prefixs = normalizeToSlice(dataWordList)
+		prefixs = normalizeToSlice(dataWordList, r.options.MaxLineLength)
 	}
 
-	if r.options.Domains != "" {
+	// the hosts given to RunHosts replace the input of the options
+	domains, zoneFile := r.options.Domains, r.options.ZoneFile
+	if len(r.runhosts) > 0 {
+		domains, zoneFile = "", ""
+		sc = bufio.NewScanner(strings.NewReader(strings.Join(r.runhosts, NewLine)))
+	}
+
+	if domains != "" {
 		var err error
-		dataDomains, err = preProcessArgument(r.options.Domains)
+		dataDomains, err = preProcessArgument(domains)
 		if err != nil {
 			return err
 		}
-		sc = bufio.NewScanner(bytes.NewReader(dataDomains))
+		sc = newLineScanner(bytes.NewReader(dataDomains), r.options.MaxLineLength)
+	}
+
+	if zoneFile != "" {
+		names, err := zoneFileNames(zoneFile, r.options.ZoneOrigin)
+		if err != nil {
+			return errors.Wrap(err, "could not read zone file")
+		}
+		sc = bufio.NewScanner(strings.NewReader(strings.Join(names, NewLine)))
+	}
+
+	if sc == nil && len(r.options.Targets) > 0 {
+		sc = bufio.NewScanner(strings.NewReader(strings.Join(r.options.Targets, NewLine)))
 	}
 
 	if sc == nil {
 		// attempt to load list from file
+		var input io.Reader
 		if fileutil.FileExists(r.options.Hosts) {
-			f, err := os.Open(r.options.Hosts)
+			f, err := openFileContext(r.ctx, r.options.Hosts)
 			if err != nil {
 				return err
 			}
-			sc = bufio.NewScanner(f)
+			defer f.Close()
+			input = f
 		} else if argumentHasStdin(r.options.Hosts) || hasStdin() {
-			sc = bufio.NewScanner(os.Stdin)
+			input = os.Stdin
 		} else {
 			return errors.New("hosts file or stdin not provided")
 		}
+		var err error
+		sc, err = r.inputScanner(input)
+		if err != nil {
+			return err
+		}
 	}
 
-	numHosts := 0
-	for sc.Scan() {
+	// the product of large wordlists and domains is generated while it's resolved
+	if domains != "" && onHost != nil {
+		return r.streamProduct(sc, prefixs, onHost)
+	}
+
+	words := uniqueWords(prefixs)
+	// the input stops being read once the runner is closed or the deadline approaches
+	for r.ingesting() && sc.Scan() {
 		item := strings.TrimSpace(sc.Text())
-		var hosts []string
-		switch {
-		case r.options.WordList != "":
-			for _, prefix := range prefixs {
-				// domains Cartesian product with wordlist
-				subdomain := strings.TrimSpace(prefix) + "." + item
-				hosts = append(hosts, subdomain)
+		// host:port inputs are resolved without the port
+		target, port := splitHostPort(item)
+		r.expandTarget(target, words, func(host string) {
+			if !r.inScope(host) {
+				return
+			}
+			// the ports of a host are distinct targets when they are preserved in the output
+			key := host
+			if r.options.PreservePort && port != "" {
+				key = net.JoinHostPort(host, port)
 			}
-		case iputil.IsCIDR(item):
-			hosts, _ = mapcidr.IPAddresses(item)
-		default:
-			hosts = []string{item}
-		}
-
-		for _, host := range hosts {
 			// Used just to get the exact number of targets
-			if _, ok := r.hm.Get(host); ok {
-				continue
+			if _, ok := r.hm.Get(key); ok {
+				return
 			}
-			numHosts++
 			// nolint:errcheck
-			r.hm.Set(host, nil)
-		}
+			r.hm.Set(key, nil)
+			if r.options.ShowStatistics {
+				r.stats.IncrementCounter("hosts", 1)
+				r.stats.IncrementCounter("total", r.requestsPerHost())
+			}
+			if onHost != nil {
+				onHost(host, item)
+			}
+		})
 	}
 
-	if r.options.ShowStatistics {
-		r.stats.AddStatic("hosts", numHosts)
-		r.stats.AddStatic("startedAt", time.Now())
-		r.stats.AddCounter("requests", 0)
-		r.stats.AddCounter("total", uint64(numHosts*len(r.dnsx.Options.QuestionTypes)))
-		// nolint:errcheck
-		r.stats.Start(makePrintCallback(), time.Duration(5)*time.Second)
+	return nil
+}
+
+// startStats starts the statistics, totals are updated as the input is ingested
+func (r *Runner) startStats() {
+	atomic.StoreInt32(&r.statsrunning, 1)
+	r.stats.AddCounter("hosts", 0)
+	r.stats.AddStatic("startedAt", time.Now())
+	r.stats.AddCounter("requests", 0)
+	r.stats.AddCounter("total", 0)
+	r.stats.AddCounter("queries", 0)
+	r.stats.AddCounter("retries", 0)
+	r.stats.AddCounter("malformed", 0)
+	r.stats.AddDynamic("panics", func(clistats.StatisticsClient) interface{} {
+		return r.PanicsRecovered()
+	})
+	if r.options.PTRZonePrecheck {
+		r.stats.AddCounter("skipped", 0)
 	}
+	if r.wildcards != nil {
+		r.stats.AddCounter("wildcard_removed", 0)
+		r.stats.AddDynamic("wildcard_probes", func(clistats.StatisticsClient) interface{} {
+			return r.wildcards.ProbeCount()
+		})
+	}
+	syncLogger()
+	// the statistics are printed by the runner, the event loop started by clistats races
+	// with its Stop and reads the standard input holding the hosts
+	r.statsstop, r.statsdone = make(chan struct{}), make(chan struct{})
+	go r.printStats(r.makePrintCallback(), statsInterval)
+}
 
-	return nil
+// statsInterval is the interval between the lines of the statistics
+var statsInterval = 5 * time.Second
+
+// printStats prints the statistics at every interval until they're stopped
+func (r *Runner) printStats(printer func(clistats.StatisticsClient), interval time.Duration) {
+	defer close(r.statsdone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			printer(r.stats)
+		case <-r.statsstop:
+			return
+		}
+	}
 }
 
+// hasStdin reports whether the input is piped or redirected on stdin: anonymous pipes, named
+// pipes (FIFOs) and files aren't character devices, unlike terminals and /dev/null. A closed
+// stdin isn't an input.
 func hasStdin() bool {
-	stat, _ := os.Stdin.Stat()
-	return (stat.Mode() & os.ModeCharDevice) == 0
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice == 0
 }
 
 func preProcessArgument(arg string) ([]byte, error) {
@@ -279,7 +886,12 @@ func preProcessArgument(arg string) ([]byte, error) {
 	// file
 	switch {
 	case fileutil.FileExists(arg):
-		data, err = os.ReadFile(arg)
+		f, err := openFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err = ioutil.ReadAll(f)
 		if err != nil {
 			return nil, err
 		}
@@ -299,9 +911,9 @@ func preProcessArgument(arg string) ([]byte, error) {
 	return bytes.Replace(data, []byte(Comma), []byte(NewLine), -1), nil
 }
 
-func normalizeToSlice(data []byte) []string {
+func normalizeToSlice(data []byte, maxLength int) []string {
 	var s []string
-	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc := newLineScanner(bytes.NewReader(data), maxLength)
 	for sc.Scan() {
 		item := strings.TrimSpace(sc.Text())
 		s = append(s, item)
@@ -309,17 +921,24 @@ func normalizeToSlice(data []byte) []string {
 	return s
 }
 
-// nolint:deadcode
-func makePrintCallback() func(stats clistats.StatisticsClient) {
+// makePrintCallback returns the statistics printer, it holds the standard error lock
+// while printing and prints nothing once the statistics are stopped
+func (r *Runner) makePrintCallback() func(stats clistats.StatisticsClient) {
 	builder := &strings.Builder{}
 	return func(stats clistats.StatisticsClient) {
+		stderrMutex.Lock()
+		defer stderrMutex.Unlock()
+		if atomic.LoadInt32(&r.statsrunning) == 0 {
+			return
+		}
+
 		builder.WriteRune('[')
 		startedAt, _ := stats.GetStatic("startedAt")
 		duration := time.Since(startedAt.(time.Time))
 		builder.WriteString(fmtDuration(duration))
 		builder.WriteRune(']')
 
-		hosts, _ := stats.GetStatic("hosts")
+		hosts, _ := stats.GetCounter("hosts")
 		builder.WriteString(" | Hosts: ")
 		builder.WriteString(clistats.String(hosts))
 
@@ -339,6 +958,38 @@ func makePrintCallback() func(stats clistats.StatisticsClient) {
 		builder.WriteString(clistats.String(uint64(float64(requests) / float64(total) * 100.0)))
 		builder.WriteRune('%')
 		builder.WriteRune(')')
+
+		queries, _ := stats.GetCounter("queries")
+		retries, _ := stats.GetCounter("retries")
+		if queries > 0 {
+			builder.WriteString(" | Avg retries: ")
+			builder.WriteString(fmt.Sprintf("%.2f", float64(retries)/float64(queries)))
+		}
+
+		if malformed, _ := stats.GetCounter("malformed"); malformed > 0 {
+			builder.WriteString(" | Malformed responses: ")
+			builder.WriteString(clistats.String(malformed))
+		}
+
+		if panics, ok := stats.GetDynamic("panics"); ok {
+			if recovered := panics(stats).(uint64); recovered > 0 {
+				builder.WriteString(" | Panics: ")
+				builder.WriteString(clistats.String(recovered))
+			}
+		}
+
+		if skipped, ok := stats.GetCounter("skipped"); ok {
+			builder.WriteString(" | Skipped: ")
+			builder.WriteString(clistats.String(skipped))
+		}
+
+		if probes, ok := stats.GetDynamic("wildcard_probes"); ok {
+			removed, _ := stats.GetCounter("wildcard_removed")
+			builder.WriteString(" | Wildcard probes: ")
+			builder.WriteString(clistats.String(probes(stats)))
+			builder.WriteString(" | Wildcard removed: ")
+			builder.WriteString(clistats.String(removed))
+		}
 		builder.WriteRune('\n')
 
 		fmt.Fprintf(os.Stderr, "%s", builder.String())
@@ -348,24 +999,103 @@ func makePrintCallback() func(stats clistats.StatisticsClient) {
 
 // SaveResumeConfig to file
 func (r *Runner) SaveResumeConfig() error {
-	var resumeCfg ResumeCfg
-	resumeCfg.Index = r.options.resumeCfg.currentIndex
+	resumeCfg := ResumeCfg{Version: resumeVersion}
+	r.resumemutex.Lock()
+	resumeCfg.Index = r.options.resumeCfg.resumeIndex()
 	resumeCfg.ResumeFrom = r.options.resumeCfg.current
+	resumeCfg.CompletedJobs = r.options.resumeCfg.CompletedJobs
+	resumeCfg.JobSize = r.options.resumeCfg.JobSize
+	r.resumemutex.Unlock()
+	resumeCfg.ResolversHash = resolversFingerprint(r.dnsx.Options.BaseResolvers)
 	return goconfig.Save(resumeCfg, DefaultResumeFile)
 }
 
+// Run resolves the input hosts, a runner can be run several times and concurrent
+// calls are serialized. Reset clears the hosts processed by the previous runs.
 func (r *Runner) Run() error {
+	r.runmutex.Lock()
+	defer r.runmutex.Unlock()
+	return r.runMode()
+}
+
+// RunHosts resolves the hosts instead of the input of the options, they are expanded like
+// the lines of the list input. The hosts processed by the previous runs are skipped unless
+// Reset is called between the runs.
+func (r *Runner) RunHosts(hosts []string) error {
+	if len(hosts) == 0 {
+		return errors.New("no hosts provided")
+	}
+	if r.options.Stream {
+		return errors.New("hosts can't be run in stream mode")
+	}
+	r.runmutex.Lock()
+	defer r.runmutex.Unlock()
+	r.runhosts = hosts
+	defer func() {
+		r.runhosts = nil
+	}()
+	return r.runMode()
+}
+
+// runMode prepares a new run and runs the mode selected by the options
+func (r *Runner) runMode() error {
+	r.prepareRun()
+
+	if r.options.dnsUpdate() {
+		return r.runUpdate()
+	}
+
+	if r.options.Serve != "" {
+		return r.runServe()
+	}
+
+	if r.options.Split > 0 {
+		return r.runSplit()
+	}
+
+	if r.options.Coordinator != "" {
+		return r.runCoordinator()
+	}
+
+	if r.options.Worker != "" {
+		return r.runWorker()
+	}
+
+	if r.options.ControlSocket != "" {
+		if err := r.startControlServer(); err != nil {
+			return err
+		}
+		defer r.stopControlServer()
+	}
+	defer r.finishExec()
+
 	if r.options.Stream {
 		return r.runStream()
 	}
 
+	if r.options.TTLWatch != "" {
+		return r.runTTLWatch()
+	}
+
 	return r.run()
 }
 
 func (r *Runner) run() error {
-	err := r.prepareInput()
-	if err != nil {
-		return err
+	r.runstarted = time.Now()
+	if r.options.Manifest != "" || r.options.replay != nil {
+		r.inputDigest = &inputDigest{}
+		if err := r.writeManifest(); err != nil {
+			return err
+		}
+	}
+	if r.options.Precheck {
+		if err := r.precheck(); err != nil {
+			r.closePrecheckInput()
+			return err
+		}
+	}
+	if r.options.ShowStatistics {
+		r.startStats()
 	}
 
 	// if resume is enabled inform the user
@@ -373,287 +1103,666 @@ func (r *Runner) run() error {
 		gologger.Debug().Msgf("Resuming scan using file %s. Restarting at position %d: %s\n", DefaultResumeFile, r.options.resumeCfg.Index, r.options.resumeCfg.ResumeFrom)
 	}
 
-	r.startWorkers()
-
-	r.wgresolveworkers.Wait()
-	if r.stats != nil {
-		err = r.stats.Stop()
-		if err != nil {
-			return err
+	r.startDeadline()
+	defer r.stopDeadline()
+	if err := r.startWorkers(); err != nil {
+		r.stopStats()
+		return err
+	}
+	// resolution starts while the input is still being read
+	inputErr := r.InputWorker()
+	// the digest of the input is partial once the deadline stopped its reading
+	if inputErr == nil && r.inputDigest != nil && !r.pastDeadline() {
+		if err := r.finishManifest(); err != nil {
+			gologger.Warning().Msgf("%s\n", err)
 		}
 	}
 
-	close(r.outputchan)
-	r.wgoutputworker.Wait()
-
-	if r.options.WildcardDomain != "" {
-		gologger.Print().Msgf("Starting to filter wildcard subdomains\n")
-		ipDomain := make(map[string]map[string]struct{})
-		listIPs := []string{}
-		// prepare in memory structure similarly to shuffledns
-		r.hm.Scan(func(k, v []byte) error {
-			var dnsdata retryabledns.DNSData
-			err := dnsdata.Unmarshal(v)
-			if err != nil {
-				// the item has no record - ignore
-				return nil
-			}
-
-			for _, a := range dnsdata.A {
-				_, ok := ipDomain[a]
-				if !ok {
-					ipDomain[a] = make(map[string]struct{})
-					listIPs = append(listIPs, a)
-				}
-				ipDomain[a][string(k)] = struct{}{}
-			}
+	r.waitWorkers()
+	if firstResult := atomic.LoadInt64(&r.firstresult); firstResult > 0 {
+		gologger.Verbose().Msgf("First result after %s\n", time.Duration(firstResult))
+	}
+	// the follow-up phases are skipped once the runner is closed
+	closing := r.ctx.Err() != nil
+	if r.options.DiscoverSubzones && atomic.LoadInt32(&r.deadlinereached) == 0 && !closing {
+		r.enumerateSubzones()
+	}
+	if r.options.SmartBrute && atomic.LoadInt32(&r.deadlinereached) == 0 && !closing {
+		r.smartBrute()
+	}
 
-			return nil
-		})
+	r.closeOutputWorker()
+	if inputErr != nil {
+		r.stopStats()
+		return inputErr
+	}
 
-		// wildcard workers
-		numThreads := r.options.Threads
-		if numThreads > len(listIPs) {
-			numThreads = len(listIPs)
-		}
-		for i := 0; i < numThreads; i++ {
-			r.wgwildcardworker.Add(1)
-			go r.wildcardWorker()
-		}
-
-		seen := make(map[string]struct{})
-		for _, a := range listIPs {
-			hosts := ipDomain[a]
-			if len(hosts) >= r.options.WildcardThreshold {
-				for host := range hosts {
-					if _, ok := seen[host]; !ok {
-						seen[host] = struct{}{}
-						r.wildcardworkerchan <- host
-					}
-				}
-			}
-		}
-		close(r.wildcardworkerchan)
-		r.wgwildcardworker.Wait()
+	if r.options.PTRZonePrecheck {
+		gologger.Info().Msgf("%d addresses skipped [reverse-zone-missing]\n", atomic.LoadUint64(&r.reverseSkipped))
+	}
+	r.reportScopeDropped()
+	r.reportQuotaDropped()
+	r.reportRcodeStats()
+	if r.options.ServerCaps {
+		r.reportServerCapabilities()
+	}
 
-		// we need to restart output
-		r.startOutputWorker()
-		seen = make(map[string]struct{})
-		seenRemovedSubdomains := make(map[string]struct{})
-		numRemovedSubdomains := 0
-		for _, A := range listIPs {
-			for host := range ipDomain[A] {
-				if host == r.options.WildcardDomain {
-					if _, ok := seen[host]; !ok {
-						seen[host] = struct{}{}
-						r.outputchan <- host
-					}
-				} else if _, ok := r.wildcards[host]; !ok {
-					if _, ok := seen[host]; !ok {
-						seen[host] = struct{}{}
-						r.outputchan <- host
-					}
-				} else {
-					if _, ok := seenRemovedSubdomains[host]; !ok {
-						numRemovedSubdomains++
-						seenRemovedSubdomains[host] = struct{}{}
-					}
-				}
-			}
+	if r.options.WildcardDomain != "" && !closing {
+		if err := r.filterWildcards(); err != nil {
+			r.stopStats()
+			return err
 		}
-		close(r.outputchan)
-		// waiting output worker
-		r.wgoutputworker.Wait()
-		gologger.Print().Msgf("%d wildcard subdomains removed\n", numRemovedSubdomains)
 	}
+	// the statistics cover the wildcard phase, they stop once its output is drained
+	r.stopStats()
 
-	return nil
+	// the panics are reported as well when the deadline stopped the run
+	panicsErr := r.reportPanics()
+	if atomic.LoadInt32(&r.deadlinereached) == 1 {
+		return ErrMaxRuntime
+	}
+	return panicsErr
 }
 
 func (r *Runner) runStream() error {
-	r.startWorkers()
+	if err := r.startWorkers(); err != nil {
+		return err
+	}
 
 	r.wgresolveworkers.Wait()
 
-	close(r.outputchan)
-	r.wgoutputworker.Wait()
+	r.closeOutputWorker()
+	r.reportScopeDropped()
+	r.reportQuotaDropped()
+	r.reportRcodeStats()
 
-	return nil
+	return r.reportPanics()
 }
 
-func (r *Runner) HandleOutput() {
+// HandleOutput fans out the events to the sinks, each one applying its own filter and format
+func (r *Runner) HandleOutput(outputchan chan *outputEvent, sinks []*sink) {
 	defer r.wgoutputworker.Done()
 
-	// setup output
-	var (
-		foutput *os.File
-		w       *bufio.Writer
-	)
-	if r.options.OutputFile != "" {
-		var err error
-		foutput, err = os.OpenFile(r.options.OutputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			gologger.Fatal().Msgf("%s\n", err)
-		}
-		defer foutput.Close()
-		w = bufio.NewWriter(foutput)
-		defer w.Flush()
-		var flushTicker *time.Ticker
-		if r.options.FlushInterval >= 0 {
-			flushTicker = time.NewTicker(time.Duration(r.options.FlushInterval) * time.Second)
-			defer flushTicker.Stop()
-			go func() {
-				for range flushTicker.C {
-					w.Flush()
-				}
-			}()
+	defer func() {
+		for _, s := range sinks {
+			s.close()
 		}
+		if r.splunk != nil {
+			r.splunk.flush()
+		}
+		if r.notifier != nil {
+			r.notifier.flush()
+		}
+	}()
+
+	// file sinks are flushed periodically from the same goroutine writing them
+	var flush <-chan time.Time
+	if r.options.FlushInterval >= 0 {
+		flushTicker := time.NewTicker(time.Duration(r.options.FlushInterval) * time.Second)
+		defer flushTicker.Stop()
+		flush = flushTicker.C
 	}
-	for item := range r.outputchan {
-		if r.options.OutputFile != "" {
-			// uses a buffer to write to file
-			// nolint:errcheck
-			w.WriteString(item + "\n")
+	for {
+		select {
+		case event, more := <-outputchan:
+			if !more {
+				return
+			}
+			r.recoverPanic(event.host(), func() {
+				r.handleEvent(sinks, event)
+			})
+		case <-flush:
+			for _, s := range sinks {
+				s.flush()
+			}
+			if r.splunk != nil {
+				r.splunk.flush()
+			}
+			if r.notifier != nil {
+				r.notifier.flush()
+			}
 		}
-		// otherwise writes sequentially to stdout
-		gologger.Silent().Msgf("%s\n", item)
 	}
 }
 
-func (r *Runner) startOutputWorker() {
-	// output worker
-	r.outputchan = make(chan string)
+// handleEvent writes the event to the sinks and hands it to the hooks
+func (r *Runner) handleEvent(sinks []*sink, event *outputEvent) {
+	for _, s := range sinks {
+		s.write(event)
+	}
+	if r.execHook != nil {
+		r.execHook.run(event)
+	}
+	if r.splunk != nil {
+		r.splunk.add(event)
+	}
+	if r.notifier != nil {
+		r.notifier.add(event)
+	}
+	if r.options.OnResult != nil && event.result != nil && event.status == statusMatched {
+		r.options.OnResult(event.result)
+	}
+}
+
+// startOutputWorker opens the sinks and starts the output worker, no worker is started when
+// a sink can't be opened
+func (r *Runner) startOutputWorker() error {
+	sinks, err := r.openSinks()
+	if err != nil {
+		return err
+	}
+	r.outputchanmutex.Lock()
+	r.outputchan = make(chan *outputEvent)
 	r.wgoutputworker.Add(1)
-	go r.HandleOutput()
+	go r.HandleOutput(r.outputchan, sinks)
+	r.outputchanmutex.Unlock()
+	return nil
 }
 
-func (r *Runner) startWorkers() {
-	if r.options.Stream {
-		go r.InputWorkerStream()
-	} else {
-		go r.InputWorker()
+// closeOutputWorker closes the current output channel and waits for the output worker to drain it.
+// The channel is detached under lock so that late writers never send on a closed channel.
+func (r *Runner) closeOutputWorker() {
+	r.outputchanmutex.Lock()
+	if r.outputchan != nil {
+		close(r.outputchan)
+		r.outputchan = nil
 	}
+	r.outputchanmutex.Unlock()
+	r.wgoutputworker.Wait()
+}
 
-	r.startOutputWorker()
+// output sends a line not bound to a dns response to the current output worker
+func (r *Runner) output(item string) {
+	r.emit(&outputEvent{lines: []string{item}})
+}
+
+// emit sends an event to the current output worker, events sent while no output worker is running are dropped.
+// In worker mode the events are collected to be sent back to the coordinator.
+func (r *Runner) emit(event *outputEvent) {
+	if event.status == statusMatched && !r.runstarted.IsZero() {
+		atomic.CompareAndSwapInt64(&r.firstresult, 0, int64(time.Since(r.runstarted)))
+	}
+	if r.collector != nil {
+		r.collector.add(event)
+		return
+	}
+	r.outputchanmutex.RLock()
+	defer r.outputchanmutex.RUnlock()
+	if r.outputchan == nil {
+		gologger.Debug().Msgf("Output worker not running, dropping: %s\n", strings.Join(event.lines, NewLine))
+		return
+	}
+	r.outputchan <- event
+}
+
+// startWorkers opens the input of the stream mode and the outputs and starts the workers,
+// none is started when one of them can't be opened
+func (r *Runner) startWorkers() error {
+	if r.options.Stream {
+		f, input, err := r.openStreamInput()
+		if err != nil {
+			return err
+		}
+		if err := r.startOutputWorker(); err != nil {
+			if f != nil {
+				f.Close()
+			}
+			return err
+		}
+		go r.InputWorkerStream(f, input)
+	} else if err := r.startOutputWorker(); err != nil {
+		return err
+	}
 	// resolve workers
+	atomic.StoreInt32(&r.threads, int32(r.options.Threads))
 	for i := 0; i < r.options.Threads; i++ {
 		r.wgresolveworkers.Add(1)
 		go r.worker()
 	}
+	return nil
 }
 
 func (r *Runner) worker() {
 	defer r.wgresolveworkers.Done()
 
-	for domain := range r.workerchan {
-		if isURL(domain) {
-			domain = extractDomain(domain)
+	for {
+		if r.shouldStopWorker() {
+			return
 		}
-		r.limiter.Take()
-
-		// Ignoring errors as partial results are still good
-		dnsData, _ := r.dnsx.QueryMultiple(domain)
-		// Just skipping nil responses (in case of critical errors)
-		if dnsData == nil {
-			continue
+		r.waitIfPaused()
+		var item inputItem
+		select {
+		case next, more := <-r.workerchan:
+			if !more {
+				return
+			}
+			item = next
+		case <-r.workerctx.Done():
+			return
 		}
-
-		if dnsData.Host == "" || dnsData.Timestamp.IsZero() {
-			continue
+		aborted := false
+		if r.recoverPanic(item.host, func() { aborted = !r.resolveItem(item) }) && r.outputsUnmatched {
+			r.emitFailure(item.host, item.input, errPanic)
 		}
-
-		// skip responses not having the expected response code
-		if len(r.options.rcodes) > 0 {
-			if _, ok := r.options.rcodes[dnsData.StatusCodeRaw]; !ok {
-				continue
-			}
+		// the hosts aborted by the deadline or by Close are resolved again on resume
+		if !aborted {
+			r.markResolved(item)
 		}
+	}
+}
 
-		if !r.options.Raw {
-			dnsData.Raw = ""
-		}
+// resolveItem queries the host of the item and emits its result, false is returned when
+// the queries were aborted because the workers were stopped
+func (r *Runner) resolveItem(item inputItem) bool {
+	domain := item.host
+	if isURL(domain) {
+		domain = extractDomain(domain)
+	}
+	domain = unbracketIP(strings.TrimSuffix(domain, "."))
+	if r.options.PTRZonePrecheck && r.skipMissingReverseZone(domain) {
+		return true
+	}
+	if r.options.MaxDomainQueries > 0 && r.domainQuotaExceeded(domain) {
+		return true
+	}
+	r.takeLimiter()
 
-		if r.options.Trace {
-			dnsData.TraceData, _ = r.dnsx.Trace(domain)
-			if dnsData.TraceData != nil {
-				for _, data := range dnsData.TraceData.DNSData {
-					if r.options.Raw && data.RawResp != nil {
-						rawRespString := data.RawResp.String()
-						data.Raw = rawRespString
-						// join the whole chain in raw field
-						dnsData.Raw += fmt.Sprintln(rawRespString)
-					}
-					data.RawResp = nil
-				}
-			}
+	if r.options.CacheSnoop {
+		r.cacheSnoop(domain, item.input)
+		return true
+	}
+
+	// Ignoring errors as partial results are still good
+	start := time.Now()
+	dnsData, metadata, err := r.query(domain)
+	latency := time.Since(start)
+	if err != nil && r.workerctx.Err() != nil && errors.Is(err, r.workerctx.Err()) {
+		return false
+	}
+	if metadata != nil && len(metadata.Malformed) > 0 {
+		for _, malformed := range metadata.Malformed {
+			gologger.Warning().Msgf("Malformed response for %s from %s\n", domain, malformed)
+		}
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("malformed", len(metadata.Malformed))
+		}
+	}
+	// failed queries are only reported to the sinks asking for them
+	if dnsData == nil || dnsData.Host == "" || dnsData.Timestamp.IsZero() {
+		if r.outputsUnmatched {
+			r.emitFailure(domain, item.input, err)
 		}
+		return true
+	}
 
-		// if wildcard filtering just store the data
-		if r.options.WildcardDomain != "" {
-			// nolint:errcheck
-			r.storeDNSData(dnsData)
-			continue
+	if !r.options.Raw {
+		dnsData.Raw = ""
+	}
+	var rewrites []string
+	if r.options.FollowDNAME && metadata != nil {
+		rewrites = r.followDNAME(domain, dnsData, metadata)
+	}
+
+	result := r.newResult(dnsData)
+	result.Input = item.input
+	result.DNAMERewrites = rewrites
+	// local answers have no round trip, neither have the ones of the cache
+	local := metadata != nil && metadata.Source == dnsx.SourceHostsFile
+	if r.options.ShowLatency && (metadata == nil || metadata.Source == dnsx.SourceNetwork) {
+		result.LatencyMs = latency.Milliseconds()
+	}
+	if r.options.CompareObserved {
+		r.compareObserved(result)
+	}
+	if metadata != nil {
+		result.Source = metadata.Source
+		if r.options.DNAME {
+			result.DNAME = dnameTargets(metadata)
 		}
-		if r.options.JSON {
-			jsons, _ := dnsData.JSON()
-			r.outputchan <- jsons
-			continue
+		if r.options.TransportDiff && !local {
+			r.transportDiff(result, metadata)
 		}
-		if r.options.Raw {
-			r.outputchan <- dnsData.Raw
-			continue
+		if r.options.ValidateAuthority {
+			result.SuspiciousAuthority = suspiciousAuthorities(domain, metadata)
 		}
-		if r.options.hasRCodes {
-			r.outputResponseCode(domain, dnsData.StatusCodeRaw)
-			continue
+		if r.options.ValidateNames {
+			records := append(append([]dns.RR{}, metadata.Answers...), metadata.Authorities...)
+			result.NameViolations = dnsx.CheckNames(records)
 		}
-		if r.options.A {
-			r.outputRecordType(domain, dnsData.A)
+		if r.options.Lint && !local {
+			result.Lint = r.lint(domain, metadata)
 		}
-		if r.options.AAAA {
-			r.outputRecordType(domain, dnsData.AAAA)
+		if r.options.DiscoverSubzones && !local {
+			result.Subzone = r.discoverSubzone(domain, dnsData, metadata)
 		}
-		if r.options.CNAME {
-			r.outputRecordType(domain, dnsData.CNAME)
+		if len(metadata.SpoofResponses) > 0 {
+			result.SpoofSuspect = true
+			result.SpoofResponses = metadata.SpoofResponses
 		}
-		if r.options.PTR {
-			r.outputRecordType(domain, dnsData.PTR)
+		if metadata.Truncated {
+			gologger.Warning().Msgf("Truncated response for %s, some records are missing\n", domain)
+			result.TruncatedFinal = true
 		}
-		if r.options.MX {
-			r.outputRecordType(domain, dnsData.MX)
+		if r.options.ShowRetries {
+			result.Retries = metadata.Retries
 		}
-		if r.options.NS {
-			r.outputRecordType(domain, dnsData.NS)
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("queries", metadata.Queries)
+			r.stats.IncrementCounter("retries", metadata.Retries)
 		}
-		if r.options.SOA {
-			r.outputRecordType(domain, dnsData.SOA)
+	}
+	if r.geo != nil {
+		result.Geo = r.geoLookups(dnsData)
+		// hosts left without addresses by the geo filter are treated as filtered
+		if r.options.GeoFilter != "" && !r.filterGeo(dnsData, result.Geo) {
+			if r.outputsUnmatched {
+				r.emit(&outputEvent{result: result, status: statusFiltered})
+			}
+			return true
 		}
-		if r.options.TXT {
-			r.outputRecordType(domain, dnsData.TXT)
+	}
+	if len(r.options.dnsblZones) > 0 {
+		result.DNSBL = r.dnsblLookups(dnsData)
+	}
+	if r.categorizer != nil {
+		result.Categories = r.categorize(domain)
+	}
+	if r.ranks != nil {
+		rank := r.ranks.rank(domain)
+		result.Rank = &rank
+	}
+
+	// skip responses not having the expected response code
+	if len(r.options.rcodes) > 0 {
+		if _, ok := r.options.rcodes[dnsData.StatusCodeRaw]; !ok {
+			if r.outputsUnmatched {
+				r.emit(&outputEvent{result: result, status: statusFiltered})
+			}
+			return true
 		}
 	}
+	if r.reputation != nil {
+		r.scheduleReputation(item, domain, dnsData, metadata, result)
+		return true
+	}
+	r.repeatItem(item, domain, dnsData, metadata, result)
+	return true
+}
+
+// repeatItem sends the repeated queries of the host when they are asked, then completes it
+func (r *Runner) repeatItem(item inputItem, domain string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata, result *dnsResult) {
+	if r.options.Repeat > 1 {
+		r.scheduleRepeats(domain, item.input, dnsData, func(consistency string, answers map[string]*repeatAnswers) {
+			result.Consistency, result.RepeatAnswers = consistency, answers
+			r.completeItem(item, domain, dnsData, metadata, result)
+		})
+		return
+	}
+	r.completeItem(item, domain, dnsData, metadata, result)
 }
 
-func (r *Runner) outputRecordType(domain string, items []string) {
-	for _, item := range items {
+// completeItem runs the follow-up lookups of a resolved host and emits its result
+func (r *Runner) completeItem(item inputItem, domain string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata, result *dnsResult) {
+	if r.options.NS && len(dnsData.NS) > 0 && metadata != nil && (r.options.JSON || r.options.GlueCheck) {
+		result.Glue = glueRecords(metadata)
+		if r.options.GlueCheck {
+			result.StaleGlue = r.staleGlue(result.Glue)
+		}
+	}
+
+	if r.options.Trace {
+		dnsData.TraceData, _ = r.dnsx.Trace(domain)
+		if dnsData.TraceData != nil {
+			for _, data := range dnsData.TraceData.DNSData {
+				if r.options.Raw && data.RawResp != nil {
+					rawRespString := data.RawResp.String()
+					data.Raw = rawRespString
+					// join the whole chain in raw field
+					dnsData.Raw += fmt.Sprintln(rawRespString)
+				}
+				data.RawResp = nil
+			}
+		}
+	}
+
+	// only registered names are reported in typo, sld-permute and tld-enum modes
+	if (r.options.Typo || r.options.SLDPermute || r.options.TLDEnum) && len(dnsData.A) == 0 && len(dnsData.NS) == 0 {
+		return
+	}
+
+	// if wildcard filtering just store the data
+	if r.options.WildcardDomain != "" {
+		if metadata != nil {
+			r.checkInheritedRecords(domain, metadata)
+		}
+		// nolint:errcheck
+		r.storeDNSData(dnsData)
+		return
+	}
+	// resolved hosts are kept for the smart-brute pass
+	if r.options.SmartBrute && isResolved(result) {
+		// nolint:errcheck
+		r.storeDNSData(dnsData)
+	}
+	key := r.outputKey(domain, item.input)
+	// the addresses are checked one by one in unique ips and summarize-cidrs modes
+	if !r.options.UniqueIPs && !r.options.SummarizeCIDRs && r.isPrevious(key) {
+		return
+	}
+	if r.hostsOutput != nil {
+		r.hostsOutput.write(domain, dnsData)
+	}
+	event := &outputEvent{result: result, status: statusMatched, lines: r.plainLines(key, result)}
+	if r.options.outputsZone {
+		event.zone = r.zoneRecords(dnsData, metadata)
+	}
+	r.emit(event)
+}
+
+// emitFailure reports a host for which no response was received
+func (r *Runner) emitFailure(domain, input string, err error) {
+	result := r.newResult(&retryabledns.DNSData{Host: domain})
+	result.Input = input
+	result.Error = "no response"
+	if err != nil {
+		result.Error = err.Error()
+	}
+	r.emit(&outputEvent{result: result, status: statusFailed})
+}
+
+// plainLines returns the plain text output of a result
+func (r *Runner) plainLines(key string, result *dnsResult) []string {
+	if r.options.UniqueIPs || r.options.SummarizeCIDRs {
+		return r.uniqueIPLines(result.DNSData)
+	}
+	if r.options.PTRCSV {
+		return r.ptrCSVLines(key, result)
+	}
+	if r.options.Repeat > 1 {
+		return r.annotate([]string{key + r.field(result.Consistency)}, result)
+	}
+	lines := r.outputRecords(key, result)
+	for _, nameserver := range result.StaleGlue {
+		lines = append(lines, key+r.field(nameserver)+r.field("stale-glue"))
+	}
+	if result.Subzone != "" {
+		lines = append(lines, result.Subzone+r.field("SUBZONE"))
+	}
+	return r.annotate(lines, result)
+}
+
+// annotate appends the details of the exchange requested by the options to the plain lines
+func (r *Runner) annotate(lines []string, result *dnsResult) []string {
+	var suffix string
+	if r.options.ShowLatency {
+		suffix += r.field(fmt.Sprintf("%dms", result.LatencyMs))
+	}
+	if r.options.ShowRetries {
+		suffix += r.field(fmt.Sprintf("retries:%d", result.Retries))
+	}
+	if result.ObservedChanged {
+		suffix += r.field("observed-changed")
+	}
+	if result.TransportDiff {
+		suffix += r.field("transport-diff")
+	}
+	if len(result.SuspiciousAuthority) > 0 {
+		suffix += r.field("suspicious-authority")
+	}
+	seenChecks := make(map[string]struct{})
+	for _, finding := range result.Lint {
+		if _, ok := seenChecks[finding.Check]; !ok {
+			seenChecks[finding.Check] = struct{}{}
+			suffix += r.field("lint:" + finding.Check)
+		}
+	}
+	for _, listing := range result.DNSBL {
+		suffix += r.field("BLACKLISTED:" + listing.Zone + ":" + listing.Code)
+	}
+	suffix += r.lowReputationTags(result.Reputation)
+	if isSmartBrute(result.Permutation) {
+		suffix += r.field(result.Permutation)
+	}
+	if len(result.DNAMERewrites) > 0 {
+		suffix += r.field("dname:" + result.DNAMERewrites[len(result.DNAMERewrites)-1])
+	}
+	if result.SpoofSuspect {
+		suffix += r.field("spoof-suspect")
+	}
+	if result.TruncatedFinal {
+		suffix += r.field("truncated")
+	}
+	if len(result.NameViolations) > 0 {
+		suffix += r.field("name-violations")
+	}
+	if r.options.ShowResolver && len(result.Resolver) > 0 {
+		if r.options.separator != "" {
+			suffix += r.options.separator + strings.Join(result.Resolver, Comma)
+		} else {
+			suffix += " via " + strings.Join(result.Resolver, Comma)
+		}
+	}
+	if suffix == "" {
+		return lines
+	}
+	for i := range lines {
+		lines[i] += suffix
+	}
+	return lines
+}
+
+// field returns the value appended to a plain line, bracketed unless a separator is configured
+func (r *Runner) field(value string) string {
+	if r.options.separator != "" {
+		return r.options.separator + value
+	}
+	return " [" + value + "]"
+}
+
+// outputKey returns the name displayed in plain output according to the key-by option
+func (r *Runner) outputKey(host, input string) string {
+	if r.options.KeyBy == keyByInput && input != "" {
+		return input
+	}
+	if r.options.PreservePort {
+		if _, port := splitHostPort(input); port != "" {
+			return net.JoinHostPort(host, port)
+		}
+	}
+	return host
+}
+
+// requestsPerHost returns the number of dns requests issued for each host
+func (r *Runner) requestsPerHost() int {
+	requests := len(r.dnsx.Options.QuestionTypes)
+	if r.options.Repeat > 1 {
+		requests *= r.options.Repeat
+	}
+	if r.options.TransportDiff {
+		requests *= 2
+	}
+	return requests
+}
+
+// outputRecords returns the plain text output of a host which already passed the rcode filter.
+// The rcode filter never changes what is displayed, the output is decided as follows:
+//
+//	record flags | -rcode | -rcode-display | output
+//	none         | no     | any            | host if it has A records ([ip] with -resp)
+//	none         | yes    | any            | host [RCODE]
+//	set          | any    | no             | host for each requested record type found ([value] with -resp)
+//	set          | any    | yes            | same as above with [RCODE] appended
+//
+// JSON and raw sinks always receive the whole response, first-only limits the plain lines.
+func (r *Runner) outputRecords(domain string, result *dnsResult) []string {
+	dnsData := result.DNSData
+	if r.options.hasRCodes && !r.options.hasRecordFlags {
+		return r.outputResponseCode(domain, dnsData.StatusCodeRaw)
+	}
+	var (
+		lines  []string
+		suffix string
+	)
+	// without record flags the output is the host alone whatever rcode-display is
+	if r.options.RCodeDisplay && r.options.hasRecordFlags {
+		if responseCodeExt, ok := dns.RcodeToString[dnsData.StatusCodeRaw]; ok {
+			suffix = r.field(responseCodeExt)
+		}
+	}
+	a, aaaa := r.options.firstAddresses(dnsData.A, dnsData.AAAA)
+	if r.options.A {
+		lines = append(lines, r.outputAddressType(domain, a, result.Geo, suffix)...)
+	}
+	if r.options.AAAA {
+		lines = append(lines, r.outputAddressType(domain, aaaa, result.Geo, suffix)...)
+	}
+	if r.options.CNAME {
+		lines = append(lines, r.outputRecordType(domain, dnsData.CNAME, suffix)...)
+	}
+	if r.options.PTR {
+		lines = append(lines, r.outputRecordType(domain, dnsData.PTR, suffix)...)
+	}
+	if r.options.MX {
+		lines = append(lines, r.outputRecordType(domain, dnsData.MX, suffix)...)
+	}
+	if r.options.NS {
+		lines = append(lines, r.outputRecordType(domain, dnsData.NS, suffix)...)
+	}
+	if r.options.SOA {
+		lines = append(lines, r.outputRecordType(domain, dnsData.SOA, suffix)...)
+	}
+	if r.options.TXT {
+		lines = append(lines, r.outputRecordType(domain, dnsData.TXT, suffix)...)
+	}
+	if r.options.DNAME {
+		lines = append(lines, r.outputRecordType(domain, result.DNAME, suffix)...)
+	}
+	return lines
+}
+
+func (r *Runner) outputRecordType(domain string, items []string, suffix string) []string {
+	var lines []string
+	for _, item := range r.options.firstRecords(items) {
 		item := strings.ToLower(item)
 		if r.options.ResponseOnly {
-			r.outputchan <- item
+			lines = append(lines, item+suffix)
 		} else if r.options.Response {
-			r.outputchan <- domain + " [" + item + "]"
+			lines = append(lines, domain+r.field(item)+suffix)
 		} else {
 			// just prints out the domain if it has a record type and exit
-			r.outputchan <- domain
+			lines = append(lines, domain+suffix)
 			break
 		}
 	}
+	return lines
 }
 
-func (r *Runner) outputResponseCode(domain string, responsecode int) {
+func (r *Runner) outputResponseCode(domain string, responsecode int) []string {
 	responseCodeExt, ok := dns.RcodeToString[responsecode]
 	if ok {
-		r.outputchan <- domain + " [" + responseCodeExt + "]"
+		return []string{domain + r.field(responseCodeExt)}
 	}
+	return nil
 }
 
 func (r *Runner) storeDNSData(dnsdata *retryabledns.DNSData) error {
@@ -664,9 +1773,58 @@ func (r *Runner) storeDNSData(dnsdata *retryabledns.DNSData) error {
 	return r.hm.Set(dnsdata.Host, data)
 }
 
-// Close running instance
+// Close running instance, it's safe to call it more than once and concurrently
+// with an interrupted run. The control socket is closed first so that no
+// command reaches a runner being torn down, the interrupted run stops reading its input
+// and Close waits for it and for its workers, then the outputs are flushed and finally
+// the on-disk maps and their temporary directories are removed.
 func (r *Runner) Close() {
-	r.hm.Close()
+	r.closeonce.Do(func() {
+		r.closecancel()
+		r.stopControlServer()
+		r.runmutex.Lock()
+		defer r.runmutex.Unlock()
+		// the workers of an interrupted run exit after their current host
+		r.wgresolveworkers.Wait()
+		r.wgwildcardworker.Wait()
+		r.stopStats()
+		r.closeOutputWorker()
+		if r.hostsOutput != nil {
+			if err := r.hostsOutput.Close(); err != nil {
+				gologger.Warning().Msgf("Could not write hosts output: %s\n", err)
+			}
+		}
+		if r.splunk != nil {
+			r.splunk.Close()
+		}
+		if r.timings != nil {
+			if err := r.timings.Close(); err != nil {
+				gologger.Warning().Msgf("Could not write timings: %s\n", err)
+			}
+		}
+		if r.syslog != nil {
+			if err := r.syslog.Close(); err != nil {
+				gologger.Warning().Msgf("Could not close syslog: %s\n", err)
+			}
+		}
+		if r.wildcardhm != nil {
+			r.wildcardhm.Close()
+		}
+		r.hm.Close()
+		if err := r.state.remove(); err != nil {
+			gologger.Warning().Msgf("Could not remove state directory: %s\n", err)
+		}
+	})
+}
+
+// stopStats stops the statistics printer if it's running, a line being printed is
+// completed before it returns
+func (r *Runner) stopStats() {
+	if !atomic.CompareAndSwapInt32(&r.statsrunning, 1, 0) {
+		return
+	}
+	close(r.statsstop)
+	<-r.statsdone
 }
 
 func (r *Runner) wildcardWorker() {
@@ -678,11 +1836,16 @@ func (r *Runner) wildcardWorker() {
 			break
 		}
 
-		if r.IsWildcard(host) {
-			// mark this host as a wildcard subdomain
-			r.wildcardsmutex.Lock()
-			r.wildcards[host] = struct{}{}
-			r.wildcardsmutex.Unlock()
-		}
+		// a host whose check panicked is kept in the output
+		r.recoverPanic(host, func() {
+			if r.IsWildcard(host) {
+				// mark this host as a wildcard subdomain
+				// nolint:errcheck
+				r.wildcardhm.Set(host, wildcardMarker)
+				if r.options.ShowStatistics {
+					r.stats.IncrementCounter("wildcard_removed", 1)
+				}
+			}
+		})
 	}
 }
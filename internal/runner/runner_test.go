@@ -0,0 +1,231 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.uber.org/goleak"
+)
+
+// answerA answers the A questions with 192.0.2.1
+func answerA(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if req.Question[0].Qtype == dns.TypeA {
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.IPv4(192, 0, 2, 1),
+		})
+	}
+	w.WriteMsg(resp) // nolint:errcheck
+}
+
+// verifyNoLeaks checks that the goroutines started by the test exited. The drain goroutine of
+// leveldb exits up to a second after the database is closed, the removal of the state directory
+// checks that the maps were closed.
+func verifyNoLeaks(t *testing.T) func() {
+	ignore := goleak.IgnoreCurrent()
+	return func() {
+		goleak.VerifyNone(t, ignore, goleak.IgnoreTopFunction("github.com/syndtr/goleveldb/leveldb.(*DB).mpoolDrain"))
+	}
+}
+
+// newTestRunner returns a runner created with the command line defaults, resolving the
+// targets with the server and collecting the results
+func newTestRunner(tb testing.TB, server string, targets ...string) (*Runner, *[]string) {
+	tb.Helper()
+	var hosts []string
+	r := newConfiguredRunner(tb, server, func(options *Options) {
+		options.Targets = targets
+	}, func(result *Result) {
+		hosts = append(hosts, result.Host)
+	})
+	return r, &hosts
+}
+
+// newConfiguredRunner returns a runner resolving with the server, its options changed by
+// configure, passing the results to the callback one at a time
+func newConfiguredRunner(tb testing.TB, server string, configure func(*Options), onResult func(*Result)) *Runner {
+	tb.Helper()
+	var mutex sync.Mutex
+	options := DefaultOptions()
+	options.Resolvers = "udp:" + server
+	options.Silent = true
+	options.Threads = 10
+	options.OnResult = func(result *Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		onResult(result)
+	}
+	configure(options)
+	if err := options.Configure(); err != nil {
+		tb.Fatal(err)
+	}
+	r, err := New(options)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return r
+}
+
+func TestCloseLeaks(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	defer verifyNoLeaks(t)()
+
+	r, hosts := newTestRunner(t, server, "a.example.com", "b.example.com")
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	// Close is idempotent
+	r.Close()
+	if len(*hosts) != 2 {
+		t.Fatalf("got results %v, want 2", *hosts)
+	}
+	if _, err := os.Stat(r.state.path); !os.IsNotExist(err) {
+		t.Fatalf("state directory not removed: %v", err)
+	}
+}
+
+func TestCloseInterruptedRun(t *testing.T) {
+	// a slow resolver keeps the workers busy when the run is interrupted
+	server := newTestDNSServer(t, func(w dns.ResponseWriter, req *dns.Msg) {
+		time.Sleep(20 * time.Millisecond)
+		answerA(w, req)
+	})
+	defer verifyNoLeaks(t)()
+
+	var targets []string
+	for i := 0; i < 1000; i++ {
+		targets = append(targets, fmt.Sprintf("host%d.example.com", i))
+	}
+	r, _ := newTestRunner(t, server, targets...)
+	done := make(chan error)
+	go func() {
+		done <- r.Run()
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Close returns once the run and its workers stopped writing to the hybrid map
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("Close returned before the run")
+	}
+}
+
+func TestRunHosts(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	r, hosts := newTestRunner(t, server)
+	defer r.Close()
+
+	tests := []struct {
+		hosts []string
+		reset bool
+		want  []string
+	}{
+		{hosts: []string{"a.example.com", "b.example.com"}, want: []string{"a.example.com", "b.example.com"}},
+		// the hosts of the previous runs are skipped
+		{hosts: []string{"b.example.com", "c.example.com"}, want: []string{"c.example.com"}},
+		{hosts: []string{"a.example.com", "d.example.com"}, reset: true, want: []string{"a.example.com", "d.example.com"}},
+	}
+	for i, test := range tests {
+		if test.reset {
+			if err := r.Reset(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		*hosts = nil
+		if err := r.RunHosts(test.hosts); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(*hosts)
+		if fmt.Sprint(*hosts) != fmt.Sprint(test.want) {
+			t.Errorf("run %d: got %v, want %v", i+1, *hosts, test.want)
+		}
+	}
+	if err := r.RunHosts(nil); err == nil {
+		t.Error("run without hosts succeeded")
+	}
+}
+
+// TestRunHostsConcurrent is meant to be run with -race, the concurrent runs are serialized
+// and their results are received by the callback from the output workers
+func TestRunHostsConcurrent(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	r, hosts := newTestRunner(t, server)
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var list []string
+			for j := 0; j < 20; j++ {
+				list = append(list, fmt.Sprintf("host%d-%d.example.com", i, j))
+			}
+			if err := r.RunHosts(list); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(*hosts) != 80 {
+		t.Fatalf("got %d results, want 80", len(*hosts))
+	}
+}
+
+// TestRunOutputError checks that an output which can't be opened fails the run before any
+// worker is started, the runner is then closed cleanly
+func TestRunOutputError(t *testing.T) {
+	server := newTestDNSServer(t, answerA)
+	missing := filepath.Join(t.TempDir(), "missing", "output.txt")
+	tests := []struct {
+		name      string
+		configure func(*Options)
+	}{
+		{name: "list", configure: func(*Options) {}},
+		{name: "stream", configure: func(o *Options) { o.Stream = true }},
+		{name: "wildcard", configure: func(o *Options) { o.WildcardDomain = "example.com" }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer verifyNoLeaks(t)()
+			hosts := filepath.Join(t.TempDir(), "hosts.txt")
+			if err := os.WriteFile(hosts, []byte("www.example.com\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			r := newConfiguredRunner(t, server, func(options *Options) {
+				options.Hosts = hosts
+				options.Output = []string{missing}
+				test.configure(options)
+			}, func(*Result) {})
+			if err := r.Run(); err == nil || !strings.Contains(err.Error(), "could not open output") {
+				t.Fatalf("got error %v, want could not open output", err)
+			}
+			r.Close()
+			if _, err := os.Stat(r.state.path); !os.IsNotExist(err) {
+				t.Fatalf("state directory not removed: %v", err)
+			}
+		})
+	}
+}
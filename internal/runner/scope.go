@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/publicsuffix"
+)
+
+// scope allows the hosts whose registered domain is in scope, ips pass unless scope cidrs
+// are given and names pass when only scope cidrs are given
+type scope struct {
+	domains map[string]struct{}
+	cidrs   []*net.IPNet
+}
+
+func newScope(domains, cidrs []string) (*scope, error) {
+	s := &scope{domains: make(map[string]struct{})}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if domain != "" {
+			s.domains[domain] = struct{}{}
+		}
+	}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid scope cidr %s", cidr)
+		}
+		s.cidrs = append(s.cidrs, ipnet)
+	}
+	return s, nil
+}
+
+// allows reports whether the host is in scope
+func (s *scope) allows(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		if len(s.cidrs) == 0 {
+			return true
+		}
+		for _, cidr := range s.cidrs {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(s.domains) == 0 {
+		return true
+	}
+	registered, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return false
+	}
+	_, ok := s.domains[registered]
+	return ok
+}
+
+// inScope reports whether the host passes the scope, dropped hosts are counted
+func (r *Runner) inScope(host string) bool {
+	if r.scope == nil || r.scope.allows(host) {
+		return true
+	}
+	atomic.AddUint64(&r.scopeDropped, 1)
+	return false
+}
+
+// reportScopeDropped logs the number of hosts dropped by the scope
+func (r *Runner) reportScopeDropped() {
+	if r.scope != nil {
+		gologger.Info().Msgf("%d hosts dropped [out-of-scope]\n", atomic.LoadUint64(&r.scopeDropped))
+	}
+}
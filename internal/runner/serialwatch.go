@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/gologger"
+)
+
+// watchedZone is a zone of the watched hosts along with the soa serial of the previous pass
+type watchedZone struct {
+	serial uint32
+	hosts  []string
+	// unchanged is set for the pass when the serial is the one of the previous pass
+	unchanged bool
+	// canary is the host queried anyway during the unchanged passes to catch the zones
+	// whose answers change without a serial increment
+	canary string
+	next   int
+	// exempt zones don't increment their serial and are always queried
+	exempt bool
+}
+
+// zoneSerials skips in ttl-watch mode the hosts of the zones whose soa serial didn't change
+// since the previous pass, the zones are learnt from the soa of the hosts in the first pass
+type zoneSerials struct {
+	mutex sync.Mutex
+	zones map[string]*watchedZone
+	// hostZones maps the hosts to the apex of their zone, "" when unknown
+	hostZones map[string]string
+	// answers holds the fingerprint of the last answers of the hosts
+	answers map[string]uint64
+	skipped uint64
+}
+
+func newZoneSerials() *zoneSerials {
+	return &zoneSerials{
+		zones:     make(map[string]*watchedZone),
+		hostZones: make(map[string]string),
+		answers:   make(map[string]uint64),
+	}
+}
+
+// refreshZoneSerials queries the soa serial of the known zones at the start of a pass
+func (r *Runner) refreshZoneSerials() {
+	z := r.zoneSerials
+	z.mutex.Lock()
+	apexes := make([]string, 0, len(z.zones))
+	for apex, zone := range z.zones {
+		if !zone.exempt {
+			apexes = append(apexes, apex)
+		}
+	}
+	z.skipped = 0
+	z.mutex.Unlock()
+
+	for _, apex := range apexes {
+		r.takeLimiter()
+		serial, ok := r.querySerial(apex)
+		z.mutex.Lock()
+		zone := z.zones[apex]
+		zone.unchanged = ok && serial == zone.serial
+		zone.canary = ""
+		if zone.unchanged {
+			zone.canary = zone.hosts[zone.next%len(zone.hosts)]
+			zone.next++
+		} else if ok {
+			zone.serial = serial
+		}
+		z.mutex.Unlock()
+	}
+}
+
+// skippedHosts returns the number of hosts skipped in the current pass
+func (z *zoneSerials) skippedHosts() uint64 {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	return z.skipped
+}
+
+// skip reports whether the host can be skipped in the current pass, the canary of its
+// zone being queried in its place
+func (z *zoneSerials) skip(host string) bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	apex, known := z.hostZones[host]
+	if !known || apex == "" {
+		return false
+	}
+	zone := z.zones[apex]
+	if zone.exempt || !zone.unchanged || zone.canary == host {
+		return false
+	}
+	z.skipped++
+	return true
+}
+
+// observe records the answers fingerprint of a queried host, the zone of a canary whose
+// answers changed while the serial didn't is exempted from the fast path
+func (z *zoneSerials) observe(host string, fingerprint uint64) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	previous, seen := z.answers[host]
+	z.answers[host] = fingerprint
+	apex := z.hostZones[host]
+	if !seen || previous == fingerprint || apex == "" {
+		return
+	}
+	if zone := z.zones[apex]; zone.unchanged && zone.canary == host && !zone.exempt {
+		zone.exempt = true
+		gologger.Verbose().Msgf("Answers of %s changed without serial increment of %s, zone exempted from the serial fast path\n", host, apex)
+	}
+}
+
+// learnZone looks up the zone of a host the first time it's queried, the hosts whose
+// zone can't be found (eg. out of zone cnames) are always queried
+func (r *Runner) learnZone(host string) {
+	z := r.zoneSerials
+	z.mutex.Lock()
+	_, known := z.hostZones[host]
+	z.mutex.Unlock()
+	if known {
+		return
+	}
+
+	r.takeLimiter()
+	apex, serial, ok := r.soaZone(host)
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	if !ok {
+		z.hostZones[host] = ""
+		return
+	}
+	z.hostZones[host] = apex
+	zone, ok := z.zones[apex]
+	if !ok {
+		zone = &watchedZone{serial: serial}
+		z.zones[apex] = zone
+	}
+	zone.hosts = append(zone.hosts, host)
+}
+
+// soaZone returns the apex and serial of the zone holding the host, from the soa of the
+// answer for the apex itself or of the authority section otherwise
+func (r *Runner) soaZone(host string) (string, uint32, bool) {
+	msg, err := r.dnsx.QueryMsg(host, dns.TypeSOA)
+	if err != nil || msg == nil {
+		return "", 0, false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, rr := range append(msg.Answer, msg.Ns...) {
+		soa, ok := rr.(*dns.SOA)
+		if !ok {
+			continue
+		}
+		apex := strings.ToLower(strings.TrimSuffix(soa.Hdr.Name, "."))
+		if host == apex || strings.HasSuffix(host, "."+apex) {
+			return apex, soa.Serial, true
+		}
+	}
+	return "", 0, false
+}
+
+// querySerial returns the soa serial of the zone apex
+func (r *Runner) querySerial(apex string) (uint32, bool) {
+	msg, err := r.dnsx.QueryMsg(apex, dns.TypeSOA)
+	if err != nil || msg == nil {
+		return 0, false
+	}
+	for _, rr := range msg.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
+
+// writeAnswers hashes the sorted answers of the question type, without their ttl which
+// decreases in the caches
+func writeAnswers(h hash.Hash64, questionType uint16, answers []dns.RR) {
+	values := make([]string, 0, len(answers))
+	for _, rr := range answers {
+		if rr.Header().Rrtype == questionType {
+			values = append(values, strings.TrimPrefix(rr.String(), rr.Header().String()))
+		}
+	}
+	sort.Strings(values)
+	h.Write([]byte(dns.TypeToString[questionType]))
+	for _, value := range values {
+		h.Write([]byte(Comma + value))
+	}
+}
@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// defaultServeTTL is the ttl of the served records whose result has no ttl
+const defaultServeTTL = 300
+
+// resultStore is a read-only view of the dns data of a completed run
+type resultStore interface {
+	// lookup returns the dns data of the fqdn, the reverse names of the resolved
+	// addresses included
+	lookup(name string) (*retryabledns.DNSData, bool)
+	size() int
+}
+
+// jsonResultStore holds the dns data of the json output of a run by fqdn
+type jsonResultStore map[string]*retryabledns.DNSData
+
+// loadJSONResultStore reads the json output of a run, the records of the hosts written
+// several times (repeat, several types) are merged
+func loadJSONResultStore(path string) (jsonResultStore, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	store := make(jsonResultStore)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 4096), maxPreviousLine)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var data retryabledns.DNSData
+		if err := json.Unmarshal([]byte(line), &data); err != nil || data.Host == "" {
+			continue
+		}
+		name := dns.Fqdn(strings.ToLower(data.Host))
+		// the ptr records of the addresses are served under their reverse name
+		if net.ParseIP(data.Host) != nil {
+			if reverse, err := dns.ReverseAddr(data.Host); err == nil {
+				name = reverse
+			}
+		}
+		store.merge(name, &data)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(store) == 0 {
+		return nil, errors.New("no json results found")
+	}
+	return store, nil
+}
+
+// merge adds the records of the dns data to the ones already stored for the name
+func (s jsonResultStore) merge(name string, data *retryabledns.DNSData) {
+	stored, ok := s[name]
+	if !ok {
+		s[name] = &retryabledns.DNSData{Host: data.Host, TTL: data.TTL, A: data.A, AAAA: data.AAAA, CNAME: data.CNAME, PTR: data.PTR}
+		return
+	}
+	if stored.TTL == 0 || data.TTL > 0 && data.TTL < stored.TTL {
+		stored.TTL = data.TTL
+	}
+	stored.A = appendMissing(stored.A, data.A...)
+	stored.AAAA = appendMissing(stored.AAAA, data.AAAA...)
+	stored.CNAME = appendMissing(stored.CNAME, data.CNAME...)
+	stored.PTR = appendMissing(stored.PTR, data.PTR...)
+}
+
+func (s jsonResultStore) lookup(name string) (*retryabledns.DNSData, bool) {
+	data, ok := s[dns.Fqdn(strings.ToLower(name))]
+	return data, ok
+}
+
+func (s jsonResultStore) size() int {
+	return len(s)
+}
+
+// appendMissing appends the values missing from the slice
+func appendMissing(values []string, added ...string) []string {
+	for _, value := range added {
+		if !contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// resultServer answers the A, AAAA, CNAME and PTR queries from a result store, the other
+// types with an empty answer and the unknown names with NXDOMAIN
+type resultServer struct {
+	store resultStore
+}
+
+func (s *resultServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+	if len(req.Question) != 1 {
+		msg.Rcode = dns.RcodeFormatError
+		// nolint:errcheck
+		w.WriteMsg(msg)
+		return
+	}
+	question := req.Question[0]
+	data, ok := s.store.lookup(question.Name)
+	if !ok {
+		msg.Rcode = dns.RcodeNameError
+		// nolint:errcheck
+		w.WriteMsg(msg)
+		return
+	}
+	msg.Answer = serveAnswers(question, data)
+	if w.LocalAddr().Network() == "udp" {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		msg.Truncate(size)
+	}
+	// nolint:errcheck
+	w.WriteMsg(msg)
+}
+
+// serveAnswers synthesizes the records of the question from the dns data, the addresses
+// follow the cname chain of the host
+func serveAnswers(question dns.Question, data *retryabledns.DNSData) []dns.RR {
+	ttl := uint32(defaultServeTTL)
+	if data.TTL > 0 {
+		ttl = uint32(data.TTL)
+	}
+	header := func(name string, rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+	}
+
+	var answers []dns.RR
+	owner := question.Name
+	switch question.Qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME:
+		for _, target := range data.CNAME {
+			target = dns.Fqdn(target)
+			answers = append(answers, &dns.CNAME{Hdr: header(owner, dns.TypeCNAME), Target: target})
+			owner = target
+		}
+	case dns.TypePTR:
+		for _, ptr := range data.PTR {
+			answers = append(answers, &dns.PTR{Hdr: header(owner, dns.TypePTR), Ptr: dns.Fqdn(ptr)})
+		}
+	}
+	switch question.Qtype {
+	case dns.TypeA:
+		for _, value := range data.A {
+			if ip := net.ParseIP(value).To4(); ip != nil {
+				answers = append(answers, &dns.A{Hdr: header(owner, dns.TypeA), A: ip})
+			}
+		}
+	case dns.TypeAAAA:
+		for _, value := range data.AAAA {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() == nil {
+				answers = append(answers, &dns.AAAA{Hdr: header(owner, dns.TypeAAAA), AAAA: ip})
+			}
+		}
+	}
+	return answers
+}
+
+// runServe answers the queries received on the serve address over udp and tcp from the
+// results of the completed run until interrupted
+func (r *Runner) runServe() error {
+	handler := &resultServer{store: r.serveStore}
+	errs := make(chan error, 2)
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{Addr: r.options.Serve, Net: network, Handler: handler}
+		go func(network string) {
+			errs <- errors.Wrapf(server.ListenAndServe(), "could not serve over %s", network)
+		}(network)
+		// nolint:errcheck
+		defer server.Shutdown()
+	}
+	gologger.Info().Msgf("Serving %d names from %s on %s\n", r.serveStore.size(), r.options.ServeFrom, r.options.Serve)
+	return <-errs
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// newServedResults writes the json output of a run and serves it
+func newServedResults(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := loadJSONResultStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestDNSServer(t, (&resultServer{store: store}).ServeDNS)
+}
+
+func TestServe(t *testing.T) {
+	server := newServedResults(t,
+		`{"host":"www.example.com","ttl":60,"a":["192.0.2.1","192.0.2.2"],"aaaa":["2001:db8::1"]}`,
+		`{"host":"alias.example.com","ttl":120,"cname":["web.example.net"],"a":["192.0.2.3"]}`,
+		`{"host":"notll.example.com","a":["192.0.2.4"]}`,
+		// the hosts written several times are merged with the lowest ttl
+		`{"host":"merged.example.com","ttl":120,"a":["192.0.2.5"]}`,
+		`{"host":"merged.example.com","ttl":30,"a":["192.0.2.5","192.0.2.6"]}`,
+		`{"host":"192.0.2.1","ptr":["www.example.com"]}`,
+		`{"host":"2001:db8::1","ttl":90,"ptr":["www.example.com"]}`,
+		"[INF] not a result",
+	)
+
+	tests := []struct {
+		name         string
+		questionType uint16
+		rcode        int
+		// the answers formatted as type:ttl:value
+		want []string
+	}{
+		{name: "www.example.com", questionType: dns.TypeA, want: []string{"A:60:192.0.2.1", "A:60:192.0.2.2"}},
+		{name: "WWW.Example.COM", questionType: dns.TypeA, want: []string{"A:60:192.0.2.1", "A:60:192.0.2.2"}},
+		{name: "www.example.com", questionType: dns.TypeAAAA, want: []string{"AAAA:60:2001:db8::1"}},
+		{name: "alias.example.com", questionType: dns.TypeA, want: []string{"CNAME:120:web.example.net.", "A:120:192.0.2.3"}},
+		{name: "alias.example.com", questionType: dns.TypeCNAME, want: []string{"CNAME:120:web.example.net."}},
+		{name: "notll.example.com", questionType: dns.TypeA, want: []string{"A:300:192.0.2.4"}},
+		{name: "merged.example.com", questionType: dns.TypeA, want: []string{"A:30:192.0.2.5", "A:30:192.0.2.6"}},
+		{name: "1.2.0.192.in-addr.arpa", questionType: dns.TypePTR, want: []string{"PTR:300:www.example.com."}},
+		{name: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", questionType: dns.TypePTR, want: []string{"PTR:90:www.example.com."}},
+		// the known names have no data for the other types
+		{name: "www.example.com", questionType: dns.TypeMX},
+		{name: "notll.example.com", questionType: dns.TypeAAAA},
+		{name: "unknown.example.com", questionType: dns.TypeA, rcode: dns.RcodeNameError},
+		{name: "192.0.2.1", questionType: dns.TypeA, rcode: dns.RcodeNameError},
+	}
+	client := new(dns.Client)
+	for _, test := range tests {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(test.name), test.questionType)
+		resp, _, err := client.Exchange(msg, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		label := test.name + " " + dns.TypeToString[test.questionType]
+		if resp.Rcode != test.rcode || !resp.Authoritative {
+			t.Errorf("%s: got rcode %s and authoritative %v, want %s", label, dns.RcodeToString[resp.Rcode], resp.Authoritative, dns.RcodeToString[test.rcode])
+		}
+		var got []string
+		for _, rr := range resp.Answer {
+			value := strings.TrimPrefix(rr.String(), rr.Header().String())
+			got = append(got, fmt.Sprintf("%s:%d:%s", dns.TypeToString[rr.Header().Rrtype], rr.Header().Ttl, value))
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: got %q, want %q", label, got, test.want)
+		}
+	}
+}
+
+func TestLoadJSONResultStoreEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	if err := os.WriteFile(path, []byte("www.example.com\n{\"a\":[\"192.0.2.1\"]}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadJSONResultStore(path); err == nil {
+		t.Fatal("results without hosts loaded")
+	}
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/projectdiscovery/dnsx/internal/mutate"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// smartBrutePrefix tags the hosts generated by the smart-brute pass along with their rule
+const smartBrutePrefix = "smart-brute:"
+
+// smartBrute resolves a second pass of candidates mutating the label patterns of the hosts
+// resolved by the first one. The hybrid map holds the hosts of the first pass, so that
+// nothing is queried twice.
+func (r *Runner) smartBrute() {
+	var names []string
+	r.hm.Scan(func(k, v []byte) error {
+		if hasAddresses(v) {
+			names = append(names, string(k))
+		}
+		return nil
+	})
+	candidates := mutate.Generate(names, r.options.SmartBruteMax)
+	gologger.Info().Msgf("Resolving %d smart-brute candidates generated from %d hosts\n", len(candidates), len(names))
+
+	hosts := make([]string, 0, len(candidates))
+	rules := make(map[string]string, len(candidates))
+	for _, candidate := range candidates {
+		hosts = append(hosts, candidate.Domain)
+		rules[candidate.Domain] = smartBrutePrefix + candidate.Rule
+	}
+	r.resolvePass(hosts, rules)
+}
+
+// resolvePass resolves the hosts generated after the input pass with new workers. Hosts
+// already processed are skipped through the hybrid map, the tags of the new ones are
+// reported as their permutation.
+func (r *Runner) resolvePass(hosts []string, tags map[string]string) {
+	r.workerchan = make(chan inputItem)
+	for i := 0; i < r.options.Threads; i++ {
+		r.wgresolveworkers.Add(1)
+		go r.worker()
+	}
+	for _, host := range hosts {
+		if r.pastDeadline() {
+			break
+		}
+		if !r.inScope(host) {
+			continue
+		}
+		if _, ok := r.hm.Get(host); ok {
+			continue
+		}
+		// nolint:errcheck
+		r.hm.Set(host, nil)
+		if tag, ok := tags[host]; ok {
+			r.permutations.Store(host, tag)
+		}
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("hosts", 1)
+			r.stats.IncrementCounter("total", r.requestsPerHost())
+			r.stats.IncrementCounter("requests", r.requestsPerHost())
+		}
+		if !r.sendItem(inputItem{input: host, host: host}) {
+			break
+		}
+	}
+	close(r.workerchan)
+	r.waitWorkers()
+}
+
+// hasAddresses reports whether the marshaled dns data has A, AAAA or CNAME records
+func hasAddresses(v []byte) bool {
+	var dnsdata retryabledns.DNSData
+	if err := dnsdata.Unmarshal(v); err != nil {
+		return false
+	}
+	return len(dnsdata.A) > 0 || len(dnsdata.AAAA) > 0 || len(dnsdata.CNAME) > 0
+}
+
+// isSmartBrute reports whether the permutation tag comes from the smart-brute pass
+func isSmartBrute(permutation string) bool {
+	return strings.HasPrefix(permutation, smartBrutePrefix)
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/fileutil"
+	"github.com/projectdiscovery/gologger"
+)
+
+// splitShardName returns the name of the n-th shard file, named after the hosts file or
+// dnsx for the other inputs (hosts_shard_1.txt)
+func splitShardName(hosts string, n int) string {
+	base := "dnsx"
+	if hosts != "" && fileutil.FileExists(hosts) {
+		base = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(hosts), ".gz"), ".zst")
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return fmt.Sprintf("%s_shard_%d.txt", base, n)
+}
+
+// runSplit writes the hosts of the input to split shard files in the current directory
+// without querying them. The hosts are expanded like in a scan and dealt in turn to the
+// shards, so that their sizes differ by one host at most.
+func (r *Runner) runSplit() error {
+	shards := make([]*bufio.Writer, r.options.Split)
+	files := make([]*os.File, r.options.Split)
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+			}
+		}
+	}()
+	for i := range shards {
+		file, err := os.Create(splitShardName(r.options.Hosts, i+1))
+		if err != nil {
+			return errors.Wrap(err, "could not create shard file")
+		}
+		files[i] = file
+		shards[i] = bufio.NewWriter(file)
+	}
+
+	var (
+		mutex    sync.Mutex
+		next     int
+		writeErr error
+	)
+	err := r.prepareInput(func(host, _ string) {
+		// the wordlist product is generated concurrently
+		mutex.Lock()
+		defer mutex.Unlock()
+		if _, err := shards[next].WriteString(host + NewLine); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		next = (next + 1) % len(shards)
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "could not write shard file")
+	}
+	for i, shard := range shards {
+		if err := shard.Flush(); err != nil {
+			return errors.Wrap(err, "could not write shard file")
+		}
+		gologger.Info().Msgf("Shard written: %s\n", files[i].Name())
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// splunkSourcetype is the sourcetype of the events sent to splunk
+	splunkSourcetype = "dnsx:dns"
+	// splunkEventPath is the path of the hec endpoint used when the url has none
+	splunkEventPath = "/services/collector/event"
+	splunkTimeout   = 30 * time.Second
+	// splunkQueueSize bounds the batches waiting to be sent, the next ones are dropped
+	splunkQueueSize = 16
+	// splunkAttempts is the number of requests sent for a batch failing with a transient error
+	splunkAttempts = 3
+	// splunkRetryDelay is the backoff delay after the first failure, it grows with each attempt
+	splunkRetryDelay = time.Second
+)
+
+// splunkEvent is a result in the splunk http event collector format
+type splunkEvent struct {
+	Time       float64         `json:"time"`
+	Sourcetype string          `json:"sourcetype"`
+	Event      json.RawMessage `json:"event"`
+	// Fields are the indexed fields of the event, the labels of the run
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// splunkHEC sends the results to a splunk http event collector in batches. The batches are
+// built by the output worker and sent by a dedicated goroutine so that a slow collector
+// doesn't hold the other sinks, the batches are dropped once the queue is full.
+type splunkHEC struct {
+	url        string
+	token      string
+	batchSize  int
+	retryDelay time.Duration
+	http       *http.Client
+	batch      bytes.Buffer
+	events     int
+	queue      chan splunkBatch
+	done       chan struct{}
+	// dropped and droppedEvents count the batches not sent, either dropped from the full
+	// queue or failed after the last attempt
+	dropped       uint64
+	droppedEvents uint64
+}
+
+// splunkBatch is the body of a request and the number of its events
+type splunkBatch struct {
+	body   []byte
+	events int
+}
+
+func newSplunkHEC(endpoint, token string, batchSize int) (*splunkHEC, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid splunk url %s", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = splunkEventPath
+	}
+	s := &splunkHEC{
+		url:        u.String(),
+		token:      token,
+		batchSize:  batchSize,
+		retryDelay: splunkRetryDelay,
+		http:       &http.Client{Timeout: splunkTimeout},
+		queue:      make(chan splunkBatch, splunkQueueSize),
+		done:       make(chan struct{}),
+	}
+	go s.sendBatches()
+	return s, nil
+}
+
+// add appends the result of the event to the batch, the batch is sent once full
+func (s *splunkHEC) add(event *outputEvent) {
+	if event.status != statusMatched || event.result == nil {
+		return
+	}
+	data, err := event.result.JSON()
+	if err != nil {
+		return
+	}
+	timestamp := event.result.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	line, err := json.Marshal(splunkEvent{
+		Time:       float64(timestamp.UnixNano()) / float64(time.Second),
+		Sourcetype: splunkSourcetype,
+		Event:      json.RawMessage(data),
+		Fields:     event.result.Labels,
+	})
+	if err != nil {
+		return
+	}
+	s.batch.Write(line)
+	s.batch.WriteString("\n")
+	s.events++
+	if s.events >= s.batchSize {
+		s.flush()
+	}
+}
+
+// flush queues the pending events, it's only used from the output worker
+func (s *splunkHEC) flush() {
+	if s.events == 0 {
+		return
+	}
+	batch := splunkBatch{body: append([]byte(nil), s.batch.Bytes()...), events: s.events}
+	s.batch.Reset()
+	s.events = 0
+	select {
+	case s.queue <- batch:
+	default:
+		s.drop(batch)
+	}
+}
+
+// sendBatches sends the queued batches until the queue is closed
+func (s *splunkHEC) sendBatches() {
+	defer close(s.done)
+	for batch := range s.queue {
+		if err := s.send(batch.body); err != nil {
+			gologger.Warning().Msgf("Could not send %d events to splunk: %s\n", batch.events, err)
+			s.drop(batch)
+		}
+	}
+}
+
+func (s *splunkHEC) drop(batch splunkBatch) {
+	atomic.AddUint64(&s.dropped, 1)
+	atomic.AddUint64(&s.droppedEvents, uint64(batch.events))
+}
+
+// Close sends the queued batches and reports the dropped ones, the output worker must have
+// exited
+func (s *splunkHEC) Close() {
+	s.flush()
+	close(s.queue)
+	<-s.done
+	if dropped := atomic.LoadUint64(&s.dropped); dropped > 0 {
+		gologger.Warning().Msgf("Dropped %d splunk batches (%d events), the collector failed or couldn't keep up\n", dropped, atomic.LoadUint64(&s.droppedEvents))
+	}
+}
+
+// send posts the body to the collector, retrying transient failures with a backoff
+func (s *splunkHEC) send(body []byte) error {
+	var err error
+	for attempt := 0; attempt < splunkAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * s.retryDelay)
+		}
+		var retry bool
+		retry, err = s.sendOnce(body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// sendOnce posts the body once and reports whether a failure is worth retrying
+func (s *splunkHEC) sendOnce(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	// nolint:errcheck
+	io.Copy(ioutil.Discard, resp.Body)
+	return false, nil
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// newTestSplunk returns a client of the collector answering with the handler
+func newTestSplunk(t *testing.T, batchSize int, handler http.HandlerFunc) *splunkHEC {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	s, err := newSplunkHEC(server.URL, "secret", batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.retryDelay = 10 * time.Millisecond
+	return s
+}
+
+func splunkResult(host string) *outputEvent {
+	return &outputEvent{status: statusMatched, result: &dnsResult{DNSData: &retryabledns.DNSData{Host: host, Timestamp: time.Unix(1700000000, 0)}}}
+}
+
+func TestSplunkBatches(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		hosts  []string
+		bodies int
+	)
+	s := newTestSplunk(t, 2, func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Splunk secret" || req.URL.Path != splunkEventPath {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		bodies++
+		scanner := bufio.NewScanner(req.Body)
+		for scanner.Scan() {
+			var event struct {
+				Time       float64 `json:"time"`
+				Sourcetype string  `json:"sourcetype"`
+				Event      struct {
+					Host string `json:"host"`
+				} `json:"event"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Sourcetype != splunkSourcetype || event.Time != 1700000000 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			hosts = append(hosts, event.Event.Host)
+		}
+	})
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		s.add(splunkResult(host))
+	}
+	// the filtered hosts aren't sent
+	s.add(&outputEvent{status: statusFiltered, result: splunkResult("d.example.com").result})
+	s.Close()
+	if fmt.Sprint(hosts) != "[a.example.com b.example.com c.example.com]" || bodies != 2 {
+		t.Errorf("got hosts %v in %d requests", hosts, bodies)
+	}
+	if s.dropped != 0 {
+		t.Errorf("got %d dropped batches", s.dropped)
+	}
+}
+
+func TestSplunkRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int32
+		dropped  uint64
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, requests: 1},
+		{name: "transient", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, requests: 3},
+		{name: "unavailable", statuses: []int{http.StatusServiceUnavailable}, requests: splunkAttempts, dropped: 1},
+		// the client errors aren't retried
+		{name: "forbidden", statuses: []int{http.StatusForbidden}, requests: 1, dropped: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			s := newTestSplunk(t, 10, func(w http.ResponseWriter, req *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n > len(test.statuses) {
+					n = len(test.statuses)
+				}
+				w.WriteHeader(test.statuses[n-1])
+			})
+			s.add(splunkResult("a.example.com"))
+			s.Close()
+			if got := atomic.LoadInt32(&requests); got != test.requests {
+				t.Errorf("got %d requests, want %d", got, test.requests)
+			}
+			if s.dropped != test.dropped || s.droppedEvents != test.dropped {
+				t.Errorf("got %d dropped batches and %d events, want %d", s.dropped, s.droppedEvents, test.dropped)
+			}
+		})
+	}
+}
+
+// TestSplunkSlowCollector checks that a collector not answering doesn't hold the output
+// worker, the batches over the queue size are dropped
+func TestSplunkSlowCollector(t *testing.T) {
+	release := make(chan struct{})
+	s := newTestSplunk(t, 1, func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	})
+	start := time.Now()
+	batches := splunkQueueSize + 10
+	for i := 0; i < batches; i++ {
+		s.add(splunkResult(fmt.Sprintf("host%d.example.com", i)))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("adding the results took %s", elapsed)
+	}
+	close(release)
+	s.Close()
+	// the batch being sent and the queued ones went through
+	if dropped := atomic.LoadUint64(&s.dropped); dropped < 9 || dropped > 10 {
+		t.Errorf("got %d dropped batches, want 9 or 10", dropped)
+	}
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+)
+
+const (
+	// stateDirPrefix is the name prefix of the run state directories
+	stateDirPrefix = "run-"
+	// stateMarker is the file holding the pid of the process owning a state directory
+	stateMarker = "dnsx.pid"
+	// staleStateAge is the age after which the state directory of a dead process is removed
+	staleStateAge = time.Hour
+)
+
+// stateDir is the directory of the temporary files of a run, the hybrid maps among them
+type stateDir struct {
+	path string
+}
+
+// newStateDir removes the stale state directories of the base and creates the one of the run
+func newStateDir(base string) (*stateDir, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	cleanStateDirs(base)
+	path, err := ioutil.TempDir(base, stateDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	pid := strconv.Itoa(os.Getpid())
+	if err := ioutil.WriteFile(filepath.Join(path, stateMarker), []byte(pid+NewLine), 0644); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	return &stateDir{path: path}, nil
+}
+
+// hmap returns a disk hybrid map stored in the state directory, removed when it's closed
+func (s *stateDir) hmap(name string) (*hybrid.HybridMap, error) {
+	path, err := ioutil.TempDir(s.path, name+"-")
+	if err != nil {
+		return nil, err
+	}
+	options := hybrid.DefaultDiskOptions
+	options.Path = path
+	return hybrid.New(options)
+}
+
+// remove deletes the state directory along with its files
+func (s *stateDir) remove() error {
+	return os.RemoveAll(s.path)
+}
+
+// cleanStateDirs removes the state directories left by crashed runs. Only the directories
+// following the naming scheme, having a marker of a process that's gone and older than
+// the stale age are removed.
+func cleanStateDirs(base string) {
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), stateDirPrefix) {
+			continue
+		}
+		path := filepath.Join(base, entry.Name())
+		marker := filepath.Join(path, stateMarker)
+		info, err := os.Stat(marker)
+		if err != nil || time.Since(info.ModTime()) < staleStateAge {
+			continue
+		}
+		data, err := ioutil.ReadFile(marker)
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 || processAlive(pid) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			gologger.Warning().Msgf("Could not remove stale state directory %s: %s\n", path, err)
+			continue
+		}
+		gologger.Debug().Msgf("Removed stale state directory %s\n", path)
+	}
+}
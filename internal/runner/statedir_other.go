@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package runner
+
+import "os"
+
+// processAlive reports whether a process with the pid exists, finding a process fails once it exited
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release() // nolint:errcheck
+	return true
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// maxSubzoneDepth bounds the passes enumerating the subzones found by the previous pass
+const maxSubzoneDepth = 3
+
+// delegatedZone returns the zone below the registered domain of the host claimed by the
+// authority records of an empty NOERROR response, the sign of a delegated subzone
+func delegatedZone(host string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata) string {
+	if dnsData.StatusCodeRaw != dns.RcodeSuccess || len(metadata.Answers) > 0 {
+		return ""
+	}
+	name := dns.Fqdn(strings.ToLower(host))
+	apex := dns.Fqdn(apexDomain(host))
+	for _, authority := range metadata.Authorities {
+		switch authority.(type) {
+		case *dns.NS, *dns.SOA:
+		default:
+			continue
+		}
+		zone := dns.Fqdn(strings.ToLower(authority.Header().Name))
+		if zone != apex && dns.IsSubDomain(apex, zone) && dns.IsSubDomain(zone, name) {
+			return strings.TrimSuffix(zone, ".")
+		}
+	}
+	return ""
+}
+
+// discoverSubzone returns the subzone delegated for the host the first time it's seen
+func (r *Runner) discoverSubzone(host string, dnsData *retryabledns.DNSData, metadata *dnsx.Metadata) string {
+	zone := delegatedZone(host, dnsData, metadata)
+	if zone == "" {
+		return ""
+	}
+	if _, seen := r.subzones.LoadOrStore(zone, struct{}{}); seen {
+		return ""
+	}
+	return zone
+}
+
+// enumerateSubzones resolves the wordlist under the subzones discovered by the previous
+// passes, until no new subzone is found or the depth limit is reached
+func (r *Runner) enumerateSubzones() {
+	data, err := preProcessArgument(r.options.WordList)
+	if err != nil {
+		gologger.Warning().Msgf("Could not read the wordlist for subzones: %s\n", err)
+		return
+	}
+	words := uniqueWords(normalizeToSlice(data, r.options.MaxLineLength))
+
+	enumerated := make(map[string]struct{})
+	for depth := 0; depth < maxSubzoneDepth; depth++ {
+		var zones []string
+		r.subzones.Range(func(key, _ interface{}) bool {
+			if _, ok := enumerated[key.(string)]; !ok {
+				zones = append(zones, key.(string))
+			}
+			return true
+		})
+		if len(zones) == 0 || r.pastDeadline() {
+			return
+		}
+		sort.Strings(zones)
+		var hosts []string
+		for _, zone := range zones {
+			enumerated[zone] = struct{}{}
+			for _, word := range words {
+				hosts = append(hosts, word+"."+zone)
+			}
+		}
+		gologger.Info().Msgf("Enumerating %d subzones\n", len(zones))
+		r.resolvePass(hosts, nil)
+	}
+}
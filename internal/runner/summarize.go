@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"net"
+
+	"github.com/projectdiscovery/mapcidr"
+)
+
+// summarizeCIDRs returns the minimal set of cidrs covering the addresses of the lines, the
+// ipv4 cidrs first
+func summarizeCIDRs(lines []string) []string {
+	var networks []*net.IPNet
+	for _, line := range lines {
+		ip := net.ParseIP(line)
+		if ip == nil {
+			continue
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	ipv4, ipv6 := mapcidr.CoalesceCIDRs(networks)
+	summarized := make([]string, 0, len(ipv4)+len(ipv6))
+	for _, network := range append(ipv4, ipv6...) {
+		summarized = append(summarized, network.String())
+	}
+	return summarized
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+)
+
+// syslogSDID is the id of the structured data of the query events (rfc 5424), 32473 is the
+// enterprise number reserved for documentation (rfc 5612)
+const syslogSDID = "dnsx@32473"
+
+// syslogSDEscaper escapes the characters not allowed in structured data parameter values
+var syslogSDEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogMessage returns the query event with its details as rfc 5424 structured data
+func syslogMessage(timing dnsx.QueryTiming) string {
+	params := [][2]string{
+		{"name", strings.TrimSuffix(timing.Name, ".")},
+		{"type", dns.TypeToString[timing.Type]},
+		{"rcode", timingRcode(timing)},
+		{"resolver", timing.Resolver},
+		{"latency_ms", formatRTT(timing.RTT)},
+		{"attempt", fmt.Sprint(timing.Attempt)},
+		{"transport", timing.Transport},
+	}
+	var builder strings.Builder
+	builder.WriteString("query [" + syslogSDID)
+	for _, param := range params {
+		builder.WriteString(" " + param[0] + `="` + syslogSDEscaper.Replace(param[1]) + `"`)
+	}
+	builder.WriteString("]")
+	return builder.String()
+}
@@ -0,0 +1,19 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package runner
+
+import "github.com/pkg/errors"
+
+// syslogWriter is not available without a local syslog daemon
+type syslogWriter struct {
+	*attemptQueue
+}
+
+func newSyslogWriter() (*syslogWriter, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *syslogWriter) Close() error {
+	return nil
+}
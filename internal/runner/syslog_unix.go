@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package runner
+
+import (
+	"log/syslog"
+
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+)
+
+// syslogWriter sends the query events to the local syslog daemon
+type syslogWriter struct {
+	*attemptQueue
+	w *syslog.Writer
+}
+
+func newSyslogWriter() (*syslogWriter, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "dnsx")
+	if err != nil {
+		return nil, err
+	}
+	s := &syslogWriter{w: w}
+	s.attemptQueue = newAttemptQueue(func(timing dnsx.QueryTiming) {
+		// queries without response are logged as warnings
+		if timing.Rcode < 0 {
+			// nolint:errcheck
+			s.w.Warning(syslogMessage(timing))
+			return
+		}
+		// nolint:errcheck
+		s.w.Info(syslogMessage(timing))
+	}, nil, 0)
+	return s, nil
+}
+
+// Close sends the queued events and closes the connection to the daemon
+func (s *syslogWriter) Close() error {
+	if dropped := s.close(); dropped > 0 {
+		gologger.Warning().Msgf("Dropped %d syslog events, the daemon couldn't keep up with the queries\n", dropped)
+	}
+	return s.w.Close()
+}
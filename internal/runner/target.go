@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/projectdiscovery/dnsx/internal/typo"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/iputil"
+)
+
+// expandTarget classifies an input target and hands the hosts to resolve to emit, whatever
+// the source of the target (domain flag, list, stdin):
+//   - urls are reduced to their host and bracketed IPv6 addresses are unbracketed
+//   - cidrs and start-end ranges are expanded to their addresses, the ones larger than a
+//     /24 only when forced or sampled, addresses are resolved as is
+//   - globs and the domains of the domain flag are combined with the words
+//   - the other names are resolved as is, replaced by their permutations in typo and
+//     sld-permute modes or by their name under every top level domain in tld-enum mode
+func (r *Runner) expandTarget(target string, words []string, emit func(host string)) {
+	if isURL(target) {
+		target = extractDomain(target)
+	}
+	target = unbracketIP(target)
+	switch {
+	case target == "":
+	case iputil.IsCIDR(target):
+		r.expandCIDR(target, emit)
+	case isIPRange(target):
+		r.expandRange(target, emit)
+	case iputil.IsIP(target):
+		emit(target)
+	case strings.Contains(target, "*"), r.options.Domains != "" && len(r.runhosts) == 0:
+		if len(words) == 0 {
+			gologger.Warning().Msgf("Skipping %s: domain and glob inputs require a wordlist(w)\n", target)
+		}
+		for _, word := range words {
+			emit(productHost(word, target))
+		}
+	case r.options.TLDEnum:
+		name := tldName(target)
+		for _, tld := range r.tlds {
+			emit(name + "." + tld)
+		}
+	case r.options.Typo:
+		for _, permutation := range typo.Generate(target, typo.DefaultTLDs, r.options.TypoMax) {
+			r.permutations.Store(permutation.Domain, permutation.Technique)
+			emit(permutation.Domain)
+		}
+	case r.options.SLDPermute:
+		for _, permutation := range typo.GenerateSLD(target, r.sldDictionary, r.options.TypoMax) {
+			r.permutations.Store(permutation.Domain, permutation.Technique)
+			emit(permutation.Domain)
+		}
+	default:
+		emit(target)
+	}
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpandTarget(t *testing.T) {
+	words := []string{"www", "mail"}
+	tests := []struct {
+		name      string
+		target    string
+		configure func(*Runner)
+		want      []string
+		// the number of hosts, checked instead of the hosts when set
+		count int
+	}{
+		{name: "domain", target: "www.example.com", want: []string{"www.example.com"}},
+		{name: "url", target: "https://www.example.com:8443/path?q=1", want: []string{"www.example.com"}},
+		{name: "ipv4", target: "192.0.2.1", want: []string{"192.0.2.1"}},
+		{name: "ipv6", target: "2001:db8::1", want: []string{"2001:db8::1"}},
+		{name: "bracketed ipv6", target: "[2001:db8::1]", want: []string{"2001:db8::1"}},
+		{name: "ipv6 url", target: "http://[2001:db8::1]:8080/", want: []string{"2001:db8::1"}},
+		{name: "ipv4 url", target: "http://192.0.2.1/", want: []string{"192.0.2.1"}},
+		{name: "cidr", target: "192.0.2.0/30", want: []string{"192.0.2.0", "192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{name: "ipv6 cidr", target: "2001:db8::/127", want: []string{"2001:db8::", "2001:db8::1"}},
+		{name: "range", target: "192.0.2.1-192.0.2.3", want: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		// the ranges larger than a /24 are forced or sampled
+		{name: "large cidr", target: "10.0.0.0/23"},
+		{name: "forced large cidr", target: "10.0.0.0/23", configure: func(r *Runner) { r.options.ForceLargeCIDR = true }, count: 512},
+		{name: "sampled large cidr", target: "10.0.0.0/23", configure: func(r *Runner) { r.options.CIDRSampleDensity = 16 }, count: 32},
+		{name: "glob", target: "*.example.com", want: []string{"mail.example.com", "www.example.com"}},
+		{name: "domain flag", target: "example.com", configure: func(r *Runner) { r.options.Domains = "example.com" }, want: []string{"mail.example.com", "www.example.com"}},
+		// the addresses of the domain flag aren't combined with the words
+		{name: "domain flag cidr", target: "192.0.2.0/31", configure: func(r *Runner) { r.options.Domains = "192.0.2.0/31" }, want: []string{"192.0.2.0", "192.0.2.1"}},
+		{name: "domain flag ip", target: "192.0.2.1", configure: func(r *Runner) { r.options.Domains = "192.0.2.1" }, want: []string{"192.0.2.1"}},
+		// the hosts given to RunHosts are resolved as is
+		{name: "run hosts", target: "example.com", configure: func(r *Runner) { r.options.Domains = "example.com"; r.runhosts = []string{"example.com"} }, want: []string{"example.com"}},
+		{name: "tld enum", target: "www.example.com", configure: func(r *Runner) { r.options.TLDEnum = true; r.tlds = []string{"com", "net"} }, want: []string{"example.com", "example.net"}},
+		{name: "empty", target: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Runner{options: &Options{}}
+			if test.configure != nil {
+				test.configure(r)
+			}
+			var got []string
+			r.expandTarget(test.target, words, func(host string) {
+				got = append(got, host)
+			})
+			if test.count > 0 {
+				if len(got) != test.count {
+					t.Fatalf("got %d hosts, want %d", len(got), test.count)
+				}
+				return
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpandTargetWithoutWords(t *testing.T) {
+	r := &Runner{options: &Options{Domains: "example.com"}}
+	for _, target := range []string{"*.example.com", "example.com"} {
+		r.expandTarget(target, nil, func(host string) {
+			t.Errorf("%s: got host %s without words", target, host)
+		})
+	}
+}
+
+// TestInputSources checks that the targets are classified the same way whatever their source
+func TestInputSources(t *testing.T) {
+	targets := []string{"192.0.2.0/31", "http://[2001:db8::1]:8080/", "192.0.2.5-192.0.2.6", "www.example.com"}
+	want := "[192.0.2.0 192.0.2.1 192.0.2.5 192.0.2.6 2001:db8::1 www.example.com]"
+	list := filepath.Join(t.TempDir(), "hosts.txt")
+	if err := os.WriteFile(list, []byte(strings.Join(targets, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source  string
+		options *Options
+	}{
+		{source: "targets", options: &Options{Targets: targets}},
+		{source: "list", options: &Options{Hosts: list}},
+		{source: "stdin", options: &Options{Hosts: stdinMarker}},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			stdin, err := os.Open(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdin.Close()
+			defer func(file *os.File) { os.Stdin = file }(os.Stdin)
+			os.Stdin = stdin
+
+			r := &Runner{options: test.options, hm: newTestHMap(t)}
+			r.prepareRun()
+			var got []string
+			if err := r.prepareInput(func(host, input string) {
+				got = append(got, host)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != want {
+				t.Errorf("got %v, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+)
+
+var timingsHeader = []string{"host", "qtype", "resolver", "attempt", "rtt_ms", "rcode", "transport"}
+
+// timingsWriter writes one csv row per query sent
+type timingsWriter struct {
+	*attemptQueue
+	file *os.File
+	buf  *bufio.Writer
+	w    *csv.Writer
+}
+
+func newTimingsWriter(path string, flushInterval int) (*timingsWriter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(file, 1<<20)
+	t := &timingsWriter{file: file, buf: buf, w: csv.NewWriter(buf)}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		// nolint:errcheck
+		t.w.Write(timingsHeader)
+	}
+	t.attemptQueue = newAttemptQueue(func(timing dnsx.QueryTiming) {
+		// nolint:errcheck
+		t.w.Write(timingRow(timing))
+	}, t.flush, flushInterval)
+	return t, nil
+}
+
+func (t *timingsWriter) flush() {
+	t.w.Flush()
+	// nolint:errcheck
+	t.buf.Flush()
+}
+
+// timingRow returns the csv fields of the timing, failed queries have the error rcode
+func timingRow(timing dnsx.QueryTiming) []string {
+	return []string{
+		strings.TrimSuffix(timing.Name, "."),
+		dns.TypeToString[timing.Type],
+		timing.Resolver,
+		strconv.Itoa(timing.Attempt),
+		formatRTT(timing.RTT),
+		timingRcode(timing),
+		timing.Transport,
+	}
+}
+
+// timingRcode returns the response code name of the timing, error when no response was received
+func timingRcode(timing dnsx.QueryTiming) string {
+	if timing.Rcode < 0 {
+		return "error"
+	}
+	return dns.RcodeToString[timing.Rcode]
+}
+
+// formatRTT returns the round-trip time in milliseconds with microseconds precision
+func formatRTT(rtt time.Duration) string {
+	return strconv.FormatFloat(float64(rtt)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// Close writes the queued rows and closes the file, the dropped rows are reported
+func (t *timingsWriter) Close() error {
+	if dropped := t.close(); dropped > 0 {
+		gologger.Warning().Msgf("Dropped %d timing rows, the disk couldn't keep up with the queries\n", dropped)
+	}
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		t.file.Close()
+		return err
+	}
+	if err := t.buf.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+)
+
+// bundledTLDs is the list of top level domains used unless tld-list is set
+//
+//go:embed tlds.txt
+var bundledTLDs []byte
+
+// tldListTimeout bounds the download of a remote tld list
+const tldListTimeout = time.Minute
+
+// loadTLDs returns the top level domains of the file or url (the iana tlds-alpha-by-domain.txt
+// format), the bundled list when the location is empty
+func loadTLDs(location string) ([]string, error) {
+	var input io.Reader
+	switch {
+	case location == "":
+		input = bytes.NewReader(bundledTLDs)
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		client := &http.Client{Timeout: tldListTimeout}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		input = resp.Body
+	default:
+		f, err := openFile(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	var tlds []string
+	seen := make(map[string]struct{})
+	sc := bufio.NewScanner(input)
+	for sc.Scan() {
+		tld := strings.ToLower(strings.Trim(strings.TrimSpace(sc.Text()), "."))
+		if tld == "" || strings.HasPrefix(tld, "#") {
+			continue
+		}
+		if _, ok := seen[tld]; ok {
+			continue
+		}
+		seen[tld] = struct{}{}
+		tlds = append(tlds, tld)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(tlds) == 0 {
+		return nil, errors.New("empty tld list")
+	}
+	return tlds, nil
+}
+
+// tldName returns the name whose tlds are enumerated, the label of the registered domain
+// for the inputs having a public suffix (www.example.co.uk is enumerated as example)
+func tldName(target string) string {
+	target = strings.ToLower(strings.Trim(target, "."))
+	if !strings.Contains(target, ".") {
+		return target
+	}
+	apex, err := publicsuffix.EffectiveTLDPlusOne(target)
+	if err != nil {
+		return target[:strings.Index(target, ".")]
+	}
+	return apex[:strings.Index(apex, ".")]
+}
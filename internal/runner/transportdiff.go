@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"sort"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+)
+
+// transportDiff resolves the host again over tcp and compares the answers with the udp ones,
+// the order of the records and ttl differences within the tolerance are ignored
+func (r *Runner) transportDiff(result *dnsResult, metadata *dnsx.Metadata) {
+	r.takeLimiter()
+	_, tcpMetadata, err := r.dnsx.QueryMultipleWithTransport(result.Host, dnsx.TransportTCP)
+	if err != nil {
+		gologger.Debug().Msgf("Could not resolve %s over tcp: %s\n", result.Host, err)
+	}
+	var tcpAnswers []dns.RR
+	if tcpMetadata != nil {
+		tcpAnswers = tcpMetadata.Answers
+		if r.options.ShowStatistics {
+			r.stats.IncrementCounter("queries", tcpMetadata.Queries)
+			r.stats.IncrementCounter("retries", tcpMetadata.Retries)
+		}
+	}
+
+	result.TransportAnswers = map[string][]string{
+		dnsx.TransportUDP: answerStrings(metadata.Answers),
+		dnsx.TransportTCP: answerStrings(tcpAnswers),
+	}
+	result.TransportDiff = !sameAnswers(metadata.Answers, tcpAnswers, uint32(r.options.TransportDiffTTL))
+}
+
+// sameAnswers reports whether both sets have the same records with ttls within the tolerance
+func sameAnswers(a, b []dns.RR, ttlTolerance uint32) bool {
+	ttlsA, ttlsB := answerTTLs(a), answerTTLs(b)
+	if len(ttlsA) != len(ttlsB) {
+		return false
+	}
+	for record, ttlA := range ttlsA {
+		ttlB, ok := ttlsB[record]
+		if !ok {
+			return false
+		}
+		if ttlA > ttlB {
+			ttlA, ttlB = ttlB, ttlA
+		}
+		if ttlB-ttlA > ttlTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// answerTTLs maps the records without their ttl to the ttl
+func answerTTLs(answers []dns.RR) map[string]uint32 {
+	ttls := make(map[string]uint32, len(answers))
+	for _, answer := range answers {
+		record := dns.Copy(answer)
+		record.Header().Ttl = 0
+		ttls[record.String()] = answer.Header().Ttl
+	}
+	return ttls
+}
+
+func answerStrings(answers []dns.RR) []string {
+	values := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		values = append(values, answer.String())
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/gologger"
+)
+
+// ttlChange is emitted when the ttl of a record set changes above the threshold or its
+// records change, the previous records are only set in the latter case
+type ttlChange struct {
+	Host            string            `json:"host"`
+	Type            string            `json:"type"`
+	TTL             uint32            `json:"ttl"`
+	PreviousTTL     uint32            `json:"previous_ttl"`
+	Records         []string          `json:"records,omitempty"`
+	PreviousRecords []string          `json:"previous_records,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// ttlRecordSet is the last answer seen for a record set. The ttls served by a caching
+// resolver count down between the passes, so the highest ttl seen for the same records
+// is kept as the configured one.
+type ttlRecordSet struct {
+	records []string
+	ttl     uint32
+}
+
+// observe updates the record set with a new answer and returns the change to report: the
+// records differ, or the ttl grew above the highest one seen by more than the threshold.
+// Lower ttls of the same records are the countdown of the caches and aren't reported.
+func (s *ttlRecordSet) observe(records []string, ttl uint32, threshold int) *ttlChange {
+	var change *ttlChange
+	switch {
+	case !stringsEqual(s.records, records):
+		change = &ttlChange{TTL: ttl, PreviousTTL: s.ttl, Records: records, PreviousRecords: s.records}
+		s.records, s.ttl = records, ttl
+	case ttl > s.ttl:
+		if ttlChanged(s.ttl, ttl, threshold) {
+			change = &ttlChange{TTL: ttl, PreviousTTL: s.ttl, Records: records}
+		}
+		s.ttl = ttl
+	}
+	return change
+}
+
+// runTTLWatch periodically re-queries the input hosts and outputs significant ttl changes,
+// until the number of passes of ttl-watch-passes is reached or the run is stopped
+func (r *Runner) runTTLWatch() error {
+	if err := r.prepareInput(nil); err != nil {
+		return err
+	}
+	if err := r.startOutputWorker(); err != nil {
+		return err
+	}
+	defer r.closeOutputWorker()
+
+	var mutex sync.Mutex
+	previous := make(map[string]*ttlRecordSet)
+	for pass := 1; ; pass++ {
+		if r.zoneSerials != nil {
+			r.refreshZoneSerials()
+		}
+		hosts := make(chan string)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < r.options.Threads; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for host := range hosts {
+					if r.ctx.Err() != nil {
+						continue
+					}
+					if r.zoneSerials != nil && r.zoneSerials.skip(host) {
+						continue
+					}
+					fingerprint, failed := fnv.New64a(), false
+					for _, questionType := range r.dnsx.Options.QuestionTypes {
+						r.takeLimiter()
+						msg, err := r.dnsx.QueryMsg(host, questionType)
+						if err != nil || msg == nil {
+							failed = true
+							continue
+						}
+						writeAnswers(fingerprint, questionType, msg.Answer)
+						ttl, ok := lowestTTL(msg.Answer, questionType)
+						if !ok {
+							continue
+						}
+						key := host + ":" + dns.TypeToString[questionType]
+						records := answerValues(msg.Answer, questionType)
+						var change *ttlChange
+						mutex.Lock()
+						if set, seen := previous[key]; seen {
+							change = set.observe(records, ttl, r.options.TTLThreshold)
+						} else {
+							previous[key] = &ttlRecordSet{records: records, ttl: ttl}
+						}
+						mutex.Unlock()
+						if change != nil {
+							change.Host, change.Type, change.Timestamp, change.Labels = host, dns.TypeToString[questionType], time.Now(), r.labels
+							r.outputTTLChange(change)
+						}
+					}
+					if r.zoneSerials != nil && !failed {
+						r.zoneSerials.observe(host, fingerprint.Sum64())
+						r.learnZone(host)
+					}
+				}
+			}()
+		}
+		r.hm.Scan(func(k, _ []byte) error {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			hosts <- string(k)
+			return nil
+		})
+		close(hosts)
+		wg.Wait()
+
+		if r.zoneSerials != nil {
+			gologger.Info().Msgf("TTL watch pass skipped %d hosts of zones with an unchanged serial\n", r.zoneSerials.skippedHosts())
+		}
+		if r.options.TTLWatchPasses > 0 && pass >= r.options.TTLWatchPasses {
+			return nil
+		}
+		gologger.Verbose().Msgf("TTL watch pass completed, next one in %s\n", r.options.ttlWatchInterval)
+		next := time.NewTimer(r.options.ttlWatchInterval)
+		select {
+		case <-r.ctx.Done():
+			next.Stop()
+			return nil
+		case <-next.C:
+		}
+	}
+}
+
+// answerValues returns the sorted values of the answers of the given type
+func answerValues(answers []dns.RR, questionType uint16) []string {
+	var values []string
+	for _, rr := range answers {
+		if rr.Header().Rrtype == questionType {
+			values = append(values, strings.TrimPrefix(rr.String(), rr.Header().String()))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// lowestTTL returns the lowest ttl among the answers of the given type
+func lowestTTL(answers []dns.RR, questionType uint16) (uint32, bool) {
+	var (
+		ttl   uint32 = math.MaxUint32
+		found bool
+	)
+	for _, rr := range answers {
+		if rr.Header().Rrtype != questionType {
+			continue
+		}
+		found = true
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return ttl, found
+}
+
+func (r *Runner) outputTTLChange(change *ttlChange) {
+	if r.options.JSON {
+		data, err := json.Marshal(change)
+		if err == nil {
+			r.output(string(data))
+		}
+		return
+	}
+	line := change.Host + r.field(change.Type) + r.field(fmt.Sprintf("%d -> %d", change.PreviousTTL, change.TTL))
+	if change.PreviousRecords != nil {
+		line += r.field(strings.Join(change.Records, Comma))
+	}
+	r.output(line)
+}
+
+// ttlChanged reports whether the ttl varied by more than threshold percent
+func ttlChanged(previous, current uint32, threshold int) bool {
+	if previous == current {
+		return false
+	}
+	if previous == 0 {
+		return true
+	}
+	delta := math.Abs(float64(current) - float64(previous))
+	return delta/float64(previous)*100 > float64(threshold)
+}
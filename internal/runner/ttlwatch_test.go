@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestTTLRecordSetObserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		records     []string
+		ttl         uint32
+		wantChange  bool
+		wantRecords bool
+	}{
+		{name: "cache countdown", records: []string{"192.0.2.1"}, ttl: 120},
+		{name: "cache countdown below threshold", records: []string{"192.0.2.1"}, ttl: 10},
+		{name: "cache refresh", records: []string{"192.0.2.1"}, ttl: 300},
+		{name: "configured ttl raised", records: []string{"192.0.2.1"}, ttl: 3600, wantChange: true},
+		{name: "raise below threshold", records: []string{"192.0.2.1"}, ttl: 3700},
+		{name: "records rotated", records: []string{"192.0.2.2"}, ttl: 60, wantChange: true, wantRecords: true},
+		{name: "rotated records countdown", records: []string{"192.0.2.2"}, ttl: 30},
+	}
+	set := &ttlRecordSet{records: []string{"192.0.2.1"}, ttl: 300}
+	for _, test := range tests {
+		change := set.observe(test.records, test.ttl, 10)
+		if (change != nil) != test.wantChange {
+			t.Fatalf("%s: got change %+v, want change %v", test.name, change, test.wantChange)
+		}
+		if change != nil && (change.PreviousRecords != nil) != test.wantRecords {
+			t.Fatalf("%s: got previous records %v", test.name, change.PreviousRecords)
+		}
+	}
+}
+
+func TestAnswerValues(t *testing.T) {
+	var answers []dns.RR
+	for _, record := range []string{"example.com. 300 IN A 192.0.2.2", "example.com. 200 IN A 192.0.2.1", "example.com. 300 IN MX 10 mail.example.com."} {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		answers = append(answers, rr)
+	}
+	values := answerValues(answers, dns.TypeA)
+	if !stringsEqual(values, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Fatalf("got values %v", values)
+	}
+	if ttl, ok := lowestTTL(answers, dns.TypeA); !ok || ttl != 200 {
+		t.Fatalf("got lowest ttl %d", ttl)
+	}
+}
+
+func TestTTLChanged(t *testing.T) {
+	tests := []struct {
+		previous, current uint32
+		threshold         int
+		want              bool
+	}{
+		{previous: 300, current: 300, threshold: 10},
+		{previous: 300, current: 320, threshold: 10},
+		{previous: 300, current: 340, threshold: 10, want: true},
+		{previous: 0, current: 1, threshold: 10, want: true},
+	}
+	for _, test := range tests {
+		if got := ttlChanged(test.previous, test.current, test.threshold); got != test.want {
+			t.Errorf("ttlChanged(%d, %d, %d) = %v, want %v", test.previous, test.current, test.threshold, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// uniqueIPLines returns the A and AAAA values of the response not displayed yet. The answers
+// of the wildcard probes are excluded as they are a catch-all rather than a real host.
+func (r *Runner) uniqueIPLines(dnsData *retryabledns.DNSData) []string {
+	var lines []string
+	for _, records := range [][]string{dnsData.A, dnsData.AAAA} {
+		for _, ip := range records {
+			if r.wildcards != nil && r.wildcards.IsWildcardAnswer(ip) {
+				continue
+			}
+			if r.isPrevious(ip) {
+				continue
+			}
+			if _, seen := r.uniqueips.LoadOrStore(ip, struct{}{}); !seen {
+				lines = append(lines, ip)
+			}
+		}
+	}
+	return lines
+}
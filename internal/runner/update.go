@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+// parseUpdateRecord parses a record of a dynamic update in the zone file format, the class
+// defaults to IN and the ttl to 3600. Records of the delete set may omit the data to delete
+// a whole rrset ("name A") or use the ANY type to delete every rrset of the name.
+func parseUpdateRecord(value string) (dns.RR, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 1 {
+		value += " ANY"
+	}
+	rr, err := dns.NewRR(value)
+	if err != nil {
+		return nil, err
+	}
+	if rr == nil {
+		return nil, errors.New("empty record")
+	}
+	return rr, nil
+}
+
+// runUpdate sends the dynamic update built from the options to the first resolver
+func (r *Runner) runUpdate() error {
+	zone := r.options.DNSUpdateZone
+	if zone == "" {
+		records := append(append([]dns.RR{}, r.options.updateInsert...), r.options.updateRemove...)
+		zone = apexDomain(records[0].Header().Name)
+	}
+	if err := r.dnsx.Update(zone, r.options.updateInsert, r.options.updateRemove, r.options.tsigKey); err != nil {
+		return errors.Wrapf(err, "could not update zone %s", zone)
+	}
+	gologger.Info().Msgf("Zone %s updated: %d records added, %d deleted\n", zone, len(r.options.updateInsert), len(r.options.updateRemove))
+	return nil
+}
@@ -2,11 +2,25 @@ package runner
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
 )
 
 const (
@@ -17,7 +31,7 @@ const (
 
 func linesInFile(fileName string) ([]string, error) {
 	result := []string{}
-	f, err := os.Open(fileName)
+	f, err := openFile(fileName)
 	if err != nil {
 		return result, err
 	}
@@ -54,6 +68,29 @@ func extractDomain(URL string) string {
 	return u.Hostname()
 }
 
+// unbracketIP removes the brackets around IPv6 addresses, as written in urls
+func unbracketIP(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") && net.ParseIP(host[1:len(host)-1]) != nil {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// splitHostPort separates the port of host:port inputs, urls and bare IPv6 addresses are returned as is
+func splitHostPort(item string) (host, port string) {
+	if !strings.Contains(item, ":") || isURL(item) {
+		return item, ""
+	}
+	host, port, err := net.SplitHostPort(item)
+	if err != nil || host == "" {
+		return item, ""
+	}
+	if value, err := strconv.Atoi(port); err != nil || value < 0 || value > 65535 {
+		return item, ""
+	}
+	return host, port
+}
+
 func prepareResolver(resolver string) string {
 	resolver = strings.TrimSpace(resolver)
 	if !strings.Contains(resolver, ":") {
@@ -71,3 +108,169 @@ func fmtDuration(d time.Duration) string {
 	s := d / time.Second
 	return fmt.Sprintf("%d:%02d:%02d", h, m, s)
 }
+
+// resolversFingerprint returns an order independent hash of the resolvers list
+func resolversFingerprint(resolvers []string) string {
+	sorted := make([]string, len(resolvers))
+	copy(sorted, resolvers)
+	sort.Strings(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(sorted, Comma)))
+	return hex.EncodeToString(hash[:])
+}
+
+// parseZoneOverrides parses the suffix=resolver[,suffix=resolver] zone overrides,
+// the same suffix can be repeated to use multiple resolvers
+func parseZoneOverrides(value string) (map[string][]string, error) {
+	overrides := make(map[string][]string)
+	for _, item := range strings.Split(value, Comma) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		suffix := strings.ToLower(strings.Trim(strings.TrimSpace(parts[0]), "."))
+		if len(parts) != 2 || suffix == "" {
+			return nil, fmt.Errorf("invalid zone override %s (expected suffix=resolver)", item)
+		}
+		resolver := prepareResolver(parts[1])
+		host, _, err := net.SplitHostPort(resolver)
+		if err != nil || net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid resolver in zone override %s", item)
+		}
+		overrides[suffix] = append(overrides[suffix], resolver)
+	}
+	return overrides, nil
+}
+
+// compressedFile closes the decompressor along with the underlying file
+type compressedFile struct {
+	io.Reader
+	file  *os.File
+	close func()
+}
+
+func (c *compressedFile) Close() error {
+	if c.close != nil {
+		c.close()
+	}
+	return c.file.Close()
+}
+
+// openFile opens the file, gzip (.gz) and zstd (.zst) files are decompressed transparently.
+// Opening a named pipe (FIFO) waits for its writer, the lines are read as they're written.
+func openFile(fileName string) (io.ReadCloser, error) {
+	return openFileContext(context.Background(), fileName)
+}
+
+// openFileContext opens the file like openFile, the wait for the writer of a named pipe is
+// abandoned once the context is canceled
+func openFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	var (
+		f   *os.File
+		err error
+	)
+	if info, statErr := os.Stat(fileName); statErr == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		gologger.Verbose().Msgf("Waiting for a writer on named pipe %s\n", fileName)
+		f, err = openPipe(ctx, fileName)
+	} else {
+		f, err = os.Open(fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "could not read gzip file %s", fileName)
+		}
+		return &compressedFile{Reader: gz, file: f, close: func() { gz.Close() }}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "could not read zstd file %s", fileName)
+		}
+		return &compressedFile{Reader: zr, file: f, close: zr.Close}, nil
+	}
+	return f, nil
+}
+
+// newLineScanner returns a scanner of the lines of the reader, lines longer than maxLength
+// bytes are dropped with a warning instead of halting the scan
+func newLineScanner(reader io.Reader, maxLength int) *bufio.Scanner {
+	sc := bufio.NewScanner(reader)
+	if maxLength <= 0 {
+		return sc
+	}
+	// room for the line ending, a longer buffer without newline is an overlong line
+	maxBuffer := maxLength + 2
+	initialBuffer := 4096
+	if initialBuffer > maxBuffer {
+		initialBuffer = maxBuffer
+	}
+	sc.Buffer(make([]byte, 0, initialBuffer), maxBuffer)
+
+	skipping := false
+	sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			if skipping {
+				skipping = false
+				return i + 1, nil, nil
+			}
+			if len(bytes.TrimSuffix(data[:i], []byte{'\r'})) > maxLength {
+				gologger.Warning().Msgf("Skipping input line longer than %d bytes\n", maxLength)
+				return i + 1, nil, nil
+			}
+			return bufio.ScanLines(data, atEOF)
+		}
+		if skipping {
+			return len(data), nil, nil
+		}
+		if len(data) > maxLength {
+			skipping = true
+			gologger.Warning().Msgf("Skipping input line longer than %d bytes\n", maxLength)
+			return len(data), nil, nil
+		}
+		if atEOF {
+			return bufio.ScanLines(data, atEOF)
+		}
+		return 0, nil, nil
+	})
+	return sc
+}
+
+func isValidPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// parsePortRange parses a port range in the start-end format
+func parsePortRange(value string) (int, int, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.Errorf("invalid port range %s (expected start-end)", value)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || !isValidPort(start) {
+		return 0, 0, errors.Errorf("invalid start port in range %s", value)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || !isValidPort(end) || end < start {
+		return 0, 0, errors.Errorf("invalid end port in range %s", value)
+	}
+	return start, end, nil
+}
+
+// stringsEqual reports whether the slices hold the same values in the same order
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,71 +1,151 @@
 package runner
 
 import (
+	"bytes"
 	"strings"
 
-	"github.com/rs/xid"
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
+	retryabledns "github.com/projectdiscovery/retryabledns"
 )
 
 // IsWildcard checks if a host is wildcard
 func (r *Runner) IsWildcard(host string) bool {
-	orig := make(map[string]struct{})
-	wildcards := make(map[string]struct{})
+	isWildcard, _, err := r.wildcards.IsWildcard(host, nil)
+	if err != nil {
+		gologger.Debug().Msgf("Could not check wildcard for %s: %s\n", host, err)
+	}
+	return isWildcard
+}
+
+// wildcardMarker marks a host verified as wildcard
+var wildcardMarker = []byte{1}
 
-	in, err := r.dnsx.QueryOne(host)
-	if err != nil || in == nil {
-		return false
+// inheritedKeyPrefix namespaces the record types inherited by a host in the wildcard map
+const inheritedKeyPrefix = "inherited:"
+
+// checkInheritedRecords stores the record types the host inherits from a wildcard
+func (r *Runner) checkInheritedRecords(host string, metadata *dnsx.Metadata) {
+	types, err := r.wildcards.InheritsRecords(host, metadata.Answers)
+	if err != nil {
+		gologger.Debug().Msgf("Could not check inherited records for %s: %s\n", host, err)
+		return
 	}
-	for _, A := range in.A {
-		orig[A] = struct{}{}
+	if len(types) == 0 {
+		return
 	}
+	var names []string
+	for _, questionType := range types {
+		names = append(names, dns.TypeToString[questionType])
+	}
+	// nolint:errcheck
+	r.wildcardhm.Set(inheritedKeyPrefix+host, []byte(strings.Join(names, Comma)))
+}
 
-	subdomainPart := strings.TrimSuffix(host, "."+r.options.WildcardDomain)
-	subdomainTokens := strings.Split(subdomainPart, ".")
-
-	// Build an array by preallocating a slice of a length
-	// and create the wildcard generation prefix.
-	// We use a rand prefix at the beginning like %rand%.domain.tld
-	// A permutation is generated for each level of the subdomain.
-	var hosts []string
-	hosts = append(hosts, r.options.WildcardDomain)
+// filterWildcards removes wildcard subdomains from the results stored in the hybrid map,
+// the hosts are streamed from disk on each pass
+func (r *Runner) filterWildcards() error {
+	gologger.Print().Msgf("Starting to filter wildcard subdomains\n")
 
-	if len(subdomainTokens) > 0 {
-		for i := 1; i < len(subdomainTokens); i++ {
-			newhost := strings.Join(subdomainTokens[i:], ".") + "." + r.options.WildcardDomain
-			hosts = append(hosts, newhost)
-		}
+	// wildcard workers
+	for i := 0; i < r.options.Threads; i++ {
+		r.wgwildcardworker.Add(1)
+		go r.wildcardWorker()
 	}
+	r.wildcardCandidates(func(host string) {
+		select {
+		case r.wildcardworkerchan <- host:
+		case <-r.ctx.Done():
+		}
+	})
+	close(r.wildcardworkerchan)
+	r.wgwildcardworker.Wait()
 
-	// Iterate over all the hosts generated for rand.
-	for _, h := range hosts {
-		r.wildcardscachemutex.Lock()
-		listip, ok := r.wildcardscache[h]
-		r.wildcardscachemutex.Unlock()
-		if !ok {
-			in, err := r.dnsx.QueryOne(xid.New().String() + "." + h)
-			if err != nil || in == nil {
-				continue
+	// we need to restart output
+	if err := r.startOutputWorker(); err != nil {
+		return err
+	}
+	numRemovedSubdomains := 0
+	r.hm.Scan(func(k, v []byte) error {
+		if len(uniqueARecords(v)) == 0 {
+			return nil
+		}
+		host := string(k)
+		if host != r.options.WildcardDomain {
+			if verdict, ok := r.wildcardhm.Get(host); ok && bytes.Equal(verdict, wildcardMarker) {
+				numRemovedSubdomains++
+				return nil
 			}
-			listip = in.A
-			r.wildcardscachemutex.Lock()
-			r.wildcardscache[h] = in.A
-			r.wildcardscachemutex.Unlock()
 		}
+		if !r.options.UniqueIPs && !r.options.SummarizeCIDRs && r.isPrevious(host) {
+			return nil
+		}
+		var dnsdata retryabledns.DNSData
+		if err := dnsdata.Unmarshal(v); err != nil {
+			r.output(host)
+			return nil
+		}
+		if r.hostsOutput != nil {
+			r.hostsOutput.write(host, &dnsdata)
+		}
+		result := r.newResult(&dnsdata)
+		line := host
+		if inherited, ok := r.wildcardhm.Get(inheritedKeyPrefix + host); ok {
+			result.WildcardInherited = true
+			result.WildcardInheritedTypes = strings.Split(string(inherited), Comma)
+			line += r.field("wildcard-inherited:" + string(inherited))
+		}
+		lines := []string{line}
+		if r.options.UniqueIPs || r.options.SummarizeCIDRs {
+			lines = r.uniqueIPLines(&dnsdata)
+		}
+		event := &outputEvent{result: result, status: statusMatched, lines: lines}
+		if r.options.outputsZone {
+			event.zone = r.zoneRecords(&dnsdata, nil)
+		}
+		r.emit(event)
+		return nil
+	})
+	r.closeOutputWorker()
+	gologger.Print().Msgf("%d wildcard subdomains removed\n", numRemovedSubdomains)
+	return nil
+}
 
-		// Get all the records and add them to the wildcard map
-		for _, A := range listip {
-			if _, ok := wildcards[A]; !ok {
-				wildcards[A] = struct{}{}
-			}
+// wildcardCandidates emits the hosts having at least one IP shared by the threshold number
+// of hosts. Only per-IP counters are kept in memory, hosts are streamed from disk twice.
+func (r *Runner) wildcardCandidates(emit func(host string)) {
+	// first pass: count the number of distinct hosts pointing to each IP
+	candidates := r.wildcards.NewCandidates()
+	r.hm.Scan(func(k, v []byte) error {
+		candidates.Add(uniqueARecords(v))
+		return nil
+	})
+
+	// second pass: emit hosts having at least one IP exceeding the threshold
+	r.hm.Scan(func(k, v []byte) error {
+		if candidates.IsCandidate(uniqueARecords(v)) {
+			emit(string(k))
 		}
-	}
+		return nil
+	})
+}
 
-	// check if original ip are among wildcards
-	for a := range orig {
-		if _, ok := wildcards[a]; ok {
-			return true
+// uniqueARecords returns the deduplicated A records of the marshaled dns data
+func uniqueARecords(v []byte) []string {
+	var dnsdata retryabledns.DNSData
+	if err := dnsdata.Unmarshal(v); err != nil {
+		// the item has no record - ignore
+		return nil
+	}
+	seen := make(map[string]struct{}, len(dnsdata.A))
+	var records []string
+	for _, a := range dnsdata.A {
+		if _, ok := seen[a]; ok {
+			continue
 		}
+		seen[a] = struct{}{}
+		records = append(records, a)
 	}
-
-	return false
+	return records
 }
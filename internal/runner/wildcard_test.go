@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/wildcards"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// newTestHMap returns a disk hybrid map removed at the end of the test
+func newTestHMap(tb testing.TB) *hybrid.HybridMap {
+	tb.Helper()
+	options := hybrid.DefaultDiskOptions
+	options.Path = tb.TempDir()
+	hm, err := hybrid.New(options)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { hm.Close() })
+	return hm
+}
+
+// storeResult stores the A records of the host like the resolve workers do
+func storeResult(tb testing.TB, hm *hybrid.HybridMap, host string, ips ...string) {
+	tb.Helper()
+	data, err := (&retryabledns.DNSData{Host: host, A: ips}).Marshal()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := hm.Set(host, data); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func newWildcardTestRunner(tb testing.TB, threshold int) *Runner {
+	return &Runner{
+		options:   &Options{},
+		hm:        newTestHMap(tb),
+		wildcards: &wildcards.Detector{Options: wildcards.Options{Threshold: threshold}},
+	}
+}
+
+func TestWildcardCandidates(t *testing.T) {
+	r := newWildcardTestRunner(t, 3)
+	for i := 0; i < 3; i++ {
+		storeResult(t, r.hm, fmt.Sprintf("w%d.example.com", i), "192.0.2.1", fmt.Sprintf("198.51.100.%d", i))
+	}
+	storeResult(t, r.hm, "www.example.com", "192.0.2.2")
+	storeResult(t, r.hm, "mail.example.com", "192.0.2.2", "192.0.2.3")
+	storeResult(t, r.hm, "empty.example.com")
+
+	var got []string
+	r.wildcardCandidates(func(host string) {
+		got = append(got, host)
+	})
+	sort.Strings(got)
+	want := []string{"w0.example.com", "w1.example.com", "w2.example.com"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got candidates %v, want %v", got, want)
+	}
+}
+
+// BenchmarkWildcardCandidates streams 10k stored hosts sharing 500 IPs, the memory held
+// between the passes is the per-IP counters only
+func BenchmarkWildcardCandidates(b *testing.B) {
+	r := newWildcardTestRunner(b, 5)
+	for i := 0; i < 10000; i++ {
+		storeResult(b, r.hm, fmt.Sprintf("host%d.example.com", i), fmt.Sprintf("10.0.%d.%d", i%500/250, i%250))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var candidates int
+		r.wildcardCandidates(func(string) {
+			candidates++
+		})
+		if candidates != 10000 {
+			b.Fatalf("got %d candidates, want 10000", candidates)
+		}
+	}
+}
+
+// answerWildcard answers every name of example.com with 192.0.2.1 except www.example.com
+// having its own record
+func answerWildcard(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if question := req.Question[0]; question.Qtype == dns.TypeA && dns.IsSubDomain("example.com.", question.Name) {
+		ip := net.IPv4(192, 0, 2, 1)
+		if question.Name == "www.example.com." {
+			ip = net.IPv4(192, 0, 2, 5)
+		}
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   ip,
+		})
+	}
+	w.WriteMsg(resp) // nolint:errcheck
+}
+
+// TestWildcardStats is meant to be run with -race, the statistics are updated by the
+// wildcard workers while the wildcard phase removes the hosts
+func TestWildcardStats(t *testing.T) {
+	// the statistics are printed during the run
+	interval := statsInterval
+	statsInterval = 10 * time.Millisecond
+	defer func() {
+		statsInterval = interval
+	}()
+	server := newTestDNSServer(t, answerWildcard)
+	targets := []string{"www.example.com"}
+	for i := 0; i < 50; i++ {
+		targets = append(targets, fmt.Sprintf("host%d.example.com", i))
+	}
+	var hosts []string
+	r := newConfiguredRunner(t, server, func(options *Options) {
+		options.Targets = targets
+		options.WildcardDomain = "example.com"
+		options.ShowStatistics = true
+	}, func(result *Result) {
+		hosts = append(hosts, result.Host)
+	})
+	defer r.Close()
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(hosts) != "[www.example.com]" {
+		t.Fatalf("got hosts %v, want www.example.com", hosts)
+	}
+	if removed, _ := r.stats.GetCounter("wildcard_removed"); removed != 50 {
+		t.Errorf("got %d wildcard hosts removed, want 50", removed)
+	}
+	if probes := r.wildcards.ProbeCount(); probes == 0 {
+		t.Error("no wildcard probe counted")
+	}
+}
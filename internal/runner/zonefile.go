@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zoneFileNames returns the unique record names of a BIND format zone file in order of appearance,
+// the relative names are completed with the origin
+func zoneFileNames(path, origin string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if origin == "" {
+		origin = zoneOrigin(path)
+	}
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+	var names []string
+	seen := make(map[string]struct{})
+	zp := dns.NewZoneParser(f, origin, path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := strings.ToLower(strings.TrimSuffix(rr.Header().Name, "."))
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names, zp.Err()
+}
+
+// zoneOrigin derives the origin of a zone file from its name (example.com.zone, db.example.com),
+// the $ORIGIN directives of the file take precedence. An empty origin is returned when the
+// name doesn't hold a domain.
+func zoneOrigin(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	for _, extension := range []string{".zone", ".db", ".txt"} {
+		name = strings.TrimSuffix(name, extension)
+	}
+	name = strings.TrimPrefix(name, "db.")
+	if !strings.Contains(name, ".") || strings.IndexFunc(name, notHostnameCharacter) >= 0 {
+		return ""
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return ""
+	}
+	return name
+}
+
+// notHostnameCharacter reports whether the character can't be part of a hostname
+func notHostnameCharacter(c rune) bool {
+	return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.')
+}
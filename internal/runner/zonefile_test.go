@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZoneOrigin(t *testing.T) {
+	tests := map[string]string{
+		"example.com.zone":          "example.com",
+		"/var/named/db.example.org": "example.org",
+		"example.net.db":            "example.net",
+		"zone.txt":                  "",
+		"db.local":                  "",
+		"bad name.com.zone":         "",
+	}
+	for path, want := range tests {
+		if got := zoneOrigin(path); got != want {
+			t.Errorf("zoneOrigin(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestZoneFileNames(t *testing.T) {
+	const relative = "$TTL 300\n@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300\n@ IN NS ns1\nns1 IN A 192.0.2.1\nwww IN CNAME @\nWWW IN A 192.0.2.2\nmail.example.net. IN A 192.0.2.3\n"
+	tests := []struct {
+		file    string
+		content string
+		origin  string
+		want    string
+		err     bool
+	}{
+		{file: "example.com.zone", content: relative, want: "[example.com ns1.example.com www.example.com mail.example.net]"},
+		{file: "zone.txt", content: relative, origin: "example.org", want: "[example.org ns1.example.org www.example.org mail.example.net]"},
+		{file: "example.com.zone", content: "$ORIGIN example.io.\n" + relative, want: "[example.io ns1.example.io www.example.io mail.example.net]"},
+		{file: "zone.txt", content: "www.example.com. 300 IN A 192.0.2.1\n", want: "[www.example.com]"},
+		{file: "zone.txt", content: relative, err: true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), test.file)
+		if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		names, err := zoneFileNames(path, test.origin)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: parsed relative names without origin", test.file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.file, err)
+		}
+		if got := fmt.Sprint(names); got != test.want {
+			t.Errorf("%s (origin %q): got %s, want %s", test.file, test.origin, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+const (
+	// defaultZoneTTL is the ttl of the zone output records unless the response ttl is requested
+	defaultZoneTTL = 300
+	// maxTXTSegment is the length limit of a character string of a TXT record
+	maxTXTSegment = 255
+)
+
+// zoneRecords returns the records of the response written in zone output. The answer records
+// are used when available, otherwise the A, AAAA, CNAME and TXT values are converted.
+func (r *Runner) zoneRecords(dnsData *retryabledns.DNSData, metadata *dnsx.Metadata) []dns.RR {
+	var records []dns.RR
+	if metadata != nil {
+		for _, rr := range metadata.Answers {
+			records = append(records, dns.Copy(rr))
+		}
+	} else {
+		records = dataRecords(dnsData)
+	}
+	for _, rr := range records {
+		// local answers have no ttl, the cached ones have the ttl left
+		if metadata != nil && metadata.Source == dnsx.SourceHostsFile {
+			rr.Header().Ttl = 0
+		}
+		if txt, ok := rr.(*dns.TXT); ok {
+			txt.Txt = splitTXT(txt.Txt)
+		}
+	}
+	return records
+}
+
+// zoneLines returns the records in the bind zone file format, the records without ttl or
+// all of them unless the response ttl is kept get the default ttl
+func zoneLines(records []dns.RR, responseTTL bool) []string {
+	var lines []string
+	seen := make(map[string]struct{})
+	for _, rr := range records {
+		if !responseTTL || rr.Header().Ttl == 0 {
+			rr = dns.Copy(rr)
+			rr.Header().Ttl = defaultZoneTTL
+		}
+		line := rr.String()
+		if _, ok := seen[line]; ok {
+			continue
+		}
+		seen[line] = struct{}{}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// formatZoneRecords returns the records in the presentation format, their ttl unchanged
+func formatZoneRecords(records []dns.RR) []string {
+	var values []string
+	for _, rr := range records {
+		values = append(values, rr.String())
+	}
+	return values
+}
+
+// parseZoneRecords parses the records of formatZoneRecords, the invalid ones are skipped
+func parseZoneRecords(values []string) []dns.RR {
+	var records []dns.RR
+	for _, value := range values {
+		if rr, err := dns.NewRR(value); err == nil && rr != nil {
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// dataRecords converts the values of the dns data to records, they have no ttl
+func dataRecords(dnsData *retryabledns.DNSData) []dns.RR {
+	header := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: dns.Fqdn(dnsData.Host), Rrtype: rrtype, Class: dns.ClassINET}
+	}
+	var records []dns.RR
+	for _, value := range dnsData.A {
+		if ip := net.ParseIP(value).To4(); ip != nil {
+			records = append(records, &dns.A{Hdr: header(dns.TypeA), A: ip})
+		}
+	}
+	for _, value := range dnsData.AAAA {
+		if ip := net.ParseIP(value); ip != nil {
+			records = append(records, &dns.AAAA{Hdr: header(dns.TypeAAAA), AAAA: ip})
+		}
+	}
+	for _, value := range dnsData.CNAME {
+		records = append(records, &dns.CNAME{Hdr: header(dns.TypeCNAME), Target: dns.Fqdn(value)})
+	}
+	for _, value := range dnsData.TXT {
+		records = append(records, &dns.TXT{Hdr: header(dns.TypeTXT), Txt: []string{value}})
+	}
+	return records
+}
+
+// splitTXT splits the strings longer than the character string limit into several strings.
+// The strings are in the escaped presentation format: they're split between the escapes of
+// their wire bytes, so that neither the wire nor the escaped segments exceed the limit as the
+// zone parser of miekg/dns splits the longer quoted strings as they are.
+func splitTXT(values []string) []string {
+	var segments []string
+	for _, value := range values {
+		var segment strings.Builder
+		for _, b := range unescapeTXT(value) {
+			escaped := escapeTXT([]byte{b})
+			if segment.Len()+len(escaped) > maxTXTSegment {
+				segments = append(segments, segment.String())
+				segment.Reset()
+			}
+			segment.WriteString(escaped)
+		}
+		segments = append(segments, segment.String())
+	}
+	return segments
+}
+
+// unescapeTXT returns the wire bytes of a character string in the presentation format,
+// the \DDD escapes are decimal bytes and the other escaped characters stand for themselves
+func unescapeTXT(value string) []byte {
+	data := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			data = append(data, value[i])
+			continue
+		}
+		if i+3 < len(value) && isDigit(value[i+1]) && isDigit(value[i+2]) && isDigit(value[i+3]) {
+			if b, err := strconv.Atoi(value[i+1 : i+4]); err == nil && b <= 255 {
+				data = append(data, byte(b))
+				i += 3
+				continue
+			}
+		}
+		data = append(data, value[i+1])
+		i++
+	}
+	return data
+}
+
+// escapeTXT returns the presentation format of the bytes of a character string like the
+// one of the records unpacked by miekg/dns
+func escapeTXT(data []byte) string {
+	var builder strings.Builder
+	for _, b := range data {
+		switch {
+		case b == '"' || b == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(b)
+		case b < ' ' || b > '~':
+			fmt.Fprintf(&builder, "\\%03d", b)
+		default:
+			builder.WriteByte(b)
+		}
+	}
+	return builder.String()
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
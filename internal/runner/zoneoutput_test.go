@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+func TestSplitTXT(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "short", value: "v=spf1 -all", want: []string{"v=spf1 -all"}},
+		{name: "exact limit", value: strings.Repeat("a", 255), want: []string{strings.Repeat("a", 255)}},
+		// the escapes are never cut in half
+		{name: "escaped quote at the limit", value: strings.Repeat("a", 254) + `\"b`, want: []string{strings.Repeat("a", 254), `\"b`}},
+		{name: "decimal escape at the limit", value: strings.Repeat("a", 250) + `\200\201`, want: []string{strings.Repeat("a", 250) + `\200`, `\201`}},
+		{name: "escaped wire bytes", value: strings.Repeat(`\200`, 70), want: []string{strings.Repeat(`\200`, 63), strings.Repeat(`\200`, 7)}},
+		{name: "escaped characters", value: `\;\a`, want: []string{";a"}},
+	}
+	for _, test := range tests {
+		got := splitTXT([]string{test.value})
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// TestZoneLinesParse re-parses the zone output with the zone parser of miekg/dns, the TXT
+// values longer than a character string keep their bytes once split
+func TestZoneLinesParse(t *testing.T) {
+	long := strings.Repeat("a", 254) + `\"\200` + strings.Repeat("b", 300)
+	answers := parseRRs(t,
+		"www.example.com. 60 IN CNAME web.example.com.",
+		"web.example.com. 120 IN A 192.0.2.1",
+		"web.example.com. 120 IN A 192.0.2.1",
+	)
+	answers = append(answers, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 30},
+		Txt: []string{long},
+	})
+	r := &Runner{options: &Options{}}
+	records := r.zoneRecords(&retryabledns.DNSData{Host: "www.example.com"}, &dnsx.Metadata{Source: dnsx.SourceNetwork, Answers: answers})
+
+	tests := []struct {
+		responseTTL bool
+		ttls        []uint32
+	}{
+		{responseTTL: false, ttls: []uint32{defaultZoneTTL, defaultZoneTTL, defaultZoneTTL}},
+		{responseTTL: true, ttls: []uint32{60, 120, 30}},
+	}
+	for _, test := range tests {
+		lines := zoneLines(records, test.responseTTL)
+		parser := dns.NewZoneParser(strings.NewReader(strings.Join(lines, "\n")+"\n"), "", "")
+		var parsed []dns.RR
+		for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+			parsed = append(parsed, rr)
+		}
+		if err := parser.Err(); err != nil {
+			t.Fatalf("could not parse %q: %s", lines, err)
+		}
+		if len(parsed) != len(test.ttls) {
+			t.Fatalf("got records %v, want %d", parsed, len(test.ttls))
+		}
+		for i, rr := range parsed {
+			if rr.Header().Ttl != test.ttls[i] {
+				t.Errorf("got ttl %d for %s, want %d", rr.Header().Ttl, rr, test.ttls[i])
+			}
+		}
+
+		// the record packs, which fails for strings over 255 bytes, and keeps the bytes of the value
+		txt := parsed[2].(*dns.TXT)
+		msg := new(dns.Msg)
+		msg.Answer = []dns.RR{txt}
+		if _, err := msg.Pack(); err != nil {
+			t.Fatalf("could not pack %s: %s", txt, err)
+		}
+		var data []byte
+		for _, segment := range txt.Txt {
+			data = append(data, unescapeTXT(segment)...)
+		}
+		if !bytes.Equal(data, unescapeTXT(long)) {
+			t.Errorf("got TXT bytes %q, want %q", data, unescapeTXT(long))
+		}
+	}
+}
+
+func TestZoneRecordsLocal(t *testing.T) {
+	answers := parseRRs(t, "www.example.com. 0 IN A 192.0.2.1")
+	r := &Runner{options: &Options{}}
+	records := r.zoneRecords(&retryabledns.DNSData{Host: "www.example.com"}, &dnsx.Metadata{Source: dnsx.SourceHostsFile, Answers: answers})
+	// local answers have no ttl, the default one is written
+	if lines := zoneLines(records, true); len(lines) != 1 || lines[0] != "www.example.com.\t300\tIN\tA\t192.0.2.1" {
+		t.Fatalf("got %q", lines)
+	}
+	// the records of the workers keep their ttl
+	if got := zoneLines(parseZoneRecords(formatZoneRecords(parseRRs(t, "www.example.com. 42 IN A 192.0.2.1"))), true); len(got) != 1 || !strings.Contains(got[0], "\t42\t") {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,2 @@
+// Package typo generates typosquatting permutations of domain names.
+package typo
@@ -0,0 +1,250 @@
+package typo
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Techniques used to generate the permutations
+const (
+	TechniqueSwap      = "swap"
+	TechniqueOmission  = "omission"
+	TechniqueHomoglyph = "homoglyph"
+	TechniqueTLDSwap   = "tld-swap"
+	// TechniqueRepetition and TechniqueHyphenation are only used for sld permutations
+	TechniqueRepetition  = "repetition"
+	TechniqueHyphenation = "hyphenation"
+)
+
+// DefaultTLDs contains the top level domains used for tld swapping
+var DefaultTLDs = []string{"com", "net", "org", "co", "io", "info", "biz", "app", "dev", "xyz"}
+
+// homoglyphs contains ascii lookalikes of common characters and sequences
+var homoglyphs = map[string][]string{
+	"a":  {"4"},
+	"b":  {"d", "6"},
+	"d":  {"b", "cl"},
+	"e":  {"3"},
+	"g":  {"q", "9"},
+	"i":  {"1", "l"},
+	"l":  {"1", "i"},
+	"m":  {"rn", "nn"},
+	"n":  {"m"},
+	"o":  {"0"},
+	"q":  {"g"},
+	"s":  {"5"},
+	"u":  {"v"},
+	"v":  {"u"},
+	"w":  {"vv"},
+	"z":  {"2"},
+	"rn": {"m"},
+	"vv": {"w"},
+	"cl": {"d"},
+}
+
+// Permutation is a generated domain along with the technique that produced it
+type Permutation struct {
+	Domain    string
+	Technique string
+}
+
+// Generate returns up to limit unique permutations of the given domain. The label
+// preceding the public suffix is mutated (example in www.example.co.uk), the tld swaps
+// replace the whole suffix. A limit <= 0 disables the limit.
+func Generate(domain string, tlds []string, limit int) []Permutation {
+	domain = normalize(domain)
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	if suffix == "" || suffix == domain {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(domain, "."+suffix), ".")
+	name := labels[len(labels)-1]
+	prefix := strings.Join(labels[:len(labels)-1], ".")
+
+	c := newCollector(domain, prefix, limit)
+	if !c.addAll(suffix, swaps(name), TechniqueSwap) ||
+		!c.addAll(suffix, omissions(name), TechniqueOmission) ||
+		!c.addAll(suffix, substitutions(name, homoglyphs), TechniqueHomoglyph) {
+		return c.permutations
+	}
+	for _, newTLD := range tlds {
+		if newTLD == suffix {
+			continue
+		}
+		if !c.add(name, newTLD, TechniqueTLDSwap) {
+			break
+		}
+	}
+	return c.permutations
+}
+
+// GenerateSLD returns up to limit unique permutations of the second level label of the
+// domain, the one preceding its public suffix (example in www.example.co.uk), the suffix is
+// kept. The lookalikes of the dictionary are used by the homoglyph substitutions, the
+// default ones when it's nil. A limit <= 0 disables the limit.
+func GenerateSLD(domain string, dictionary map[string][]string, limit int) []Permutation {
+	domain = normalize(domain)
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	if suffix == "" || suffix == domain {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(domain, "."+suffix), ".")
+	name := labels[len(labels)-1]
+	prefix := strings.Join(labels[:len(labels)-1], ".")
+	if dictionary == nil {
+		dictionary = homoglyphs
+	}
+
+	c := newCollector(domain, prefix, limit)
+	if c.addAll(suffix, substitutions(name, dictionary), TechniqueHomoglyph) &&
+		c.addAll(suffix, repetitions(name), TechniqueRepetition) &&
+		c.addAll(suffix, hyphenations(name), TechniqueHyphenation) &&
+		c.addAll(suffix, swaps(name), TechniqueSwap) {
+		c.addAll(suffix, omissions(name), TechniqueOmission)
+	}
+	return c.permutations
+}
+
+// ParseDictionary parses lookalike lines in the source:lookalike[,lookalike...] format (eg.
+// o:0 or m:rn,nn), empty lines and lines starting with # are skipped
+func ParseDictionary(data string) (map[string][]string, error) {
+	dictionary := make(map[string][]string)
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		source := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || source == "" {
+			return nil, fmt.Errorf("invalid dictionary line %d: %s (expected source:lookalike)", i+1, line)
+		}
+		for _, lookalike := range strings.Split(parts[1], ",") {
+			if lookalike = strings.ToLower(strings.TrimSpace(lookalike)); lookalike != "" {
+				dictionary[source] = append(dictionary[source], lookalike)
+			}
+		}
+	}
+	if len(dictionary) == 0 {
+		return nil, fmt.Errorf("empty dictionary")
+	}
+	return dictionary, nil
+}
+
+func normalize(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
+
+// collector accumulates the unique permutations having a valid mutated label
+type collector struct {
+	prefix       string
+	limit        int
+	seen         map[string]struct{}
+	permutations []Permutation
+}
+
+func newCollector(domain, prefix string, limit int) *collector {
+	return &collector{prefix: prefix, limit: limit, seen: map[string]struct{}{domain: {}}}
+}
+
+// add builds the domain of the mutated label and reports whether more permutations are accepted
+func (c *collector) add(name, suffix, technique string) bool {
+	if c.limit > 0 && len(c.permutations) >= c.limit {
+		return false
+	}
+	if !isValidLabel(name) {
+		return true
+	}
+	candidate := name + "." + suffix
+	if c.prefix != "" {
+		candidate = c.prefix + "." + candidate
+	}
+	if _, ok := c.seen[candidate]; ok {
+		return true
+	}
+	c.seen[candidate] = struct{}{}
+	c.permutations = append(c.permutations, Permutation{Domain: candidate, Technique: technique})
+	return true
+}
+
+func (c *collector) addAll(suffix string, names []string, technique string) bool {
+	for _, name := range names {
+		if !c.add(name, suffix, technique) {
+			return false
+		}
+	}
+	return true
+}
+
+// swaps transposes adjacent characters
+func swaps(name string) []string {
+	var results []string
+	for i := 0; i < len(name)-1; i++ {
+		if name[i] == name[i+1] {
+			continue
+		}
+		b := []byte(name)
+		b[i], b[i+1] = b[i+1], b[i]
+		results = append(results, string(b))
+	}
+	return results
+}
+
+// omissions removes one character at a time
+func omissions(name string) []string {
+	var results []string
+	for i := 0; i < len(name); i++ {
+		results = append(results, name[:i]+name[i+1:])
+	}
+	return results
+}
+
+// substitutions replaces one character or sequence with its lookalikes
+func substitutions(name string, dictionary map[string][]string) []string {
+	sizes := make(map[int]struct{})
+	for source := range dictionary {
+		sizes[len(source)] = struct{}{}
+	}
+	var results []string
+	for i := 0; i < len(name); i++ {
+		for size := 1; size <= len(name)-i; size++ {
+			if _, ok := sizes[size]; !ok {
+				continue
+			}
+			for _, replacement := range dictionary[name[i:i+size]] {
+				results = append(results, name[:i]+replacement+name[i+size:])
+			}
+		}
+	}
+	return results
+}
+
+// repetitions doubles one character at a time
+func repetitions(name string) []string {
+	var results []string
+	for i := 0; i < len(name); i++ {
+		results = append(results, name[:i+1]+name[i:])
+	}
+	return results
+}
+
+// hyphenations inserts a hyphen between two characters
+func hyphenations(name string) []string {
+	var results []string
+	for i := 1; i < len(name); i++ {
+		if name[i-1] == '-' || name[i] == '-' {
+			continue
+		}
+		results = append(results, name[:i]+"-"+name[i:])
+	}
+	return results
+}
+
+func isValidLabel(label string) bool {
+	if label == "" || len(label) > 63 {
+		return false
+	}
+	return !strings.HasPrefix(label, "-") && !strings.HasSuffix(label, "-")
+}
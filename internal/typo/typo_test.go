@@ -0,0 +1,119 @@
+package typo
+
+import (
+	"strings"
+	"testing"
+)
+
+func domains(permutations []Permutation, technique string) map[string]struct{} {
+	found := make(map[string]struct{})
+	for _, permutation := range permutations {
+		if technique == "" || permutation.Technique == technique {
+			found[permutation.Domain] = struct{}{}
+		}
+	}
+	return found
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		domain    string
+		technique string
+		want      []string
+		unwanted  []string
+	}{
+		{domain: "example.com", technique: TechniqueSwap, want: []string{"xeample.com", "exmaple.com", "exampel.com"}},
+		{domain: "example.com", technique: TechniqueOmission, want: []string{"xample.com", "exmple.com", "exampl.com"}},
+		{domain: "example.com", technique: TechniqueHomoglyph, want: []string{"3xample.com", "examp1e.com", "ex4mple.com"}},
+		{domain: "example.com", technique: TechniqueTLDSwap, want: []string{"example.net", "example.io"}, unwanted: []string{"example.com"}},
+		// the label before the public suffix is mutated, not the second level of the suffix
+		{domain: "www.example.co.uk", technique: TechniqueSwap, want: []string{"www.exmaple.co.uk"}, unwanted: []string{"www.example.oc.uk"}},
+		{domain: "www.example.co.uk", technique: TechniqueOmission, want: []string{"www.exampl.co.uk"}, unwanted: []string{"www.example.o.uk"}},
+		{domain: "www.example.co.uk", technique: TechniqueTLDSwap, want: []string{"www.example.com"}, unwanted: []string{"www.example.co.com"}},
+		{domain: "EXAMPLE.COM.", technique: TechniqueSwap, want: []string{"exmaple.com"}},
+	}
+	for _, test := range tests {
+		found := domains(Generate(test.domain, DefaultTLDs, 0), test.technique)
+		for _, want := range test.want {
+			if _, ok := found[want]; !ok {
+				t.Errorf("Generate(%s) %s: missing %s", test.domain, test.technique, want)
+			}
+		}
+		for _, unwanted := range test.unwanted {
+			if _, ok := found[unwanted]; ok {
+				t.Errorf("Generate(%s) %s: unexpected %s", test.domain, test.technique, unwanted)
+			}
+		}
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	for _, domain := range []string{"", "com", "co.uk"} {
+		if permutations := Generate(domain, DefaultTLDs, 0); len(permutations) != 0 {
+			t.Errorf("Generate(%q) = %v, want none", domain, permutations)
+		}
+	}
+}
+
+func TestGenerateLimit(t *testing.T) {
+	permutations := Generate("example.com", DefaultTLDs, 5)
+	if len(permutations) != 5 {
+		t.Fatalf("got %d permutations, want 5", len(permutations))
+	}
+	seen := make(map[string]struct{})
+	for _, permutation := range permutations {
+		if permutation.Domain == "example.com" {
+			t.Errorf("the domain itself was generated")
+		}
+		if _, ok := seen[permutation.Domain]; ok {
+			t.Errorf("duplicate permutation %s", permutation.Domain)
+		}
+		seen[permutation.Domain] = struct{}{}
+	}
+}
+
+func TestGenerateValidLabels(t *testing.T) {
+	// omissions and swaps of a hyphenated label must not produce labels starting or ending with a hyphen
+	for _, permutation := range Generate("a-b.com", DefaultTLDs, 0) {
+		label := strings.Split(permutation.Domain, ".")[0]
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			t.Errorf("invalid label in %s (%s)", permutation.Domain, permutation.Technique)
+		}
+	}
+}
+
+func TestGenerateSLD(t *testing.T) {
+	tests := []struct {
+		domain    string
+		technique string
+		want      []string
+	}{
+		{domain: "login.example.co.uk", technique: TechniqueHomoglyph, want: []string{"login.examp1e.co.uk"}},
+		{domain: "login.example.co.uk", technique: TechniqueRepetition, want: []string{"login.examplee.co.uk"}},
+		{domain: "login.example.co.uk", technique: TechniqueHyphenation, want: []string{"login.exam-ple.co.uk"}},
+		{domain: "example.com", technique: TechniqueSwap, want: []string{"exmaple.com"}},
+	}
+	for _, test := range tests {
+		found := domains(GenerateSLD(test.domain, nil, 0), test.technique)
+		for _, want := range test.want {
+			if _, ok := found[want]; !ok {
+				t.Errorf("GenerateSLD(%s) %s: missing %s", test.domain, test.technique, want)
+			}
+		}
+	}
+}
+
+func TestParseDictionary(t *testing.T) {
+	dictionary, err := ParseDictionary("# comment\no:0\n\nm: rn, nn\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dictionary["o"]) != 1 || len(dictionary["m"]) != 2 || dictionary["m"][1] != "nn" {
+		t.Fatalf("unexpected dictionary %v", dictionary)
+	}
+	for _, data := range []string{"", "# only comments", "novalue", ":0"} {
+		if _, err := ParseDictionary(data); err == nil {
+			t.Errorf("ParseDictionary(%q) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package dnsx
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// maxCacheTTL bounds how long a response is answered from the cache whatever its ttl
+const maxCacheTTL = time.Hour
+
+// responseCache keeps the merged responses of the queries until the smallest ttl of their
+// records expires, the least recently used ones are evicted once it's full
+type responseCache struct {
+	sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	key      string
+	dnsdata  *retryabledns.DNSData
+	metadata *Metadata
+	stored   time.Time
+	expires  time.Time
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{size: size, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// cacheKey identifies the questions of the name over the transport
+func cacheKey(hostname string, questionTypes []uint16, transport string) string {
+	var builder strings.Builder
+	builder.WriteString(strings.ToLower(miekgdns.Fqdn(hostname)))
+	for _, questionType := range questionTypes {
+		builder.WriteString("/" + miekgdns.TypeToString[questionType])
+	}
+	builder.WriteString("/" + transport)
+	return builder.String()
+}
+
+// get returns a copy of the cached response, the ttl of its records decreased by the time
+// spent in the cache
+func (c *responseCache) get(key string) (*retryabledns.DNSData, *Metadata, bool) {
+	c.Lock()
+	defer c.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	now := time.Now()
+	if !now.Before(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(element)
+	elapsed := uint32(now.Sub(entry.stored) / time.Second)
+	return cloneDNSData(entry.dnsdata), cloneMetadata(entry.metadata, elapsed), true
+}
+
+// set stores a copy of the response of successful or negative answers having a ttl,
+// the failures, truncated and spoofed responses are never cached
+func (c *responseCache) set(key string, dnsdata *retryabledns.DNSData, metadata *Metadata) {
+	if dnsdata.StatusCodeRaw != miekgdns.RcodeSuccess && dnsdata.StatusCodeRaw != miekgdns.RcodeNameError {
+		return
+	}
+	if metadata.Truncated || len(metadata.SpoofResponses) > 0 {
+		return
+	}
+	ttl, ok := responseTTL(metadata)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	entry := &cacheEntry{
+		key:      key,
+		dnsdata:  cloneDNSData(dnsdata),
+		metadata: cloneMetadata(metadata, 0),
+		stored:   now,
+		expires:  now.Add(ttl),
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// responseTTL returns the smallest ttl of the answers, or the negative ttl of the SOA record
+// of the authority section (RFC 2308) for responses without answers
+func responseTTL(metadata *Metadata) (time.Duration, bool) {
+	var (
+		ttl   uint32
+		found bool
+	)
+	minimum := func(value uint32) {
+		if !found || value < ttl {
+			ttl, found = value, true
+		}
+	}
+	for _, rr := range metadata.Answers {
+		minimum(rr.Header().Ttl)
+	}
+	if !found {
+		for _, rr := range metadata.Authorities {
+			if soa, ok := rr.(*miekgdns.SOA); ok {
+				minimum(soa.Hdr.Ttl)
+				minimum(soa.Minttl)
+			}
+		}
+	}
+	if !found || ttl == 0 {
+		return 0, false
+	}
+	if duration := time.Duration(ttl) * time.Second; duration < maxCacheTTL {
+		return duration, true
+	}
+	return maxCacheTTL, true
+}
+
+// cloneDNSData returns a copy of the dns data not sharing its slices and message
+func cloneDNSData(dnsdata *retryabledns.DNSData) *retryabledns.DNSData {
+	clone := *dnsdata
+	for _, values := range []*[]string{&clone.Resolver, &clone.A, &clone.AAAA, &clone.CNAME, &clone.MX, &clone.PTR, &clone.SOA, &clone.NS, &clone.TXT, &clone.InternalIPs} {
+		if *values != nil {
+			*values = append([]string(nil), (*values)...)
+		}
+	}
+	if clone.RawResp != nil {
+		clone.RawResp = clone.RawResp.Copy()
+	}
+	clone.TraceData = nil
+	return &clone
+}
+
+// cloneMetadata returns a copy of the records of the metadata, their ttl decreased by the
+// elapsed seconds. The copy marks answers from the cache, no query was sent for them.
+func cloneMetadata(metadata *Metadata, elapsed uint32) *Metadata {
+	clone := &Metadata{Source: SourceCache, Truncated: metadata.Truncated}
+	copyRecords := func(records []miekgdns.RR) []miekgdns.RR {
+		var copied []miekgdns.RR
+		for _, rr := range records {
+			rr = miekgdns.Copy(rr)
+			if header := rr.Header(); header.Ttl > elapsed {
+				header.Ttl -= elapsed
+			} else {
+				header.Ttl = 0
+			}
+			copied = append(copied, rr)
+		}
+		return copied
+	}
+	clone.Answers = copyRecords(metadata.Answers)
+	clone.Authorities = copyRecords(metadata.Authorities)
+	clone.Additionals = copyRecords(metadata.Additionals)
+	return clone
+}
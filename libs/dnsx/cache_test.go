@@ -0,0 +1,139 @@
+package dnsx
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// answerCached answers the names of the zone: ttl0 has an answer not to be cached, missing
+// doesn't exist and failing returns SERVFAIL
+func answerCached(queries *int32) miekgdns.HandlerFunc {
+	return func(w miekgdns.ResponseWriter, req *miekgdns.Msg) {
+		atomic.AddInt32(queries, 1)
+		resp := new(miekgdns.Msg)
+		resp.SetReply(req)
+		name := req.Question[0].Name
+		switch name {
+		case "failing.example.com.":
+			resp.Rcode = miekgdns.RcodeServerFailure
+		case "missing.example.com.":
+			resp.Rcode = miekgdns.RcodeNameError
+			soa, _ := miekgdns.NewRR("example.com. 300 IN SOA ns.example.com. admin.example.com. 1 3600 600 86400 60")
+			resp.Ns = append(resp.Ns, soa)
+		case "ttl0.example.com.":
+			rr, _ := miekgdns.NewRR(name + " 0 IN A 192.0.2.1")
+			resp.Answer = append(resp.Answer, rr)
+		default:
+			rr, _ := miekgdns.NewRR(name + " 300 IN A 192.0.2.1")
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	}
+}
+
+func newCachingClient(t *testing.T, server string, size int) *DNSX {
+	t.Helper()
+	options := DefaultOptions
+	options.BaseResolvers = []string{server}
+	options.Hostsfile = false
+	options.QuestionTypes = []uint16{miekgdns.TypeA}
+	options.CacheSize = size
+	client, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestResponseCache(t *testing.T) {
+	var queries int32
+	server := newTestServer(t, answerCached(&queries))
+	tests := []struct {
+		host    string
+		queries int32
+		source  string
+		rcode   int
+	}{
+		{host: "www.example.com", queries: 1, source: SourceCache, rcode: miekgdns.RcodeSuccess},
+		// the negative answers are cached with the ttl of the SOA record
+		{host: "missing.example.com", queries: 1, source: SourceCache, rcode: miekgdns.RcodeNameError},
+		{host: "ttl0.example.com", queries: 2, source: SourceNetwork, rcode: miekgdns.RcodeSuccess},
+		{host: "failing.example.com", queries: 2, source: SourceNetwork, rcode: miekgdns.RcodeServerFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.host, func(t *testing.T) {
+			atomic.StoreInt32(&queries, 0)
+			client := newCachingClient(t, server, 10)
+			if _, _, err := client.QueryMultipleWithMetadata(test.host); err != nil {
+				t.Fatal(err)
+			}
+			dnsdata, metadata, err := client.QueryMultipleWithMetadata(test.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := atomic.LoadInt32(&queries); got != test.queries {
+				t.Errorf("got %d queries at the resolver, want %d", got, test.queries)
+			}
+			if metadata.Source != test.source || dnsdata.StatusCodeRaw != test.rcode {
+				t.Errorf("got source %s and rcode %d, want %s and %d", metadata.Source, dnsdata.StatusCodeRaw, test.source, test.rcode)
+			}
+			if metadata.Source == SourceCache && metadata.Queries != 0 {
+				t.Errorf("got %d queries for a cached answer", metadata.Queries)
+			}
+		})
+	}
+}
+
+func TestResponseCacheCopies(t *testing.T) {
+	var queries int32
+	client := newCachingClient(t, newTestServer(t, answerCached(&queries)), 10)
+	first, _, err := client.QueryMultipleWithMetadata("www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.A[0] = "198.51.100.1"
+	cached, metadata, err := client.QueryMultipleWithMetadata("www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(cached.A) != "[192.0.2.1]" || len(metadata.Answers) != 1 {
+		t.Fatalf("got A %v and answers %v from the cache", cached.A, metadata.Answers)
+	}
+	if ttl := metadata.Answers[0].Header().Ttl; ttl == 0 || ttl > 300 {
+		t.Errorf("got ttl %d, want at most 300", ttl)
+	}
+}
+
+func TestResponseCacheFresh(t *testing.T) {
+	var queries int32
+	client := newCachingClient(t, newTestServer(t, answerCached(&queries)), 10)
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.QueryMultipleWithMetadata("www.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, metadata, err := client.QueryMultipleFresh("www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&queries); got != 2 || metadata.Source != SourceNetwork {
+		t.Fatalf("got %d queries and source %s, want 2 and %s", got, metadata.Source, SourceNetwork)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	var queries int32
+	client := newCachingClient(t, newTestServer(t, answerCached(&queries)), 1)
+	for _, host := range []string{"a.example.com", "b.example.com", "b.example.com", "a.example.com"} {
+		if _, _, err := client.QueryMultipleWithMetadata(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a was evicted by b
+	if got := atomic.LoadInt32(&queries); got != 3 {
+		t.Fatalf("got %d queries at the resolver, want 3", got)
+	}
+}
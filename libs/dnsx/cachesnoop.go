@@ -0,0 +1,43 @@
+package dnsx
+
+import (
+	"context"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// CacheSnoop sends the question without recursion to every resolver of the name and returns
+// the ones answering it from their cache. An error is returned when no resolver responded.
+func (d *DNSX) CacheSnoop(hostname string, questionType uint16) ([]string, error) {
+	pool := d.resolvers
+	if _, override := d.overridePool(miekgdns.Fqdn(hostname)); override != nil {
+		pool = override
+	}
+	if len(pool.resolvers) == 0 {
+		return nil, errNoResolvers
+	}
+
+	var (
+		cached    []string
+		responses int
+		lastErr   error
+	)
+	for _, r := range pool.resolvers {
+		msg := newQuestion(hostname, questionType)
+		msg.RecursionDesired = false
+		resp, _, err := d.exchangeWith(context.Background(), r, msg, d.Options.Transport)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		responses++
+		// resolvers refusing non recursive queries reveal nothing
+		if resp.Rcode == miekgdns.RcodeSuccess && len(resp.Answer) > 0 {
+			cached = append(cached, r.String())
+		}
+	}
+	if responses == 0 {
+		return nil, lastErr
+	}
+	return cached, nil
+}
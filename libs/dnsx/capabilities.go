@@ -0,0 +1,82 @@
+package dnsx
+
+import (
+	"sync"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// capabilitiesUDPSize is the payload size advertised by the queries probing server capabilities
+const capabilitiesUDPSize = 1232
+
+// ServerCapabilities are the capability signals observed in the responses of a resolver
+type ServerCapabilities struct {
+	EDNS             bool   `json:"edns"`
+	EDNSVersion      uint8  `json:"edns_version"`
+	UDPSize          uint16 `json:"udp_size,omitempty"`
+	Cookies          bool   `json:"cookies"`
+	TCP              bool   `json:"tcp"`
+	MinimalResponses bool   `json:"minimal_responses"`
+}
+
+// capabilities accumulates the signals of the responses of a resolver
+type capabilities struct {
+	sync.Mutex
+	tcpProbe   sync.Once
+	caps       ServerCapabilities
+	answers    int
+	nonMinimal int
+}
+
+// withCapabilityProbes returns a copy of the message advertising EDNS and a client cookie
+func withCapabilityProbes(msg *miekgdns.Msg) *miekgdns.Msg {
+	msg = msg.Copy()
+	msg.SetEdns0(capabilitiesUDPSize, false)
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, &miekgdns.EDNS0_COOKIE{Code: miekgdns.EDNS0COOKIE, Cookie: newClientCookie()})
+	return msg
+}
+
+// observe records the capability signals of a response
+func (c *capabilities) observe(resp *miekgdns.Msg) {
+	c.Lock()
+	defer c.Unlock()
+	if opt := resp.IsEdns0(); opt != nil {
+		c.caps.EDNS = true
+		c.caps.EDNSVersion = opt.Version()
+		c.caps.UDPSize = opt.UDPSize()
+		for _, option := range opt.Option {
+			// the server cookie follows the client cookie
+			if cookie, ok := option.(*miekgdns.EDNS0_COOKIE); ok && len(cookie.Cookie) > clientCookieLength {
+				c.caps.Cookies = true
+			}
+		}
+	}
+	if len(resp.Answer) == 0 {
+		return
+	}
+	c.answers++
+	extra := 0
+	for _, rr := range resp.Extra {
+		if rr.Header().Rrtype != miekgdns.TypeOPT {
+			extra++
+		}
+	}
+	if len(resp.Ns) > 0 || extra > 0 {
+		c.nonMinimal++
+	}
+}
+
+func (c *capabilities) setTCP(available bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.caps.TCP = available
+}
+
+func (c *capabilities) snapshot() *ServerCapabilities {
+	c.Lock()
+	defer c.Unlock()
+	caps := c.caps
+	caps.MinimalResponses = c.answers > 0 && c.nonMinimal == 0
+	return &caps
+}
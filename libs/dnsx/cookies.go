@@ -0,0 +1,102 @@
+package dnsx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// clientCookieLength is the length of the hex encoded 8 bytes client cookie
+const clientCookieLength = 16
+
+// cookieJar keeps the client cookie sent to a resolver and the last server cookie it returned (RFC 7873)
+type cookieJar struct {
+	sync.Mutex
+	client string
+	server string
+}
+
+// cookie returns the cookie option value of the next query to the resolver
+func (c *cookieJar) cookie() string {
+	c.Lock()
+	defer c.Unlock()
+	if c.client == "" {
+		c.client = newClientCookie()
+	}
+	return c.client + c.server
+}
+
+// update stores the server cookie of a response echoing our client cookie
+func (c *cookieJar) update(resp *miekgdns.Msg) {
+	cookie := responseCookie(resp)
+	if len(cookie) <= clientCookieLength {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if strings.EqualFold(cookie[:clientCookieLength], c.client) {
+		c.server = cookie[clientCookieLength:]
+	}
+}
+
+// exchangeWithCookies sends the message with the cookies of the resolver, a query answered
+// with BADCOOKIE is repeated once with the server cookie of the response
+func (d *DNSX) exchangeWithCookies(ctx context.Context, r *resolver, msg *miekgdns.Msg, transport string) (*miekgdns.Msg, []*miekgdns.Msg, error) {
+	resp, conflicting, err := d.exchangeWith(ctx, r, withCookie(msg, r.cookies.cookie()), transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.cookies.update(resp)
+	if resp.Rcode != miekgdns.RcodeBadCookie {
+		return resp, conflicting, nil
+	}
+	resp, conflicting, err = d.exchangeWith(ctx, r, withCookie(msg, r.cookies.cookie()), transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.cookies.update(resp)
+	return resp, conflicting, nil
+}
+
+// withCookie returns a copy of the message carrying the cookie in place of any other one
+func withCookie(msg *miekgdns.Msg, cookie string) *miekgdns.Msg {
+	msg = msg.Copy()
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(miekgdns.DefaultMsgSize, false)
+		opt = msg.IsEdns0()
+	}
+	options := opt.Option[:0]
+	for _, option := range opt.Option {
+		if option.Option() != miekgdns.EDNS0COOKIE {
+			options = append(options, option)
+		}
+	}
+	opt.Option = append(options, &miekgdns.EDNS0_COOKIE{Code: miekgdns.EDNS0COOKIE, Cookie: cookie})
+	return msg
+}
+
+// responseCookie returns the cookie option value of the response, if any
+func responseCookie(resp *miekgdns.Msg) string {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return ""
+	}
+	for _, option := range opt.Option {
+		if cookie, ok := option.(*miekgdns.EDNS0_COOKIE); ok {
+			return cookie.Cookie
+		}
+	}
+	return ""
+}
+
+func newClientCookie() string {
+	clientCookie := make([]byte, clientCookieLength/2)
+	// nolint:errcheck
+	rand.Read(clientCookie)
+	return hex.EncodeToString(clientCookie)
+}
@@ -1,8 +1,13 @@
 package dnsx
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math"
+	"net"
+	"strings"
+	"time"
 
 	miekgdns "github.com/miekg/dns"
 	"github.com/projectdiscovery/iputil"
@@ -12,6 +17,10 @@ import (
 // DNSX is structure to perform dns lookups
 type DNSX struct {
 	dnsClient *retryabledns.Client
+	resolvers *resolverPool
+	overrides map[string]*resolverPool
+	hostsFile *HostsFile
+	cache     *responseCache
 	Options   *Options
 }
 
@@ -22,9 +31,46 @@ type Options struct {
 	QuestionTypes     []uint16
 	Trace             bool
 	TraceMaxRecursion int
+	// TraceStartServers are the servers the traces start from instead of the public roots
+	TraceStartServers []string
 	Hostsfile         bool
+	Timeout           time.Duration
+	// ZoneOverrides routes the names under a suffix to dedicated resolvers
+	ZoneOverrides map[string][]string
+	// ServerCapabilities records the EDNS, cookies, tcp and minimal responses support of the resolvers
+	ServerCapabilities bool
+	// Transport forces the protocol of the queries (udp, tcp), the default udp-tcp uses the
+	// protocol of each resolver and retries truncated udp responses over tcp
+	Transport string
+	// SourcePortMin and SourcePortMax restrict the source port of the queries to a range,
+	// a single port is used when only the minimum is set (the default lets the os choose)
+	SourcePortMin int
+	SourcePortMax int
+	// SpoofWindow is how long udp sockets keep listening after the first response for
+	// conflicting duplicates, a sign of injection attempts (disabled when zero)
+	SpoofWindow time.Duration
+	// DNSCookies sends DNS cookies (RFC 7873) echoing the server cookie of each resolver
+	DNSCookies bool
+	// PinResolvers sends the first attempt of the names of a registered domain to the
+	// same resolver, retries fall back to the other ones
+	PinResolvers bool
+	// OnAttempt is called with the timing of every query sent to a resolver, it must not block
+	OnAttempt func(QueryTiming)
+	// TypeTimeouts and TypeRetries override the timeout and the retries of the queries of
+	// specific question types
+	TypeTimeouts map[uint16]time.Duration
+	TypeRetries  map[uint16]int
+	// CacheSize is the number of responses answered from memory until the smallest ttl of
+	// their records expires (disabled when zero)
+	CacheSize int
 }
 
+const (
+	TransportUDP    = "udp"
+	TransportTCP    = "tcp"
+	TransportUDPTCP = "udp-tcp"
+)
+
 // DefaultOptions contains the default configuration options
 var DefaultOptions = Options{
 	BaseResolvers:     DefaultResolvers,
@@ -32,6 +78,8 @@ var DefaultOptions = Options{
 	QuestionTypes:     []uint16{miekgdns.TypeA},
 	TraceMaxRecursion: math.MaxUint16,
 	Hostsfile:         true,
+	Timeout:           DefaultTimeout,
+	Transport:         TransportUDPTCP,
 }
 
 // DefaultResolvers contains the list of resolvers known to be trusted.
@@ -51,9 +99,26 @@ func New(options Options) (*DNSX, error) {
 	}
 
 	dnsClient := retryabledns.NewWithOptions(retryablednsOptions)
-	dnsClient.TCPFallback = true
+	dnsClient.TCPFallback = options.Transport != TransportUDP
 
-	return &DNSX{dnsClient: dnsClient, Options: &options}, nil
+	dnsx := &DNSX{dnsClient: dnsClient, resolvers: newResolverPool(options.BaseResolvers), Options: &options}
+	if options.Hostsfile {
+		dnsx.hostsFile = systemHostsFile()
+	}
+	if options.CacheSize > 0 {
+		dnsx.cache = newResponseCache(options.CacheSize)
+	}
+	if len(options.ZoneOverrides) > 0 {
+		dnsx.overrides = make(map[string]*resolverPool)
+		for suffix, resolvers := range options.ZoneOverrides {
+			pool := newResolverPool(resolvers)
+			if len(pool.resolvers) == 0 {
+				return nil, fmt.Errorf("no resolvers for zone override %s", suffix)
+			}
+			dnsx.overrides[normalizeSuffix(suffix)] = pool
+		}
+	}
+	return dnsx, nil
 }
 
 // Lookup performs a DNS A question and returns corresponding IPs
@@ -76,15 +141,128 @@ func (d *DNSX) Lookup(hostname string) ([]string, error) {
 
 // QueryOne performs a DNS question of a specified type and returns raw responses
 func (d *DNSX) QueryOne(hostname string) (*retryabledns.DNSData, error) {
-	return d.dnsClient.Query(hostname, d.Options.QuestionTypes[0])
+	dnsdata, _, err := d.query(context.Background(), hostname, d.Options.QuestionTypes[:1], d.Options.Transport)
+	return dnsdata, err
 }
 
 // QueryMultiple performs a DNS question of the specified types and returns raw responses
 func (d *DNSX) QueryMultiple(hostname string) (*retryabledns.DNSData, error) {
-	return d.dnsClient.QueryMultiple(hostname, d.Options.QuestionTypes)
+	dnsdata, _, err := d.query(context.Background(), hostname, d.Options.QuestionTypes, d.Options.Transport)
+	return dnsdata, err
+}
+
+// QueryMultipleWithMetadata performs a DNS question of the specified types and returns
+// raw responses along with the details of the exchanges
+func (d *DNSX) QueryMultipleWithMetadata(hostname string) (*retryabledns.DNSData, *Metadata, error) {
+	return d.QueryMultipleWithMetadataContext(context.Background(), hostname)
+}
+
+// QueryMultipleWithMetadataContext is like QueryMultipleWithMetadata, the queries in flight
+// are aborted when the context is done and its error is returned
+func (d *DNSX) QueryMultipleWithMetadataContext(ctx context.Context, hostname string) (*retryabledns.DNSData, *Metadata, error) {
+	return d.query(ctx, hostname, d.Options.QuestionTypes, d.Options.Transport)
+}
+
+// QueryMultipleWithTransport performs a DNS question of the specified types over the given
+// transport (udp, tcp) and returns raw responses along with the details of the exchanges
+func (d *DNSX) QueryMultipleWithTransport(hostname, transport string) (*retryabledns.DNSData, *Metadata, error) {
+	return d.query(context.Background(), hostname, d.Options.QuestionTypes, transport)
+}
+
+// QueryMultipleFresh performs a DNS question of the specified types at the resolvers, the
+// local answers of the hosts file and the response cache are bypassed
+func (d *DNSX) QueryMultipleFresh(hostname string) (*retryabledns.DNSData, *Metadata, error) {
+	return d.QueryMultipleFreshContext(context.Background(), hostname)
+}
+
+// QueryMultipleFreshContext is like QueryMultipleFresh, the queries in flight are aborted
+// when the context is done and its error is returned
+func (d *DNSX) QueryMultipleFreshContext(ctx context.Context, hostname string) (*retryabledns.DNSData, *Metadata, error) {
+	return d.queryNetwork(ctx, hostname, d.Options.QuestionTypes, d.Options.Transport)
 }
 
-// Trace performs a DNS trace of the specified types and returns raw responses
-func (d *DNSX) Trace(hostname string) (*retryabledns.TraceData, error) {
-	return d.dnsClient.Trace(hostname, d.Options.QuestionTypes[0], d.Options.TraceMaxRecursion)
+// QueryMsg performs a DNS question of the specified type and returns the native response
+func (d *DNSX) QueryMsg(hostname string, questionType uint16) (*miekgdns.Msg, error) {
+	return d.QueryMsgContext(context.Background(), hostname, questionType)
+}
+
+// QueryMsgContext is like QueryMsg, the query in flight is aborted when the context is done
+// and its error is returned
+func (d *DNSX) QueryMsgContext(ctx context.Context, hostname string, questionType uint16) (*miekgdns.Msg, error) {
+	result, err := d.exchange(ctx, newQuestion(hostname, questionType), d.Options.Transport)
+	return result.resp, err
+}
+
+// ResolverHealth returns the rolling health statistics of the resolvers
+func (d *DNSX) ResolverHealth() []ResolverHealth {
+	health := d.resolvers.health(d.Options.ServerCapabilities)
+	for _, pool := range d.overrides {
+		health = append(health, pool.health(d.Options.ServerCapabilities)...)
+	}
+	return health
+}
+
+// ZoneOverride returns the longest zone override suffix matching the hostname, if any
+func (d *DNSX) ZoneOverride(hostname string) string {
+	suffix, _ := d.overridePool(hostname)
+	return suffix
+}
+
+// overridePool returns the resolvers of the longest zone override suffix matching the name
+func (d *DNSX) overridePool(name string) (string, *resolverPool) {
+	if len(d.overrides) == 0 {
+		return "", nil
+	}
+	name = normalizeSuffix(name)
+	for {
+		if pool, ok := d.overrides[name]; ok {
+			return name, pool
+		}
+		idx := strings.IndexByte(name, '.')
+		if idx < 0 {
+			return "", nil
+		}
+		name = name[idx+1:]
+	}
+}
+
+func normalizeSuffix(name string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(name), "."))
+}
+
+// DiscoverResolvers returns the resolvers published via RFC 2782 SRV records
+// (_dns._udp.<domain> and _dns._tcp.<domain>) in the format protocol:ip:port
+func (d *DNSX) DiscoverResolvers(domain string) ([]string, error) {
+	var resolvers []string
+	seen := make(map[string]struct{})
+	for _, protocol := range []string{"udp", "tcp"} {
+		msg, err := d.QueryMsg(fmt.Sprintf("_dns._%s.%s", protocol, domain), miekgdns.TypeSRV)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range msg.Answer {
+			srv, ok := rr.(*miekgdns.SRV)
+			if !ok {
+				continue
+			}
+			target := strings.TrimSuffix(srv.Target, ".")
+			// a target of "." means the service is not available
+			if target == "" {
+				continue
+			}
+			ips, err := d.Lookup(target)
+			if err != nil {
+				continue
+			}
+			for _, ip := range ips {
+				resolver := fmt.Sprintf("%s:%s", protocol, net.JoinHostPort(ip, fmt.Sprint(srv.Port)))
+				if _, ok := seen[resolver]; ok {
+					continue
+				}
+				seen[resolver] = struct{}{}
+				resolvers = append(resolvers, resolver)
+			}
+		}
+	}
+	return resolvers, nil
 }
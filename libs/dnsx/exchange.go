@@ -0,0 +1,420 @@
+package dnsx
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// DefaultTimeout is the timeout of a single query attempt
+const DefaultTimeout = 3 * time.Second
+
+var errNoResolvers = errors.New("no resolvers available")
+
+// QueryTiming is the outcome of a single query sent to a resolver
+type QueryTiming struct {
+	Name      string
+	Type      uint16
+	Resolver  string
+	Attempt   int
+	RTT       time.Duration
+	Transport string
+	// Rcode is the response code, -1 when no response was received
+	Rcode int
+}
+
+// exchangeResult is the response of an exchange along with the details of how it was obtained
+type exchangeResult struct {
+	resp     *miekgdns.Msg
+	resolver *resolver
+	// retries is the number of attempts repeated after a failure
+	retries int
+	// conflicting are the late responses to the same query having different answers
+	conflicting []*miekgdns.Msg
+	// malformed are the errors of the responses rejected as malformed
+	malformed []string
+}
+
+// exchange sends the message to the resolvers until a response is received. The first
+// attempt uses the resolvers in round robin, or the resolver pinned to the registered domain
+// of the name, retries go to the fastest healthiest ones.
+// Names under a zone override suffix only use the resolvers of the override.
+// The attempt in flight is aborted and no other one is made once the context is done.
+func (d *DNSX) exchange(ctx context.Context, msg *miekgdns.Msg, transport string) (*exchangeResult, error) {
+	pool := d.resolvers
+	if len(msg.Question) > 0 {
+		if _, override := d.overridePool(msg.Question[0].Name); override != nil {
+			pool = override
+		}
+	}
+	if len(pool.resolvers) == 0 {
+		return &exchangeResult{}, errNoResolvers
+	}
+	attempts := d.attempts(msg)
+	if d.Options.ServerCapabilities {
+		msg = withCapabilityProbes(msg)
+	}
+
+	var (
+		failed    []*resolver
+		malformed []string
+		err       error
+	)
+	for attempt := 0; attempt < attempts; attempt++ {
+		var current *resolver
+		switch {
+		case attempt == 0 && d.Options.PinResolvers && len(msg.Question) > 0:
+			current = pool.pinned(msg.Question[0].Name)
+		case attempt == 0:
+			current = pool.next()
+		default:
+			current = pool.retry(failed)
+		}
+		var resp *miekgdns.Msg
+		var conflicting []*miekgdns.Msg
+		start := time.Now()
+		if d.Options.DNSCookies {
+			resp, conflicting, err = d.exchangeWithCookies(ctx, current, msg, transport)
+		} else {
+			resp, conflicting, err = d.exchangeWith(ctx, current, msg, transport)
+		}
+		rtt := time.Since(start)
+		// the aborted attempts say nothing about the health of the resolver
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return &exchangeResult{retries: attempt, malformed: malformed}, ctxErr
+		}
+		current.record(rtt, err != nil)
+		if d.Options.OnAttempt != nil && len(msg.Question) > 0 {
+			timing := QueryTiming{
+				Name:      msg.Question[0].Name,
+				Type:      msg.Question[0].Qtype,
+				Resolver:  current.String(),
+				Attempt:   attempt,
+				RTT:       rtt,
+				Transport: queryProtocol(current, transport),
+				Rcode:     -1,
+			}
+			if err == nil {
+				timing.Rcode = resp.Rcode
+			}
+			d.Options.OnAttempt(timing)
+		}
+		if err == nil {
+			if d.Options.ServerCapabilities {
+				d.observeCapabilities(current, msg, resp)
+			}
+			return &exchangeResult{resp: resp, resolver: current, retries: attempt, conflicting: conflicting, malformed: malformed}, nil
+		}
+		if errors.Is(err, ErrMalformedResponse) {
+			malformed = append(malformed, current.String()+": "+err.Error())
+		}
+		failed = append(failed, current)
+	}
+	return &exchangeResult{retries: attempts - 1, malformed: malformed}, err
+}
+
+// exchangeWith sends the message to a single resolver, rejecting the responses having malformed
+// names. Truncated udp responses are retried over tcp unless the transport is forced, and kept
+// when the tcp query fails so that their TC bit marks the missing records. The udp responses
+// are watched for conflicting late duplicates when spoofing detection is enabled.
+func (d *DNSX) exchangeWith(ctx context.Context, r *resolver, msg *miekgdns.Msg, transport string) (*miekgdns.Msg, []*miekgdns.Msg, error) {
+	protocol := queryProtocol(r, transport)
+	var (
+		resp        *miekgdns.Msg
+		conflicting []*miekgdns.Msg
+		err         error
+	)
+	if protocol == "udp" && d.Options.SpoofWindow > 0 {
+		resp, conflicting, err = d.exchangeWatching(ctx, d.newClient(protocol, msg), msg, r.address)
+	} else {
+		resp, err = d.exchangeValidated(ctx, d.newClient(protocol, msg), msg, r.address)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return nil, nil, errors.New("empty response")
+	}
+	if resp.Truncated && protocol == "udp" && transport != TransportUDP {
+		if tcpResp, err := d.exchangeValidated(ctx, d.newClient("tcp", msg), msg, r.address); err == nil {
+			resp = tcpResp
+		}
+	}
+	return resp, conflicting, nil
+}
+
+// queryProtocol returns the protocol of the queries sent to the resolver, the one of the
+// resolver unless the transport forces it
+func queryProtocol(r *resolver, transport string) string {
+	if transport == TransportUDP || transport == TransportTCP {
+		return transport
+	}
+	return r.protocol
+}
+
+// observeCapabilities records the signals of the response, tcp support is probed once per resolver
+func (d *DNSX) observeCapabilities(r *resolver, msg, resp *miekgdns.Msg) {
+	r.capabilities.observe(resp)
+	r.capabilities.tcpProbe.Do(func() {
+		if r.protocol == "tcp" {
+			r.capabilities.setTCP(true)
+			return
+		}
+		_, _, err := d.newClient("tcp", msg).Exchange(msg, r.address)
+		r.capabilities.setTCP(err == nil)
+	})
+}
+
+// newClient returns a client of the protocol bound to the configured source port, if any,
+// timing out according to the question type of the message
+func (d *DNSX) newClient(protocol string, msg *miekgdns.Msg) *miekgdns.Client {
+	timeout := d.timeout(msg)
+	client := &miekgdns.Client{Net: protocol, Timeout: timeout}
+	port := d.sourcePort()
+	if port == 0 {
+		return client
+	}
+	var localAddr net.Addr = &net.UDPAddr{Port: port}
+	if protocol == "tcp" {
+		localAddr = &net.TCPAddr{Port: port}
+	}
+	client.Dialer = &net.Dialer{Timeout: timeout, LocalAddr: localAddr}
+	return client
+}
+
+// sourcePort returns a random port of the configured source port range, zero leaves
+// the choice to the operating system
+func (d *DNSX) sourcePort() int {
+	min, max := d.Options.SourcePortMin, d.Options.SourcePortMax
+	if min <= 0 {
+		return 0
+	}
+	if max <= min {
+		return min
+	}
+	return min + rand.Intn(max-min+1)
+}
+
+// timeout returns the timeout of a query attempt of the message, the one of its question
+// type when overridden
+func (d *DNSX) timeout(msg *miekgdns.Msg) time.Duration {
+	if len(msg.Question) > 0 {
+		if timeout, ok := d.Options.TypeTimeouts[msg.Question[0].Qtype]; ok && timeout > 0 {
+			return timeout
+		}
+	}
+	if d.Options.Timeout > 0 {
+		return d.Options.Timeout
+	}
+	return DefaultTimeout
+}
+
+// attempts returns the number of attempts of the message, the retries of its question
+// type when overridden
+func (d *DNSX) attempts(msg *miekgdns.Msg) int {
+	attempts := d.Options.MaxRetries
+	if len(msg.Question) > 0 {
+		if retries, ok := d.Options.TypeRetries[msg.Question[0].Qtype]; ok {
+			attempts = retries
+		}
+	}
+	if attempts <= 0 {
+		attempts = 1
+	}
+	return attempts
+}
+
+const (
+	// SourceNetwork marks the answers received from resolvers
+	SourceNetwork = "network"
+	// SourceHostsFile marks the answers synthesized from a hosts file
+	SourceHostsFile = "hostsfile"
+	// SourceCache marks the answers of the response cache
+	SourceCache = "cache"
+)
+
+// Metadata contains the details of the exchanges performed to resolve a host
+type Metadata struct {
+	// Source is where the answers come from (network, hostsfile, cache)
+	Source string
+	// Answers are the answer records of the responses
+	Answers []miekgdns.RR
+	// Authorities are the authority section records of the responses
+	Authorities []miekgdns.RR
+	// Additionals are the additional section records of the responses
+	Additionals []miekgdns.RR
+	// Queries is the number of questions sent
+	Queries int
+	// Retries is the number of attempts repeated after a failure
+	Retries int
+	// SpoofResponses are the first and the conflicting late responses of the queries
+	// answered more than once with different answers
+	SpoofResponses []string
+	// Malformed are the errors of the responses rejected because of malformed names
+	Malformed []string
+	// Truncated reports whether a response was still truncated after the tcp fallback,
+	// some of its records are missing
+	Truncated bool
+}
+
+// query performs the questions of the specified types over the transport and merges the responses.
+// The A and AAAA questions of the names mapped by the hosts file are answered by it, the other
+// types are still asked to the resolvers.
+func (d *DNSX) query(ctx context.Context, hostname string, questionTypes []uint16, transport string) (*retryabledns.DNSData, *Metadata, error) {
+	if d.hostsFile == nil {
+		return d.queryCached(ctx, hostname, questionTypes, transport)
+	}
+	local, localMetadata := d.hostsFile.Answer(hostname)
+	if len(local.A) == 0 && len(local.AAAA) == 0 {
+		return d.queryCached(ctx, hostname, questionTypes, transport)
+	}
+	var remaining []uint16
+	addressTypes := make(map[uint16]struct{})
+	for _, questionType := range questionTypes {
+		if questionType == miekgdns.TypeA || questionType == miekgdns.TypeAAAA {
+			addressTypes[questionType] = struct{}{}
+		} else {
+			remaining = append(remaining, questionType)
+		}
+	}
+	if _, ok := addressTypes[miekgdns.TypeA]; !ok {
+		local.A = nil
+	}
+	if _, ok := addressTypes[miekgdns.TypeAAAA]; !ok {
+		local.AAAA = nil
+	}
+	resp := localResponse(local)
+	local.Raw = resp.String()
+	localMetadata.Answers = resp.Answer
+	if len(remaining) == 0 {
+		return local, localMetadata, nil
+	}
+
+	dnsdata, metadata, err := d.queryCached(ctx, hostname, remaining, transport)
+	// the addresses of the hosts file are kept when the resolvers don't answer, not when
+	// the questions were aborted
+	if err != nil || dnsdata.Timestamp.IsZero() {
+		if (len(local.A) == 0 && len(local.AAAA) == 0) || ctx.Err() != nil {
+			return dnsdata, metadata, err
+		}
+		return local, localMetadata, nil
+	}
+	dnsdata.A = append(local.A, dnsdata.A...)
+	dnsdata.AAAA = append(local.AAAA, dnsdata.AAAA...)
+	dnsdata.Resolver = append([]string{HostsFileResolver}, dnsdata.Resolver...)
+	// the hosts file vouches for the name unknown to the resolvers
+	if dnsdata.StatusCodeRaw == miekgdns.RcodeNameError && (len(local.A) > 0 || len(local.AAAA) > 0) {
+		dnsdata.StatusCodeRaw = miekgdns.RcodeSuccess
+		dnsdata.StatusCode = miekgdns.RcodeToString[dnsdata.StatusCodeRaw]
+	}
+	dnsdata.Raw = local.Raw + dnsdata.Raw
+	metadata.Answers = append(localMetadata.Answers, metadata.Answers...)
+	return dnsdata, metadata, nil
+}
+
+// queryCached answers the questions from the response cache when it's enabled, the responses
+// received from the resolvers are stored in it
+func (d *DNSX) queryCached(ctx context.Context, hostname string, questionTypes []uint16, transport string) (*retryabledns.DNSData, *Metadata, error) {
+	if d.cache == nil {
+		return d.queryNetwork(ctx, hostname, questionTypes, transport)
+	}
+	key := cacheKey(hostname, questionTypes, transport)
+	if dnsdata, metadata, ok := d.cache.get(key); ok {
+		return dnsdata, metadata, nil
+	}
+	dnsdata, metadata, err := d.queryNetwork(ctx, hostname, questionTypes, transport)
+	if err == nil && !dnsdata.Timestamp.IsZero() {
+		d.cache.set(key, dnsdata, metadata)
+	}
+	return dnsdata, metadata, err
+}
+
+// queryNetwork sends the questions to the resolvers, the local answers are never used. The
+// responses are incomplete when the context is done and its error is returned.
+func (d *DNSX) queryNetwork(ctx context.Context, hostname string, questionTypes []uint16, transport string) (*retryabledns.DNSData, *Metadata, error) {
+	metadata := &Metadata{Source: SourceNetwork}
+
+	dnsdata := &retryabledns.DNSData{Host: hostname}
+	seenResolvers := make(map[*resolver]struct{})
+	var lastErr error
+	for _, questionType := range questionTypes {
+		if ctx.Err() != nil {
+			break
+		}
+		msg := newQuestion(hostname, questionType)
+		result, err := d.exchange(ctx, msg, transport)
+		metadata.Queries++
+		metadata.Retries += result.retries
+		metadata.Malformed = append(metadata.Malformed, result.malformed...)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp := result.resp
+		if err := dnsdata.ParseFromMsg(resp); err != nil {
+			lastErr = err
+			continue
+		}
+		if _, ok := seenResolvers[result.resolver]; !ok {
+			seenResolvers[result.resolver] = struct{}{}
+			dnsdata.Resolver = append(dnsdata.Resolver, result.resolver.String())
+		}
+		if len(result.conflicting) > 0 {
+			metadata.SpoofResponses = append(metadata.SpoofResponses, resp.String())
+			for _, conflicting := range result.conflicting {
+				metadata.SpoofResponses = append(metadata.SpoofResponses, conflicting.String())
+			}
+		}
+		if resp.Truncated {
+			metadata.Truncated = true
+		}
+		dnsdata.StatusCode = miekgdns.RcodeToString[resp.Rcode]
+		dnsdata.StatusCodeRaw = resp.Rcode
+		dnsdata.Raw += resp.String()
+		dnsdata.Timestamp = time.Now()
+		metadata.Answers = append(metadata.Answers, resp.Answer...)
+		metadata.Authorities = append(metadata.Authorities, resp.Ns...)
+		metadata.Additionals = append(metadata.Additionals, resp.Extra...)
+	}
+	if err := ctx.Err(); err != nil {
+		return dnsdata, metadata, err
+	}
+	// no response at all, the host is reported as failed
+	if dnsdata.Timestamp.IsZero() {
+		return dnsdata, metadata, lastErr
+	}
+	return dnsdata, metadata, nil
+}
+
+// newQuestion returns the query of the name, the PTR questions of addresses ask for their reverse name
+func newQuestion(hostname string, questionType uint16) *miekgdns.Msg {
+	if questionType == miekgdns.TypePTR {
+		hostname = ptrName(hostname)
+	}
+	msg := new(miekgdns.Msg)
+	msg.Id = miekgdns.Id()
+	msg.RecursionDesired = true
+	msg.SetQuestion(miekgdns.Fqdn(hostname), questionType)
+	return msg
+}
+
+// systemHostsFile loads the hosts file of the operating system, if any
+func systemHostsFile() *HostsFile {
+	path := "/etc/hosts"
+	if runtime.GOOS == "windows" {
+		path = filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	hostsFile, err := LoadHostsFile(path)
+	if err != nil {
+		return nil
+	}
+	return hostsFile
+}
@@ -0,0 +1,272 @@
+package dnsx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// newTestServer starts a udp dns server answering with the handler and returns its address
+func newTestServer(tb testing.TB, handler miekgdns.HandlerFunc) string {
+	tb.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &miekgdns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe() // nolint:errcheck
+	<-started
+	tb.Cleanup(func() { server.Shutdown() }) // nolint:errcheck
+	return conn.LocalAddr().String()
+}
+
+// newBlackhole returns the address of a udp socket never answering
+func newBlackhole(tb testing.TB) string {
+	tb.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String()
+}
+
+// answerMX answers the MX questions with a single record and the other ones with no data
+func answerMX(queries *int32) miekgdns.HandlerFunc {
+	return func(w miekgdns.ResponseWriter, req *miekgdns.Msg) {
+		if queries != nil {
+			atomic.AddInt32(queries, 1)
+		}
+		resp := new(miekgdns.Msg)
+		resp.SetReply(req)
+		if req.Question[0].Qtype == miekgdns.TypeMX {
+			rr, _ := miekgdns.NewRR(req.Question[0].Name + " 300 IN MX 10 mail.example.com.")
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	}
+}
+
+func newTestHostsFile(t *testing.T, content string) *HostsFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	hostsFile, err := LoadHostsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hostsFile
+}
+
+func TestQueryHostsFile(t *testing.T) {
+	var queries int32
+	server := newTestServer(t, answerMX(&queries))
+	tests := []struct {
+		name          string
+		questionTypes []uint16
+		queries       int32
+		a             string
+		mx            string
+		source        string
+	}{
+		{name: "addresses only", questionTypes: []uint16{miekgdns.TypeA, miekgdns.TypeAAAA}, a: "[192.0.2.1]", mx: "[]", source: SourceHostsFile},
+		{name: "other types", questionTypes: []uint16{miekgdns.TypeA, miekgdns.TypeMX}, queries: 1, a: "[192.0.2.1]", mx: "[mail.example.com]", source: SourceNetwork},
+		{name: "no address question", questionTypes: []uint16{miekgdns.TypeMX}, queries: 1, a: "[]", mx: "[mail.example.com]", source: SourceNetwork},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&queries, 0)
+			options := DefaultOptions
+			options.BaseResolvers = []string{server}
+			options.Hostsfile = false
+			options.QuestionTypes = test.questionTypes
+			client, err := New(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.hostsFile = newTestHostsFile(t, "192.0.2.1 internal.example.com\n")
+
+			dnsdata, metadata, err := client.QueryMultipleWithMetadata("internal.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := atomic.LoadInt32(&queries); got != test.queries {
+				t.Errorf("got %d queries at the resolver, want %d", got, test.queries)
+			}
+			if fmt.Sprint(dnsdata.A) != test.a || fmt.Sprint(dnsdata.MX) != test.mx {
+				t.Errorf("got A %v and MX %v, want %s and %s", dnsdata.A, dnsdata.MX, test.a, test.mx)
+			}
+			if metadata.Source != test.source || dnsdata.StatusCodeRaw != miekgdns.RcodeSuccess {
+				t.Errorf("got source %s and rcode %d", metadata.Source, dnsdata.StatusCodeRaw)
+			}
+		})
+	}
+}
+
+func TestResolverPoolRetry(t *testing.T) {
+	pool := newResolverPool([]string{"192.0.2.1", "192.0.2.2", "198.51.100.1", "198.51.100.2", "203.0.113.1"})
+	slow, fast, faster, failing := pool.resolvers[0], pool.resolvers[2], pool.resolvers[3], pool.resolvers[4]
+	slow.record(time.Second, false)
+	fast.record(20*time.Millisecond, false)
+	faster.record(10*time.Millisecond, false)
+	failing.record(100*time.Millisecond, true)
+
+	tests := []struct {
+		failed []*resolver
+		want   *resolver
+	}{
+		// the healthiest resolver out of the subnet of the last failed one
+		{failed: []*resolver{slow}, want: faster},
+		{failed: []*resolver{faster}, want: pool.resolvers[1]},
+		// the same subnet is used when no other resolver is left
+		{failed: []*resolver{pool.resolvers[1], faster, fast, failing, slow}, want: nil},
+		{failed: []*resolver{pool.resolvers[1], fast, failing, faster}, want: slow},
+	}
+	for i, test := range tests {
+		got := pool.retry(test.failed)
+		if test.want != nil && got != test.want {
+			t.Errorf("test %d: got %s, want %s", i, got, test.want)
+		}
+		if test.want == nil && got == nil {
+			t.Errorf("test %d: no resolver once all failed", i)
+		}
+	}
+}
+
+// BenchmarkExchange resolves through 100 resolvers of which 30% are blackholed, the retries
+// select the healthy resolvers from the shared ranking
+func BenchmarkExchange(b *testing.B) {
+	var resolvers []string
+	for i := 0; i < 100; i++ {
+		if i%10 < 3 {
+			resolvers = append(resolvers, newBlackhole(b))
+		} else {
+			resolvers = append(resolvers, newTestServer(b, answerMX(nil)))
+		}
+	}
+	options := DefaultOptions
+	options.BaseResolvers = resolvers
+	options.Hostsfile = false
+	options.Timeout = 50 * time.Millisecond
+	options.Transport = TransportUDP
+	client, err := New(options)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var failures int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.exchange(context.Background(), newQuestion("example.com", miekgdns.TypeMX), TransportUDP); err != nil {
+				atomic.AddInt64(&failures, 1)
+			}
+		}
+	})
+	b.ReportMetric(float64(failures)/float64(b.N), "failures/op")
+}
+
+// TestTypeOverrides sends the queries of each type to a resolver never answering, each type
+// is attempted and timed out with its own values
+func TestTypeOverrides(t *testing.T) {
+	options := DefaultOptions
+	options.BaseResolvers = []string{newBlackhole(t)}
+	options.Hostsfile = false
+	options.Transport = TransportUDP
+	options.MaxRetries = 2
+	options.Timeout = 200 * time.Millisecond
+	options.TypeTimeouts = map[uint16]time.Duration{miekgdns.TypeTXT: 20 * time.Millisecond}
+	options.TypeRetries = map[uint16]int{miekgdns.TypeTXT: 3, miekgdns.TypeMX: 1}
+	var (
+		mutex   sync.Mutex
+		timings []QueryTiming
+	)
+	options.OnAttempt = func(timing QueryTiming) {
+		mutex.Lock()
+		timings = append(timings, timing)
+		mutex.Unlock()
+	}
+	client, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		questionType uint16
+		attempts     int
+		timeout      time.Duration
+	}{
+		{questionType: miekgdns.TypeA, attempts: 2, timeout: 200 * time.Millisecond},
+		{questionType: miekgdns.TypeTXT, attempts: 3, timeout: 20 * time.Millisecond},
+		{questionType: miekgdns.TypeMX, attempts: 1, timeout: 200 * time.Millisecond},
+	}
+	for _, test := range tests {
+		name := miekgdns.TypeToString[test.questionType]
+		timings = nil
+		if _, err := client.exchange(context.Background(), newQuestion("example.com", test.questionType), TransportUDP); err == nil {
+			t.Fatalf("%s: the blackhole answered", name)
+		}
+		if len(timings) != test.attempts {
+			t.Errorf("%s: got %d attempts, want %d", name, len(timings), test.attempts)
+		}
+		for _, timing := range timings {
+			if timing.Type != test.questionType || timing.RTT < test.timeout || timing.RTT > test.timeout+150*time.Millisecond {
+				t.Errorf("%s: got attempt of %s timing out after %s, want %s", name, miekgdns.TypeToString[timing.Type], timing.RTT, test.timeout)
+			}
+		}
+	}
+}
+
+// TestQueryContext checks that the queries sent to a resolver never answering are aborted
+// when the context is done instead of waiting for their timeout and retries
+func TestQueryContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		spoofWindow time.Duration
+	}{
+		{name: "udp"},
+		{name: "spoofing detection", spoofWindow: 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DefaultOptions
+			options.BaseResolvers = []string{newBlackhole(t)}
+			options.Hostsfile = false
+			options.MaxRetries = 3
+			options.Timeout = 5 * time.Second
+			options.QuestionTypes = []uint16{miekgdns.TypeA, miekgdns.TypeAAAA}
+			options.SpoofWindow = test.spoofWindow
+			client, err := New(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, _, err = client.QueryMultipleWithMetadataContext(ctx, "www.example.com")
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("got query aborted after %s", elapsed)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+			}
+			// the aborted attempts aren't failures of the resolver
+			if health := client.ResolverHealth(); health[0].Queries != 0 {
+				t.Errorf("got %d queries recorded", health[0].Queries)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package dnsx
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// HostsFileResolver is the resolver reported for answers coming from a hosts file
+const HostsFileResolver = "hostsfile"
+
+// HostsFile contains the mappings of a hosts file
+type HostsFile struct {
+	names     map[string][]string
+	addresses map[string][]string
+}
+
+// LoadHostsFile parses a hosts file in the /etc/hosts format
+func LoadHostsFile(path string) (*HostsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hostsFile := &HostsFile{names: make(map[string][]string), addresses: make(map[string][]string)}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		address := ip.String()
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			hostsFile.names[name] = append(hostsFile.names[name], address)
+			hostsFile.addresses[address] = append(hostsFile.addresses[address], name)
+		}
+	}
+	return hostsFile, sc.Err()
+}
+
+// Query resolves the hostname (or the names of an IP) from the hosts file only
+func (h *HostsFile) Query(hostname string) *retryabledns.DNSData {
+	dnsdata, _ := h.Answer(hostname)
+	return dnsdata
+}
+
+// Answer resolves the hostname from the hosts file and synthesizes the metadata and the raw
+// response of an authoritative answer, so that local answers look like network ones
+func (h *HostsFile) Answer(hostname string) (*retryabledns.DNSData, *Metadata) {
+	dnsdata := &retryabledns.DNSData{Host: hostname, Resolver: []string{HostsFileResolver}}
+	if ip := net.ParseIP(hostname); ip != nil {
+		dnsdata.PTR = append(dnsdata.PTR, h.addresses[ip.String()]...)
+	} else {
+		for _, address := range h.names[strings.ToLower(strings.TrimSuffix(hostname, "."))] {
+			if strings.Contains(address, ":") {
+				dnsdata.AAAA = append(dnsdata.AAAA, address)
+			} else {
+				dnsdata.A = append(dnsdata.A, address)
+			}
+		}
+	}
+
+	dnsdata.StatusCodeRaw = miekgdns.RcodeSuccess
+	if len(dnsdata.A) == 0 && len(dnsdata.AAAA) == 0 && len(dnsdata.PTR) == 0 {
+		dnsdata.StatusCodeRaw = miekgdns.RcodeNameError
+	}
+	dnsdata.StatusCode = miekgdns.RcodeToString[dnsdata.StatusCodeRaw]
+	dnsdata.Timestamp = time.Now()
+
+	resp := localResponse(dnsdata)
+	dnsdata.Raw = resp.String()
+	return dnsdata, &Metadata{Source: SourceHostsFile, Answers: resp.Answer, Queries: 1}
+}
+
+// localResponse builds the response message carrying the records of a local answer
+func localResponse(dnsdata *retryabledns.DNSData) *miekgdns.Msg {
+	questionType := miekgdns.TypeA
+	name := miekgdns.Fqdn(dnsdata.Host)
+	if ip := net.ParseIP(dnsdata.Host); ip != nil {
+		questionType = miekgdns.TypePTR
+		if reverse, err := miekgdns.ReverseAddr(ip.String()); err == nil {
+			name = reverse
+		}
+	}
+	resp := new(miekgdns.Msg)
+	resp.SetQuestion(name, questionType)
+	resp.Response = true
+	resp.Authoritative = true
+	resp.RecursionAvailable = true
+	resp.Rcode = dnsdata.StatusCodeRaw
+
+	header := func(rrtype uint16) miekgdns.RR_Header {
+		return miekgdns.RR_Header{Name: name, Rrtype: rrtype, Class: miekgdns.ClassINET}
+	}
+	for _, a := range dnsdata.A {
+		resp.Answer = append(resp.Answer, &miekgdns.A{Hdr: header(miekgdns.TypeA), A: net.ParseIP(a)})
+	}
+	for _, aaaa := range dnsdata.AAAA {
+		resp.Answer = append(resp.Answer, &miekgdns.AAAA{Hdr: header(miekgdns.TypeAAAA), AAAA: net.ParseIP(aaaa)})
+	}
+	for _, ptr := range dnsdata.PTR {
+		resp.Answer = append(resp.Answer, &miekgdns.PTR{Hdr: header(miekgdns.TypePTR), Ptr: miekgdns.Fqdn(ptr)})
+	}
+	return resp
+}
@@ -0,0 +1,213 @@
+package dnsx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// ErrMalformedResponse is returned for responses whose names can't be decoded safely
+var ErrMalformedResponse = errors.New("malformed response")
+
+const headerLength = 12
+
+// sendMsg dials the address and writes the message, the connection is returned ready to
+// read responses sized according to the edns buffer of the message
+func (d *DNSX) sendMsg(ctx context.Context, client *miekgdns.Client, msg *miekgdns.Msg, address string) (*miekgdns.Conn, error) {
+	conn, err := client.DialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= miekgdns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	}
+	// nolint:errcheck
+	conn.SetDeadline(time.Now().Add(d.timeout(msg)))
+	if err := conn.WriteMsg(msg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// exchangeValidated sends the message and validates the names of the raw response before unpacking it
+func (d *DNSX) exchangeValidated(ctx context.Context, client *miekgdns.Client, msg *miekgdns.Msg, address string) (*miekgdns.Msg, error) {
+	conn, err := d.sendMsg(ctx, client, msg, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer abortOnDone(ctx, conn)()
+	resp, err := readResponse(conn, msg.Id)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return resp, err
+}
+
+// abortOnDone makes the pending reads of the connection fail once the context is done, the
+// returned function stops watching the context
+func abortOnDone(ctx context.Context, conn *miekgdns.Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// nolint:errcheck
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// readResponse reads packets until the response to the query id is received. Responses having
+// malformed compression pointers are reported with ErrMalformedResponse instead of being
+// unpacked into truncated data. Truncated responses cut in the middle of a record are returned
+// with their header only, so that the query can be repeated over tcp.
+func readResponse(conn *miekgdns.Conn, id uint16) (*miekgdns.Msg, error) {
+	for {
+		raw, err := conn.ReadMsgHeader(nil)
+		if err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(raw) != id {
+			continue
+		}
+		resp := new(miekgdns.Msg)
+		err = validateNames(raw)
+		if err == nil {
+			err = resp.Unpack(raw)
+		}
+		if err != nil {
+			if truncatedHeader(raw) {
+				resp = new(miekgdns.Msg)
+				// the header alone has no sections to fail on
+				_ = resp.Unpack(raw[:headerLength])
+				return resp, nil
+			}
+			return nil, fmt.Errorf("%w: %s", ErrMalformedResponse, err)
+		}
+		return resp, nil
+	}
+}
+
+// truncatedHeader reports whether the raw message has a complete header with the TC bit set
+func truncatedHeader(raw []byte) bool {
+	return len(raw) >= headerLength && raw[2]&0x02 != 0
+}
+
+// validateNames walks the names of the raw message checking that every compression pointer
+// targets a previous offset of the message and that records don't overflow it
+func validateNames(raw []byte) error {
+	if len(raw) < headerLength {
+		return errors.New("short header")
+	}
+	questions := int(binary.BigEndian.Uint16(raw[4:]))
+	records := int(binary.BigEndian.Uint16(raw[6:])) + int(binary.BigEndian.Uint16(raw[8:])) + int(binary.BigEndian.Uint16(raw[10:]))
+
+	offset := headerLength
+	for i := 0; i < questions; i++ {
+		next, err := checkName(raw, offset)
+		if err != nil {
+			return err
+		}
+		// type and class
+		offset = next + 4
+	}
+	for i := 0; i < records; i++ {
+		next, err := checkName(raw, offset)
+		if err != nil {
+			return err
+		}
+		// type, class, ttl and rdata length
+		if next+10 > len(raw) {
+			return fmt.Errorf("record header at offset %d overflows message", next)
+		}
+		rrtype := binary.BigEndian.Uint16(raw[next:])
+		rdlength := int(binary.BigEndian.Uint16(raw[next+8:]))
+		start := next + 10
+		end := start + rdlength
+		if end > len(raw) {
+			return fmt.Errorf("record data at offset %d overflows message", start)
+		}
+		if err := checkRdataNames(raw[:end], start, rrtype); err != nil {
+			return err
+		}
+		offset = end
+	}
+	if offset > len(raw) {
+		return fmt.Errorf("question at offset %d overflows message", offset)
+	}
+	return nil
+}
+
+// checkRdataNames validates the compressible names contained in the record data
+func checkRdataNames(raw []byte, offset int, rrtype uint16) error {
+	var names int
+	switch rrtype {
+	case miekgdns.TypeCNAME, miekgdns.TypeNS, miekgdns.TypePTR:
+		names = 1
+	case miekgdns.TypeSOA:
+		names = 2
+	case miekgdns.TypeMX:
+		// preference
+		offset += 2
+		names = 1
+	}
+	for i := 0; i < names; i++ {
+		next, err := checkName(raw, offset)
+		if err != nil {
+			return err
+		}
+		offset = next
+	}
+	return nil
+}
+
+// checkName validates the name starting at the offset and returns the offset following it.
+// Pointers must target offsets before the previous pointer, which also rules out loops.
+func checkName(raw []byte, offset int) (int, error) {
+	next := -1
+	limit := offset
+	for {
+		if offset >= len(raw) {
+			return 0, fmt.Errorf("name at offset %d overflows message", offset)
+		}
+		label := int(raw[offset])
+		switch label & 0xC0 {
+		case 0x00:
+			if label == 0 {
+				if next < 0 {
+					next = offset + 1
+				}
+				return next, nil
+			}
+			offset += label + 1
+		case 0xC0:
+			if offset+1 >= len(raw) {
+				return 0, fmt.Errorf("compression pointer at offset %d overflows message", offset)
+			}
+			pointer := (label&0x3F)<<8 | int(raw[offset+1])
+			if pointer >= limit {
+				return 0, fmt.Errorf("compression pointer at offset %d targets forward offset %d", offset, pointer)
+			}
+			if pointer < headerLength {
+				return 0, fmt.Errorf("compression pointer at offset %d targets the header", offset)
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			limit = pointer
+			offset = pointer
+		default:
+			return 0, fmt.Errorf("invalid label type at offset %d", offset)
+		}
+	}
+}
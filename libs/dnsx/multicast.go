@@ -0,0 +1,168 @@
+package dnsx
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// MDNSAddresses are the IPv4 and IPv6 multicast DNS endpoints (RFC 6762)
+var MDNSAddresses = []string{"224.0.0.251:5353", "[ff02::fb]:5353"}
+
+// LLMNRAddresses are the IPv4 and IPv6 link-local multicast name resolution endpoints (RFC 4795)
+var LLMNRAddresses = []string{"224.0.0.252:5355", "[ff02::1:3]:5355"}
+
+// MulticastTimeout is the time spent collecting answers to a multicast query
+var MulticastTimeout = 2 * time.Second
+
+type multicastResponse struct {
+	from string
+	msg  *miekgdns.Msg
+}
+
+// QueryMulticast performs one-shot multicast queries of the configured types against the given
+// addresses and merges all the answers received before the timeout
+func (d *DNSX) QueryMulticast(hostname string, addresses []string) (*retryabledns.DNSData, error) {
+	dnsdata := &retryabledns.DNSData{Host: hostname}
+	seenResponders := make(map[string]struct{})
+	for _, questionType := range d.Options.QuestionTypes {
+		responses, err := exchangeMulticast(hostname, questionType, addresses, MulticastTimeout)
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range responses {
+			parseRecords(dnsdata, response.msg.Answer)
+			if _, ok := seenResponders[response.from]; !ok {
+				seenResponders[response.from] = struct{}{}
+				dnsdata.Resolver = append(dnsdata.Resolver, response.from)
+			}
+		}
+	}
+	// multicast responders stay silent for unknown names
+	dnsdata.StatusCodeRaw = miekgdns.RcodeSuccess
+	if len(dnsdata.Resolver) == 0 {
+		dnsdata.StatusCodeRaw = miekgdns.RcodeNameError
+	}
+	dnsdata.StatusCode = miekgdns.RcodeToString[dnsdata.StatusCodeRaw]
+	dnsdata.Timestamp = time.Now()
+	return dnsdata, nil
+}
+
+// exchangeMulticast sends the question to every address from an unconnected socket,
+// so that unicast answers coming from the responders addresses are received
+func exchangeMulticast(hostname string, questionType uint16, addresses []string, timeout time.Duration) ([]multicastResponse, error) {
+	msg := new(miekgdns.Msg)
+	msg.Id = miekgdns.Id()
+	msg.SetQuestion(miekgdns.Fqdn(hostname), questionType)
+	msg.RecursionDesired = false
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mutex     sync.Mutex
+		wg        sync.WaitGroup
+		responses []multicastResponse
+		lastErr   error
+		sent      int
+	)
+	var raddrs []*net.UDPAddr
+	for _, address := range addresses {
+		raddr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		raddrs = append(raddrs, multicastZones(raddr)...)
+	}
+	for _, raddr := range raddrs {
+		network := "udp4"
+		if raddr.IP.To4() == nil {
+			network = "udp6"
+		}
+		conn, err := net.ListenUDP(network, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if _, err := conn.WriteTo(packed, raddr); err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+		sent++
+
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			defer conn.Close()
+			// nolint:errcheck
+			conn.SetReadDeadline(time.Now().Add(timeout))
+			buf := make([]byte, miekgdns.MaxMsgSize)
+			for {
+				n, from, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				resp := new(miekgdns.Msg)
+				if err := resp.Unpack(buf[:n]); err != nil || !resp.Response {
+					continue
+				}
+				// responders may zero the id of one-shot queries
+				if resp.Id != 0 && resp.Id != msg.Id {
+					continue
+				}
+				mutex.Lock()
+				responses = append(responses, multicastResponse{from: from.String(), msg: resp})
+				mutex.Unlock()
+			}
+		}(conn)
+	}
+	wg.Wait()
+
+	if sent == 0 {
+		return nil, lastErr
+	}
+	return responses, nil
+}
+
+// multicastZones returns the address once for each multicast interface having an ipv6 address
+// when it is an ipv6 link-local multicast address without zone, such an address is only
+// reachable through an interface
+func multicastZones(raddr *net.UDPAddr) []*net.UDPAddr {
+	if raddr.IP.To4() != nil || !raddr.IP.IsLinkLocalMulticast() || raddr.Zone != "" {
+		return []*net.UDPAddr{raddr}
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var zoned []*net.UDPAddr
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if hasIPv6(&iface) {
+			zoned = append(zoned, &net.UDPAddr{IP: raddr.IP, Port: raddr.Port, Zone: iface.Name})
+		}
+	}
+	return zoned
+}
+
+// hasIPv6 reports whether the interface has an ipv6 address
+func hasIPv6(iface *net.Interface) bool {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package dnsx
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+func TestParseRecords(t *testing.T) {
+	records := []string{
+		"printer.local. 120 IN A 192.0.2.10",
+		"printer.local. 120 IN AAAA fe80::1",
+		"printer.local. 120 IN TXT \"path=/\" \"queue\"",
+		"local. 120 IN SOA ns.local. hostmaster.local. 1 7200 3600 1209600 3600",
+		"local. 120 IN NS ns.local.",
+	}
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+	dnsdata := &retryabledns.DNSData{}
+	parseRecords(dnsdata, rrs)
+
+	// the address and name fields must match the parsing of the unicast responses
+	expected := &retryabledns.DNSData{}
+	if err := expected.ParseFromMsg(&dns.Msg{Answer: rrs}); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"A", dnsdata.A, expected.A},
+		{"AAAA", dnsdata.AAAA, expected.AAAA},
+		{"SOA", dnsdata.SOA, expected.SOA},
+		{"NS", dnsdata.NS, expected.NS},
+	} {
+		if fmt.Sprint(field.got) != fmt.Sprint(field.want) {
+			t.Errorf("%s: got %v, want %v", field.name, field.got, field.want)
+		}
+	}
+	if fmt.Sprint(dnsdata.SOA) != "[ns.local hostmaster.local]" {
+		t.Errorf("got SOA %v", dnsdata.SOA)
+	}
+}
+
+func TestMulticastZones(t *testing.T) {
+	ipv4 := &net.UDPAddr{IP: net.ParseIP("224.0.0.251"), Port: 5353}
+	if zoned := multicastZones(ipv4); len(zoned) != 1 || zoned[0] != ipv4 {
+		t.Errorf("ipv4 address changed: %v", zoned)
+	}
+	withZone := &net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: 5353, Zone: "eth0"}
+	if zoned := multicastZones(withZone); len(zoned) != 1 || zoned[0] != withZone {
+		t.Errorf("zoned address changed: %v", zoned)
+	}
+	for _, zoned := range multicastZones(&net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: 5353}) {
+		iface, err := net.InterfaceByName(zoned.Zone)
+		if err != nil {
+			t.Fatalf("unknown zone %s", zoned.Zone)
+		}
+		if iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 || zoned.Port != 5353 {
+			t.Errorf("unexpected target %s", zoned)
+		}
+	}
+}
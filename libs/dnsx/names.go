@@ -0,0 +1,165 @@
+package dnsx
+
+import (
+	miekgdns "github.com/miekg/dns"
+)
+
+// Classes of the name violations
+const (
+	ViolationIllegalCharacter = "illegal-character"
+	ViolationLabelTooLong     = "label-too-long"
+	ViolationNameTooLong      = "name-too-long"
+	ViolationEmbeddedDot      = "embedded-dot"
+	ViolationEmbeddedNull     = "embedded-null"
+)
+
+const (
+	maxLabelLength = 63
+	// maxNameLength is the limit of the wire format of a name, length octets included
+	maxNameLength = 255
+)
+
+// NameViolation is a structural violation of a name of a record
+type NameViolation struct {
+	// Name is the name in presentation format, the offending octets escaped
+	Name string `json:"name"`
+	// Field is the part of the record holding the name (owner, target)
+	Field     string `json:"field"`
+	Type      string `json:"type"`
+	Violation string `json:"violation"`
+}
+
+// CheckNames checks the owner and target names of the records for illegal characters,
+// labels longer than 63 octets, names longer than 255 octets and dots or nulls embedded
+// in labels. Names whose wire format can't hold the violation are rejected when the
+// response is unpacked, they are checked anyway for the records built from other sources.
+func CheckNames(records []miekgdns.RR) []NameViolation {
+	var violations []NameViolation
+	seen := make(map[NameViolation]struct{})
+	check := func(rr miekgdns.RR, field, name string) {
+		for _, violation := range nameViolations(name, field == "owner") {
+			v := NameViolation{Name: name, Field: field, Type: miekgdns.TypeToString[rr.Header().Rrtype], Violation: violation}
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				violations = append(violations, v)
+			}
+		}
+	}
+	for _, rr := range records {
+		check(rr, "owner", rr.Header().Name)
+		for _, target := range targetNames(rr) {
+			check(rr, "target", target)
+		}
+	}
+	return violations
+}
+
+// targetNames returns the host names referenced by the record data, the SOA mailbox is
+// not a host name and is skipped
+func targetNames(rr miekgdns.RR) []string {
+	switch record := rr.(type) {
+	case *miekgdns.CNAME:
+		return []string{record.Target}
+	case *miekgdns.DNAME:
+		return []string{record.Target}
+	case *miekgdns.NS:
+		return []string{record.Ns}
+	case *miekgdns.PTR:
+		return []string{record.Ptr}
+	case *miekgdns.MX:
+		return []string{record.Mx}
+	case *miekgdns.SRV:
+		return []string{record.Target}
+	case *miekgdns.SOA:
+		return []string{record.Ns}
+	}
+	return nil
+}
+
+// nameViolations returns the violation classes of the name in presentation format, the
+// wildcard label is allowed first in owner names
+func nameViolations(name string, owner bool) []string {
+	labels, ok := decodeLabels(name)
+	if !ok {
+		return []string{ViolationIllegalCharacter}
+	}
+	found := make(map[string]struct{})
+	var violations []string
+	add := func(violation string) {
+		if _, ok := found[violation]; !ok {
+			found[violation] = struct{}{}
+			violations = append(violations, violation)
+		}
+	}
+	// root label
+	length := 1
+	for i, label := range labels {
+		length += len(label) + 1
+		if len(label) > maxLabelLength {
+			add(ViolationLabelTooLong)
+		}
+		if owner && i == 0 && string(label) == "*" {
+			continue
+		}
+		for _, c := range label {
+			switch {
+			case c == '.':
+				add(ViolationEmbeddedDot)
+			case c == 0:
+				add(ViolationEmbeddedNull)
+			case !hostnameCharacter(c):
+				add(ViolationIllegalCharacter)
+			}
+		}
+	}
+	if length > maxNameLength {
+		add(ViolationNameTooLong)
+	}
+	return violations
+}
+
+// hostnameCharacter reports whether the octet is a letter, a digit, a hyphen or an underscore
+func hostnameCharacter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// decodeLabels splits the name in presentation format into the octets of its labels,
+// resolving the \X and \DDD escapes
+func decodeLabels(name string) ([][]byte, bool) {
+	var (
+		labels [][]byte
+		label  []byte
+	)
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\\':
+			if i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]) {
+				value := int(name[i+1]-'0')*100 + int(name[i+2]-'0')*10 + int(name[i+3]-'0')
+				if value > 255 {
+					return nil, false
+				}
+				label = append(label, byte(value))
+				i += 3
+				continue
+			}
+			if i+1 >= len(name) {
+				return nil, false
+			}
+			label = append(label, name[i+1])
+			i++
+		case '.':
+			labels = append(labels, label)
+			label = nil
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		labels = append(labels, label)
+	}
+	return labels, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,118 @@
+package dnsx
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// fixturePacket packs a response holding the record and unpacks it, the names are read
+// back from the wire format like the ones of the resolvers
+func fixturePacket(t *testing.T, rr miekgdns.RR) []miekgdns.RR {
+	t.Helper()
+	msg := new(miekgdns.Msg)
+	msg.SetQuestion("example.com.", rr.Header().Rrtype)
+	msg.Response = true
+	msg.Answer = []miekgdns.RR{rr}
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("could not pack %s: %s", rr, err)
+	}
+	resp := new(miekgdns.Msg)
+	if err := resp.Unpack(data); err != nil {
+		t.Fatalf("could not unpack %s: %s", rr, err)
+	}
+	return resp.Answer
+}
+
+// roundTrips reports whether the records are packed and unpacked without error
+func roundTrips(records []miekgdns.RR) bool {
+	data, err := (&miekgdns.Msg{Answer: records}).Pack()
+	if err != nil {
+		return false
+	}
+	return new(miekgdns.Msg).Unpack(data) == nil
+}
+
+func TestCheckNames(t *testing.T) {
+	a := func(name string) miekgdns.RR {
+		return &miekgdns.A{Hdr: miekgdns.RR_Header{Name: name, Rrtype: miekgdns.TypeA, Class: miekgdns.ClassINET, Ttl: 60}, A: net.IPv4(192, 0, 2, 1)}
+	}
+	cname := func(target string) miekgdns.RR {
+		return &miekgdns.CNAME{Hdr: miekgdns.RR_Header{Name: "www.example.com.", Rrtype: miekgdns.TypeCNAME, Class: miekgdns.ClassINET, Ttl: 60}, Target: target}
+	}
+	long := strings.Repeat("a", 64)
+	// 5 labels of 63 octets, 321 octets in wire format
+	tooLong := strings.Repeat(strings.Repeat("b", 63)+".", 5)
+
+	tests := []struct {
+		name string
+		rr   miekgdns.RR
+		// wire is set when the violation fits in a packet, the other ones are rejected
+		// when the response is unpacked
+		wire bool
+		want []string
+	}{
+		{name: "valid", rr: a("www.example.com."), wire: true},
+		{name: "underscore", rr: a("_dmarc.example.com."), wire: true},
+		{name: "wildcard owner", rr: a("*.example.com."), wire: true},
+		{name: "wildcard target", rr: cname("*.example.com."), wire: true, want: []string{"target:illegal-character"}},
+		{name: "illegal character", rr: a("ex!ample.com."), wire: true, want: []string{"owner:illegal-character"}},
+		{name: "escaped space", rr: cname(`web\032server.example.com.`), wire: true, want: []string{"target:illegal-character"}},
+		{name: "high octet", rr: a(`\200.example.com.`), wire: true, want: []string{"owner:illegal-character"}},
+		{name: "embedded dot", rr: a(`www\.internal.example.com.`), wire: true, want: []string{"owner:embedded-dot"}},
+		{name: "embedded null", rr: cname(`www\000.example.com.`), wire: true, want: []string{"target:embedded-null"}},
+		{name: "several classes", rr: a(`a\.b\000c!.example.com.`), wire: true, want: []string{"owner:embedded-dot", "owner:embedded-null", "owner:illegal-character"}},
+		{name: "long label", rr: a(long + ".example.com."), want: []string{"owner:label-too-long"}},
+		{name: "long name", rr: cname(tooLong), want: []string{"target:name-too-long"}},
+		{name: "long label and name", rr: a(long + "." + tooLong), want: []string{"owner:label-too-long", "owner:name-too-long"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records := []miekgdns.RR{test.rr}
+			if test.wire {
+				records = fixturePacket(t, test.rr)
+			} else if roundTrips(records) {
+				t.Fatalf("%s was read back from a packet", test.rr)
+			}
+			var got []string
+			for _, violation := range CheckNames(records) {
+				if violation.Type != miekgdns.TypeToString[test.rr.Header().Rrtype] {
+					t.Errorf("got type %s for %s", violation.Type, violation.Name)
+				}
+				got = append(got, violation.Field+":"+violation.Violation)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got violations %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCheckNamesDuplicates checks that a violation repeated by the records of a set is
+// reported once
+func TestCheckNamesDuplicates(t *testing.T) {
+	var records []miekgdns.RR
+	for i := 1; i <= 3; i++ {
+		rr, err := miekgdns.NewRR(fmt.Sprintf(`ex!ample.com. 60 IN A 192.0.2.%d`, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rr)
+	}
+	mx, err := miekgdns.NewRR(`example.com. 60 IN MX 10 mail\.server.example.com.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records = append(records, mx)
+	violations := CheckNames(records)
+	if len(violations) != 2 {
+		t.Fatalf("got violations %+v, want 2", violations)
+	}
+	if violations[1].Field != "target" || violations[1].Type != "MX" || violations[1].Violation != ViolationEmbeddedDot {
+		t.Errorf("got violation %+v", violations[1])
+	}
+}
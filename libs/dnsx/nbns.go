@@ -0,0 +1,216 @@
+package dnsx
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	"github.com/projectdiscovery/iputil"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// NBNSBroadcastAddress is the default target of NetBIOS name queries
+const NBNSBroadcastAddress = "255.255.255.255"
+
+const (
+	nbnsPort          = 137
+	nbnsTypeNB        = 0x0020
+	nbnsTypeNBSTAT    = 0x0021
+	nbnsClassIN       = 0x0001
+	nbnsFlagBroadcast = 0x0110
+	nbnsHeaderSize    = 12
+	nbnsNameSize      = 15
+	nbnsGroupFlag     = 0x8000
+)
+
+var errInvalidNBNSResponse = errors.New("invalid nbns response")
+
+// QueryNBNS resolves a NetBIOS name to its IPv4 addresses, or the names registered by
+// a host when an IP is given, sending the query to the target address (broadcast or unicast)
+func QueryNBNS(host, target string, timeout time.Duration) (*retryabledns.DNSData, error) {
+	dnsdata := &retryabledns.DNSData{Host: host}
+	seenResponders := make(map[string]struct{})
+
+	var (
+		questionType uint16
+		name         []byte
+	)
+	if iputil.IsIPv4(host) {
+		// node status request to the host itself
+		questionType = nbnsTypeNBSTAT
+		name = encodeNetBIOSName("*", 0x00, 0x00)
+		target = host
+	} else {
+		questionType = nbnsTypeNB
+		name = encodeNetBIOSName(strings.ToUpper(host), ' ', 0x00)
+	}
+
+	responses, err := exchangeNBNS(name, questionType, target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	for _, response := range responses {
+		if _, ok := seenResponders[response.from]; !ok {
+			seenResponders[response.from] = struct{}{}
+			dnsdata.Resolver = append(dnsdata.Resolver, response.from)
+		}
+		switch questionType {
+		case nbnsTypeNB:
+			dnsdata.A = append(dnsdata.A, parseNBAddresses(response.rdata)...)
+		case nbnsTypeNBSTAT:
+			dnsdata.PTR = append(dnsdata.PTR, parseNBStatNames(response.rdata)...)
+		}
+	}
+
+	dnsdata.StatusCodeRaw = miekgdns.RcodeSuccess
+	if len(dnsdata.A) == 0 && len(dnsdata.PTR) == 0 {
+		dnsdata.StatusCodeRaw = miekgdns.RcodeNameError
+	}
+	dnsdata.StatusCode = miekgdns.RcodeToString[dnsdata.StatusCodeRaw]
+	dnsdata.Timestamp = time.Now()
+	return dnsdata, nil
+}
+
+type nbnsResponse struct {
+	from  string
+	rdata []byte
+}
+
+// exchangeNBNS sends the question and collects the answers, broadcast queries are
+// answered by every host owning the name so they are read until the timeout
+func exchangeNBNS(name []byte, questionType uint16, target string, timeout time.Duration) ([]nbnsResponse, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(target, strconv.Itoa(nbnsPort)))
+	if err != nil {
+		return nil, err
+	}
+	broadcast := raddr.IP.Equal(net.IPv4bcast)
+
+	id := miekgdns.Id()
+	packet := make([]byte, nbnsHeaderSize, nbnsHeaderSize+len(name)+4)
+	binary.BigEndian.PutUint16(packet[0:], id)
+	if broadcast {
+		binary.BigEndian.PutUint16(packet[2:], nbnsFlagBroadcast)
+	}
+	binary.BigEndian.PutUint16(packet[4:], 1)
+	packet = append(packet, name...)
+	packet = append(packet, byte(questionType>>8), byte(questionType), 0, nbnsClassIN)
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteTo(packet, raddr); err != nil {
+		return nil, err
+	}
+
+	// nolint:errcheck
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	var responses []nbnsResponse
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		rdata, err := parseNBNSResponse(buf[:n], id)
+		if err != nil {
+			continue
+		}
+		responses = append(responses, nbnsResponse{from: from.String(), rdata: rdata})
+		if !broadcast {
+			break
+		}
+	}
+	return responses, nil
+}
+
+// parseNBNSResponse validates the header of a positive response and returns the rdata of its answer
+func parseNBNSResponse(packet []byte, id uint16) ([]byte, error) {
+	if len(packet) < nbnsHeaderSize || binary.BigEndian.Uint16(packet[0:]) != id {
+		return nil, errInvalidNBNSResponse
+	}
+	flags := binary.BigEndian.Uint16(packet[2:])
+	// response bit set and no error code
+	if flags&0x8000 == 0 || flags&0x000f != 0 || binary.BigEndian.Uint16(packet[6:]) == 0 {
+		return nil, errInvalidNBNSResponse
+	}
+	offset := nbnsHeaderSize
+	// skip the answer name, either a pointer or a sequence of labels
+	for offset < len(packet) {
+		length := int(packet[offset])
+		if length&0xc0 == 0xc0 {
+			offset += 2
+			break
+		}
+		offset += length + 1
+		if length == 0 {
+			break
+		}
+	}
+	// type, class, ttl and rdlength
+	if offset+10 > len(packet) {
+		return nil, errInvalidNBNSResponse
+	}
+	rdlength := int(binary.BigEndian.Uint16(packet[offset+8:]))
+	offset += 10
+	if offset+rdlength > len(packet) {
+		return nil, errInvalidNBNSResponse
+	}
+	return packet[offset : offset+rdlength], nil
+}
+
+// parseNBAddresses returns the IPv4 addresses of the NB records (2 bytes flags + 4 bytes address)
+func parseNBAddresses(rdata []byte) []string {
+	var addresses []string
+	for i := 0; i+6 <= len(rdata); i += 6 {
+		addresses = append(addresses, net.IP(rdata[i+2:i+6]).String())
+	}
+	return addresses
+}
+
+// parseNBStatNames returns the unique workstation names of the node status response
+func parseNBStatNames(rdata []byte) []string {
+	if len(rdata) == 0 {
+		return nil
+	}
+	var names []string
+	count := int(rdata[0])
+	for i := 0; i < count; i++ {
+		offset := 1 + i*18
+		if offset+18 > len(rdata) {
+			break
+		}
+		suffix := rdata[offset+nbnsNameSize]
+		flags := binary.BigEndian.Uint16(rdata[offset+16:])
+		if suffix != 0x00 || flags&nbnsGroupFlag != 0 {
+			continue
+		}
+		names = append(names, strings.TrimSpace(string(rdata[offset:offset+nbnsNameSize])))
+	}
+	return names
+}
+
+// encodeNetBIOSName returns the first level encoding (RFC 1001) of the padded name
+func encodeNetBIOSName(name string, padding, suffix byte) []byte {
+	if len(name) > nbnsNameSize {
+		name = name[:nbnsNameSize]
+	}
+	raw := make([]byte, nbnsNameSize+1)
+	for i := range raw {
+		raw[i] = padding
+	}
+	copy(raw, name)
+	raw[nbnsNameSize] = suffix
+
+	encoded := make([]byte, 0, 34)
+	encoded = append(encoded, 32)
+	for _, c := range raw {
+		encoded = append(encoded, 'A'+(c>>4), 'A'+(c&0x0f))
+	}
+	return append(encoded, 0)
+}
@@ -0,0 +1,30 @@
+package dnsx
+
+import (
+	"context"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// OpenResolverProbe is the external name resolved to test the recursion of a resolver, its
+// authoritative servers answer with the address of the server querying them
+const OpenResolverProbe = "whoami.akamai.net"
+
+// IsOpenResolver reports whether the resolver performs recursion for external names, that is
+// it resolves the probe name instead of refusing it or answering without recursion
+func (d *DNSX) IsOpenResolver(value string) (bool, error) {
+	r := newResolver(value)
+	resp, _, err := d.exchangeWith(context.Background(), r, newQuestion(OpenResolverProbe, miekgdns.TypeA), d.Options.Transport)
+	if err != nil {
+		return false, err
+	}
+	if resp.Rcode != miekgdns.RcodeSuccess || !resp.RecursionAvailable {
+		return false, nil
+	}
+	for _, answer := range resp.Answer {
+		if _, ok := answer.(*miekgdns.A); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,249 @@
+package dnsx
+
+import (
+	"hash/fnv"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// healthSmoothing is the weight of the latest sample in the rolling statistics
+const healthSmoothing = 0.2
+
+// resolver is a dns server along with its rolling health statistics
+type resolver struct {
+	protocol string
+	address  string
+	subnet   string
+
+	sync.Mutex
+	latency   time.Duration
+	errorRate float64
+	queries   uint64
+	errors    uint64
+
+	capabilities capabilities
+	cookies      cookieJar
+}
+
+// ResolverHealth contains the health statistics of a resolver
+type ResolverHealth struct {
+	Resolver  string  `json:"resolver"`
+	Queries   uint64  `json:"queries"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	LatencyMs int64   `json:"latency_ms"`
+
+	ServerCapabilities *ServerCapabilities `json:"server_capabilities,omitempty"`
+}
+
+// newResolver parses a resolver in the [protocol:]host[:port] format
+func newResolver(value string) *resolver {
+	value = strings.TrimSpace(value)
+	protocol := "udp"
+	for _, prefix := range []string{"udp:", "tcp:"} {
+		if strings.HasPrefix(value, prefix) {
+			protocol = strings.TrimSuffix(prefix, ":")
+			value = strings.TrimPrefix(value, prefix)
+		}
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		value = net.JoinHostPort(strings.Trim(value, "[]"), "53")
+	}
+	return &resolver{protocol: protocol, address: value, subnet: resolverSubnet(value)}
+}
+
+// resolverSubnet returns the /24 (IPv4) or /48 (IPv6) network of the resolver,
+// resolvers of the same provider tend to be clustered and fail together
+func resolverSubnet(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// record updates the rolling statistics with the outcome of a query
+func (r *resolver) record(latency time.Duration, failed bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.queries++
+	failure := 0.0
+	if failed {
+		r.errors++
+		failure = 1
+	}
+	if r.queries == 1 {
+		r.latency = latency
+		r.errorRate = failure
+		return
+	}
+	r.latency = time.Duration(healthSmoothing*float64(latency) + (1-healthSmoothing)*float64(r.latency))
+	r.errorRate = healthSmoothing*failure + (1-healthSmoothing)*r.errorRate
+}
+
+// score orders resolvers from the fastest healthiest to the worst, lower is better.
+// Resolvers without samples get a neutral score so that they are tried as well.
+func (r *resolver) score() float64 {
+	r.Lock()
+	defer r.Unlock()
+	if r.queries == 0 {
+		return 0.5 * float64(time.Second)
+	}
+	return float64(r.latency) * (1 + 10*r.errorRate)
+}
+
+func (r *resolver) health(withCapabilities bool) ResolverHealth {
+	r.Lock()
+	health := ResolverHealth{
+		Resolver:  r.String(),
+		Queries:   r.queries,
+		Errors:    r.errors,
+		ErrorRate: r.errorRate,
+		LatencyMs: r.latency.Milliseconds(),
+	}
+	r.Unlock()
+	if withCapabilities {
+		health.ServerCapabilities = r.capabilities.snapshot()
+	}
+	return health
+}
+
+func (r *resolver) String() string {
+	return r.address
+}
+
+// rankingInterval is the maximum age of the ranking of the resolvers used by the retries
+const rankingInterval = 100 * time.Millisecond
+
+// resolverRanking contains the resolvers ordered from the healthiest
+type resolverRanking struct {
+	resolvers []*resolver
+	at        time.Time
+}
+
+// resolverPool selects the resolvers used for each attempt
+type resolverPool struct {
+	resolvers  []*resolver
+	index      uint32
+	ranking    atomic.Value
+	refreshing int32
+}
+
+func newResolverPool(values []string) *resolverPool {
+	pool := &resolverPool{}
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		pool.resolvers = append(pool.resolvers, newResolver(value))
+	}
+	return pool
+}
+
+// next returns the resolver for a first attempt in round robin
+func (p *resolverPool) next() *resolver {
+	index := atomic.AddUint32(&p.index, 1)
+	return p.resolvers[int(index)%len(p.resolvers)]
+}
+
+// pinned returns the resolver assigned to the registered domain of the name, so that the
+// first attempts of the names of a zone always reach the same resolver
+func (p *resolverPool) pinned(name string) *resolver {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		name = domain
+	}
+	hash := fnv.New32a()
+	// nolint:errcheck
+	hash.Write([]byte(name))
+	return p.resolvers[int(hash.Sum32()%uint32(len(p.resolvers)))]
+}
+
+// retry returns the healthiest resolver not among the failed ones, preferring
+// a different subnet than the last failed resolver
+func (p *resolverPool) retry(failed []*resolver) *resolver {
+	last := failed[len(failed)-1]
+	var bestSameSubnet *resolver
+	for _, candidate := range p.ranked() {
+		if containsResolver(failed, candidate) {
+			continue
+		}
+		if candidate.subnet != last.subnet {
+			return candidate
+		}
+		if bestSameSubnet == nil {
+			bestSameSubnet = candidate
+		}
+	}
+	if bestSameSubnet != nil {
+		return bestSameSubnet
+	}
+	// every resolver failed already, start over
+	return p.next()
+}
+
+// ranked returns the resolvers ordered from the healthiest. The ranking is shared by the
+// retries and refreshed by one of them once it's older than the ranking interval, so that
+// a retry doesn't lock every resolver of the pool.
+func (p *resolverPool) ranked() []*resolver {
+	current, _ := p.ranking.Load().(*resolverRanking)
+	if current != nil && time.Since(current.at) < rankingInterval {
+		return current.resolvers
+	}
+	if !atomic.CompareAndSwapInt32(&p.refreshing, 0, 1) {
+		// another retry is refreshing the ranking
+		if current != nil {
+			return current.resolvers
+		}
+		return p.resolvers
+	}
+	defer atomic.StoreInt32(&p.refreshing, 0)
+
+	type scored struct {
+		resolver *resolver
+		score    float64
+	}
+	scores := make([]scored, len(p.resolvers))
+	for i, resolver := range p.resolvers {
+		scores[i] = scored{resolver: resolver, score: resolver.score()}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score < scores[j].score
+	})
+	ranking := &resolverRanking{resolvers: make([]*resolver, len(scores)), at: time.Now()}
+	for i, item := range scores {
+		ranking.resolvers[i] = item.resolver
+	}
+	p.ranking.Store(ranking)
+	return ranking.resolvers
+}
+
+func (p *resolverPool) health(withCapabilities bool) []ResolverHealth {
+	var health []ResolverHealth
+	for _, resolver := range p.resolvers {
+		health = append(health, resolver.health(withCapabilities))
+	}
+	return health
+}
+
+func containsResolver(resolvers []*resolver, r *resolver) bool {
+	for _, item := range resolvers {
+		if item == r {
+			return true
+		}
+	}
+	return false
+}
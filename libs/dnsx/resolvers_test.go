@@ -0,0 +1,110 @@
+package dnsx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+func TestResolverPoolPinned(t *testing.T) {
+	pool := newResolverPool([]string{"192.0.2.1", "192.0.2.2", "198.51.100.1", "198.51.100.2", "203.0.113.1"})
+
+	// the names of a registered domain share the resolver of the domain
+	tests := []struct {
+		domain string
+		names  []string
+	}{
+		{domain: "example.com", names: []string{"www.example.com", "www.example.com.", "WWW.Example.COM", "a.b.c.example.com"}},
+		{domain: "example.co.uk", names: []string{"www.example.co.uk", "mail.example.co.uk."}},
+		// the names without a registered domain are pinned on their own
+		{domain: "localhost", names: []string{"localhost."}},
+	}
+	for _, test := range tests {
+		want := pool.pinned(test.domain)
+		for _, name := range test.names {
+			if got := pool.pinned(name); got != want {
+				t.Errorf("%s: got %s, want %s of %s", name, got, want, test.domain)
+			}
+		}
+	}
+
+	want := pool.pinned("example.com")
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("host%d.example.com", i)
+		if got := pool.pinned(name); got != want {
+			t.Fatalf("%s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+// TestResolverPoolPinnedDistribution checks that the registered domains are spread evenly
+// across the resolvers
+func TestResolverPoolPinnedDistribution(t *testing.T) {
+	tests := []struct {
+		resolvers int
+		domains   int
+	}{
+		{resolvers: 2, domains: 2000},
+		{resolvers: 5, domains: 5000},
+		{resolvers: 16, domains: 16000},
+	}
+	for _, test := range tests {
+		var values []string
+		for i := 0; i < test.resolvers; i++ {
+			values = append(values, fmt.Sprintf("192.0.2.%d", i+1))
+		}
+		pool := newResolverPool(values)
+		counts := make(map[*resolver]int)
+		for i := 0; i < test.domains; i++ {
+			counts[pool.pinned(fmt.Sprintf("www.domain%d.com", i))]++
+		}
+		if len(counts) != test.resolvers {
+			t.Errorf("%d resolvers: got %d resolvers used", test.resolvers, len(counts))
+		}
+		// every resolver gets the mean number of domains within 15%
+		mean := test.domains / test.resolvers
+		for resolver, count := range counts {
+			if count < mean*85/100 || count > mean*115/100 {
+				t.Errorf("%d resolvers: got %d domains on %s, want %d", test.resolvers, count, resolver, mean)
+			}
+		}
+	}
+}
+
+func TestExchangePinned(t *testing.T) {
+	options := DefaultOptions
+	options.BaseResolvers = []string{newTestServer(t, answerMX(nil)), newTestServer(t, answerMX(nil)), newTestServer(t, answerMX(nil))}
+	options.Hostsfile = false
+	options.Transport = TransportUDP
+	options.Timeout = time.Second
+	options.PinResolvers = true
+	client, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		domain string
+		hosts  int
+	}{
+		{domain: "example.com", hosts: 100},
+		{domain: "example.org", hosts: 100},
+		{domain: "example.net", hosts: 100},
+	}
+	for _, test := range tests {
+		used := make(map[string]int)
+		for i := 0; i < test.hosts; i++ {
+			result, err := client.exchange(context.Background(), newQuestion(fmt.Sprintf("host%d.%s", i, test.domain), miekgdns.TypeA), TransportUDP)
+			if err != nil {
+				t.Fatal(err)
+			}
+			used[result.resolver.address]++
+		}
+		if len(used) != 1 {
+			t.Errorf("%s: got resolvers %v, want one", test.domain, used)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dnsx
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// exchangeWatching sends the message over udp and keeps the socket open for the spoof window
+// after the first response. Late responses to the same query whose answers differ from the
+// first one are returned as conflicting.
+func (d *DNSX) exchangeWatching(ctx context.Context, client *miekgdns.Client, msg *miekgdns.Msg, address string) (*miekgdns.Msg, []*miekgdns.Msg, error) {
+	conn, err := d.sendMsg(ctx, client, msg, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	defer abortOnDone(ctx, conn)()
+	resp, err := readResponse(conn, msg.Id)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	// nolint:errcheck
+	conn.SetReadDeadline(time.Now().Add(d.Options.SpoofWindow))
+	answers := answerKey(resp)
+	var conflicting []*miekgdns.Msg
+	for {
+		duplicate, err := conn.ReadMsg()
+		if err != nil {
+			// the window is over, malformed packets are ignored
+			if _, ok := err.(net.Error); ok {
+				break
+			}
+			continue
+		}
+		if duplicate.Id == msg.Id && answerKey(duplicate) != answers {
+			conflicting = append(conflicting, duplicate)
+		}
+	}
+	return resp, conflicting, nil
+}
+
+// answerKey returns the answers of the response without ttls and regardless of their order
+func answerKey(msg *miekgdns.Msg) string {
+	records := make([]string, 0, len(msg.Answer))
+	for _, answer := range msg.Answer {
+		record := miekgdns.Copy(answer)
+		record.Header().Ttl = 0
+		records = append(records, record.String())
+	}
+	sort.Strings(records)
+	return miekgdns.RcodeToString[msg.Rcode] + "\n" + strings.Join(records, "\n")
+}
@@ -0,0 +1,155 @@
+package dnsx
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Trace performs a DNS trace of the specified types and returns raw responses. The names of
+// the hosts file are answered by it alone. The walk starts from the trace start servers, from
+// the base resolvers when the hosts file maps the apex of the name (an internal zone unknown
+// to the public roots) and from the public roots otherwise.
+func (d *DNSX) Trace(hostname string) (*retryabledns.TraceData, error) {
+	if d.hostsFile != nil {
+		if dnsdata, _ := d.hostsFile.Answer(hostname); dnsdata.StatusCodeRaw == miekgdns.RcodeSuccess {
+			dnsdata.RawResp = localResponse(dnsdata)
+			return &retryabledns.TraceData{DNSData: []*retryabledns.DNSData{dnsdata}}, nil
+		}
+	}
+	servers := d.Options.TraceStartServers
+	if len(servers) == 0 && d.hostsFile != nil && d.hostsFile.hasApex(hostname) {
+		servers = d.Options.BaseResolvers
+	}
+	if len(servers) == 0 {
+		return d.dnsClient.Trace(hostname, d.Options.QuestionTypes[0], d.Options.TraceMaxRecursion)
+	}
+	return d.traceFrom(hostname, servers), nil
+}
+
+// hasApex reports whether the hosts file maps the registered domain of the name
+func (h *HostsFile) hasApex(hostname string) bool {
+	apex, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(strings.TrimSuffix(hostname, ".")))
+	if err != nil {
+		return false
+	}
+	_, ok := h.names[apex]
+	return ok
+}
+
+// traceFrom follows the delegations of the name from the servers like the trace of the
+// public roots, the steps having the same format. The addresses of the name servers come
+// from the glue of the referrals, or are resolved by the base resolvers.
+func (d *DNSX) traceFrom(hostname string, servers []string) *retryabledns.TraceData {
+	tracedata := &retryabledns.TraceData{}
+	host := miekgdns.CanonicalName(hostname)
+	questionType := d.Options.QuestionTypes[0]
+	seen := make(map[string]struct{})
+	for i := 1; i < d.Options.TraceMaxRecursion; i++ {
+		steps := d.traceStep(host, questionType, servers)
+		for _, server := range servers {
+			seen[newResolver(server).address] = struct{}{}
+		}
+		if len(steps) == 0 {
+			break
+		}
+		tracedata.DNSData = append(tracedata.DNSData, steps...)
+
+		var next []string
+		var cname string
+		for _, step := range steps {
+			next = append(next, d.nameServerAddresses(step)...)
+			if cname == "" && len(step.CNAME) > 0 {
+				cname = step.CNAME[0]
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		server := next[rand.Intn(len(next))]
+		if _, ok := seen[server]; ok && cname == "" {
+			break
+		}
+		servers = []string{server}
+		if cname != "" {
+			host = miekgdns.CanonicalName(cname)
+		}
+	}
+	return tracedata
+}
+
+// traceStep sends the question to the servers in parallel and returns the responses received
+func (d *DNSX) traceStep(host string, questionType uint16, servers []string) []*retryabledns.DNSData {
+	steps := make([]*retryabledns.DNSData, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, r *resolver) {
+			defer wg.Done()
+			msg := new(miekgdns.Msg)
+			msg.SetQuestion(host, questionType)
+			resp, _, err := d.exchangeWith(context.Background(), r, msg, d.Options.Transport)
+			if err != nil {
+				return
+			}
+			dnsdata := &retryabledns.DNSData{}
+			if err := dnsdata.ParseFromMsg(resp); err != nil {
+				return
+			}
+			dnsdata.Host = host
+			dnsdata.StatusCode = miekgdns.RcodeToString[resp.Rcode]
+			dnsdata.StatusCodeRaw = resp.Rcode
+			dnsdata.Timestamp = time.Now()
+			dnsdata.Resolver = []string{r.address}
+			dnsdata.RawResp = resp
+			dnsdata.Raw = resp.String()
+			steps[i] = dnsdata
+		}(i, newResolver(server))
+	}
+	wg.Wait()
+
+	var received []*retryabledns.DNSData
+	for _, step := range steps {
+		if step != nil {
+			received = append(received, step)
+		}
+	}
+	return received
+}
+
+// nameServerAddresses returns the ipv4 addresses of the name servers of a referral, from its
+// glue records or resolved by the base resolvers
+func (d *DNSX) nameServerAddresses(step *retryabledns.DNSData) []string {
+	var addresses []string
+	seen := make(map[string]struct{})
+	for _, ns := range step.NS {
+		var ips []string
+		if step.RawResp != nil {
+			for _, rr := range step.RawResp.Extra {
+				if a, ok := rr.(*miekgdns.A); ok && strings.EqualFold(a.Hdr.Name, miekgdns.Fqdn(ns)) {
+					ips = append(ips, a.A.String())
+				}
+			}
+		}
+		if len(ips) == 0 {
+			ips, _ = d.Lookup(ns)
+		}
+		for _, ip := range ips {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				address := net.JoinHostPort(ip, "53")
+				if _, ok := seen[address]; !ok {
+					seen[address] = struct{}{}
+					addresses = append(addresses, address)
+				}
+			}
+		}
+	}
+	return addresses
+}
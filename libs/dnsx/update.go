@@ -0,0 +1,92 @@
+package dnsx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+// tsigFudge is the time difference in seconds allowed between the signer and the server
+const tsigFudge = 300
+
+// TSIGKey is a transaction signature key (rfc 8945)
+type TSIGKey struct {
+	Name      string
+	Algorithm string
+	Secret    string
+}
+
+// tsigAlgorithms are the supported algorithms by their short names
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   miekgdns.HmacSHA1,
+	"hmac-sha224": miekgdns.HmacSHA224,
+	"hmac-sha256": miekgdns.HmacSHA256,
+	"hmac-sha384": miekgdns.HmacSHA384,
+	"hmac-sha512": miekgdns.HmacSHA512,
+}
+
+// ParseTSIGKey parses a key in the [algorithm:]name:secret format, the secret being base64
+// encoded and the algorithm hmac-sha256 by default
+func ParseTSIGKey(value string) (*TSIGKey, error) {
+	parts := strings.Split(value, ":")
+	algorithm := "hmac-sha256"
+	if len(parts) == 3 {
+		algorithm, parts = strings.ToLower(parts[0]), parts[1:]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid tsig key %s, expected [algorithm:]name:secret", value)
+	}
+	fqdnAlgorithm, ok := tsigAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tsig algorithm %s", algorithm)
+	}
+	return &TSIGKey{Name: miekgdns.Fqdn(strings.ToLower(parts[0])), Algorithm: fqdnAlgorithm, Secret: parts[1]}, nil
+}
+
+// Update sends a dynamic update (rfc 2136) of the zone to the first resolver over tcp, the
+// records of insert are added and the ones of remove are deleted. Remove records having no
+// data delete the whole rrset of their type, or all the rrsets of their name for type ANY.
+// The update is signed when a key is provided.
+func (d *DNSX) Update(zone string, insert, remove []miekgdns.RR, key *TSIGKey) error {
+	if len(d.resolvers.resolvers) == 0 {
+		return errNoResolvers
+	}
+	msg := new(miekgdns.Msg)
+	msg.SetUpdate(miekgdns.Fqdn(zone))
+	if len(insert) > 0 {
+		msg.Insert(insert)
+	}
+	for _, rr := range remove {
+		switch {
+		case rr.Header().Rrtype == miekgdns.TypeANY:
+			msg.RemoveName([]miekgdns.RR{rr})
+		case isEmptyRdata(rr):
+			msg.RemoveRRset([]miekgdns.RR{rr})
+		default:
+			msg.Remove([]miekgdns.RR{rr})
+		}
+	}
+
+	client := d.newClient("tcp", msg)
+	if key != nil {
+		client.TsigSecret = map[string]string{key.Name: key.Secret}
+		msg.SetTsig(key.Name, key.Algorithm, tsigFudge, time.Now().Unix())
+	}
+	resp, _, err := client.Exchange(msg, d.resolvers.resolvers[0].address)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != miekgdns.RcodeSuccess {
+		return errors.New("update refused: " + miekgdns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// isEmptyRdata reports whether the record was parsed without any data, its packed length is
+// the one of its header alone
+func isEmptyRdata(rr miekgdns.RR) bool {
+	return miekgdns.Len(rr) == miekgdns.Len(&miekgdns.RFC3597{Hdr: *rr.Header()})
+}
@@ -2,9 +2,11 @@ package dnsx
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
 )
 
 // StringToRequestType conversion helper
@@ -27,6 +29,8 @@ func StringToRequestType(tp string) (rt uint16, err error) {
 		rt = dns.TypeTXT
 	case "AAAA":
 		rt = dns.TypeAAAA
+	case "DNAME":
+		rt = dns.TypeDNAME
 	default:
 		rt = dns.TypeNone
 		err = fmt.Errorf("incorrect type")
@@ -34,3 +38,46 @@ func StringToRequestType(tp string) (rt uint16, err error) {
 
 	return
 }
+
+// ptrName returns the reverse name of an IPv4 or IPv6 address, including the compressed,
+// bracketed and zoned IPv6 forms. IPv4-mapped addresses use the in-addr.arpa name, the
+// other hostnames are returned as is.
+func ptrName(hostname string) string {
+	address := strings.TrimSuffix(strings.TrimPrefix(hostname, "["), "]")
+	if index := strings.IndexByte(address, '%'); index >= 0 {
+		address = address[:index]
+	}
+	if net.ParseIP(address) == nil {
+		return hostname
+	}
+	reverse, err := dns.ReverseAddr(address)
+	if err != nil {
+		return hostname
+	}
+	return reverse
+}
+
+// parseRecords appends the values of the supported records to the dns data
+func parseRecords(dnsdata *retryabledns.DNSData, rrs []dns.RR) {
+	for _, rr := range rrs {
+		switch record := rr.(type) {
+		case *dns.A:
+			dnsdata.A = append(dnsdata.A, record.A.String())
+		case *dns.AAAA:
+			dnsdata.AAAA = append(dnsdata.AAAA, record.AAAA.String())
+		case *dns.CNAME:
+			dnsdata.CNAME = append(dnsdata.CNAME, strings.TrimSuffix(record.Target, "."))
+		case *dns.PTR:
+			dnsdata.PTR = append(dnsdata.PTR, strings.TrimSuffix(record.Ptr, "."))
+		case *dns.MX:
+			dnsdata.MX = append(dnsdata.MX, strings.TrimSuffix(record.Mx, "."))
+		case *dns.NS:
+			dnsdata.NS = append(dnsdata.NS, strings.TrimSuffix(record.Ns, "."))
+		case *dns.SOA:
+			// primary name server and mailbox of the zone, like the responses of the resolvers
+			dnsdata.SOA = append(dnsdata.SOA, strings.TrimSuffix(record.Ns, "."), strings.TrimSuffix(record.Mbox, "."))
+		case *dns.TXT:
+			dnsdata.TXT = append(dnsdata.TXT, strings.Join(record.Txt, ""))
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package dnsx
+
+import "testing"
+
+func TestPTRName(t *testing.T) {
+	const v6 = "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{hostname: "192.0.2.1", want: "1.2.0.192.in-addr.arpa."},
+		{hostname: "2001:db8::1", want: v6},
+		{hostname: "2001:0db8:0000:0000:0000:0000:0000:0001", want: v6},
+		{hostname: "2001:DB8::1", want: v6},
+		{hostname: "[2001:db8::1]", want: v6},
+		{hostname: "fe80::1%eth0", want: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.e.f.ip6.arpa."},
+		{hostname: "::", want: "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa."},
+		// the ipv4-mapped addresses are reversed as ipv4 ones
+		{hostname: "::ffff:192.0.2.1", want: "1.2.0.192.in-addr.arpa."},
+		{hostname: "www.example.com", want: "www.example.com"},
+		{hostname: "[www.example.com]", want: "[www.example.com]"},
+		{hostname: "2001:db8::zz", want: "2001:db8::zz"},
+	}
+	for _, test := range tests {
+		if got := ptrName(test.hostname); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.hostname, got, test.want)
+		}
+	}
+}
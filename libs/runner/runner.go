@@ -0,0 +1,199 @@
+// Package runner runs dnsx scans from go programs with the defaults of the command line
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dnsxrunner "github.com/projectdiscovery/dnsx/internal/runner"
+)
+
+// Result is the result of a resolved host, the fields are the ones of the json output of the
+// command line with the same names
+type Result struct {
+	// Host is the queried host and Input the input line it was generated from
+	Host  string `json:"host"`
+	Input string `json:"input,omitempty"`
+	// StatusCode is the name of the response code (NOERROR, NXDOMAIN, ...)
+	StatusCode string   `json:"status_code,omitempty"`
+	TTL        int      `json:"ttl,omitempty"`
+	Resolver   []string `json:"resolver,omitempty"`
+	// Source is where the answer came from: network, cache or hosts-file
+	Source    string            `json:"source,omitempty"`
+	A         []string          `json:"a,omitempty"`
+	AAAA      []string          `json:"aaaa,omitempty"`
+	CNAME     []string          `json:"cname,omitempty"`
+	NS        []string          `json:"ns,omitempty"`
+	TXT       []string          `json:"txt,omitempty"`
+	PTR       []string          `json:"ptr,omitempty"`
+	MX        []string          `json:"mx,omitempty"`
+	SOA       []string          `json:"soa,omitempty"`
+	DNAME     []string          `json:"dname,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"timestamp,omitempty"`
+}
+
+// newResult copies the fields of the library result from the result of the runner
+func newResult(result *dnsxrunner.Result) *Result {
+	converted := &Result{
+		Input:  result.Input,
+		Source: result.Source,
+		DNAME:  result.DNAME,
+		Labels: result.Labels,
+	}
+	if data := result.DNSData; data != nil {
+		converted.Host = data.Host
+		converted.StatusCode = data.StatusCode
+		converted.TTL = data.TTL
+		converted.Resolver = data.Resolver
+		converted.A = data.A
+		converted.AAAA = data.AAAA
+		converted.CNAME = data.CNAME
+		converted.NS = data.NS
+		converted.TXT = data.TXT
+		converted.PTR = data.PTR
+		converted.MX = data.MX
+		converted.SOA = data.SOA
+		converted.Timestamp = data.Timestamp
+	}
+	return converted
+}
+
+// Option configures the options of a runner
+type Option func(options *dnsxrunner.Options) error
+
+// WithResolvers sets the resolvers of the queries (ip, ip:port or protocol:ip:port)
+func WithResolvers(resolvers ...string) Option {
+	return func(options *dnsxrunner.Options) error {
+		if len(resolvers) == 0 {
+			return fmt.Errorf("no resolvers provided")
+		}
+		options.Resolvers = strings.Join(resolvers, dnsxrunner.Comma)
+		return nil
+	}
+}
+
+// WithQuestionTypes sets the question types of the queries (a, aaaa, cname, ns, txt, ptr, mx, soa, dname)
+func WithQuestionTypes(questionTypes ...string) Option {
+	return func(options *dnsxrunner.Options) error {
+		for _, questionType := range questionTypes {
+			switch strings.ToLower(questionType) {
+			case "a":
+				options.A = true
+			case "aaaa":
+				options.AAAA = true
+			case "cname":
+				options.CNAME = true
+			case "ns":
+				options.NS = true
+			case "txt":
+				options.TXT = true
+			case "ptr":
+				options.PTR = true
+			case "mx":
+				options.MX = true
+			case "soa":
+				options.SOA = true
+			case "dname":
+				options.DNAME = true
+			default:
+				return fmt.Errorf("unsupported question type %s", questionType)
+			}
+		}
+		return nil
+	}
+}
+
+// WithRateLimit sets the max number of queries per second, zero disables the limit
+func WithRateLimit(rateLimit int) Option {
+	return func(options *dnsxrunner.Options) error {
+		if rateLimit < 0 {
+			return fmt.Errorf("invalid rate limit %d", rateLimit)
+		}
+		options.RateLimit = rateLimit
+		return nil
+	}
+}
+
+// WithThreads sets the number of concurrent workers
+func WithThreads(threads int) Option {
+	return func(options *dnsxrunner.Options) error {
+		if threads <= 0 {
+			return fmt.Errorf("invalid number of threads %d", threads)
+		}
+		options.Threads = threads
+		return nil
+	}
+}
+
+// WithHosts sets the hosts to resolve, they are expanded like the lines of the list input
+func WithHosts(hosts ...string) Option {
+	return func(options *dnsxrunner.Options) error {
+		options.Targets = append(options.Targets, hosts...)
+		return nil
+	}
+}
+
+// WithLabel adds a key=value label to the results, the key must be an identifier
+func WithLabel(key, value string) Option {
+	return func(options *dnsxrunner.Options) error {
+		options.Labels = append(options.Labels, key+"="+value)
+		return nil
+	}
+}
+
+// WithOnResult sets the callback receiving the results, they are no longer written to the
+// standard output. The callback is called from a single goroutine.
+func WithOnResult(onResult func(*Result)) Option {
+	return func(options *dnsxrunner.Options) error {
+		options.OnResult = func(result *dnsxrunner.Result) {
+			onResult(newResult(result))
+		}
+		return nil
+	}
+}
+
+// Runner is a dnsx scan configured with options
+type Runner struct {
+	runner *dnsxrunner.Runner
+}
+
+// New creates a runner from the command line defaults changed by the options
+func New(opts ...Option) (*Runner, error) {
+	options := dnsxrunner.DefaultOptions()
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+	if err := options.Configure(); err != nil {
+		return nil, err
+	}
+	runner, err := dnsxrunner.New(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{runner: runner}, nil
+}
+
+// Run resolves the hosts
+func (r *Runner) Run() error {
+	return r.runner.Run()
+}
+
+// RunHosts resolves the hosts instead of the ones of the options, the runner can be run
+// several times with different hosts
+func (r *Runner) RunHosts(hosts ...string) error {
+	return r.runner.RunHosts(hosts)
+}
+
+// Reset clears the hosts processed by the previous runs, they are resolved again by the next run
+func (r *Runner) Reset() error {
+	return r.runner.Reset()
+}
+
+// Close releases the resources of the runner
+func (r *Runner) Close() {
+	r.runner.Close()
+}
@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	dnsxrunner "github.com/projectdiscovery/dnsx/internal/runner"
+)
+
+// TestDefaultOptions checks that the defaults of the library are the ones of the command
+// line run without arguments. The command line can be parsed once per process.
+func TestDefaultOptions(t *testing.T) {
+	// goflags writes its config file to the home directory
+	t.Setenv("HOME", t.TempDir())
+	args := os.Args
+	os.Args = []string{"dnsx"}
+	defer func() {
+		os.Args = args
+	}()
+
+	parsed := dnsxrunner.ParseOptions()
+	defaults := dnsxrunner.DefaultOptions()
+	if err := defaults.Configure(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, got := reflect.ValueOf(parsed).Elem(), reflect.ValueOf(defaults).Elem()
+	for i := 0; i < want.NumField(); i++ {
+		field := want.Type().Field(i)
+		if !field.IsExported() || field.Type.Kind() == reflect.Func {
+			continue
+		}
+		if !reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+			t.Errorf("%s: got default %#v, want %#v", field.Name, got.Field(i).Interface(), want.Field(i).Interface())
+		}
+	}
+}
+
+// TestDefaultOptionsConcurrent is meant to be run with -race, the defaults are built without
+// touching the global command line used by the other packages
+func TestDefaultOptionsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if options := dnsxrunner.DefaultOptions(); options.Threads != 100 {
+				t.Errorf("got %d threads, want 100", options.Threads)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			flag.CommandLine.VisitAll(func(*flag.Flag) {})
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option
+	}{
+		{name: "no resolvers", option: WithResolvers()},
+		{name: "question type", option: WithQuestionTypes("a", "any")},
+		{name: "rate limit", option: WithRateLimit(-1)},
+		{name: "threads", option: WithThreads(0)},
+		{name: "label", option: WithLabel("1key", "value")},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil {
+			t.Errorf("%s: invalid option accepted", test.name)
+		}
+	}
+}
+
+// newTestServer starts a udp dns server answering the A questions with 192.0.2.1 and
+// returns its address
+func newTestServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA {
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.IPv4(192, 0, 2, 1),
+			})
+		}
+		w.WriteMsg(resp) // nolint:errcheck
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(handler), NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe() // nolint:errcheck
+	<-started
+	t.Cleanup(func() { server.Shutdown() }) // nolint:errcheck
+	return conn.LocalAddr().String()
+}
+
+func TestRunResults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := newTestServer(t)
+	var results []*Result
+	r, err := New(
+		WithResolvers("udp:"+server),
+		WithHosts("http://www.example.com/path"),
+		WithLabel("program", "acme"),
+		WithOnResult(func(result *Result) {
+			results = append(results, result)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	result := results[0]
+	tests := []struct {
+		field string
+		got   interface{}
+		want  interface{}
+	}{
+		{field: "host", got: result.Host, want: "www.example.com"},
+		{field: "input", got: result.Input, want: "http://www.example.com/path"},
+		{field: "status code", got: result.StatusCode, want: "NOERROR"},
+		{field: "source", got: result.Source, want: "network"},
+		{field: "a", got: result.A, want: []string{"192.0.2.1"}},
+		{field: "labels", got: result.Labels, want: map[string]string{"program": "acme"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.field, test.got, test.want)
+		}
+	}
+	if fmt.Sprint(result.Resolver) == "[]" || result.Timestamp.IsZero() {
+		t.Errorf("got resolver %v and timestamp %s", result.Resolver, result.Timestamp)
+	}
+}
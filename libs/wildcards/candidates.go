@@ -0,0 +1,30 @@
+package wildcards
+
+// Candidates counts the hosts sharing each IP, the hosts having an IP shared by at least
+// Threshold hosts are the candidates to verify with IsWildcard
+type Candidates struct {
+	threshold int
+	counts    map[string]int
+}
+
+// NewCandidates returns a counter using the threshold of the detector
+func (d *Detector) NewCandidates() *Candidates {
+	return &Candidates{threshold: d.Options.Threshold, counts: make(map[string]int)}
+}
+
+// Add counts a host having the given unique IPs
+func (c *Candidates) Add(ips []string) {
+	for _, ip := range ips {
+		c.counts[ip]++
+	}
+}
+
+// IsCandidate reports whether one of the IPs of the host is shared by the threshold number of hosts
+func (c *Candidates) IsCandidate(ips []string) bool {
+	for _, ip := range ips {
+		if c.counts[ip] >= c.threshold {
+			return true
+		}
+	}
+	return false
+}
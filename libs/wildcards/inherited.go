@@ -0,0 +1,107 @@
+package wildcards
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	miekgdns "github.com/miekg/dns"
+	"github.com/rs/xid"
+)
+
+// MsgClient performs the questions of the record types compared for inheritance
+type MsgClient interface {
+	QueryMsg(hostname string, questionType uint16) (*miekgdns.Msg, error)
+}
+
+// errNoMsgClient is returned when the probes client can't send typed questions
+var errNoMsgClient = errors.New("wildcard probes client does not support record types")
+
+// InheritsRecords checks whether the records of the host having one of the compared
+// RecordTypes are the same as the ones returned for random names of its levels, which
+// means they are inherited from a wildcard rather than configured for the host.
+// It returns the types whose records are inherited.
+func (d *Detector) InheritsRecords(host string, answers []miekgdns.RR) ([]uint16, error) {
+	if len(d.Options.RecordTypes) == 0 {
+		return nil, nil
+	}
+	client, ok := d.probes.(MsgClient)
+	if !ok {
+		return nil, errNoMsgClient
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	var inherited []uint16
+	for _, questionType := range d.Options.RecordTypes {
+		values := recordValues(answers, questionType)
+		if len(values) == 0 {
+			continue
+		}
+		for _, level := range d.levels(host) {
+			if values == d.levelRecords(client, level, questionType) {
+				inherited = append(inherited, questionType)
+				break
+			}
+		}
+	}
+	return inherited, nil
+}
+
+// levelRecords returns the cached records of the type returned for random names of the level
+func (d *Detector) levelRecords(client MsgClient, level string, questionType uint16) string {
+	key := miekgdns.TypeToString[questionType] + ":" + level
+	d.RLock()
+	values, ok := d.records[key]
+	d.RUnlock()
+	if ok {
+		return values
+	}
+
+	var answers []miekgdns.RR
+	for i := 0; i < d.Options.Probes; i++ {
+		msg, err := client.QueryMsg(xid.New().String()+"."+level, questionType)
+		if err != nil || msg == nil {
+			continue
+		}
+		answers = append(answers, msg.Answer...)
+	}
+	values = recordValues(answers, questionType)
+
+	d.Lock()
+	d.records[key] = values
+	d.Unlock()
+	return values
+}
+
+// recordValues returns the sorted unique values of the records of the type with type
+// aware normalization: TXT records as their joined strings, MX records as preference
+// and exchange pairs. An empty string is returned when there is no such record.
+func recordValues(answers []miekgdns.RR, questionType uint16) string {
+	seen := make(map[string]struct{})
+	var values []string
+	for _, answer := range answers {
+		var value string
+		switch rr := answer.(type) {
+		case *miekgdns.TXT:
+			if questionType != miekgdns.TypeTXT {
+				continue
+			}
+			value = strings.Join(rr.Txt, "")
+		case *miekgdns.MX:
+			if questionType != miekgdns.TypeMX {
+				continue
+			}
+			value = fmt.Sprintf("%d %s", rr.Preference, strings.ToLower(strings.TrimSuffix(rr.Mx, ".")))
+		default:
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return strings.Join(values, "\n")
+}
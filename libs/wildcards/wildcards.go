@@ -0,0 +1,216 @@
+// Package wildcards detects hosts answered by wildcard dns records
+package wildcards
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	miekgdns "github.com/miekg/dns"
+	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+	"github.com/rs/xid"
+)
+
+// Client performs the A question used to resolve hosts and wildcard probes
+type Client interface {
+	QueryOne(hostname string) (*retryabledns.DNSData, error)
+}
+
+// Options contains the configuration options of the detector
+type Options struct {
+	// Threshold is the number of hosts sharing an IP before they are verified
+	Threshold int
+	// Probes is the number of random names resolved for each level, rotating
+	// wildcard pools return a different subset of their IPs for each probe and
+	// need more than one
+	Probes int
+	// TrustedResolvers are used for the probes instead of the client when set
+	TrustedResolvers []string
+	// MaxRetries of the probes sent to the trusted resolvers
+	MaxRetries int
+	// RecordTypes are the types whose records are compared by InheritsRecords (TXT, MX)
+	RecordTypes []uint16
+}
+
+// DefaultOptions contains the default configuration options
+var DefaultOptions = Options{
+	Threshold:  5,
+	Probes:     1,
+	MaxRetries: 5,
+}
+
+// Detector verifies hosts against the answers of random names of each level of a root domain
+type Detector struct {
+	// probeCount is the number of random names resolved, first for its 64-bit alignment
+	probeCount uint64
+
+	client  Client
+	probes  Client
+	domain  string
+	Options Options
+
+	sync.RWMutex
+	cache   map[string]map[string]struct{}
+	records map[string]string
+}
+
+// New creates a wildcard detector for the subdomains of the given root domain
+func New(client Client, domain string, options Options) (*Detector, error) {
+	if client == nil {
+		return nil, errors.New("no dns client provided")
+	}
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return nil, errors.New("no root domain provided")
+	}
+	if options.Threshold <= 0 {
+		options.Threshold = DefaultOptions.Threshold
+	}
+	if options.Probes <= 0 {
+		options.Probes = DefaultOptions.Probes
+	}
+
+	probes := client
+	if len(options.TrustedResolvers) > 0 {
+		dnsxOptions := dnsx.DefaultOptions
+		dnsxOptions.BaseResolvers = options.TrustedResolvers
+		dnsxOptions.MaxRetries = options.MaxRetries
+		// the inherited record types are asked explicitly by QueryMsg
+		dnsxOptions.QuestionTypes = []uint16{miekgdns.TypeA}
+		trusted, err := dnsx.New(dnsxOptions)
+		if err != nil {
+			return nil, err
+		}
+		probes = trusted
+	}
+
+	return &Detector{
+		client:  client,
+		probes:  probes,
+		domain:  domain,
+		Options: options,
+		cache:   make(map[string]map[string]struct{}),
+		records: make(map[string]string),
+	}, nil
+}
+
+// IsWildcard checks if the host answers are among the wildcard answers of its levels
+// and returns the matching ones. The host is resolved when no IPs are given, in which
+// case its CNAME records are compared as well.
+func (d *Detector) IsWildcard(host string, ips []string) (bool, []string, error) {
+	host = strings.TrimSuffix(host, ".")
+	answers := ips
+	if len(answers) == 0 {
+		dnsdata, err := d.client.QueryOne(host)
+		if err != nil {
+			return false, nil, err
+		}
+		if dnsdata == nil {
+			return false, nil, nil
+		}
+		answers = append(append(answers, dnsdata.A...), normalizeNames(dnsdata.CNAME)...)
+	}
+
+	var matches []string
+	seen := make(map[string]struct{})
+	for _, level := range d.levels(host) {
+		wildcardAnswers := d.levelAnswers(level)
+		for _, answer := range answers {
+			if _, ok := wildcardAnswers[answer]; !ok {
+				continue
+			}
+			if _, ok := seen[answer]; !ok {
+				seen[answer] = struct{}{}
+				matches = append(matches, answer)
+			}
+		}
+	}
+	return len(matches) > 0, matches, nil
+}
+
+// levels returns the root domain and every parent of the host below it,
+// a random label is prepended to each of them to probe for wildcards
+func (d *Detector) levels(host string) []string {
+	levels := []string{d.domain}
+	subdomainPart := strings.TrimSuffix(host, "."+d.domain)
+	if subdomainPart == host {
+		return levels
+	}
+	subdomainTokens := strings.Split(subdomainPart, ".")
+	for i := 1; i < len(subdomainTokens); i++ {
+		levels = append(levels, strings.Join(subdomainTokens[i:], ".")+"."+d.domain)
+	}
+	return levels
+}
+
+// levelAnswers returns the cached wildcard answers of the level, probing it on first use
+func (d *Detector) levelAnswers(level string) map[string]struct{} {
+	d.RLock()
+	answers, ok := d.cache[level]
+	d.RUnlock()
+	if ok {
+		return answers
+	}
+
+	answers = make(map[string]struct{})
+	for i := 0; i < d.Options.Probes; i++ {
+		atomic.AddUint64(&d.probeCount, 1)
+		dnsdata, err := d.probes.QueryOne(xid.New().String() + "." + level)
+		if err != nil || dnsdata == nil {
+			continue
+		}
+		for _, a := range dnsdata.A {
+			answers[a] = struct{}{}
+		}
+		for _, cname := range normalizeNames(dnsdata.CNAME) {
+			answers[cname] = struct{}{}
+		}
+	}
+
+	d.Lock()
+	d.cache[level] = answers
+	d.Unlock()
+	return answers
+}
+
+// ProbeCount returns the number of random names resolved to probe the levels
+func (d *Detector) ProbeCount() uint64 {
+	return atomic.LoadUint64(&d.probeCount)
+}
+
+// IsWildcardAnswer reports whether the answer was returned by the probes of any level probed so far
+func (d *Detector) IsWildcardAnswer(answer string) bool {
+	d.RLock()
+	defer d.RUnlock()
+	for _, answers := range d.cache {
+		if _, ok := answers[answer]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheSize returns the number of levels probed so far
+func (d *Detector) CacheSize() int {
+	d.RLock()
+	defer d.RUnlock()
+	return len(d.cache)
+}
+
+// ClearCache removes the probed answers, levels are probed again on next use
+func (d *Detector) ClearCache() {
+	d.Lock()
+	defer d.Unlock()
+	d.cache = make(map[string]map[string]struct{})
+	d.records = make(map[string]string)
+}
+
+func normalizeNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, strings.ToLower(strings.TrimSuffix(name, ".")))
+	}
+	return normalized
+}
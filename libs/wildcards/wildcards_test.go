@@ -0,0 +1,298 @@
+package wildcards
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	miekgdns "github.com/miekg/dns"
+	retryabledns "github.com/projectdiscovery/retryabledns"
+)
+
+// mockClient answers from a script keyed by name, the random probe names are answered by
+// the entry of their parent prefixed with "*."
+type mockClient struct {
+	sync.Mutex
+	answers map[string][][]string
+	records map[string][]string
+	calls   map[string]int
+	// questions counts the typed questions by type name
+	questions map[string]int
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{
+		answers:   make(map[string][][]string),
+		records:   make(map[string][]string),
+		calls:     make(map[string]int),
+		questions: make(map[string]int),
+	}
+}
+
+func (m *mockClient) lookup(hostname string) string {
+	if _, ok := m.answers[hostname]; ok {
+		return hostname
+	}
+	if idx := strings.IndexByte(hostname, '.'); idx >= 0 {
+		return "*" + hostname[idx:]
+	}
+	return hostname
+}
+
+// QueryOne returns the scripted answers of the name in turn, A records or "cname:" prefixed names
+func (m *mockClient) QueryOne(hostname string) (*retryabledns.DNSData, error) {
+	m.Lock()
+	defer m.Unlock()
+	key := m.lookup(hostname)
+	dnsdata := &retryabledns.DNSData{Host: hostname}
+	scripted := m.answers[key]
+	if len(scripted) == 0 {
+		return dnsdata, nil
+	}
+	for _, answer := range scripted[m.calls[key]%len(scripted)] {
+		if strings.HasPrefix(answer, "cname:") {
+			dnsdata.CNAME = append(dnsdata.CNAME, strings.TrimPrefix(answer, "cname:"))
+		} else {
+			dnsdata.A = append(dnsdata.A, answer)
+		}
+	}
+	m.calls[key]++
+	return dnsdata, nil
+}
+
+// QueryMsg returns the scripted records of the name and type
+func (m *mockClient) QueryMsg(hostname string, questionType uint16) (*miekgdns.Msg, error) {
+	m.Lock()
+	defer m.Unlock()
+	msg := new(miekgdns.Msg)
+	typeName := miekgdns.TypeToString[questionType]
+	m.questions[typeName]++
+	for _, record := range m.records[typeName+":"+m.lookup(hostname)] {
+		rr, err := miekgdns.NewRR(miekgdns.Fqdn(hostname) + " 300 IN " + typeName + " " + record)
+		if err != nil {
+			return nil, err
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+	return msg, nil
+}
+
+func TestIsWildcard(t *testing.T) {
+	tests := []struct {
+		name    string
+		probes  int
+		script  map[string][][]string
+		host    string
+		ips     []string
+		want    bool
+		matches string
+	}{
+		{
+			name:   "static wildcard",
+			script: map[string][][]string{"*.example.com": {{"192.0.2.1"}}},
+			host:   "www.example.com", ips: []string{"192.0.2.1"},
+			want: true, matches: "[192.0.2.1]",
+		},
+		{
+			name:   "no wildcard",
+			script: map[string][][]string{},
+			host:   "www.example.com", ips: []string{"192.0.2.1"},
+		},
+		{
+			name:   "different address",
+			script: map[string][][]string{"*.example.com": {{"192.0.2.1"}}},
+			host:   "www.example.com", ips: []string{"192.0.2.9"},
+		},
+		{
+			name:   "rotating pool with one probe",
+			probes: 1,
+			script: map[string][][]string{"*.example.com": {{"192.0.2.1"}, {"192.0.2.2"}, {"192.0.2.3"}}},
+			host:   "www.example.com", ips: []string{"192.0.2.3"},
+		},
+		{
+			name:   "rotating pool",
+			probes: 3,
+			script: map[string][][]string{"*.example.com": {{"192.0.2.1"}, {"192.0.2.2"}, {"192.0.2.3"}}},
+			host:   "www.example.com", ips: []string{"192.0.2.3"},
+			want: true, matches: "[192.0.2.3]",
+		},
+		{
+			name: "lower level wildcard",
+			script: map[string][][]string{
+				"*.dev.example.com":     {{"192.0.2.5"}},
+				"api.dev.example.com":   {{"192.0.2.5"}},
+				"*.example.com":         {},
+				"*.api.dev.example.com": {},
+			},
+			host: "api.dev.example.com",
+			want: true, matches: "[192.0.2.5]",
+		},
+		{
+			name: "cname wildcard",
+			script: map[string][][]string{
+				"*.example.com":   {{"cname:wildcard.cdn.example.net", "198.51.100.1"}},
+				"www.example.com": {{"cname:Wildcard.cdn.example.net.", "198.51.100.7"}},
+			},
+			host: "www.example.com",
+			want: true, matches: "[wildcard.cdn.example.net]",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newMockClient()
+			client.answers = test.script
+			detector, err := New(client, "example.com", Options{Probes: test.probes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, matches, err := detector.IsWildcard(test.host, test.ips)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want || (test.want && fmt.Sprint(matches) != test.matches) {
+				t.Fatalf("got %v %v, want %v %s", got, matches, test.want, test.matches)
+			}
+		})
+	}
+}
+
+func TestProbeCount(t *testing.T) {
+	tests := []struct {
+		probes int
+		want   uint64
+	}{
+		{probes: 0, want: 2},
+		{probes: 1, want: 2},
+		{probes: 3, want: 6},
+	}
+	for _, test := range tests {
+		detector, err := New(newMockClient(), "example.com", Options{Probes: test.probes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the root and the dev.example.com levels are probed once and then cached
+		for i := 0; i < 3; i++ {
+			// nolint:errcheck
+			detector.IsWildcard("a.dev.example.com", []string{"192.0.2.1"})
+		}
+		if got := detector.ProbeCount(); got != test.want {
+			t.Errorf("probes %d: got %d probe queries, want %d", test.probes, got, test.want)
+		}
+		if detector.CacheSize() != 2 {
+			t.Errorf("probes %d: got %d cached levels, want 2", test.probes, detector.CacheSize())
+		}
+		detector.ClearCache()
+		if detector.CacheSize() != 0 {
+			t.Errorf("probes %d: cache not cleared", test.probes)
+		}
+	}
+}
+
+func TestIsWildcardAnswer(t *testing.T) {
+	client := newMockClient()
+	client.answers["*.example.com"] = [][]string{{"192.0.2.1"}}
+	detector, err := New(client, "example.com", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detector.IsWildcardAnswer("192.0.2.1") {
+		t.Fatal("answer reported before the level was probed")
+	}
+	// nolint:errcheck
+	detector.IsWildcard("www.example.com", []string{"192.0.2.9"})
+	if !detector.IsWildcardAnswer("192.0.2.1") || detector.IsWildcardAnswer("192.0.2.9") {
+		t.Fatal("unexpected wildcard answers")
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		threshold int
+		hosts     [][]string
+		check     []string
+		want      bool
+	}{
+		{threshold: 3, hosts: [][]string{{"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1", "192.0.2.2"}}, check: []string{"192.0.2.2", "192.0.2.1"}, want: true},
+		{threshold: 3, hosts: [][]string{{"192.0.2.1"}, {"192.0.2.1"}}, check: []string{"192.0.2.1"}},
+		{threshold: 0, hosts: [][]string{{"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1"}}, check: []string{"192.0.2.1"}},
+		{threshold: 0, hosts: [][]string{{"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1"}, {"192.0.2.1"}}, check: []string{"192.0.2.1"}, want: true},
+		{threshold: 1, check: nil},
+	}
+	for i, test := range tests {
+		detector, err := New(newMockClient(), "example.com", Options{Threshold: test.threshold})
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates := detector.NewCandidates()
+		for _, ips := range test.hosts {
+			candidates.Add(ips)
+		}
+		if got := candidates.IsCandidate(test.check); got != test.want {
+			t.Errorf("test %d: got %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestInheritsRecords(t *testing.T) {
+	client := newMockClient()
+	client.records["TXT:*.example.com"] = []string{`"v=spf1 -all"`}
+	client.records["MX:*.example.com"] = []string{"10 mail.example.com."}
+	detector, err := New(client, "example.com", Options{RecordTypes: []uint16{miekgdns.TypeTXT, miekgdns.TypeMX}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+	}{
+		{name: "inherited", records: []string{`TXT "v=spf1" " -all"`, "MX 10 MAIL.example.com."}, want: "[16 15]"},
+		{name: "own txt", records: []string{`TXT "v=spf1 include:_spf.example.net -all"`, "MX 10 mail.example.com."}, want: "[15]"},
+		{name: "other preference", records: []string{"MX 20 mail.example.com."}, want: "[]"},
+		{name: "no records", want: "[]"},
+	}
+	for _, test := range tests {
+		var answers []miekgdns.RR
+		for _, record := range test.records {
+			rr, err := miekgdns.NewRR("www.example.com. 300 IN " + record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			answers = append(answers, rr)
+		}
+		types, err := detector.InheritsRecords("www.example.com", answers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(types); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInheritsRecordsProbedTypes(t *testing.T) {
+	client := newMockClient()
+	client.records["TXT:*.example.com"] = []string{`"v=spf1 -all"`}
+	detector, err := New(client, "example.com", Options{Probes: 2, RecordTypes: []uint16{miekgdns.TypeTXT}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var answers []miekgdns.RR
+	for _, record := range []string{`TXT "v=spf1 -all"`, "MX 10 mail.example.com.", "A 192.0.2.1"} {
+		rr, err := miekgdns.NewRR("www.dev.example.com. 300 IN " + record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		answers = append(answers, rr)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := detector.InheritsRecords("www.dev.example.com", answers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the filtered type is probed, once for the root level whose records match
+	if fmt.Sprint(client.questions) != "map[TXT:2]" {
+		t.Fatalf("got questions %v, want the 2 probes of the root level", client.questions)
+	}
+}